@@ -52,6 +52,13 @@ func Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 利用停止中のユーザーはログインできない
+	if user.SuspendedAt != nil {
+		common.LogUser(common.WARN, "Suspended user tried to log in: "+creds.Mail)
+		http.Error(w, "Account suspended", http.StatusForbidden)
+		return
+	}
+
 	// JWTトークンの作成
 	expirationTime := time.Now().Add(7 * 24 * time.Hour)
 	claims := &common.Claims{
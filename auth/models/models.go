@@ -8,13 +8,15 @@ import (
 )
 
 type User struct {
-	ID         uint           `gorm:"primaryKey"`
-	Name       string         `gorm:"size:255;not null"`
-	Mail       string         `gorm:"size:255;unique;not null" validate:"required,email"`
-	Pass       string         `gorm:"size:255;not null" validate:"required,min=8"`
-	CreatedAt  time.Time      `gorm:"autoCreateTime"`
-	ModifiedAt time.Time      `gorm:"autoUpdateTime"`
-	DeletedAt  gorm.DeletedAt `gorm:"index"`
+	ID          uint           `gorm:"primaryKey"`
+	Name        string         `gorm:"size:255;not null"`
+	Mail        string         `gorm:"size:255;unique;not null" validate:"required,email"`
+	Pass        string         `gorm:"size:255;not null" validate:"required,min=8"`
+	Role        string         `gorm:"type:enum('user','moderator','admin');default:'user'"`
+	SuspendedAt *time.Time     `gorm:"default:NULL"`
+	CreatedAt   time.Time      `gorm:"autoCreateTime"`
+	ModifiedAt  time.Time      `gorm:"autoUpdateTime"`
+	DeletedAt   gorm.DeletedAt `gorm:"index"`
 }
 
 func (u *User) Validate() error {
@@ -0,0 +1,153 @@
+package common
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// 概要欄からチャプターとして読み取る最小の数
+	MinDescriptionChapters = 3
+	// 1つの動画に設定できるチャプターの最大数
+	MaxChaptersPerVideo = 100
+	// チャプターのタイトルの最大文字数
+	MaxChapterTitleLength = 255
+)
+
+// ChapterMarker はチャプターの開始位置（秒）とタイトルです
+type ChapterMarker struct {
+	StartSeconds uint
+	Title        string
+}
+
+// 行頭の「0:00 タイトル」「1:02:03 - タイトル」形式のタイムスタンプ
+var chapterLinePattern = regexp.MustCompile(`^\s*[\[(]?((?:\d{1,2}:)?\d{1,2}:\d{2})[\])]?\s*(?:[-–—:|]\s*)?(.+?)\s*$`)
+
+// ParseDescriptionChapters は概要欄の「0:00 タイトル」形式の行からチャプターを読み取る関数です
+// 最初のチャプターが 0:00 から始まり、開始位置が昇順で、MinDescriptionChapters 個以上ある場合のみ返します
+func ParseDescriptionChapters(description string) []ChapterMarker {
+	var chapters []ChapterMarker
+	for _, line := range strings.Split(description, "\n") {
+		match := chapterLinePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		seconds, ok := ParseTimestamp(match[1])
+		if !ok {
+			continue
+		}
+		title := match[2]
+		if utf8.RuneCountInString(title) > MaxChapterTitleLength {
+			title = string([]rune(title)[:MaxChapterTitleLength])
+		}
+		chapters = append(chapters, ChapterMarker{StartSeconds: seconds, Title: title})
+	}
+
+	if len(chapters) < MinDescriptionChapters || len(chapters) > MaxChaptersPerVideo || chapters[0].StartSeconds != 0 {
+		return nil
+	}
+	for i := 1; i < len(chapters); i++ {
+		if chapters[i].StartSeconds <= chapters[i-1].StartSeconds {
+			return nil
+		}
+	}
+	return chapters
+}
+
+// ValidateChapters はAPIで指定されたチャプターを検証する関数です
+// 開始位置は重複できず、昇順に並べ替えた結果を返します
+func ValidateChapters(chapters []ChapterMarker) ([]ChapterMarker, error) {
+	if len(chapters) > MaxChaptersPerVideo {
+		return nil, fmt.Errorf("チャプターは%d個まで指定できます", MaxChaptersPerVideo)
+	}
+
+	sorted := make([]ChapterMarker, 0, len(chapters))
+	seen := map[uint]bool{}
+	for _, c := range chapters {
+		c.Title = strings.TrimSpace(c.Title)
+		if c.Title == "" {
+			return nil, fmt.Errorf("チャプターのタイトルを指定してください")
+		}
+		if utf8.RuneCountInString(c.Title) > MaxChapterTitleLength {
+			return nil, fmt.Errorf("チャプターのタイトルは%d文字以内で指定してください", MaxChapterTitleLength)
+		}
+		if seen[c.StartSeconds] {
+			return nil, fmt.Errorf("チャプターの開始位置が重複しています: %s", FormatTimestamp(c.StartSeconds))
+		}
+		seen[c.StartSeconds] = true
+		sorted = append(sorted, c)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].StartSeconds < sorted[j].StartSeconds
+	})
+	return sorted, nil
+}
+
+// ParseTimestamp は「m:ss」「h:mm:ss」形式の時刻を秒に変換する関数です
+func ParseTimestamp(timestamp string) (uint, bool) {
+	parts := strings.Split(timestamp, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+
+	var seconds uint
+	for i, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 32)
+		if err != nil {
+			return 0, false
+		}
+		// 先頭以外の分・秒は 60 未満
+		if i > 0 && v >= 60 {
+			return 0, false
+		}
+		seconds = seconds*60 + uint(v)
+	}
+	return seconds, true
+}
+
+// FormatTimestamp は秒を「m:ss」「h:mm:ss」形式に変換する関数です
+func FormatTimestamp(seconds uint) string {
+	h, m, s := seconds/3600, seconds/60%60, seconds%60
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
+
+// 再生時間が不明な場合に最後のチャプターの終了位置とする時刻（秒）
+const chapterTrackOpenEnd = 100*3600 - 1
+
+// BuildChaptersWebVTT はチャプターを WebVTT のチャプタートラックに変換する関数です
+// 各チャプターは次のチャプターの開始位置まで続き、最後のチャプターは動画の終わりまで続きます
+func BuildChaptersWebVTT(chapters []ChapterMarker, durationSeconds uint) string {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+	for i, c := range chapters {
+		end := durationSeconds
+		if i+1 < len(chapters) {
+			end = chapters[i+1].StartSeconds
+		}
+		if end <= c.StartSeconds {
+			end = chapterTrackOpenEnd
+		}
+		fmt.Fprintf(&b, "\n%d\n%s --> %s\n%s\n", i+1, FormatWebVTTTimestamp(uint64(c.StartSeconds)*1000), FormatWebVTTTimestamp(uint64(end)*1000), EscapeWebVTTText(c.Title))
+	}
+	return b.String()
+}
+
+// FormatWebVTTTimestamp はミリ秒を WebVTT の「hh:mm:ss.ttt」形式に変換する関数です
+func FormatWebVTTTimestamp(millis uint64) string {
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", millis/3600000, millis/60000%60, millis/1000%60, millis%1000)
+}
+
+// EscapeWebVTTText は WebVTT のキューテキストで特別な意味を持つ文字をエスケープする関数です
+func EscapeWebVTTText(text string) string {
+	return webVTTTextEscaper.Replace(text)
+}
+
+var webVTTTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
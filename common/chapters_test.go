@@ -0,0 +1,69 @@
+package common
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseDescriptionChapters(t *testing.T) {
+	tests := []struct {
+		name        string
+		description string
+		want        []ChapterMarker
+	}{
+		{
+			name:        "basic",
+			description: "動画の説明\n\n0:00 オープニング\n1:30 本編\n12:05 まとめ\n",
+			want:        []ChapterMarker{{0, "オープニング"}, {90, "本編"}, {725, "まとめ"}},
+		},
+		{
+			name:        "separators, brackets and hours",
+			description: "[00:00] イントロ\n(5:00) - 前半\n1:02:03 | 後半\n  1:10:00: 終わり",
+			want:        []ChapterMarker{{0, "イントロ"}, {300, "前半"}, {3723, "後半"}, {4200, "終わり"}},
+		},
+		{
+			name:        "other lines are ignored",
+			description: "0:00 A\n参考: https://example.com/1:00\n0:30 B\n時刻 1:00 は行頭ではない\n1:00 C",
+			want:        []ChapterMarker{{0, "A"}, {30, "B"}, {60, "C"}},
+		},
+		{
+			name:        "crlf",
+			description: "0:00 A\r\n0:10 B\r\n0:20 C\r\n",
+			want:        []ChapterMarker{{0, "A"}, {10, "B"}, {20, "C"}},
+		},
+		{name: "too few", description: "0:00 A\n1:00 B"},
+		{name: "not starting at zero", description: "0:05 A\n1:00 B\n2:00 C"},
+		{name: "not ascending", description: "0:00 A\n2:00 B\n1:00 C"},
+		{name: "duplicate start", description: "0:00 A\n1:00 B\n1:00 C"},
+		{name: "seconds out of range", description: "0:00 A\n0:75 B\n1:30 C"},
+		{name: "empty", description: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseDescriptionChapters(tt.description)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseDescriptionChapters() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDescriptionChaptersLimits(t *testing.T) {
+	// タイトルは最大文字数で切り詰める
+	long := strings.Repeat("あ", MaxChapterTitleLength+10)
+	got := ParseDescriptionChapters("0:00 " + long + "\n0:10 B\n0:20 C")
+	if len(got) != 3 || got[0].Title != strings.Repeat("あ", MaxChapterTitleLength) {
+		t.Errorf("long title was not truncated: %v", got)
+	}
+
+	// 最大数を超える場合はチャプターとして扱わない
+	var b strings.Builder
+	for i := 0; i <= MaxChaptersPerVideo; i++ {
+		b.WriteString(FormatTimestamp(uint(i*10)) + " チャプター\n")
+	}
+	if got := ParseDescriptionChapters(b.String()); got != nil {
+		t.Errorf("ParseDescriptionChapters() with %d chapters = %d chapters, want nil", MaxChaptersPerVideo+1, len(got))
+	}
+}
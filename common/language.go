@@ -0,0 +1,40 @@
+package common
+
+import (
+	"net/http"
+
+	"golang.org/x/text/language"
+)
+
+// PreferredLanguages はクエリパラメータ lang と Accept-Language ヘッダーから、希望する言語を優先度の高い順に返す関数です
+// lang が指定されている場合はそちらを優先します
+func PreferredLanguages(r *http.Request) []language.Tag {
+	var preferred []language.Tag
+	if lang := r.URL.Query().Get("lang"); lang != "" {
+		if tag, err := language.Parse(lang); err == nil {
+			preferred = append(preferred, tag)
+		}
+	}
+	if tags, _, err := language.ParseAcceptLanguage(r.Header.Get("Accept-Language")); err == nil {
+		preferred = append(preferred, tags...)
+	}
+	return preferred
+}
+
+// MatchLanguage は希望する言語に最も近い言語を available から選び、そのインデックスを返す関数です
+// 近い言語がない場合は false を返します
+func MatchLanguage(preferred []language.Tag, available []string) (int, bool) {
+	if len(preferred) == 0 || len(available) == 0 {
+		return 0, false
+	}
+
+	tags := make([]language.Tag, 0, len(available))
+	for _, a := range available {
+		tags = append(tags, language.Make(a))
+	}
+	_, index, confidence := language.NewMatcher(tags).Match(preferred...)
+	if confidence == language.No {
+		return 0, false
+	}
+	return index, true
+}
@@ -14,6 +14,7 @@ var (
 	userLogger        *logrus.Logger
 	videouploadLogger *logrus.Logger
 	videohubLogger    *logrus.Logger
+	notifyLogger      *logrus.Logger
 )
 
 func init() {
@@ -87,6 +88,18 @@ func init() {
 	}
 	// 標準出力とファイルの両方に書き込み
 	videohubLogger.SetOutput(io.MultiWriter(stdOut, videohubLogFile))
+
+	// notification.log ロガーの初期化
+	notifyLogger = logrus.New()
+	notifyLogger.SetFormatter(&logrus.JSONFormatter{
+		DisableHTMLEscape: true,
+	})
+	notifyLogFile, err := os.OpenFile("logs/notification.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		logrus.Fatalf("Failed to open notification log file: %v", err)
+	}
+	// 標準出力とファイルの両方に書き込み
+	notifyLogger.SetOutput(io.MultiWriter(stdOut, notifyLogFile))
 }
 
 func LogError(err error) {
@@ -129,6 +142,14 @@ func LogVideoUploadError(err error) {
 	}).Error()
 }
 
+func LogVideoUploadInfo(message string) {
+	videouploadLogger.WithFields(logrus.Fields{
+		"timestamp": time.Now().Format(time.RFC3339),
+		"level":     "INFO",
+		"message":   message,
+	}).Info()
+}
+
 func LogVideoHubError(err error) {
 	videohubLogger.WithFields(logrus.Fields{
 		"timestamp": time.Now().Format(time.RFC3339),
@@ -144,3 +165,11 @@ func LogVideoHubInfo(message string) {
 		"message":   message,
 	}).Info()
 }
+
+func LogNotificationError(err error) {
+	notifyLogger.WithFields(logrus.Fields{
+		"timestamp": time.Now().Format(time.RFC3339),
+		"level":     "ERROR",
+		"message":   err.Error(),
+	}).Error()
+}
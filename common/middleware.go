@@ -1,3 +1,161 @@
 // common/middleware.go
 
 package common
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// OptionalAuthMiddleware はトークンがあればクレーム情報をコンテキストに設定するミドルウェアです
+// トークンがない、または無効な場合も未ログインとして次のハンドラを実行します
+func OptionalAuthMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		if authHeader == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
+
+		claims := &Claims{}
+		token, err := jwt.ParseWithClaims(tokenStr, claims, func(token *jwt.Token) (interface{}, error) {
+			return JwtKey, nil
+		})
+		if err != nil || !token.Valid {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), "claims", claims)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ログイン中であればユーザーIDを返す関数（未ログインの場合は ok が false）
+func GetOptionalUserIDFromContext(ctx context.Context) (uint, bool) {
+	claims, ok := ctx.Value("claims").(*Claims)
+	if !ok || claims == nil {
+		return 0, false
+	}
+	return claims.UserID, true
+}
+
+// QueryTokenMiddleware は Authorization ヘッダーがない場合にクエリパラメータ token をトークンとして扱うミドルウェアです
+// ヘッダーを設定できない EventSource などからの接続に使います
+func QueryTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			if token := r.URL.Query().Get("token"); token != "" {
+				r = r.Clone(r.Context())
+				r.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// ユーザーの権限と利用停止状態
+type UserStatus struct {
+	Role        string
+	SuspendedAt *time.Time
+}
+
+// ユーザーの権限と利用停止状態を取得する関数
+func GetUserStatus(userID uint) (*UserStatus, error) {
+	var status UserStatus
+	err := DB.Table("users").
+		Select("role, suspended_at").
+		Where("id = ? AND deleted_at IS NULL", userID).
+		Take(&status).Error
+	if err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ActiveUserMiddleware は利用停止中のユーザーを拒否するミドルウェアです
+// AuthMiddleware の後に使用します
+func ActiveUserMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := GetUserIDFromContext(r.Context())
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		status, err := GetUserStatus(userID)
+		if err != nil {
+			LogError(err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if status.SuspendedAt != nil {
+			http.Error(w, "アカウントは利用停止中です", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// ModeratorMiddleware はモデレーターと管理者のみを許可するミドルウェアです
+// AuthMiddleware の後に使用します
+func ModeratorMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := GetUserIDFromContext(r.Context())
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		status, err := GetUserStatus(userID)
+		if err != nil {
+			LogError(err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if status.SuspendedAt != nil || (status.Role != RoleModerator && status.Role != RoleAdmin) {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// AdminMiddleware は管理者のみを許可するミドルウェアです
+// AuthMiddleware の後に使用します
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := GetUserIDFromContext(r.Context())
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		status, err := GetUserStatus(userID)
+		if err != nil {
+			LogError(err)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if status.SuspendedAt != nil || status.Role != RoleAdmin {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,183 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/language"
+)
+
+const (
+	// 字幕ファイルの最大サイズ
+	MaxSubtitleFileSize = 2 << 20
+	// 字幕トラックの表示名の最大文字数
+	MaxSubtitleLabelLength = 100
+)
+
+// 字幕のキューの開始・終了時刻（「00:00:01,000 --> 00:00:04,000」、WebVTT は「.」区切りで時を省略できる）
+var subtitleTimingPattern = regexp.MustCompile(`^((?:\d+:)?\d{2}:\d{2}[.,]\d{3})\s+-->\s+((?:\d+:)?\d{2}:\d{2}[.,]\d{3})(?:\s+(.*))?$`)
+
+// SRT の <font> タグ（WebVTT では使用できない）
+var srtFontTagPattern = regexp.MustCompile(`(?i)</?font[^>]*>`)
+
+// NormalizeLanguageTag は言語コードを検証し、BCP 47 の正規の表記（例: ja, en-US）に変換する関数です
+func NormalizeLanguageTag(tag string) (string, error) {
+	parsed, err := language.Parse(strings.TrimSpace(tag))
+	if err != nil || parsed == language.Und {
+		return "", fmt.Errorf("無効な言語コードです: %s", tag)
+	}
+	return parsed.String(), nil
+}
+
+// ConvertSubtitleToWebVTT は SRT または WebVTT の字幕を検証し、WebVTT に変換する関数です
+// 先頭が WEBVTT の場合は WebVTT、それ以外は SRT として扱います
+func ConvertSubtitleToWebVTT(data []byte) ([]byte, error) {
+	text, err := normalizeSubtitleText(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if isWebVTT(text) {
+		if err := validateWebVTT(text); err != nil {
+			return nil, err
+		}
+		return []byte(text), nil
+	}
+	return convertSRT(text)
+}
+
+// BOM と改行コードを揃える
+func normalizeSubtitleText(data []byte) (string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+	if !utf8.Valid(data) {
+		return "", fmt.Errorf("字幕ファイルは UTF-8 で保存してください")
+	}
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	return text, nil
+}
+
+func isWebVTT(text string) bool {
+	header, _, _ := strings.Cut(text, "\n")
+	return header == "WEBVTT" || strings.HasPrefix(header, "WEBVTT ") || strings.HasPrefix(header, "WEBVTT\t")
+}
+
+// WebVTT の各キューの時刻を検証する
+func validateWebVTT(text string) error {
+	cues := 0
+	lines := strings.Split(text, "\n")
+	for i := 1; i < len(lines); i++ {
+		if !strings.Contains(lines[i], "-->") {
+			continue
+		}
+		if _, _, err := parseSubtitleTiming(lines[i], '.'); err != nil {
+			return fmt.Errorf("%d行目: %w", i+1, err)
+		}
+		cues++
+	}
+	if cues == 0 {
+		return fmt.Errorf("字幕のキューがありません")
+	}
+	return nil
+}
+
+// SRT を WebVTT に変換する
+func convertSRT(text string) ([]byte, error) {
+	var b strings.Builder
+	b.WriteString("WEBVTT\n")
+
+	cues := 0
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); {
+		if strings.TrimSpace(lines[i]) == "" {
+			i++
+			continue
+		}
+
+		// 連番の行（省略されている場合もある）
+		blockStart := i
+		if !strings.Contains(lines[i], "-->") {
+			if _, err := strconv.Atoi(strings.TrimSpace(lines[i])); err != nil {
+				return nil, fmt.Errorf("%d行目: 字幕の番号または時刻が必要です", i+1)
+			}
+			i++
+		}
+		if i >= len(lines) || !strings.Contains(lines[i], "-->") {
+			return nil, fmt.Errorf("%d行目: 字幕の時刻がありません", blockStart+1)
+		}
+
+		start, end, err := parseSubtitleTiming(lines[i], ',')
+		if err != nil {
+			return nil, fmt.Errorf("%d行目: %w", i+1, err)
+		}
+		i++
+
+		var cueText []string
+		for ; i < len(lines) && strings.TrimSpace(lines[i]) != ""; i++ {
+			line := srtFontTagPattern.ReplaceAllString(lines[i], "")
+			// WebVTT ではキューの本文に「-->」を含められない
+			cueText = append(cueText, strings.ReplaceAll(line, "-->", "->"))
+		}
+
+		cues++
+		fmt.Fprintf(&b, "\n%d\n%s --> %s\n%s\n", cues, FormatWebVTTTimestamp(start), FormatWebVTTTimestamp(end), strings.Join(cueText, "\n"))
+	}
+
+	if cues == 0 {
+		return nil, fmt.Errorf("字幕のキューがありません")
+	}
+	return []byte(b.String()), nil
+}
+
+// 「開始 --> 終了」の行を解析し、開始・終了時刻（ミリ秒）を返す
+// SRT は「,」、WebVTT は「.」でミリ秒を区切る
+func parseSubtitleTiming(line string, separator byte) (uint64, uint64, error) {
+	match := subtitleTimingPattern.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return 0, 0, fmt.Errorf("字幕の時刻の形式が正しくありません: %s", line)
+	}
+
+	start, ok := parseSubtitleTimestamp(match[1], separator)
+	if !ok {
+		return 0, 0, fmt.Errorf("字幕の時刻の形式が正しくありません: %s", match[1])
+	}
+	end, ok := parseSubtitleTimestamp(match[2], separator)
+	if !ok {
+		return 0, 0, fmt.Errorf("字幕の時刻の形式が正しくありません: %s", match[2])
+	}
+	if end <= start {
+		return 0, 0, fmt.Errorf("字幕の終了時刻が開始時刻より前です: %s", line)
+	}
+	return start, end, nil
+}
+
+// 「hh:mm:ss,ttt」「mm:ss.ttt」形式の時刻をミリ秒に変換する
+func parseSubtitleTimestamp(timestamp string, separator byte) (uint64, bool) {
+	i := strings.LastIndexAny(timestamp, ".,")
+	if timestamp[i] != separator {
+		return 0, false
+	}
+	millis, err := strconv.ParseUint(timestamp[i+1:], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	var seconds uint64
+	parts := strings.Split(timestamp[:i], ":")
+	for j, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		// 時以外は 60 未満
+		if (j > 0 || len(parts) == 2) && v >= 60 {
+			return 0, false
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds*1000 + millis, true
+}
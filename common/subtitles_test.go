@@ -0,0 +1,58 @@
+package common
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertSubtitleToWebVTT(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "srt",
+			input: "1\n00:00:01,000 --> 00:00:04,500\nこんにちは\n\n2\n00:00:05,000 --> 00:00:07,000\n<font color=\"red\">赤</font>\n2行目\n",
+			want:  "WEBVTT\n\n1\n00:00:01.000 --> 00:00:04.500\nこんにちは\n\n2\n00:00:05.000 --> 00:00:07.000\n赤\n2行目\n",
+		},
+		{
+			name:  "srt with bom, crlf and no numbers",
+			input: "\xef\xbb\xbf00:00:01,000 --> 00:00:02,000\r\nA --> B\r\n\r\n\r\n1:00:00,000 --> 1:00:01,000\r\nC\r\n",
+			want:  "WEBVTT\n\n1\n00:00:01.000 --> 00:00:02.000\nA -> B\n\n2\n01:00:00.000 --> 01:00:01.000\nC\n",
+		},
+		{
+			name:  "webvtt is kept",
+			input: "WEBVTT - 字幕\n\n00:01.000 --> 00:02.000 line:0\nテキスト\n",
+			want:  "WEBVTT - 字幕\n\n00:01.000 --> 00:02.000 line:0\nテキスト\n",
+		},
+		{name: "empty", input: "", wantErr: "字幕のキューがありません"},
+		{name: "webvtt without cues", input: "WEBVTT\n\nNOTE メモ\n", wantErr: "字幕のキューがありません"},
+		{name: "not utf-8", input: "1\n00:00:01,000 --> 00:00:02,000\n\x82\xa0\n", wantErr: "UTF-8"},
+		{name: "srt with period", input: "1\n00:00:01.000 --> 00:00:02.000\nA\n", wantErr: "2行目"},
+		{name: "webvtt with comma", input: "WEBVTT\n\n00:00:01,000 --> 00:00:02,000\nA\n", wantErr: "3行目"},
+		{name: "end before start", input: "1\n00:00:02,000 --> 00:00:01,000\nA\n", wantErr: "終了時刻が開始時刻より前"},
+		{name: "minutes out of range", input: "1\n00:60:00,000 --> 01:00:01,000\nA\n", wantErr: "時刻の形式"},
+		{name: "missing timing", input: "1\nこんにちは\n", wantErr: "1行目: 字幕の時刻がありません"},
+		{name: "not a number", input: "abc\n00:00:01,000 --> 00:00:02,000\nA\n", wantErr: "1行目: 字幕の番号または時刻が必要です"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ConvertSubtitleToWebVTT([]byte(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("ConvertSubtitleToWebVTT() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ConvertSubtitleToWebVTT() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("ConvertSubtitleToWebVTT() =\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
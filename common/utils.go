@@ -1,13 +1,128 @@
 package common
 
 import (
+	"fmt"
+	"net"
+	"net/http"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 )
 
+const (
+	// 1つの動画に付けられるタグの最大数
+	MaxTagsPerVideo = 20
+	// タグ名の最大文字数
+	MaxTagLength = 50
+)
+
 // generateUniqueFileName は一意のファイル名を生成する関数です
 func GenerateUniqueFileName(originalName string) string {
 	extension := filepath.Ext(originalName)
 	return uuid.New().String() + extension
 }
+
+// NormalizeTags はタグ名を正規化し、重複を除いたタグ一覧を返す関数です
+// カンマ区切りの値も分割し、先頭の # を取り除いて小文字に揃えます
+func NormalizeTags(rawTags []string) ([]string, error) {
+	seen := map[string]bool{}
+	tags := []string{}
+	for _, raw := range rawTags {
+		for _, tag := range strings.Split(raw, ",") {
+			tag = strings.ToLower(strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(tag), "#")))
+			if tag == "" || seen[tag] {
+				continue
+			}
+			if utf8.RuneCountInString(tag) > MaxTagLength {
+				return nil, fmt.Errorf("タグは%d文字以内で指定してください: %s", MaxTagLength, tag)
+			}
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	if len(tags) > MaxTagsPerVideo {
+		return nil, fmt.Errorf("タグは%d個まで指定できます", MaxTagsPerVideo)
+	}
+	return tags, nil
+}
+
+// ClientIP はリクエスト元のIPアドレスを返す関数です
+// 接続元が TRUSTED_PROXIES 環境変数（カンマ区切りのIPアドレスまたはCIDR）に含まれるプロキシの場合のみ X-Forwarded-For を参照し、
+// 右から順に信頼するプロキシを除いた最初のアドレスを使用します（左側のアドレスはクライアントが自由に設定できるため使いません）
+func ClientIP(r *http.Request) string {
+	remote, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		remote = r.RemoteAddr
+	}
+	if !isTrustedProxy(remote) {
+		return remote
+	}
+
+	hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if net.ParseIP(hop) == nil {
+			// 形式が正しくないアドレスより左側は信頼できない
+			break
+		}
+		if !isTrustedProxy(hop) {
+			return hop
+		}
+		remote = hop
+	}
+	return remote
+}
+
+var (
+	trustedProxiesOnce sync.Once
+	trustedProxies     []*net.IPNet
+)
+
+// 信頼するプロキシのアドレスかどうかを返す関数です
+func isTrustedProxy(addr string) bool {
+	trustedProxiesOnce.Do(func() {
+		trustedProxies = parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	})
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// カンマ区切りのIPアドレスまたはCIDRを解析する関数です（解析できない値はログに記録して無視します）
+func parseTrustedProxies(value string) []*net.IPNet {
+	networks := []*net.IPNet{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil {
+				bits := 128
+				if ip.To4() != nil {
+					ip, bits = ip.To4(), 32
+				}
+				networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+				continue
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			LogError(fmt.Errorf("TRUSTED_PROXIES の値を解析できません: %s", entry))
+			continue
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
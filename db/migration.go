@@ -75,7 +75,7 @@ func RunMigration() {
 
 func getLatestVersion() uint {
 	// ここで最新のマイグレーションバージョンを返すロジックを適用する
-	return 20240830085003
+	return 20241208090000
 }
 
 // マイグレーションを実行する関数
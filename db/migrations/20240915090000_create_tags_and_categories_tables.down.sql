@@ -0,0 +1,12 @@
+-- テーブル: video_tags の削除
+DROP TABLE IF EXISTS video_tags;
+
+-- テーブル: tags の削除
+DROP TABLE IF EXISTS tags;
+
+-- テーブル: videos からカテゴリを削除
+ALTER TABLE videos DROP FOREIGN KEY fk_videos_category_id;
+ALTER TABLE videos DROP COLUMN category_id;
+
+-- テーブル: categories の削除
+DROP TABLE IF EXISTS categories;
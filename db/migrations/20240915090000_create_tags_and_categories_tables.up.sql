@@ -0,0 +1,46 @@
+
+-- テーブル: categories（固定のカテゴリ分類）
+CREATE TABLE categories (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    slug VARCHAR(50) NOT NULL UNIQUE,                    -- URLで使用する識別子
+    name VARCHAR(100) NOT NULL,                          -- 表示名
+    sort_order INT UNSIGNED NOT NULL DEFAULT 0           -- 表示順
+);
+
+INSERT INTO categories (slug, name, sort_order) VALUES
+    ('music', '音楽', 1),
+    ('gaming', 'ゲーム', 2),
+    ('education', '教育', 3),
+    ('technology', '科学と技術', 4),
+    ('entertainment', 'エンターテイメント', 5),
+    ('sports', 'スポーツ', 6),
+    ('news', 'ニュース', 7),
+    ('howto', 'ハウツーとスタイル', 8),
+    ('travel', '旅行とイベント', 9),
+    ('pets', 'ペットと動物', 10),
+    ('comedy', 'コメディ', 11),
+    ('vlog', 'ブログ', 12),
+    ('other', 'その他', 99);
+
+-- テーブル: videos にカテゴリを追加
+ALTER TABLE videos
+    ADD COLUMN category_id INT UNSIGNED NULL AFTER description,  -- カテゴリID（外部キー）
+    ADD CONSTRAINT fk_videos_category_id FOREIGN KEY (category_id) REFERENCES categories(id);
+
+-- テーブル: tags
+CREATE TABLE tags (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,                    -- 正規化済みのタグ名
+    created DATETIME DEFAULT CURRENT_TIMESTAMP           -- 作成日時
+);
+
+-- テーブル: video_tags（動画とタグの中間テーブル）
+CREATE TABLE video_tags (
+    video_id BIGINT UNSIGNED NOT NULL,                   -- videosテーブルとのリレーション用外部キー
+    tag_id BIGINT UNSIGNED NOT NULL,                     -- tagsテーブルとのリレーション用外部キー
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    PRIMARY KEY (video_id, tag_id),
+    INDEX idx_video_tags_tag_id (tag_id),
+    FOREIGN KEY (video_id) REFERENCES videos(id),        -- 外部キー制約（videosテーブル）
+    FOREIGN KEY (tag_id) REFERENCES tags(id)             -- 外部キー制約（tagsテーブル）
+);
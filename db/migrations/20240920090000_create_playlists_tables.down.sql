@@ -0,0 +1,8 @@
+-- テーブル: playlist_editors の削除
+DROP TABLE IF EXISTS playlist_editors;
+
+-- テーブル: playlist_items の削除
+DROP TABLE IF EXISTS playlist_items;
+
+-- テーブル: playlists の削除
+DROP TABLE IF EXISTS playlists;
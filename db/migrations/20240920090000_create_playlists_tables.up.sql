@@ -0,0 +1,39 @@
+
+-- テーブル: playlists
+CREATE TABLE playlists (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,                       -- 所有者のユーザーID（外部キー）
+    title VARCHAR(255) NOT NULL,                         -- プレイリストのタイトル
+    description TEXT NULL,                               -- プレイリストの説明
+    visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'private', -- 公開範囲
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    modified DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, -- 更新日時
+    deleted DATETIME NULL,                               -- 削除日時（ソフトデリート用）
+    FOREIGN KEY (user_id) REFERENCES users(id)           -- 外部キー制約（usersテーブル）
+);
+
+-- テーブル: playlist_items
+CREATE TABLE playlist_items (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    playlist_id BIGINT UNSIGNED NOT NULL,                -- playlistsテーブルとのリレーション用外部キー
+    video_id BIGINT UNSIGNED NOT NULL,                   -- videosテーブルとのリレーション用外部キー
+    sort_order INT UNSIGNED NOT NULL,                    -- 並び順（0始まり）
+    added_by INT UNSIGNED NOT NULL,                      -- 追加したユーザーID
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    UNIQUE KEY uq_playlist_items_video (playlist_id, video_id),
+    INDEX idx_playlist_items_sort_order (playlist_id, sort_order),
+    FOREIGN KEY (playlist_id) REFERENCES playlists(id),  -- 外部キー制約（playlistsテーブル）
+    FOREIGN KEY (video_id) REFERENCES videos(id),        -- 外部キー制約（videosテーブル）
+    FOREIGN KEY (added_by) REFERENCES users(id)          -- 外部キー制約（usersテーブル）
+);
+
+-- テーブル: playlist_editors（共同編集者）
+CREATE TABLE playlist_editors (
+    playlist_id BIGINT UNSIGNED NOT NULL,                -- playlistsテーブルとのリレーション用外部キー
+    user_id INT UNSIGNED NOT NULL,                       -- 編集者のユーザーID
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    PRIMARY KEY (playlist_id, user_id),
+    INDEX idx_playlist_editors_user_id (user_id),
+    FOREIGN KEY (playlist_id) REFERENCES playlists(id),  -- 外部キー制約（playlistsテーブル）
+    FOREIGN KEY (user_id) REFERENCES users(id)           -- 外部キー制約（usersテーブル）
+);
@@ -0,0 +1,11 @@
+-- テーブル: video_reactions の削除
+DROP TABLE IF EXISTS video_reactions;
+
+-- テーブル: video_daily_views の削除
+DROP TABLE IF EXISTS video_daily_views;
+
+-- テーブル: videos から再生数と評価数を削除
+ALTER TABLE videos
+    DROP COLUMN dislike_count,
+    DROP COLUMN like_count,
+    DROP COLUMN view_count;
@@ -0,0 +1,29 @@
+
+-- テーブル: videos に再生数と評価数を追加
+ALTER TABLE videos
+    ADD COLUMN view_count BIGINT UNSIGNED NOT NULL DEFAULT 0 AFTER category_id,  -- 再生数
+    ADD COLUMN like_count INT UNSIGNED NOT NULL DEFAULT 0 AFTER view_count,      -- 高評価数
+    ADD COLUMN dislike_count INT UNSIGNED NOT NULL DEFAULT 0 AFTER like_count;   -- 低評価数
+
+-- テーブル: video_daily_views（日別の再生数）
+CREATE TABLE video_daily_views (
+    video_id BIGINT UNSIGNED NOT NULL,                   -- videosテーブルとのリレーション用外部キー
+    view_date DATE NOT NULL,                             -- 集計日
+    views BIGINT UNSIGNED NOT NULL DEFAULT 0,            -- 再生数
+    PRIMARY KEY (video_id, view_date),
+    INDEX idx_video_daily_views_date (view_date),
+    FOREIGN KEY (video_id) REFERENCES videos(id)         -- 外部キー制約（videosテーブル）
+);
+
+-- テーブル: video_reactions（ユーザーごとの高評価・低評価）
+CREATE TABLE video_reactions (
+    video_id BIGINT UNSIGNED NOT NULL,                   -- videosテーブルとのリレーション用外部キー
+    user_id INT UNSIGNED NOT NULL,                       -- 評価したユーザーID
+    reaction ENUM('like', 'dislike') NOT NULL,           -- 評価の種類
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    modified DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, -- 更新日時
+    PRIMARY KEY (video_id, user_id),
+    INDEX idx_video_reactions_user_id (user_id),
+    FOREIGN KEY (video_id) REFERENCES videos(id),        -- 外部キー制約（videosテーブル）
+    FOREIGN KEY (user_id) REFERENCES users(id)           -- 外部キー制約（usersテーブル）
+);
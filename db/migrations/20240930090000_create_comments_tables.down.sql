@@ -0,0 +1,8 @@
+-- テーブル: comment_likes の削除
+DROP TABLE IF EXISTS comment_likes;
+
+-- テーブル: comments の削除
+DROP TABLE IF EXISTS comments;
+
+-- テーブル: videos からコメントの承認設定を削除
+ALTER TABLE videos DROP COLUMN comments_require_approval;
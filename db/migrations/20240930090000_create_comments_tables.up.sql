@@ -0,0 +1,36 @@
+
+-- テーブル: videos にコメントの承認設定を追加
+ALTER TABLE videos
+    ADD COLUMN comments_require_approval TINYINT(1) NOT NULL DEFAULT 0 AFTER dislike_count; -- コメントの公開に投稿者の承認が必要か
+
+-- テーブル: comments
+CREATE TABLE comments (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    video_id BIGINT UNSIGNED NOT NULL,                   -- videosテーブルとのリレーション用外部キー
+    user_id INT UNSIGNED NOT NULL,                       -- 投稿したユーザーID
+    parent_id BIGINT UNSIGNED NULL,                      -- 返信先のコメントID（返信は1階層のみ）
+    body TEXT NOT NULL,                                  -- コメント本文
+    status ENUM('approved', 'pending', 'hidden') NOT NULL DEFAULT 'approved', -- モデレーション状態
+    pinned TINYINT(1) NOT NULL DEFAULT 0,                -- 動画の投稿者による固定
+    like_count INT UNSIGNED NOT NULL DEFAULT 0,          -- 高評価数
+    reply_count INT UNSIGNED NOT NULL DEFAULT 0,         -- 公開中の返信数
+    edited DATETIME NULL,                                -- 最終編集日時
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    modified DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, -- 更新日時
+    deleted DATETIME NULL,                               -- 削除日時（ソフトデリート用）
+    INDEX idx_comments_video_parent (video_id, parent_id, status),
+    INDEX idx_comments_parent_id (parent_id),
+    FOREIGN KEY (video_id) REFERENCES videos(id),        -- 外部キー制約（videosテーブル）
+    FOREIGN KEY (user_id) REFERENCES users(id),          -- 外部キー制約（usersテーブル）
+    FOREIGN KEY (parent_id) REFERENCES comments(id)      -- 外部キー制約（commentsテーブル）
+);
+
+-- テーブル: comment_likes
+CREATE TABLE comment_likes (
+    comment_id BIGINT UNSIGNED NOT NULL,                 -- commentsテーブルとのリレーション用外部キー
+    user_id INT UNSIGNED NOT NULL,                       -- 高評価したユーザーID
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    PRIMARY KEY (comment_id, user_id),
+    FOREIGN KEY (comment_id) REFERENCES comments(id),    -- 外部キー制約（commentsテーブル）
+    FOREIGN KEY (user_id) REFERENCES users(id)           -- 外部キー制約（usersテーブル）
+);
@@ -0,0 +1,2 @@
+-- テーブル: watch_history の削除
+DROP TABLE IF EXISTS watch_history;
@@ -0,0 +1,16 @@
+
+-- テーブル: watch_history（ユーザーごとの視聴履歴と再生位置）
+CREATE TABLE watch_history (
+    user_id INT UNSIGNED NOT NULL,                       -- 視聴したユーザーID
+    video_id BIGINT UNSIGNED NOT NULL,                   -- videosテーブルとのリレーション用外部キー
+    position_seconds INT UNSIGNED NOT NULL DEFAULT 0,    -- 最後に再生していた位置（秒単位）
+    duration_seconds INT UNSIGNED NULL,                  -- 動画の再生時間（秒単位）
+    completed TINYINT(1) NOT NULL DEFAULT 0,             -- 最後まで視聴したか
+    watched DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 最終視聴日時
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    PRIMARY KEY (user_id, video_id),
+    INDEX idx_watch_history_user_watched (user_id, watched),
+    INDEX idx_watch_history_video_id (video_id),
+    FOREIGN KEY (user_id) REFERENCES users(id),          -- 外部キー制約（usersテーブル）
+    FOREIGN KEY (video_id) REFERENCES videos(id)         -- 外部キー制約（videosテーブル）
+);
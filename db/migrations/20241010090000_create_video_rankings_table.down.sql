@@ -0,0 +1,6 @@
+-- 急上昇スコアの集計用インデックスの削除
+DROP INDEX idx_comments_created ON comments;
+DROP INDEX idx_video_reactions_created ON video_reactions;
+
+-- テーブル: video_rankings の削除
+DROP TABLE IF EXISTS video_rankings;
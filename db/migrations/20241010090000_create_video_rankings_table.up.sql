@@ -0,0 +1,16 @@
+-- テーブル: video_rankings（定期的に集計する急上昇ランキング）
+CREATE TABLE video_rankings (
+    period ENUM('daily', 'weekly') NOT NULL,             -- 集計期間
+    category_id INT UNSIGNED NOT NULL DEFAULT 0,         -- カテゴリID（0は全カテゴリ）
+    rank_order INT UNSIGNED NOT NULL,                    -- 順位（1から）
+    video_id BIGINT UNSIGNED NOT NULL,                   -- videosテーブルとのリレーション用外部キー
+    score DOUBLE NOT NULL DEFAULT 0,                     -- 急上昇スコア
+    computed DATETIME NOT NULL,                          -- 集計日時
+    PRIMARY KEY (period, category_id, rank_order),
+    INDEX idx_video_rankings_video_id (video_id),
+    FOREIGN KEY (video_id) REFERENCES videos(id)         -- 外部キー制約（videosテーブル）
+);
+
+-- 急上昇スコアの集計用インデックス
+CREATE INDEX idx_video_reactions_created ON video_reactions (created);
+CREATE INDEX idx_comments_created ON comments (created);
@@ -0,0 +1,3 @@
+-- テーブル: videos から公開範囲を削除
+ALTER TABLE videos
+    DROP COLUMN visibility;
@@ -0,0 +1,3 @@
+-- テーブル: videos に公開範囲を追加
+ALTER TABLE videos
+    ADD COLUMN visibility ENUM('public', 'unlisted', 'private') NOT NULL DEFAULT 'public' AFTER description; -- 公開範囲
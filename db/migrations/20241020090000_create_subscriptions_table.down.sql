@@ -0,0 +1,2 @@
+-- テーブル: subscriptions の削除
+DROP TABLE IF EXISTS subscriptions;
@@ -0,0 +1,10 @@
+-- テーブル: subscriptions（チャンネル登録）
+CREATE TABLE subscriptions (
+    subscriber_id INT UNSIGNED NOT NULL,                 -- 登録したユーザーID
+    channel_id INT UNSIGNED NOT NULL,                    -- 登録されたチャンネル（投稿者）のユーザーID
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 登録日時
+    PRIMARY KEY (subscriber_id, channel_id),
+    INDEX idx_subscriptions_channel_id (channel_id),
+    FOREIGN KEY (subscriber_id) REFERENCES users(id),    -- 外部キー制約（usersテーブル）
+    FOREIGN KEY (channel_id) REFERENCES users(id)        -- 外部キー制約（usersテーブル）
+);
@@ -0,0 +1,5 @@
+-- テーブル: notification_preferences の削除
+DROP TABLE IF EXISTS notification_preferences;
+
+-- テーブル: notifications の削除
+DROP TABLE IF EXISTS notifications;
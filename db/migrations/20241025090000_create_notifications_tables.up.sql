@@ -0,0 +1,25 @@
+-- テーブル: notifications（アプリ内通知）
+CREATE TABLE notifications (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,       -- 通知ID
+    user_id INT UNSIGNED NOT NULL,                       -- 通知を受け取るユーザーID
+    type VARCHAR(50) NOT NULL,                           -- 通知の種類（new_upload, comment_reply, video_processed）
+    actor_id INT UNSIGNED NULL,                          -- 通知のきっかけになったユーザーID
+    video_id BIGINT UNSIGNED NULL,                       -- 関連する動画ID
+    comment_id BIGINT UNSIGNED NULL,                     -- 関連するコメントID
+    message VARCHAR(255) NOT NULL,                       -- 表示用のメッセージ
+    read_at DATETIME NULL,                               -- 既読日時（未読の場合はNULL）
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    INDEX idx_notifications_user_id (user_id, id),
+    INDEX idx_notifications_user_unread (user_id, read_at),
+    FOREIGN KEY (user_id) REFERENCES users(id)           -- 外部キー制約（usersテーブル）
+);
+
+-- テーブル: notification_preferences（通知の種類ごとの受信設定）
+CREATE TABLE notification_preferences (
+    user_id INT UNSIGNED NOT NULL,                       -- ユーザーID
+    type VARCHAR(50) NOT NULL,                           -- 通知の種類
+    enabled TINYINT(1) NOT NULL DEFAULT 1,               -- 通知を受け取るか
+    modified DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, -- 更新日時
+    PRIMARY KEY (user_id, type),
+    FOREIGN KEY (user_id) REFERENCES users(id)           -- 外部キー制約（usersテーブル）
+);
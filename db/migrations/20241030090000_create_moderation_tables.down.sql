@@ -0,0 +1,14 @@
+-- テーブル: moderation_actions の削除
+DROP TABLE IF EXISTS moderation_actions;
+
+-- テーブル: reports の削除
+DROP TABLE IF EXISTS reports;
+
+-- テーブル: videos からモデレーターによる非表示日時を削除
+ALTER TABLE videos
+    DROP COLUMN hidden;
+
+-- テーブル: users から権限と利用停止日時を削除
+ALTER TABLE users
+    DROP COLUMN suspended_at,
+    DROP COLUMN role;
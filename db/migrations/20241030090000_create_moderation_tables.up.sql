@@ -0,0 +1,39 @@
+-- テーブル: users に権限と利用停止日時を追加
+ALTER TABLE users
+    ADD COLUMN role ENUM('user', 'moderator', 'admin') NOT NULL DEFAULT 'user' AFTER pass, -- 権限
+    ADD COLUMN suspended_at DATETIME NULL AFTER role;                                    -- 利用停止日時（停止中でなければNULL）
+
+-- テーブル: videos にモデレーターによる非表示日時を追加
+ALTER TABLE videos
+    ADD COLUMN hidden DATETIME NULL AFTER modified;      -- モデレーターが非表示にした日時（投稿者のみ閲覧可能）
+
+-- テーブル: reports（動画・コメントの通報）
+CREATE TABLE reports (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,       -- 通報ID
+    reporter_id INT UNSIGNED NOT NULL,                   -- 通報したユーザーID
+    target_type ENUM('video', 'comment') NOT NULL,       -- 通報対象の種類
+    target_id BIGINT UNSIGNED NOT NULL,                  -- 通報対象のID
+    reason ENUM('spam', 'harassment', 'hate', 'violence', 'sexual', 'copyright', 'misinformation', 'other') NOT NULL, -- 通報理由
+    details TEXT,                                        -- 補足説明
+    status ENUM('open', 'resolved', 'dismissed') NOT NULL DEFAULT 'open', -- 対応状況
+    resolved_by INT UNSIGNED NULL,                       -- 対応したモデレーターのユーザーID
+    resolved_at DATETIME NULL,                           -- 対応日時
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    INDEX idx_reports_target (target_type, target_id, status),
+    INDEX idx_reports_status (status),
+    INDEX idx_reports_reporter_id (reporter_id),
+    FOREIGN KEY (reporter_id) REFERENCES users(id)       -- 外部キー制約（usersテーブル）
+);
+
+-- テーブル: moderation_actions（モデレーターの対応履歴）
+CREATE TABLE moderation_actions (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,       -- 対応ID
+    moderator_id INT UNSIGNED NOT NULL,                  -- 対応したモデレーターのユーザーID
+    target_type ENUM('video', 'comment', 'user') NOT NULL, -- 対応対象の種類
+    target_id BIGINT UNSIGNED NOT NULL,                  -- 対応対象のID
+    action ENUM('dismiss', 'hide', 'take_down', 'suspend') NOT NULL, -- 対応内容
+    reason TEXT NOT NULL,                                -- 対応理由
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    INDEX idx_moderation_actions_target (target_type, target_id),
+    FOREIGN KEY (moderator_id) REFERENCES users(id)      -- 外部キー制約（usersテーブル）
+);
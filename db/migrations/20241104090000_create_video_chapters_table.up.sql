@@ -0,0 +1,11 @@
+-- テーブル: video_chapters（動画のチャプター）
+CREATE TABLE video_chapters (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,          -- チャプターID
+    video_id BIGINT UNSIGNED NOT NULL,                   -- 動画ID
+    start_seconds INT UNSIGNED NOT NULL,                 -- 開始位置（秒）
+    title VARCHAR(255) NOT NULL,                         -- チャプターのタイトル
+    source ENUM('manual', 'description') NOT NULL DEFAULT 'manual', -- 登録元（APIで指定 / 概要欄から読み取り）
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    UNIQUE KEY uq_video_chapters_video_start (video_id, start_seconds),
+    FOREIGN KEY (video_id) REFERENCES videos(id)         -- 外部キー制約（videosテーブル）
+);
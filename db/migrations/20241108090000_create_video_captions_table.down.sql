@@ -0,0 +1,2 @@
+-- テーブル: video_captions の削除
+DROP TABLE IF EXISTS video_captions;
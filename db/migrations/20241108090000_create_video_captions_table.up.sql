@@ -0,0 +1,12 @@
+-- テーブル: video_captions（動画の字幕）
+CREATE TABLE video_captions (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,          -- 字幕ID
+    video_id BIGINT UNSIGNED NOT NULL,                   -- 動画ID
+    language VARCHAR(35) NOT NULL,                       -- 言語コード（BCP 47、例: ja, en-US）
+    label VARCHAR(100) NOT NULL,                         -- プレーヤーに表示する名前
+    file_path VARCHAR(255) NOT NULL,                     -- WebVTT ファイルのパス（captions/ 以下）
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    modified DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, -- 更新日時
+    UNIQUE KEY uq_video_captions_video_language (video_id, language),
+    FOREIGN KEY (video_id) REFERENCES videos(id)         -- 外部キー制約（videosテーブル）
+);
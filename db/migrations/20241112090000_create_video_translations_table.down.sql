@@ -0,0 +1,6 @@
+-- テーブル: video_translations の削除
+DROP TABLE IF EXISTS video_translations;
+
+-- テーブル: videos から原文の言語を削除
+ALTER TABLE videos
+    DROP COLUMN language;
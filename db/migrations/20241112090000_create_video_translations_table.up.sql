@@ -0,0 +1,15 @@
+-- テーブル: videos に原文の言語を追加
+ALTER TABLE videos
+    ADD COLUMN language VARCHAR(35) DEFAULT NULL AFTER description; -- タイトル・説明の言語（BCP 47、未設定の場合は NULL）
+
+-- テーブル: video_translations（動画のタイトル・説明の翻訳）
+CREATE TABLE video_translations (
+    video_id BIGINT UNSIGNED NOT NULL,                   -- 動画ID
+    language VARCHAR(35) NOT NULL,                       -- 言語コード（BCP 47、例: ja, en-US）
+    title VARCHAR(255) NOT NULL,                         -- 翻訳したタイトル
+    description TEXT,                                    -- 翻訳した説明（空の場合は原文の説明を表示）
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    modified DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, -- 更新日時
+    PRIMARY KEY (video_id, language),
+    FOREIGN KEY (video_id) REFERENCES videos(id)         -- 外部キー制約（videosテーブル）
+);
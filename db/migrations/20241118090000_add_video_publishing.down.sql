@@ -0,0 +1,6 @@
+-- テーブル: videos から公開状態と予約公開日時を削除
+ALTER TABLE videos
+    DROP INDEX idx_videos_status_publish_at,
+    DROP COLUMN published,
+    DROP COLUMN publish_at,
+    DROP COLUMN status;
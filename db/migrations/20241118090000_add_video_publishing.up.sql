@@ -0,0 +1,9 @@
+-- テーブル: videos に公開状態と予約公開日時を追加
+ALTER TABLE videos
+    ADD COLUMN status ENUM('draft', 'scheduled', 'published') NOT NULL DEFAULT 'published' AFTER visibility, -- 公開状態（下書き / 予約公開 / 公開済み）
+    ADD COLUMN publish_at DATETIME DEFAULT NULL AFTER status,       -- 予約公開する日時
+    ADD COLUMN published DATETIME DEFAULT NULL AFTER publish_at,    -- 最初に公開した日時
+    ADD INDEX idx_videos_status_publish_at (status, publish_at);
+
+-- 既存の動画は投稿時に公開したものとする（更新日時は変更しない）
+UPDATE videos SET published = created, modified = modified;
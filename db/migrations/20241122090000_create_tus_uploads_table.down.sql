@@ -0,0 +1,2 @@
+-- テーブル: tus_uploads の削除
+DROP TABLE IF EXISTS tus_uploads;
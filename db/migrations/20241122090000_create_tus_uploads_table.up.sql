@@ -0,0 +1,14 @@
+-- テーブル: tus_uploads（tus プロトコルによる再開可能なアップロード）
+CREATE TABLE tus_uploads (
+    id CHAR(36) NOT NULL PRIMARY KEY,                    -- アップロードID（UUID）
+    user_id INT UNSIGNED NOT NULL,                       -- アップロードしたユーザーID
+    upload_length BIGINT UNSIGNED NOT NULL,              -- ファイルの合計サイズ（バイト）
+    upload_offset BIGINT UNSIGNED NOT NULL DEFAULT 0,    -- 受信済みのサイズ（バイト）
+    metadata TEXT,                                       -- Upload-Metadata ヘッダーの値
+    video_id BIGINT UNSIGNED DEFAULT NULL,               -- 完了後に作成した動画ID
+    expires DATETIME NOT NULL,                           -- 有効期限（過ぎたアップロードは削除する）
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    INDEX idx_tus_uploads_expires (expires),
+    FOREIGN KEY (user_id) REFERENCES users(id),          -- 外部キー制約（usersテーブル）
+    FOREIGN KEY (video_id) REFERENCES videos(id)         -- 外部キー制約（videosテーブル）
+);
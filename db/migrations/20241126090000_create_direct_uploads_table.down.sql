@@ -0,0 +1,6 @@
+-- テーブル: direct_uploads の削除
+DROP TABLE IF EXISTS direct_uploads;
+
+-- テーブル: video_files からアップロード完了日時を削除
+ALTER TABLE video_files
+    DROP COLUMN uploaded;
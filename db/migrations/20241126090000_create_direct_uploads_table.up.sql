@@ -0,0 +1,23 @@
+-- テーブル: video_files にアップロード完了日時を追加（ストレージへの直接アップロードは完了するまで NULL）
+ALTER TABLE video_files
+    ADD COLUMN uploaded DATETIME DEFAULT NULL AFTER status;
+
+-- 既存の動画ファイルは作成時にアップロード済みとする（更新日時は変更しない）
+UPDATE video_files SET uploaded = created, modified = modified;
+
+-- テーブル: direct_uploads（署名付きURLによるストレージへの直接アップロード）
+CREATE TABLE direct_uploads (
+    video_file_id BIGINT UNSIGNED NOT NULL PRIMARY KEY,  -- アップロード先の動画ファイルID
+    user_id INT UNSIGNED NOT NULL,                       -- アップロードするユーザーID
+    upload_id VARCHAR(255) DEFAULT NULL,                 -- マルチパートアップロードのID（1回の PUT の場合は NULL）
+    part_size BIGINT UNSIGNED NOT NULL DEFAULT 0,        -- マルチパートアップロードの1パートのサイズ（バイト）
+    file_size BIGINT UNSIGNED NOT NULL,                  -- アップロードするファイルのサイズ（バイト）
+    content_type VARCHAR(100) NOT NULL,                  -- アップロードするファイルの Content-Type
+    status ENUM('draft', 'scheduled', 'published') NOT NULL DEFAULT 'published', -- 完了後の動画の公開状態
+    publish_at DATETIME DEFAULT NULL,                    -- 完了後の動画の予約公開日時
+    expires DATETIME NOT NULL,                           -- 署名付きURLの有効期限（過ぎても完了しないアップロードは削除する）
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    INDEX idx_direct_uploads_expires (expires),
+    FOREIGN KEY (video_file_id) REFERENCES video_files(id), -- 外部キー制約（video_filesテーブル）
+    FOREIGN KEY (user_id) REFERENCES users(id)           -- 外部キー制約（usersテーブル）
+);
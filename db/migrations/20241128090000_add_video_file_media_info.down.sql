@@ -0,0 +1,7 @@
+-- テーブル: video_files から動画ファイルの解析結果を削除
+ALTER TABLE video_files
+    DROP COLUMN audio_codec,
+    DROP COLUMN video_codec,
+    DROP COLUMN frame_rate,
+    DROP COLUMN height,
+    DROP COLUMN width;
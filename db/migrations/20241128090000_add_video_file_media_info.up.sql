@@ -0,0 +1,7 @@
+-- テーブル: video_files に動画ファイルの解析結果を追加（解析できない形式の場合は NULL）
+ALTER TABLE video_files
+    ADD COLUMN width INT UNSIGNED DEFAULT NULL AFTER duration,       -- 映像の幅（ピクセル）
+    ADD COLUMN height INT UNSIGNED DEFAULT NULL AFTER width,         -- 映像の高さ（ピクセル）
+    ADD COLUMN frame_rate DECIMAL(8, 3) DEFAULT NULL AFTER height,   -- フレームレート（fps）
+    ADD COLUMN video_codec VARCHAR(50) DEFAULT NULL AFTER frame_rate, -- 映像のコーデック
+    ADD COLUMN audio_codec VARCHAR(50) DEFAULT NULL AFTER video_codec; -- 音声のコーデック
@@ -0,0 +1,13 @@
+-- テーブル: video_thumbnails（動画のサムネイル。アップロードされた画像から表示サイズごとに作成する）
+CREATE TABLE video_thumbnails (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,          -- サムネイルID
+    video_id BIGINT UNSIGNED NOT NULL,                   -- 動画ID
+    variant ENUM('small', 'medium', 'large', 'webp') NOT NULL, -- サムネイルの種類
+    file_path VARCHAR(255) NOT NULL,                     -- 画像ファイルのオブジェクトキー（thumbnails/ 以下）
+    width INT UNSIGNED NOT NULL,                         -- 幅（ピクセル）
+    height INT UNSIGNED NOT NULL,                        -- 高さ（ピクセル）
+    content_type VARCHAR(50) NOT NULL,                   -- 画像の形式（image/jpeg, image/webp）
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    UNIQUE KEY uq_video_thumbnails_video_variant (video_id, variant),
+    FOREIGN KEY (video_id) REFERENCES videos(id)         -- 外部キー制約（videosテーブル）
+);
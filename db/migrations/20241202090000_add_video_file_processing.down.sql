@@ -0,0 +1,9 @@
+-- テーブル: video_files から動画ファイルの処理の状態を削除
+ALTER TABLE video_files
+    DROP INDEX idx_video_files_status,
+    DROP COLUMN processed,
+    DROP COLUMN next_attempt,
+    DROP COLUMN processing_started,
+    DROP COLUMN processing_attempts,
+    DROP COLUMN processing_error,
+    DROP COLUMN processing_step;
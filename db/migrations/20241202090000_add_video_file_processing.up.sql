@@ -0,0 +1,9 @@
+-- テーブル: video_files に動画ファイルの処理（解析・変換・サムネイル作成・配信用の書き出し）の状態を追加
+ALTER TABLE video_files
+    ADD COLUMN processing_step VARCHAR(20) DEFAULT NULL AFTER status,              -- 実行中または失敗した処理の段階
+    ADD COLUMN processing_error TEXT DEFAULT NULL AFTER processing_step,           -- 最後に失敗した処理のエラー内容
+    ADD COLUMN processing_attempts INT UNSIGNED NOT NULL DEFAULT 0 AFTER processing_error, -- 処理を試みた回数
+    ADD COLUMN processing_started DATETIME DEFAULT NULL AFTER processing_attempts, -- 処理を開始した日時
+    ADD COLUMN next_attempt DATETIME DEFAULT NULL AFTER processing_started,        -- 失敗した処理を再試行する日時
+    ADD COLUMN processed DATETIME DEFAULT NULL AFTER next_attempt,                  -- 処理が完了した日時
+    ADD INDEX idx_video_files_status (status, next_attempt);
@@ -0,0 +1,6 @@
+-- テーブル: video_files からレンディションの情報を削除
+ALTER TABLE video_files
+    DROP FOREIGN KEY fk_video_files_source_file,
+    DROP COLUMN bandwidth,
+    DROP COLUMN rendition,
+    DROP COLUMN source_file_id;
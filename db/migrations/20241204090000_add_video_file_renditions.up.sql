@@ -0,0 +1,7 @@
+-- テーブル: video_files に HLS / DASH 配信用のレンディション（画質ごとに変換した動画）の情報を追加
+-- レンディションの行の file_path には HLS のメディアプレイリストのパスを保存する
+ALTER TABLE video_files
+    ADD COLUMN source_file_id BIGINT UNSIGNED DEFAULT NULL AFTER video_id, -- 変換元の動画ファイル（元の動画ファイルの場合は NULL）
+    ADD COLUMN rendition VARCHAR(20) DEFAULT NULL AFTER source_file_id,    -- レンディションの名前（720p など）
+    ADD COLUMN bandwidth INT UNSIGNED DEFAULT NULL AFTER rendition,        -- セグメントの最大のビットレート（bps）
+    ADD CONSTRAINT fk_video_files_source_file FOREIGN KEY (source_file_id) REFERENCES video_files(id);
@@ -0,0 +1,4 @@
+-- テーブル: upload_reservations と user_upload_quotas、upload_plans の削除
+DROP TABLE IF EXISTS upload_reservations;
+DROP TABLE IF EXISTS user_upload_quotas;
+DROP TABLE IF EXISTS upload_plans;
@@ -0,0 +1,46 @@
+-- テーブル: upload_plans（アップロードの上限を定めたプラン、上限の列が NULL の場合は無制限）
+CREATE TABLE upload_plans (
+    id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    name VARCHAR(50) NOT NULL UNIQUE,                    -- プラン名
+    max_total_bytes BIGINT UNSIGNED DEFAULT NULL,        -- 保存できる動画ファイルの合計サイズ（バイト）
+    max_file_size BIGINT UNSIGNED DEFAULT NULL,          -- 1ファイルの最大サイズ（バイト）
+    max_duration INT UNSIGNED DEFAULT NULL,              -- 1ファイルの最大の再生時間（秒）
+    max_uploads_per_day INT UNSIGNED DEFAULT NULL,       -- 24時間にアップロードできる動画ファイルの数
+    is_default TINYINT(1) NOT NULL DEFAULT 0,            -- プランを指定していないユーザーに適用するプラン
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    modified DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP -- 更新日時
+);
+
+INSERT INTO upload_plans (name, max_total_bytes, max_file_size, max_duration, max_uploads_per_day, is_default) VALUES
+    ('free', 21474836480, 2147483648, 3600, 10, 1),        -- 20 GiB / 2 GiB / 1時間 / 10本
+    ('standard', 214748364800, 10737418240, 14400, 50, 0), -- 200 GiB / 10 GiB / 4時間 / 50本
+    ('unlimited', NULL, NULL, NULL, NULL, 0);
+
+-- テーブル: user_upload_quotas（管理者が設定したユーザーごとのプランと上限、上限の列が NULL の場合はプランの上限を使う）
+CREATE TABLE user_upload_quotas (
+    user_id INT UNSIGNED NOT NULL PRIMARY KEY,           -- 対象のユーザーID
+    plan_id INT UNSIGNED DEFAULT NULL,                   -- 適用するプラン（NULL の場合は既定のプラン）
+    max_total_bytes BIGINT UNSIGNED DEFAULT NULL,        -- 保存できる動画ファイルの合計サイズ（バイト）
+    max_file_size BIGINT UNSIGNED DEFAULT NULL,          -- 1ファイルの最大サイズ（バイト）
+    max_duration INT UNSIGNED DEFAULT NULL,              -- 1ファイルの最大の再生時間（秒）
+    max_uploads_per_day INT UNSIGNED DEFAULT NULL,       -- 24時間にアップロードできる動画ファイルの数
+    note VARCHAR(255) NOT NULL DEFAULT '',               -- 設定の理由などのメモ
+    updated_by INT UNSIGNED DEFAULT NULL,                -- 最後に設定した管理者のユーザーID
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    modified DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP, -- 更新日時
+    FOREIGN KEY (user_id) REFERENCES users(id),          -- 外部キー制約（usersテーブル）
+    FOREIGN KEY (plan_id) REFERENCES upload_plans(id),   -- 外部キー制約（upload_plansテーブル）
+    FOREIGN KEY (updated_by) REFERENCES users(id)        -- 外部キー制約（usersテーブル）
+);
+
+-- テーブル: upload_reservations（受信を始めたアップロードのために確保した容量と数）
+-- 上限の確認と確保はユーザーの行をロックして行い、同時に始めたアップロードが上限を超えないようにする
+CREATE TABLE upload_reservations (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,                       -- アップロードしているユーザーID
+    size BIGINT UNSIGNED NOT NULL,                       -- 確保したサイズ（バイト）
+    expires DATETIME NOT NULL,                           -- 解放されなかった場合に無効になる日時
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    INDEX idx_upload_reservations_user (user_id, expires), -- ユーザーごとの有効な確保の集計用
+    FOREIGN KEY (user_id) REFERENCES users(id)           -- 外部キー制約（usersテーブル）
+);
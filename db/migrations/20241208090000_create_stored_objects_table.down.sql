@@ -0,0 +1,2 @@
+-- テーブル: stored_objects の削除
+DROP TABLE IF EXISTS stored_objects;
@@ -0,0 +1,12 @@
+-- テーブル: stored_objects（内容の SHA-256 をキーに保存した動画ファイルのオブジェクトと参照数）
+-- 同じ内容の動画ファイルは1つのオブジェクトを共有し、参照がなくなった時点でオブジェクトを削除する
+CREATE TABLE stored_objects (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    sha256 CHAR(64) NOT NULL UNIQUE,                     -- ファイルの内容の SHA-256（16進数）
+    object_key VARCHAR(255) NOT NULL UNIQUE,             -- ストレージのオブジェクトキー（movies/sha256/<SHA-256>.<拡張子>）
+    file_size BIGINT UNSIGNED NOT NULL,                  -- ファイルサイズ（バイト）
+    format VARCHAR(50) NOT NULL,                         -- ファイルの内容から判別した MIME タイプ
+    ref_count INT UNSIGNED NOT NULL DEFAULT 0,           -- オブジェクトを参照している動画ファイルの数
+    created DATETIME DEFAULT CURRENT_TIMESTAMP,          -- 作成日時
+    modified DATETIME DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP -- 更新日時
+);
@@ -0,0 +1,3 @@
+-- テーブル: video_files から元の動画ファイルのパスを削除
+ALTER TABLE video_files
+    DROP COLUMN source_path;
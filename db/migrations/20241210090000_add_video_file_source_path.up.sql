@@ -0,0 +1,4 @@
+-- テーブル: video_files に配信用に書き出す前の元の動画ファイルのパスを追加
+-- 元の動画ファイルの参照を残し、同じ内容の動画ファイルがアップロードされた場合に再利用できるようにする
+ALTER TABLE video_files
+    ADD COLUMN source_path VARCHAR(255) DEFAULT NULL AFTER file_path; -- 配信用に書き出す前の元の動画ファイルのパス（書き出していない場合は NULL）
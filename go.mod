@@ -3,6 +3,7 @@ module live
 go 1.22.0
 
 require (
+	github.com/aws/aws-sdk-go v1.55.5
 	github.com/go-playground/validator/v10 v10.22.0
 	github.com/golang-jwt/jwt/v4 v4.5.0
 	github.com/golang-migrate/migrate/v4 v4.17.1
@@ -12,13 +13,14 @@ require (
 	github.com/minio/minio-go/v7 v7.0.76
 	github.com/sirupsen/logrus v1.9.3
 	golang.org/x/crypto v0.26.0
+	golang.org/x/image v0.19.0
+	golang.org/x/text v0.17.0
 	gorm.io/driver/mysql v1.5.7
 	gorm.io/gorm v1.25.11
 )
 
 require (
 	filippo.io/edwards25519 v1.1.0 // indirect
-	github.com/aws/aws-sdk-go v1.55.5 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
 	github.com/gabriel-vasile/mimetype v1.4.3 // indirect
 	github.com/go-ini/ini v1.67.0 // indirect
@@ -39,5 +41,4 @@ require (
 	go.uber.org/atomic v1.11.0 // indirect
 	golang.org/x/net v0.28.0 // indirect
 	golang.org/x/sys v0.24.0 // indirect
-	golang.org/x/text v0.17.0 // indirect
 )
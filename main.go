@@ -1,15 +1,20 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"live/auth"
 	"live/common"
 	"live/db"
+	"live/notification"
 	"live/videohub"
 	"live/videoupload"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
@@ -45,13 +50,52 @@ func main() {
 
 	auth.RegisterRoutes(r)
 	videoupload.RegisterRoutes(r)
-	videohub.RegisterRoutes(r)
+	videoRouter := videohub.RegisterRoutes(r)
+	videoupload.RegisterVideoRoutes(videoRouter)
+	notification.RegisterRoutes(r)
 
 	r.HandleFunc("/api/v1/health", common.HealthHandler)
 	r.HandleFunc("/api/v1/todo/{id}", common.TodoHandler)
 
-	common.LogTodo(common.INFO, "Starting server on port!: "+port)
-	if err := http.ListenAndServe(":"+port, common.EnableCors(r)); err != nil {
+	// バックグラウンド処理の開始
+	videohub.StartWorkers()
+	videoupload.StartWorkers()
+
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: common.EnableCors(r),
+	}
+	// Shutdown は接続中のリクエストの完了を待つため、SSE の接続を先に終了させる
+	server.RegisterOnShutdown(notification.Shutdown)
+
+	serverErr := make(chan error, 1)
+	go func() {
+		common.LogTodo(common.INFO, "Starting server on port!: "+port)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			serverErr <- err
+		}
+	}()
+
+	// 終了シグナルを受け取ったらリクエストの処理を終えてから停止する
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-quit:
+	case err := <-serverErr:
+		// ポートを使用できない場合などは、リクエストを受け付けないまま動き続けないよう異常終了する
 		common.LogError(fmt.Errorf("Error starting server: %v", err))
+		videoupload.StopWorkers()
+		videohub.StopWorkers()
+		os.Exit(1)
 	}
+
+	common.LogTodo(common.INFO, "Shutting down server...")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		common.LogError(fmt.Errorf("Error shutting down server: %v", err))
+	}
+
+	videoupload.StopWorkers()
+	videohub.StopWorkers()
 }
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"live/common"
+	"live/notification/models"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// 既読にするリクエスト（ids を省略した場合はすべての未読通知を既読にする）
+type MarkReadRequest struct {
+	IDs []uint `json:"ids" validate:"max=500"`
+}
+
+// 通知の一覧（unread=1 で未読のみ、cursor でページング）
+func ListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogNotificationError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+	unreadOnly := r.URL.Query().Get("unread") == "1" || r.URL.Query().Get("unread") == "true"
+
+	page, err := models.ListNotifications(userID, unreadOnly, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, models.ErrInvalidCursor) {
+			http.Error(w, "無効なカーソルです", http.StatusBadRequest)
+			return
+		}
+		common.LogNotificationError(err)
+		http.Error(w, "通知の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// 通知をまとめて既読にする
+func MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogNotificationError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	var req MarkReadRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	updated, err := models.MarkNotificationsRead(userID, req.IDs)
+	if err != nil {
+		common.LogNotificationError(err)
+		http.Error(w, "通知の更新に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	unread, err := models.CountUnreadNotifications(userID)
+	if err != nil {
+		common.LogNotificationError(err)
+		http.Error(w, "通知の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]int64{"Updated": updated, "UnreadCount": unread})
+}
+
+// 通知の種類ごとの受信設定
+func GetPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogNotificationError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	writePreferences(w, userID)
+}
+
+// 通知の種類ごとの受信設定の更新（例: {"comment_reply": false}、省略した種類は変更しない）
+func UpdatePreferences(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogNotificationError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	var settings map[string]bool
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		common.LogNotificationError(err)
+		http.Error(w, "リクエストの解析に失敗しました", http.StatusBadRequest)
+		return
+	}
+	for t := range settings {
+		if !models.IsNotificationType(t) {
+			http.Error(w, "無効な通知の種類です: "+t, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := models.UpdateNotificationPreferences(userID, settings); err != nil {
+		common.LogNotificationError(err)
+		http.Error(w, "受信設定の保存に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writePreferences(w, userID)
+}
+
+func writePreferences(w http.ResponseWriter, userID uint) {
+	settings, err := models.GetNotificationPreferences(userID)
+	if err != nil {
+		common.LogNotificationError(err)
+		http.Error(w, "受信設定の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, settings)
+}
+
+// リクエストボディを解析してバリデーションを実行する
+// 失敗した場合はエラーレスポンスを書き込み false を返す
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		common.LogNotificationError(err)
+		http.Error(w, "リクエストの解析に失敗しました", http.StatusBadRequest)
+		return false
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		common.LogNotificationError(err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Validation failed", "details": err.Error()})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		common.LogNotificationError(err)
+	}
+}
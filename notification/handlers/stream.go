@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"live/common"
+	"live/notification/models"
+	"live/notification/services"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// 接続を維持するためにコメント行を送る間隔
+	streamHeartbeatInterval = 30 * time.Second
+	// 再接続時に送り直す通知の最大数
+	streamReplayLimit = 100
+	// 切断時にブラウザが再接続するまでの時間（ミリ秒）
+	streamRetryMillis = 5000
+)
+
+// 新しい通知を Server-Sent Events で配信する
+// EventSource はヘッダーを設定できないため、トークンはクエリパラメータでも受け付ける
+func StreamNotifications(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogNotificationError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "ストリーミングに対応していません", http.StatusInternalServerError)
+		return
+	}
+
+	// 取りこぼしを防ぐため、未送信分を取得する前に配信を受け付ける
+	notifications, unsubscribe := services.Notifications.Subscribe(userID)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// リバースプロキシでのバッファリングを無効にする
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetryMillis)
+
+	// 再接続の場合は切断中に作成された通知を送る
+	var lastID uint
+	if id, err := strconv.ParseUint(r.Header.Get("Last-Event-ID"), 10, 64); err == nil {
+		lastID = uint(id)
+		missed, err := models.ListNotificationsSince(userID, lastID, streamReplayLimit)
+		if err != nil {
+			common.LogNotificationError(err)
+			return
+		}
+		for _, n := range missed {
+			if err := writeEvent(w, n); err != nil {
+				return
+			}
+			lastID = n.ID
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case n, ok := <-notifications:
+			if !ok {
+				// サーバーの停止
+				return
+			}
+			if n.ID <= lastID {
+				continue
+			}
+			if err := writeEvent(w, n); err != nil {
+				return
+			}
+			lastID = n.ID
+			flusher.Flush()
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": ping\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, n models.Notification) error {
+	data, err := json.Marshal(n)
+	if err != nil {
+		common.LogNotificationError(err)
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: notification\ndata: %s\n\n", n.ID, data)
+	return err
+}
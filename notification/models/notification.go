@@ -0,0 +1,233 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"live/common"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// 登録中のチャンネルが動画を公開した
+	NotificationTypeNewUpload = "new_upload"
+	// 自分のコメントに返信が付いた
+	NotificationTypeCommentReply = "comment_reply"
+	// アップロードした動画の処理が完了した
+	NotificationTypeVideoProcessed = "video_processed"
+)
+
+// 通知の種類の一覧（受信設定の対象）
+var NotificationTypes = []string{
+	NotificationTypeNewUpload,
+	NotificationTypeCommentReply,
+	NotificationTypeVideoProcessed,
+}
+
+// ページングのカーソルが不正な場合のエラー
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+type Notification struct {
+	ID        uint       `gorm:"primary_key"`
+	UserID    uint       `gorm:"not null"`
+	Type      string     `gorm:"type:varchar(50);not null"`
+	ActorID   *uint      `gorm:"default:NULL"`
+	VideoID   *uint      `gorm:"default:NULL"`
+	CommentID *uint      `gorm:"default:NULL"`
+	Message   string     `gorm:"type:varchar(255);not null"`
+	ReadAt    *time.Time `gorm:"default:NULL"`
+	Created   time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+type NotificationPreference struct {
+	UserID   uint      `gorm:"primaryKey"`
+	Type     string    `gorm:"primaryKey;type:varchar(50)"`
+	Enabled  bool      `gorm:"not null"` // false を保存できるよう default は指定しない
+	Modified time.Time `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+}
+
+// カーソル付きの通知一覧
+type NotificationPage struct {
+	Notifications []Notification
+	UnreadCount   int64
+	NextCursor    string // 次のページがない場合は空文字
+}
+
+func IsNotificationType(notificationType string) bool {
+	for _, t := range NotificationTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
+
+// 通知を受け取る設定のユーザーに絞って通知を保存する関数
+// 保存した通知を返す（すべてのユーザーが受信しない設定の場合は空）
+func CreateNotifications(notifications []Notification) ([]Notification, error) {
+	if len(notifications) == 0 {
+		return notifications, nil
+	}
+
+	// 受信しない設定のユーザーを除外する（設定がない場合は受信する）
+	disabled := map[string]bool{}
+	userIDs := make([]uint, 0, len(notifications))
+	for _, n := range notifications {
+		userIDs = append(userIDs, n.UserID)
+	}
+	var preferences []NotificationPreference
+	err := common.DB.Where("user_id IN ? AND enabled = 0", userIDs).Find(&preferences).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range preferences {
+		disabled[preferenceKey(p.UserID, p.Type)] = true
+	}
+
+	now := time.Now().Truncate(time.Second)
+	enabled := make([]Notification, 0, len(notifications))
+	for _, n := range notifications {
+		n.Created = now
+		if !disabled[preferenceKey(n.UserID, n.Type)] {
+			enabled = append(enabled, n)
+		}
+	}
+	if len(enabled) == 0 {
+		return enabled, nil
+	}
+
+	if err := common.DB.CreateInBatches(&enabled, 500).Error; err != nil {
+		return nil, err
+	}
+	return enabled, nil
+}
+
+// 通知を新しい順に取得する関数（unreadOnly が true の場合は未読のみ）
+func ListNotifications(userID uint, unreadOnly bool, cursor string, limit int) (*NotificationPage, error) {
+	query := common.DB.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+
+	if cursor != "" {
+		beforeID, err := decodeCursor(cursor)
+		if err != nil {
+			return nil, err
+		}
+		query = query.Where("id < ?", beforeID)
+	}
+
+	var notifications []Notification
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&notifications).Error; err != nil {
+		return nil, err
+	}
+
+	page := &NotificationPage{}
+	if len(notifications) > limit {
+		notifications = notifications[:limit]
+		page.NextCursor = encodeCursor(notifications[len(notifications)-1].ID)
+	}
+	page.Notifications = notifications
+
+	unread, err := CountUnreadNotifications(userID)
+	if err != nil {
+		return nil, err
+	}
+	page.UnreadCount = unread
+	return page, nil
+}
+
+// 指定したIDより新しい通知を古い順に取得する関数（SSEの再接続時に取りこぼした通知を送るため）
+func ListNotificationsSince(userID, afterID uint, limit int) ([]Notification, error) {
+	var notifications []Notification
+	err := common.DB.Where("user_id = ? AND id > ?", userID, afterID).
+		Order("id").
+		Limit(limit).
+		Find(&notifications).Error
+	if err != nil {
+		return nil, err
+	}
+	return notifications, nil
+}
+
+func CountUnreadNotifications(userID uint) (int64, error) {
+	var count int64
+	err := common.DB.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&count).Error
+	return count, err
+}
+
+// 通知をまとめて既読にする関数（ids が空の場合はすべての未読通知）
+// 既読にした件数を返す
+func MarkNotificationsRead(userID uint, ids []uint) (int64, error) {
+	query := common.DB.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	result := query.Update("read_at", time.Now())
+	return result.RowsAffected, result.Error
+}
+
+// 通知の種類ごとの受信設定を取得する関数（設定がない種類は受信する）
+func GetNotificationPreferences(userID uint) (map[string]bool, error) {
+	settings := make(map[string]bool, len(NotificationTypes))
+	for _, t := range NotificationTypes {
+		settings[t] = true
+	}
+
+	var preferences []NotificationPreference
+	if err := common.DB.Where("user_id = ?", userID).Find(&preferences).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range preferences {
+		if _, ok := settings[p.Type]; ok {
+			settings[p.Type] = p.Enabled
+		}
+	}
+	return settings, nil
+}
+
+// 通知の種類ごとの受信設定を保存する関数（指定しなかった種類は変更しない）
+func UpdateNotificationPreferences(userID uint, settings map[string]bool) error {
+	if len(settings) == 0 {
+		return nil
+	}
+
+	preferences := make([]NotificationPreference, 0, len(settings))
+	for t, enabled := range settings {
+		preferences = append(preferences, NotificationPreference{UserID: userID, Type: t, Enabled: enabled})
+	}
+	return common.DB.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"enabled"}),
+	}).Create(&preferences).Error
+}
+
+// チャンネル登録者のユーザーIDを取得する関数
+func GetSubscriberIDs(channelID uint) ([]uint, error) {
+	var ids []uint
+	err := common.DB.Table("subscriptions").Where("channel_id = ?", channelID).Pluck("subscriber_id", &ids).Error
+	return ids, err
+}
+
+func preferenceKey(userID uint, notificationType string) string {
+	return fmt.Sprintf("%d:%s", userID, notificationType)
+}
+
+func encodeCursor(id uint) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(uint64(id), 10)))
+}
+
+// ページングのカーソルを解析する
+func decodeCursor(cursor string) (uint, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	id, err := strconv.ParseUint(string(raw), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+	return uint(id), nil
+}
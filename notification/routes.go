@@ -0,0 +1,25 @@
+package notification
+
+import (
+	"live/common"
+	"live/notification/handlers"
+	"live/notification/services"
+	"net/http"
+
+	"github.com/gorilla/mux"
+)
+
+func RegisterRoutes(router *mux.Router) {
+	notificationRouter := router.PathPrefix("/api/v1/notifications").Subrouter()
+
+	notificationRouter.Handle("", common.AuthMiddleware(http.HandlerFunc(handlers.ListNotifications))).Methods("GET")
+	notificationRouter.Handle("/read", common.AuthMiddleware(http.HandlerFunc(handlers.MarkNotificationsRead))).Methods("POST")
+	notificationRouter.Handle("/preferences", common.AuthMiddleware(http.HandlerFunc(handlers.GetPreferences))).Methods("GET")
+	notificationRouter.Handle("/preferences", common.AuthMiddleware(http.HandlerFunc(handlers.UpdatePreferences))).Methods("PUT")
+	notificationRouter.Handle("/stream", common.QueryTokenMiddleware(common.AuthMiddleware(http.HandlerFunc(handlers.StreamNotifications)))).Methods("GET")
+}
+
+// Shutdown は SSE の接続をすべて終了させる
+func Shutdown() {
+	services.Notifications.Close()
+}
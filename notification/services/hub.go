@@ -0,0 +1,86 @@
+package services
+
+import (
+	"live/notification/models"
+	"sync"
+)
+
+// 接続ごとに保持する未送信の通知の最大数（超えた分は再接続時に取得させる）
+const subscriberBufferSize = 32
+
+// Hub は接続中のブラウザへ通知を配信する
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan models.Notification]struct{}
+	closed      bool
+}
+
+// アプリケーション全体で共有する通知の配信先
+var Notifications = NewHub()
+
+func NewHub() *Hub {
+	return &Hub{subscribers: map[uint]map[chan models.Notification]struct{}{}}
+}
+
+// Subscribe はユーザーへの通知を受け取るチャネルを登録する
+// 受信を終えたら unsubscribe を呼ぶこと。Hub が閉じられるとチャネルも閉じられる
+func (h *Hub) Subscribe(userID uint) (<-chan models.Notification, func()) {
+	ch := make(chan models.Notification, subscriberBufferSize)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if h.subscribers[userID] == nil {
+		h.subscribers[userID] = map[chan models.Notification]struct{}{}
+	}
+	h.subscribers[userID][ch] = struct{}{}
+
+	unsubscribe := func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		if _, ok := h.subscribers[userID][ch]; !ok {
+			return
+		}
+		delete(h.subscribers[userID], ch)
+		if len(h.subscribers[userID]) == 0 {
+			delete(h.subscribers, userID)
+		}
+		close(ch)
+	}
+	return ch, unsubscribe
+}
+
+// Publish は接続中のユーザーに通知を送る
+// 受信が追いつかない接続には送らない（DBに保存済みのため一覧から取得できる）
+func (h *Hub) Publish(notifications []models.Notification) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, n := range notifications {
+		for ch := range h.subscribers[n.UserID] {
+			select {
+			case ch <- n:
+			default:
+			}
+		}
+	}
+}
+
+// Close はすべての接続のチャネルを閉じ、以降の登録を受け付けない
+// サーバーの停止時に SSE の接続を終了させるために使う
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return
+	}
+	h.closed = true
+	for userID, channels := range h.subscribers {
+		for ch := range channels {
+			close(ch)
+		}
+		delete(h.subscribers, userID)
+	}
+}
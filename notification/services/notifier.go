@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"live/common"
+	"live/notification/models"
+)
+
+// Notify は受信設定を確認して通知を保存し、接続中のブラウザへ送る
+// 通知の失敗で元の処理を失敗させないよう、エラーはログに記録するのみ
+func Notify(notifications []models.Notification) {
+	saved, err := models.CreateNotifications(notifications)
+	if err != nil {
+		common.LogNotificationError(fmt.Errorf("Failed to save notifications: %w", err))
+		return
+	}
+	Notifications.Publish(saved)
+}
+
+// NotifyNewUpload はチャンネル登録者に新しい動画の公開を通知する
+func NotifyNewUpload(channelID, videoID uint, title string) {
+	subscriberIDs, err := models.GetSubscriberIDs(channelID)
+	if err != nil {
+		common.LogNotificationError(fmt.Errorf("Failed to get subscribers of channel %d: %w", channelID, err))
+		return
+	}
+
+	notifications := make([]models.Notification, 0, len(subscriberIDs))
+	for _, subscriberID := range subscriberIDs {
+		notifications = append(notifications, models.Notification{
+			UserID:  subscriberID,
+			Type:    models.NotificationTypeNewUpload,
+			ActorID: &channelID,
+			VideoID: &videoID,
+			Message: fmt.Sprintf("登録中のチャンネルが「%s」を公開しました", truncate(title)),
+		})
+	}
+	Notify(notifications)
+}
+
+// NotifyCommentReply はコメントの投稿者に返信を通知する（自分への返信は通知しない）
+func NotifyCommentReply(recipientID, replierID, videoID, commentID uint) {
+	if recipientID == replierID {
+		return
+	}
+	Notify([]models.Notification{{
+		UserID:    recipientID,
+		Type:      models.NotificationTypeCommentReply,
+		ActorID:   &replierID,
+		VideoID:   &videoID,
+		CommentID: &commentID,
+		Message:   "あなたのコメントに返信がありました",
+	}})
+}
+
+// NotifyVideoProcessed は投稿者に動画の処理結果を通知する
+func NotifyVideoProcessed(userID, videoID uint, title string, succeeded bool) {
+	message := fmt.Sprintf("「%s」の処理が完了しました", truncate(title))
+	if !succeeded {
+		message = fmt.Sprintf("「%s」の処理に失敗しました", truncate(title))
+	}
+	Notify([]models.Notification{{
+		UserID:  userID,
+		Type:    models.NotificationTypeVideoProcessed,
+		VideoID: &videoID,
+		Message: message,
+	}})
+}
+
+// メッセージに含めるタイトルを通知のメッセージ長に収まるよう切り詰める
+func truncate(title string) string {
+	const maxRunes = 100
+	runes := []rune(title)
+	if len(runes) <= maxRunes {
+		return title
+	}
+	return string(runes[:maxRunes]) + "…"
+}
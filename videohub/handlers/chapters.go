@@ -0,0 +1,161 @@
+package handlers
+
+import (
+	"errors"
+	"live/common"
+	"live/videohub/models"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// チャプターの指定
+type ChapterRequest struct {
+	Start uint   `json:"start"` // 開始位置（秒）
+	Title string `json:"title" validate:"required,max=255"`
+}
+
+// チャプターの置き換えリクエスト（空の場合は概要欄から読み取ったチャプターに戻す）
+type ReplaceChaptersRequest struct {
+	Chapters []ChapterRequest `json:"chapters" validate:"max=100,dive"`
+}
+
+// 動画のチャプターの一覧
+func ListChapters(w http.ResponseWriter, r *http.Request) {
+	video, ok := loadViewableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	chapters, err := models.GetVideoChapters(video.ID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "チャプターの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, chapters)
+}
+
+// 動画のチャプターを WebVTT のチャプタートラックとして返す（<track kind="chapters"> 用）
+func GetChaptersTrack(w http.ResponseWriter, r *http.Request) {
+	video, ok := loadViewableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	chapters, err := models.GetVideoChapters(video.ID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "チャプターの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	duration, err := models.GetVideoDuration(video.ID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	markers := make([]common.ChapterMarker, 0, len(chapters))
+	for _, c := range chapters {
+		markers = append(markers, common.ChapterMarker{StartSeconds: c.StartSeconds, Title: c.Title})
+	}
+
+	w.Header().Set("Content-Type", "text/vtt; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte(common.BuildChaptersWebVTT(markers, duration))); err != nil {
+		common.LogVideoHubError(err)
+	}
+}
+
+// 動画のチャプターを置き換える（投稿者のみ）
+func ReplaceChapters(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	videoID, err := parseVideoID(r)
+	if err != nil {
+		http.Error(w, "無効な動画IDです", http.StatusBadRequest)
+		return
+	}
+
+	var req ReplaceChaptersRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	markers := make([]common.ChapterMarker, 0, len(req.Chapters))
+	for _, c := range req.Chapters {
+		markers = append(markers, common.ChapterMarker{StartSeconds: c.Start, Title: c.Title})
+	}
+	markers, err = common.ValidateChapters(markers)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	video, err := models.GetVideoByID(videoID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	// 動画の所有者のみ編集できる
+	if video.UserID != userID {
+		http.Error(w, "この動画を編集する権限がありません", http.StatusForbidden)
+		return
+	}
+
+	chapters, err := models.ReplaceVideoChapters(video, markers)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "チャプターの保存に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, chapters)
+}
+
+// 視聴できる動画を取得する
+// 失敗した場合はエラーレスポンスを書き込み false を返す
+func loadViewableVideo(w http.ResponseWriter, r *http.Request) (*models.Video, bool) {
+	videoID, err := parseVideoID(r)
+	if err != nil {
+		http.Error(w, "無効な動画IDです", http.StatusBadRequest)
+		return nil, false
+	}
+	return loadViewableVideoByID(w, r, videoID)
+}
+
+// ID を指定して視聴できる動画を取得する
+// 失敗した場合はエラーレスポンスを書き込み false を返す
+func loadViewableVideoByID(w http.ResponseWriter, r *http.Request, videoID uint) (*models.Video, bool) {
+	video, err := models.GetVideoByID(videoID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return nil, false
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	userID, loggedIn := common.GetOptionalUserIDFromContext(r.Context())
+	if !video.CanView(userID, loggedIn) {
+		http.Error(w, "動画が見つかりません", http.StatusNotFound)
+		return nil, false
+	}
+	return video, true
+}
@@ -0,0 +1,362 @@
+package handlers
+
+import (
+	"errors"
+	"live/common"
+	notificationServices "live/notification/services"
+	"live/videohub/models"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+type CreateCommentRequest struct {
+	Body     string `json:"body" validate:"required,max=10000"`
+	ParentID *uint  `json:"parent_id"`
+}
+
+type UpdateCommentRequest struct {
+	Body string `json:"body" validate:"required,max=10000"`
+}
+
+type CommentStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=approved hidden"`
+}
+
+// 動画のコメント一覧（sort=newest|top、cursor でページング）
+func ListComments(w http.ResponseWriter, r *http.Request) {
+	video, ok := loadViewableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	sort := r.URL.Query().Get("sort")
+	if sort == "" {
+		sort = models.CommentSortNewest
+	}
+	if sort != models.CommentSortNewest && sort != models.CommentSortTop {
+		http.Error(w, "無効な並び順です", http.StatusBadRequest)
+		return
+	}
+
+	limit, _ := parsePagination(r)
+	page, err := models.ListComments(video.ID, sort, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeCursorListError(w, err, "コメントの取得に失敗しました")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+// コメントへの返信一覧（古い順）
+func ListReplies(w http.ResponseWriter, r *http.Request) {
+	commentID, err := parsePathID(r, "commentID")
+	if err != nil {
+		http.Error(w, "無効なコメントIDです", http.StatusBadRequest)
+		return
+	}
+
+	// 返信先のコメントが視聴できない動画のものであれば見つからないものとして扱う
+	comment, err := models.GetCommentByID(commentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "コメントが見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "コメントの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	if _, ok := loadViewableVideoByID(w, r, comment.VideoID); !ok {
+		return
+	}
+
+	limit, _ := parsePagination(r)
+	page, err := models.ListReplies(commentID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeCursorListError(w, err, "コメントの取得に失敗しました")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func CreateComment(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateCommentRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	video, ok := loadViewableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	comment, err := models.CreateComment(video, userID, req.ParentID, req.Body)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "返信先のコメントが見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "コメントの投稿に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	// 返信が公開された場合は返信先のコメントの投稿者に通知する
+	if comment.ParentID != nil && comment.Status == models.CommentStatusApproved {
+		parent, err := models.GetCommentByID(*comment.ParentID)
+		if err != nil {
+			common.LogVideoHubError(err)
+		} else {
+			go notificationServices.NotifyCommentReply(parent.UserID, userID, video.ID, comment.ID)
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, comment)
+}
+
+// コメントの編集（投稿者のみ、投稿から一定時間内）
+func UpdateComment(w http.ResponseWriter, r *http.Request) {
+	comment, _, userID, ok := loadCommentForAction(w, r)
+	if !ok {
+		return
+	}
+	if comment.UserID != userID {
+		http.Error(w, "このコメントを編集する権限がありません", http.StatusForbidden)
+		return
+	}
+
+	var req UpdateCommentRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	if err := models.UpdateCommentBody(comment, req.Body); err != nil {
+		if errors.Is(err, models.ErrCommentEditWindowExpired) {
+			http.Error(w, "コメントを編集できる期間を過ぎています", http.StatusForbidden)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "コメントの更新に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comment)
+}
+
+// コメントの削除（投稿者または動画の投稿者）
+func DeleteComment(w http.ResponseWriter, r *http.Request) {
+	comment, video, userID, ok := loadCommentForAction(w, r)
+	if !ok {
+		return
+	}
+	if comment.UserID != userID && video.UserID != userID {
+		http.Error(w, "このコメントを削除する権限がありません", http.StatusForbidden)
+		return
+	}
+
+	if err := models.DeleteComment(comment); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "コメントの削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func PinComment(w http.ResponseWriter, r *http.Request) {
+	setCommentPinned(w, r, true)
+}
+
+func UnpinComment(w http.ResponseWriter, r *http.Request) {
+	setCommentPinned(w, r, false)
+}
+
+// コメントの固定・固定解除（動画の投稿者のみ、返信は固定できない）
+func setCommentPinned(w http.ResponseWriter, r *http.Request, pinned bool) {
+	comment, video, userID, ok := loadCommentForAction(w, r)
+	if !ok {
+		return
+	}
+	if video.UserID != userID {
+		http.Error(w, "コメントを固定する権限がありません", http.StatusForbidden)
+		return
+	}
+	if pinned && (comment.ParentID != nil || comment.Status != models.CommentStatusApproved) {
+		http.Error(w, "公開中のコメントのみ固定できます", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.SetCommentPinned(comment, pinned); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "コメントの固定に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comment)
+}
+
+// コメントの承認・非表示（動画の投稿者のみ）
+func ModerateComment(w http.ResponseWriter, r *http.Request) {
+	comment, video, userID, ok := loadCommentForAction(w, r)
+	if !ok {
+		return
+	}
+	if video.UserID != userID {
+		http.Error(w, "コメントを管理する権限がありません", http.StatusForbidden)
+		return
+	}
+
+	var req CommentStatusRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	if err := models.SetCommentStatus(comment, req.Status); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "コメントの更新に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, comment)
+}
+
+// 動画の投稿者向けの承認待ち・非表示コメント一覧（status=pending|hidden）
+func ListModerationComments(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	videoID, err := parseVideoID(r)
+	if err != nil {
+		http.Error(w, "無効な動画IDです", http.StatusBadRequest)
+		return
+	}
+
+	video, err := models.GetVideoByID(videoID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	if video.UserID != userID {
+		http.Error(w, "コメントを管理する権限がありません", http.StatusForbidden)
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.CommentStatusPending
+	}
+	if status != models.CommentStatusPending && status != models.CommentStatusHidden {
+		http.Error(w, "無効なステータスです", http.StatusBadRequest)
+		return
+	}
+
+	limit, _ := parsePagination(r)
+	page, err := models.ListCommentsByStatus(videoID, status, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeCursorListError(w, err, "コメントの取得に失敗しました")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func ToggleCommentLike(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	commentID, err := parsePathID(r, "commentID")
+	if err != nil {
+		http.Error(w, "無効なコメントIDです", http.StatusBadRequest)
+		return
+	}
+
+	liked, likeCount, err := models.ToggleCommentLike(commentID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "コメントが見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "高評価の更新に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"Liked": liked, "LikeCount": likeCount})
+}
+
+// 操作対象のコメントと動画を取得する
+// 取得できなかった場合はエラーレスポンスを書き込み、ok に false を返す
+func loadCommentForAction(w http.ResponseWriter, r *http.Request) (*models.Comment, *models.Video, uint, bool) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return nil, nil, 0, false
+	}
+
+	commentID, err := parsePathID(r, "commentID")
+	if err != nil {
+		http.Error(w, "無効なコメントIDです", http.StatusBadRequest)
+		return nil, nil, 0, false
+	}
+
+	comment, err := models.GetCommentByID(commentID)
+	if err == nil && comment.Deleted != nil {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "コメントが見つかりません", http.StatusNotFound)
+			return nil, nil, 0, false
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "コメントの取得に失敗しました", http.StatusInternalServerError)
+		return nil, nil, 0, false
+	}
+
+	video, err := models.GetVideoByID(comment.VideoID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return nil, nil, 0, false
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return nil, nil, 0, false
+	}
+
+	return comment, video, userID, true
+}
+
+// カーソル付き一覧の取得エラーをレスポンスに書き込む
+func writeCursorListError(w http.ResponseWriter, err error, message string) {
+	if errors.Is(err, models.ErrInvalidCursor) {
+		http.Error(w, "無効なカーソルです", http.StatusBadRequest)
+		return
+	}
+	common.LogVideoHubError(err)
+	http.Error(w, message, http.StatusInternalServerError)
+}
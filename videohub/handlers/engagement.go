@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"live/common"
+	"live/videohub/models"
+	"live/videohub/services"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+type ReactionRequest struct {
+	Reaction string `json:"reaction" validate:"required,oneof=like dislike"`
+}
+
+// 再生を記録する（同じ視聴者の短時間での再生は1回として数える）
+func RecordView(w http.ResponseWriter, r *http.Request) {
+	// 視聴できない動画（非公開・下書き・公開予約・非表示）の再生は記録しない
+	video, ok := loadViewableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	userID, loggedIn := common.GetOptionalUserIDFromContext(r.Context())
+	counted := services.Views.Record(video.ID, userID, loggedIn, common.ClientIP(r))
+
+	writeJSON(w, http.StatusAccepted, map[string]bool{"counted": counted})
+}
+
+// 高評価・低評価を切り替える
+func ToggleReaction(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	// 視聴できない動画（非公開・下書き・公開予約・非表示）は評価できず、存在も明かさない
+	video, ok := loadViewableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	var req ReactionRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	result, err := models.ToggleReaction(video.ID, userID, req.Reaction)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "評価の更新に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// DBにまだ書き込まれていない再生数を加算する
+func addPendingViews(video *models.Video) {
+	video.ViewCount += services.Views.Pending(video.ID)
+}
@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"live/common"
+	"live/videohub/models"
+	"live/videohub/services"
+	"net/http"
+)
+
+type WatchProgressRequest struct {
+	Position *float64 `json:"position" validate:"required,min=0"`  // 再生位置（秒）
+	Duration *float64 `json:"duration" validate:"omitempty,min=0"` // 動画の再生時間（秒）
+}
+
+// プレイヤーから再生位置を記録する
+func SaveWatchProgress(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	// 視聴できない動画（非公開・下書き・公開予約・非表示）の再生位置は記録せず、存在も明かさない
+	video, ok := loadViewableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	var req WatchProgressRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	var duration *uint
+	if req.Duration != nil && *req.Duration > 0 {
+		d := uint(*req.Duration)
+		duration = &d
+	}
+
+	history, err := models.SaveWatchProgress(userID, video.ID, uint(*req.Position), duration)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "再生位置の保存に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, history)
+}
+
+// 視聴履歴の一覧
+func ListWatchHistory(w http.ResponseWriter, r *http.Request) {
+	listWatchHistory(w, r, models.ListWatchHistory)
+}
+
+// 途中まで視聴した動画の一覧
+func ListContinueWatching(w http.ResponseWriter, r *http.Request) {
+	listWatchHistory(w, r, models.ListContinueWatching)
+}
+
+// 視聴履歴から動画を1件削除する
+func DeleteWatchHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	videoID, err := parseVideoID(r)
+	if err != nil {
+		http.Error(w, "無効な動画IDです", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.DeleteWatchHistory(userID, videoID); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "視聴履歴の削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 視聴履歴をすべて削除する
+func ClearWatchHistory(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	if err := models.ClearWatchHistory(userID); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "視聴履歴の削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func listWatchHistory(w http.ResponseWriter, r *http.Request, list func(userID uint, cursor string, limit int) (*models.WatchHistoryPage, error)) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	limit, _ := parsePagination(r)
+	page, err := list(userID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeCursorListError(w, err, "視聴履歴の取得に失敗しました")
+		return
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	for _, item := range page.Items {
+		if item.Video == nil {
+			continue
+		}
+		addPendingViews(item.Video)
+		if err := presignVideoFiles(storageService, item.Video); err != nil {
+			common.LogVideoHubError(err)
+			http.Error(w, "動画URLの取得に失敗しました", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,334 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"live/common"
+	"live/videohub/models"
+	"live/videohub/services"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type CreatePlaylistRequest struct {
+	Title       string `json:"title" validate:"required,max=255"`
+	Description string `json:"description"`
+	Visibility  string `json:"visibility" validate:"omitempty,oneof=public unlisted private"`
+}
+
+// プレイリストの更新リクエスト（省略した項目は変更しない）
+type UpdatePlaylistRequest struct {
+	Title       *string `json:"title" validate:"omitempty,min=1,max=255"`
+	Description *string `json:"description"`
+	Visibility  *string `json:"visibility" validate:"omitempty,oneof=public unlisted private"`
+}
+
+type AddPlaylistEditorRequest struct {
+	UserID uint `json:"user_id" validate:"required"`
+}
+
+func CreatePlaylist(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreatePlaylistRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	if req.Visibility == "" {
+		req.Visibility = models.PlaylistVisibilityPrivate
+	}
+
+	playlist, err := models.CreatePlaylist(userID, req.Title, req.Description, req.Visibility)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "プレイリストの作成に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, playlist)
+}
+
+// ログインユーザーが所有・共同編集しているプレイリストの一覧
+func ListMyPlaylists(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	playlists, err := models.GetEditablePlaylists(userID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "プレイリストの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, playlists)
+}
+
+// ユーザーの公開プレイリストの一覧
+func ListUserPlaylists(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		http.Error(w, "無効なユーザーIDです", http.StatusBadRequest)
+		return
+	}
+
+	playlists, err := models.GetPublicPlaylistsByUser(uint(userID))
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "プレイリストの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, playlists)
+}
+
+// プレイリストと動画（署名付きURL付き）を取得する
+func GetPlaylist(w http.ResponseWriter, r *http.Request) {
+	playlistID, err := parsePathID(r, "id")
+	if err != nil {
+		http.Error(w, "無効なプレイリストIDです", http.StatusBadRequest)
+		return
+	}
+
+	playlist, err := models.GetPlaylistWithItems(playlistID)
+	if err != nil {
+		writePlaylistLookupError(w, err)
+		return
+	}
+
+	userID, loggedIn := common.GetOptionalUserIDFromContext(r.Context())
+	if !playlist.CanView(userID, loggedIn) {
+		// 非公開プレイリストの存在を知られないよう 404 を返す
+		http.Error(w, "プレイリストが見つかりません", http.StatusNotFound)
+		return
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	// 削除済みの動画は除外する
+	items := make([]models.PlaylistItem, 0, len(playlist.Items))
+	for _, item := range playlist.Items {
+		if item.Video == nil {
+			continue
+		}
+		if err := presignVideoFiles(storageService, item.Video); err != nil {
+			common.LogVideoHubError(err)
+			http.Error(w, "動画URLの取得に失敗しました", http.StatusInternalServerError)
+			return
+		}
+		items = append(items, item)
+	}
+	playlist.Items = items
+
+	writeJSON(w, http.StatusOK, playlist)
+}
+
+func UpdatePlaylist(w http.ResponseWriter, r *http.Request) {
+	playlist, userID, ok := loadPlaylistForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	var req UpdatePlaylistRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	title, description, visibility := playlist.Title, playlist.Description, playlist.Visibility
+	if req.Title != nil {
+		title = *req.Title
+	}
+	if req.Description != nil {
+		description = *req.Description
+	}
+	if req.Visibility != nil && *req.Visibility != playlist.Visibility {
+		// 公開範囲の変更は所有者のみ
+		if playlist.UserID != userID {
+			http.Error(w, "公開範囲を変更する権限がありません", http.StatusForbidden)
+			return
+		}
+		visibility = *req.Visibility
+	}
+
+	if err := models.UpdatePlaylist(playlist, title, description, visibility); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "プレイリストの更新に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, playlist)
+}
+
+func DeletePlaylist(w http.ResponseWriter, r *http.Request) {
+	playlist, userID, ok := loadPlaylistForEdit(w, r)
+	if !ok {
+		return
+	}
+	if playlist.UserID != userID {
+		http.Error(w, "プレイリストを削除する権限がありません", http.StatusForbidden)
+		return
+	}
+
+	if err := models.DeletePlaylist(playlist.ID); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "プレイリストの削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func AddPlaylistEditor(w http.ResponseWriter, r *http.Request) {
+	playlist, userID, ok := loadPlaylistForEdit(w, r)
+	if !ok {
+		return
+	}
+	if playlist.UserID != userID {
+		http.Error(w, "共同編集者を追加する権限がありません", http.StatusForbidden)
+		return
+	}
+
+	var req AddPlaylistEditorRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+	if req.UserID == playlist.UserID {
+		http.Error(w, "所有者は共同編集者に追加できません", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.AddPlaylistEditor(playlist.ID, req.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "ユーザーが見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "共同編集者の追加に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 所有者は共同編集者を外すことができ、共同編集者は自分自身を外すことができる
+func RemovePlaylistEditor(w http.ResponseWriter, r *http.Request) {
+	playlist, userID, ok := loadPlaylistForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	editorID, err := parsePathID(r, "userID")
+	if err != nil {
+		http.Error(w, "無効なユーザーIDです", http.StatusBadRequest)
+		return
+	}
+	if playlist.UserID != userID && editorID != userID {
+		http.Error(w, "共同編集者を削除する権限がありません", http.StatusForbidden)
+		return
+	}
+
+	if err := models.RemovePlaylistEditor(playlist.ID, editorID); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "共同編集者の削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ログインユーザーが編集できるプレイリストを取得する
+// 取得できなかった場合はエラーレスポンスを書き込み、ok に false を返す
+func loadPlaylistForEdit(w http.ResponseWriter, r *http.Request) (*models.Playlist, uint, bool) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return nil, 0, false
+	}
+
+	playlistID, err := parsePathID(r, "id")
+	if err != nil {
+		http.Error(w, "無効なプレイリストIDです", http.StatusBadRequest)
+		return nil, 0, false
+	}
+
+	playlist, err := models.GetPlaylistByID(playlistID)
+	if err != nil {
+		writePlaylistLookupError(w, err)
+		return nil, 0, false
+	}
+
+	if !playlist.CanEdit(userID) {
+		if !playlist.CanView(userID, true) {
+			http.Error(w, "プレイリストが見つかりません", http.StatusNotFound)
+			return nil, 0, false
+		}
+		http.Error(w, "プレイリストを編集する権限がありません", http.StatusForbidden)
+		return nil, 0, false
+	}
+
+	return playlist, userID, true
+}
+
+func writePlaylistLookupError(w http.ResponseWriter, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "プレイリストが見つかりません", http.StatusNotFound)
+		return
+	}
+	common.LogVideoHubError(err)
+	http.Error(w, "プレイリストの取得に失敗しました", http.StatusInternalServerError)
+}
+
+// URLパスから数値のIDを取得する関数
+func parsePathID(r *http.Request, name string) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)[name], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id), nil
+}
+
+// リクエストボディを解析してバリデーションを実行する
+// 失敗した場合はエラーレスポンスを書き込み false を返す
+func decodeAndValidate(w http.ResponseWriter, r *http.Request, req interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "リクエストの解析に失敗しました", http.StatusBadRequest)
+		return false
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		common.LogVideoHubError(err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Validation failed", "details": err.Error()})
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		common.LogVideoHubError(err)
+	}
+}
@@ -0,0 +1,108 @@
+package handlers
+
+import (
+	"errors"
+	"live/common"
+	"live/videohub/models"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+type AddPlaylistItemRequest struct {
+	VideoID  uint `json:"video_id" validate:"required"`
+	Position *int `json:"position"` // 省略した場合は末尾に追加
+}
+
+type MovePlaylistItemRequest struct {
+	Position *int `json:"position" validate:"required"`
+}
+
+func AddPlaylistItem(w http.ResponseWriter, r *http.Request) {
+	playlist, userID, ok := loadPlaylistForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	var req AddPlaylistItemRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	if _, err := models.GetVideoByID(req.VideoID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	item, err := models.InsertPlaylistItem(playlist.ID, req.VideoID, userID, req.Position)
+	if err != nil {
+		if errors.Is(err, models.ErrVideoAlreadyInPlaylist) {
+			http.Error(w, "この動画は既にプレイリストに追加されています", http.StatusConflict)
+			return
+		}
+		writePlaylistLookupError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, item)
+}
+
+func MovePlaylistItem(w http.ResponseWriter, r *http.Request) {
+	playlist, _, ok := loadPlaylistForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	itemID, err := parsePathID(r, "itemID")
+	if err != nil {
+		http.Error(w, "無効なアイテムIDです", http.StatusBadRequest)
+		return
+	}
+
+	var req MovePlaylistItemRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	item, err := models.MovePlaylistItem(playlist.ID, itemID, *req.Position)
+	if err != nil {
+		writePlaylistItemError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, item)
+}
+
+func RemovePlaylistItem(w http.ResponseWriter, r *http.Request) {
+	playlist, _, ok := loadPlaylistForEdit(w, r)
+	if !ok {
+		return
+	}
+
+	itemID, err := parsePathID(r, "itemID")
+	if err != nil {
+		http.Error(w, "無効なアイテムIDです", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.RemovePlaylistItem(playlist.ID, itemID); err != nil {
+		writePlaylistItemError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writePlaylistItemError(w http.ResponseWriter, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		http.Error(w, "プレイリストのアイテムが見つかりません", http.StatusNotFound)
+		return
+	}
+	common.LogVideoHubError(err)
+	http.Error(w, "プレイリストの更新に失敗しました", http.StatusInternalServerError)
+}
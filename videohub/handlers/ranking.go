@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"errors"
+	"live/common"
+	"live/videohub/models"
+	"live/videohub/services"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// 集計済みのランキング（category=カテゴリのスラッグで絞り込み）
+// ランキングは定期的に集計されるため、リクエストごとには計算しない
+func ListRankings(w http.ResponseWriter, r *http.Request) {
+	period := mux.Vars(r)["period"]
+	if period != models.RankingPeriodDaily && period != models.RankingPeriodWeekly {
+		http.Error(w, "無効な集計期間です", http.StatusBadRequest)
+		return
+	}
+
+	categoryID := uint(models.RankingAllCategories)
+	if slug := r.URL.Query().Get("category"); slug != "" {
+		category, err := models.GetCategoryBySlug(slug)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				http.Error(w, "カテゴリが見つかりません", http.StatusNotFound)
+				return
+			}
+			common.LogVideoHubError(err)
+			http.Error(w, "カテゴリの取得に失敗しました", http.StatusInternalServerError)
+			return
+		}
+		categoryID = category.ID
+	}
+
+	limit, offset := parsePagination(r)
+	rankings, err := models.GetRankings(period, categoryID, limit, offset)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ランキングの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range rankings {
+		addPendingViews(rankings[i].Video)
+		if err := presignVideoFiles(storageService, rankings[i].Video); err != nil {
+			common.LogVideoHubError(err)
+			http.Error(w, "動画URLの取得に失敗しました", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=60")
+	writeJSON(w, http.StatusOK, rankings)
+}
@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"errors"
+	"live/common"
+	"live/videohub/models"
+	"live/videohub/services"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 関連動画の一覧（strategy=推薦方式の名前、省略時は既定の方式）
+func ListRelatedVideos(w http.ResponseWriter, r *http.Request) {
+	videoID, err := parseVideoID(r)
+	if err != nil {
+		http.Error(w, "無効な動画IDです", http.StatusBadRequest)
+		return
+	}
+
+	video, err := models.GetVideoByID(videoID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	userID, loggedIn := common.GetOptionalUserIDFromContext(r.Context())
+	if !video.CanView(userID, loggedIn) {
+		http.Error(w, "動画が見つかりません", http.StatusNotFound)
+		return
+	}
+
+	strategy := r.URL.Query().Get("strategy")
+	limit, _ := parsePagination(r)
+	videos, err := services.GetRelatedVideos(video, strategy, limit)
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownRelatedStrategy) {
+			http.Error(w, "無効な推薦方式です（"+strings.Join(services.RelatedStrategyNames(), ", ")+"）", http.StatusBadRequest)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "関連動画の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeVideoList(w, r, videos)
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"live/common"
+	"live/videohub/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+type CreateReportRequest struct {
+	TargetType string `json:"target_type" validate:"required,oneof=video comment"`
+	TargetID   uint   `json:"target_id" validate:"required"`
+	Reason     string `json:"reason" validate:"required,oneof=spam harassment hate violence sexual copyright misinformation other"`
+	Details    string `json:"details" validate:"max=2000"`
+}
+
+type ModerationActionRequest struct {
+	TargetType string `json:"target_type" validate:"required,oneof=video comment"`
+	TargetID   uint   `json:"target_id" validate:"required"`
+	Action     string `json:"action" validate:"required,oneof=dismiss hide take_down suspend"`
+	Reason     string `json:"reason" validate:"required,max=2000"`
+}
+
+// 動画・コメントを通報する
+func CreateReport(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	var req CreateReportRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	report, err := models.CreateReport(userID, req.TargetType, req.TargetID, req.Reason, req.Details)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			http.Error(w, "通報対象が見つかりません", http.StatusNotFound)
+		case errors.Is(err, models.ErrAlreadyReported):
+			http.Error(w, "既に通報済みです", http.StatusConflict)
+		default:
+			common.LogVideoHubError(err)
+			http.Error(w, "通報に失敗しました", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, report)
+}
+
+// 対象ごとにまとめた通報の一覧（status=open|resolved|dismissed、target_type=video|comment）
+func ListReportQueue(w http.ResponseWriter, r *http.Request) {
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = models.ReportStatusOpen
+	}
+	if status != models.ReportStatusOpen && status != models.ReportStatusResolved && status != models.ReportStatusDismissed {
+		http.Error(w, "無効なステータスです", http.StatusBadRequest)
+		return
+	}
+
+	targetType := r.URL.Query().Get("target_type")
+	if targetType != "" && targetType != models.ReportTargetVideo && targetType != models.ReportTargetComment {
+		http.Error(w, "無効な通報対象の種類です", http.StatusBadRequest)
+		return
+	}
+
+	limit, offset := parsePagination(r)
+	groups, err := models.ListReportGroups(status, targetType, limit, offset)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "通報の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// 対象への通報と対応履歴
+func GetReportTarget(w http.ResponseWriter, r *http.Request) {
+	targetType := mux.Vars(r)["type"]
+	if targetType != models.ReportTargetVideo && targetType != models.ReportTargetComment {
+		http.Error(w, "無効な通報対象の種類です", http.StatusBadRequest)
+		return
+	}
+	targetID, err := parsePathID(r, "id")
+	if err != nil {
+		http.Error(w, "無効なIDです", http.StatusBadRequest)
+		return
+	}
+
+	reports, err := models.GetReportsForTarget(targetType, targetID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "通報の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	actions, err := models.ListModerationActions(targetType, targetID, maxPageSize, 0)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "対応履歴の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{"Reports": reports, "Actions": actions})
+}
+
+// 通報対象に対応する（却下、非表示、削除、投稿者の利用停止）
+func ApplyModerationAction(w http.ResponseWriter, r *http.Request) {
+	moderatorID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	var req ModerationActionRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	action, err := models.ApplyModerationAction(moderatorID, req.TargetType, req.TargetID, req.Action, req.Reason)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "対応対象が見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "対応に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	common.LogVideoHubInfo("Moderation action applied: " + action.Action + " " + action.TargetType)
+	writeJSON(w, http.StatusCreated, action)
+}
+
+// 対応履歴の一覧
+func ListModerationActions(w http.ResponseWriter, r *http.Request) {
+	limit, offset := parsePagination(r)
+	actions, err := models.ListModerationActions("", 0, limit, offset)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "対応履歴の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, actions)
+}
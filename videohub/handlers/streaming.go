@@ -0,0 +1,433 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"live/common"
+	"live/videohub/models"
+	"live/videohub/services"
+	"math"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// 読み込む HLS のメディアプレイリストの最大サイズ
+	maxMediaPlaylistSize = 1 << 20
+	// レンディションの映像と音声のコーデック（H.264 High Profile Level 4.1 / AAC-LC）
+	renditionVideoCodec = "avc1.640029"
+	renditionAudioCodec = "mp4a.40.2"
+)
+
+// 動画の配信用のURL（レンディションがない場合は含めない）
+type StreamingURLs struct {
+	HLS  string
+	DASH string
+}
+
+// 動画のレンディションから HLS のマスタープレイリストを作成する
+// メディアプレイリストの URI は相対パスにする。投稿者のみ視聴できる動画の場合は、
+// ログイン用のトークンを引き継がず、この動画にだけ使える再生用のトークンをクエリパラメータ playback に含める
+func GetHLSMasterPlaylist(w http.ResponseWriter, r *http.Request) {
+	video, ok := loadViewableVideo(w, r)
+	if !ok {
+		return
+	}
+	renditions, ok := loadRenditions(w, video.ID)
+	if !ok {
+		return
+	}
+
+	query := ""
+	if !video.CanView(0, false) {
+		userID, _ := common.GetOptionalUserIDFromContext(r.Context())
+		query = "?" + url.Values{"playback": {services.NewPlaybackToken(video.ID, userID, time.Now())}}.Encode()
+	}
+
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-INDEPENDENT-SEGMENTS\n")
+	for _, rendition := range renditions {
+		fmt.Fprintf(&b, "#EXT-X-STREAM-INF:BANDWIDTH=%d,CODECS=\"%s\"", rendition.Bandwidth, renditionCodecs(rendition))
+		if rendition.Width != nil && rendition.Height != nil {
+			fmt.Fprintf(&b, ",RESOLUTION=%dx%d", *rendition.Width, *rendition.Height)
+		}
+		if rendition.FrameRate != nil {
+			fmt.Fprintf(&b, ",FRAME-RATE=%.3f", *rendition.FrameRate)
+		}
+		fmt.Fprintf(&b, "\n%d.m3u8%s\n", rendition.ID, query)
+	}
+	writePlaylist(w, "application/vnd.apple.mpegurl", []byte(b.String()))
+}
+
+// レンディションのメディアプレイリストのセグメントの URI を署名付きURLに書き換えて返す
+// 署名付きURLで直接セグメントを取得するため、非公開のバケットでも再生できる
+// 投稿者のみ視聴できる動画は、Authorization ヘッダーかマスタープレイリストの再生用トークンで取得する
+func GetHLSMediaPlaylist(w http.ResponseWriter, r *http.Request) {
+	video, ok := loadPlayableVideo(w, r)
+	if !ok {
+		return
+	}
+	fileID, err := parsePathID(r, "fileID")
+	if err != nil {
+		http.Error(w, "無効な動画ファイルIDです", http.StatusBadRequest)
+		return
+	}
+	renditions, ok := loadRenditions(w, video.ID)
+	if !ok {
+		return
+	}
+
+	var rendition *models.VideoRendition
+	for i := range renditions {
+		if renditions[i].ID == fileID {
+			rendition = &renditions[i]
+			break
+		}
+	}
+	if rendition == nil {
+		http.Error(w, "レンディションが見つかりません", http.StatusNotFound)
+		return
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	data, err := storageService.ReadFile(r.Context(), rendition.FilePath, maxMediaPlaylistSize)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "プレイリストの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	playlist, err := presignMediaPlaylist(storageService, rendition.FilePath, data)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "プレイリストの作成に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	writePlaylist(w, "application/vnd.apple.mpegurl", playlist)
+}
+
+// DASH の MPD（SegmentList で署名付きURLを列挙した静的なマニフェスト）
+type dashMPD struct {
+	XMLName                   xml.Name   `xml:"MPD"`
+	Xmlns                     string     `xml:"xmlns,attr"`
+	Profiles                  string     `xml:"profiles,attr"`
+	Type                      string     `xml:"type,attr"`
+	MediaPresentationDuration string     `xml:"mediaPresentationDuration,attr"`
+	MinBufferTime             string     `xml:"minBufferTime,attr"`
+	Period                    dashPeriod `xml:"Period"`
+}
+
+type dashPeriod struct {
+	AdaptationSet dashAdaptationSet `xml:"AdaptationSet"`
+}
+
+type dashAdaptationSet struct {
+	MimeType         string               `xml:"mimeType,attr"`
+	SegmentAlignment bool                 `xml:"segmentAlignment,attr"`
+	Representations  []dashRepresentation `xml:"Representation"`
+}
+
+type dashRepresentation struct {
+	ID          string          `xml:"id,attr"`
+	Bandwidth   uint            `xml:"bandwidth,attr"`
+	Codecs      string          `xml:"codecs,attr"`
+	Width       *uint           `xml:"width,attr,omitempty"`
+	Height      *uint           `xml:"height,attr,omitempty"`
+	FrameRate   string          `xml:"frameRate,attr,omitempty"`
+	SegmentList dashSegmentList `xml:"SegmentList"`
+}
+
+type dashSegmentList struct {
+	Timescale       uint             `xml:"timescale,attr"`
+	Initialization  *dashURL         `xml:"Initialization,omitempty"`
+	SegmentTimeline []dashTimeline   `xml:"SegmentTimeline>S"`
+	SegmentURLs     []dashSegmentURL `xml:"SegmentURL"`
+}
+
+type dashURL struct {
+	SourceURL string `xml:"sourceURL,attr"`
+}
+
+type dashTimeline struct {
+	Duration uint64 `xml:"d,attr"`
+	Repeat   int    `xml:"r,attr,omitempty"`
+}
+
+type dashSegmentURL struct {
+	Media string `xml:"media,attr"`
+}
+
+// DASH のタイムラインの単位（ミリ秒）
+const dashTimescale = 1000
+
+// HLS と同じセグメント（fMP4）を使う DASH のマニフェストを作成する
+func GetDASHManifest(w http.ResponseWriter, r *http.Request) {
+	video, ok := loadViewableVideo(w, r)
+	if !ok {
+		return
+	}
+	renditions, ok := loadRenditions(w, video.ID)
+	if !ok {
+		return
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	mpd := dashMPD{
+		Xmlns:         "urn:mpeg:dash:schema:mpd:2011",
+		Profiles:      "urn:mpeg:dash:profile:isoff-main:2011",
+		Type:          "static",
+		MinBufferTime: "PT2S",
+		Period: dashPeriod{AdaptationSet: dashAdaptationSet{
+			MimeType:         "video/mp4",
+			SegmentAlignment: true,
+		}},
+	}
+	var duration float64
+	for _, rendition := range renditions {
+		data, err := storageService.ReadFile(r.Context(), rendition.FilePath, maxMediaPlaylistSize)
+		if err != nil {
+			common.LogVideoHubError(err)
+			http.Error(w, "プレイリストの取得に失敗しました", http.StatusInternalServerError)
+			return
+		}
+		representation, total, err := dashRepresentationFromPlaylist(storageService, rendition, data)
+		if err != nil {
+			common.LogVideoHubError(err)
+			http.Error(w, "マニフェストの作成に失敗しました", http.StatusInternalServerError)
+			return
+		}
+		mpd.Period.AdaptationSet.Representations = append(mpd.Period.AdaptationSet.Representations, *representation)
+		duration = math.Max(duration, total)
+	}
+	mpd.MediaPresentationDuration = fmt.Sprintf("PT%.3fS", duration)
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	if err := xml.NewEncoder(&buf).Encode(mpd); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "マニフェストの作成に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	writePlaylist(w, "application/dash+xml", buf.Bytes())
+}
+
+// メディアプレイリストを取得できる動画を取得する
+// 失敗した場合はエラーレスポンスを書き込み false を返す
+func loadPlayableVideo(w http.ResponseWriter, r *http.Request) (*models.Video, bool) {
+	token := r.URL.Query().Get("playback")
+	if _, loggedIn := common.GetOptionalUserIDFromContext(r.Context()); token == "" || loggedIn {
+		return loadViewableVideo(w, r)
+	}
+
+	videoID, err := parseVideoID(r)
+	if err != nil {
+		http.Error(w, "無効な動画IDです", http.StatusBadRequest)
+		return nil, false
+	}
+	userID, ok := services.VerifyPlaybackToken(token, videoID, time.Now())
+	if !ok {
+		http.Error(w, "再生用のトークンが無効です", http.StatusUnauthorized)
+		return nil, false
+	}
+	video, err := models.GetVideoByID(videoID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return nil, false
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return nil, false
+	}
+	// トークンを作成した後に非公開にした場合などに備えて、視聴できるか確認し直す
+	if !video.CanView(userID, userID != 0) {
+		http.Error(w, "動画が見つかりません", http.StatusNotFound)
+		return nil, false
+	}
+	return video, true
+}
+
+// 動画のレンディションを取得する
+// 失敗した場合やレンディションがない場合はエラーレスポンスを書き込み false を返す
+func loadRenditions(w http.ResponseWriter, videoID uint) ([]models.VideoRendition, bool) {
+	renditions, err := models.GetVideoRenditions(videoID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "レンディションの取得に失敗しました", http.StatusInternalServerError)
+		return nil, false
+	}
+	if len(renditions) == 0 {
+		http.Error(w, "配信用の動画がまだ作成されていません", http.StatusNotFound)
+		return nil, false
+	}
+	return renditions, true
+}
+
+// CODECS 属性の値（音声がない場合は映像のみ）
+func renditionCodecs(rendition models.VideoRendition) string {
+	if rendition.AudioCodec == nil {
+		return renditionVideoCodec
+	}
+	return renditionVideoCodec + "," + renditionAudioCodec
+}
+
+// メディアプレイリストの初期化セグメントとセグメントの URI を署名付きURLに書き換える
+// URI はプレイリストからの相対パスとして扱い、既に URL の場合は変更しない
+func presignMediaPlaylist(storageService *services.StorageService, playlistPath string, data []byte) ([]byte, error) {
+	presign := func(uri string) (string, error) {
+		if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+			return uri, nil
+		}
+		return storageService.GetVideoPresignedURL(path.Join(path.Dir(playlistPath), uri))
+	}
+
+	var b strings.Builder
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			uri, ok := playlistAttribute(line, "URI")
+			if ok {
+				signed, err := presign(uri)
+				if err != nil {
+					return nil, err
+				}
+				line = strings.Replace(line, `URI="`+uri+`"`, `URI="`+signed+`"`, 1)
+			}
+		case line != "" && !strings.HasPrefix(line, "#"):
+			signed, err := presign(line)
+			if err != nil {
+				return nil, err
+			}
+			line = signed
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return []byte(b.String()), nil
+}
+
+// メディアプレイリストから DASH の Representation を作成し、再生時間（秒）とともに返す
+func dashRepresentationFromPlaylist(storageService *services.StorageService, rendition models.VideoRendition, data []byte) (*dashRepresentation, float64, error) {
+	representation := &dashRepresentation{
+		ID:          rendition.Rendition,
+		Bandwidth:   rendition.Bandwidth,
+		Codecs:      renditionCodecs(rendition),
+		Width:       rendition.Width,
+		Height:      rendition.Height,
+		SegmentList: dashSegmentList{Timescale: dashTimescale},
+	}
+	if rendition.FrameRate != nil {
+		representation.FrameRate = strconv.FormatFloat(*rendition.FrameRate, 'f', -1, 64)
+	}
+
+	dir := path.Dir(rendition.FilePath)
+	var total float64
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			uri, ok := playlistAttribute(line, "URI")
+			if !ok {
+				continue
+			}
+			url, err := storageService.GetVideoPresignedURL(path.Join(dir, uri))
+			if err != nil {
+				return nil, 0, err
+			}
+			representation.SegmentList.Initialization = &dashURL{SourceURL: url}
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, 0, fmt.Errorf("セグメントの長さを読み取れません: %s", line)
+			}
+			total += seconds
+			d := uint64(math.Round(seconds * dashTimescale))
+
+			// 同じ長さのセグメントが続く場合は r 属性でまとめる
+			timeline := representation.SegmentList.SegmentTimeline
+			if n := len(timeline); n > 0 && timeline[n-1].Duration == d {
+				timeline[n-1].Repeat++
+			} else {
+				representation.SegmentList.SegmentTimeline = append(timeline, dashTimeline{Duration: d})
+			}
+		case line != "" && !strings.HasPrefix(line, "#"):
+			url, err := storageService.GetVideoPresignedURL(path.Join(dir, line))
+			if err != nil {
+				return nil, 0, err
+			}
+			representation.SegmentList.SegmentURLs = append(representation.SegmentList.SegmentURLs, dashSegmentURL{Media: url})
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, err
+	}
+	return representation, total, nil
+}
+
+// タグの属性リスト（#EXT-X-MAP:URI="init.mp4",BYTERANGE="..." など）から値を取得する
+func playlistAttribute(line, name string) (string, bool) {
+	_, attributes, _ := strings.Cut(line, ":")
+	for len(attributes) > 0 {
+		var key, value string
+		key, attributes, _ = strings.Cut(attributes, "=")
+		if strings.HasPrefix(attributes, `"`) {
+			value, attributes, _ = strings.Cut(attributes[1:], `"`)
+			attributes = strings.TrimPrefix(attributes, ",")
+		} else {
+			value, attributes, _ = strings.Cut(attributes, ",")
+		}
+		if strings.TrimSpace(key) == name {
+			return value, true
+		}
+	}
+	return "", false
+}
+
+// プレイリストとマニフェストを返す
+// 署名付きURLや再生用のトークンを含むため、共有のキャッシュには保存させない
+func writePlaylist(w http.ResponseWriter, contentType string, body []byte) {
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "private, no-cache")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(body); err != nil {
+		common.LogVideoHubError(err)
+	}
+}
+
+// 動画の配信用のURLを返す（レンディションがない場合は nil）
+func streamingURLs(videoID uint) (*StreamingURLs, error) {
+	renditions, err := models.GetVideoRenditions(videoID)
+	if err != nil || len(renditions) == 0 {
+		return nil, err
+	}
+	return &StreamingURLs{
+		HLS:  fmt.Sprintf("/api/v1/videos/%d/hls/master.m3u8", videoID),
+		DASH: fmt.Sprintf("/api/v1/videos/%d/dash/manifest.mpd", videoID),
+	}, nil
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"errors"
+	"live/common"
+	"live/videohub/models"
+	"live/videohub/services"
+	"net/http"
+
+	"gorm.io/gorm"
+)
+
+// チャンネルの情報（登録者数、ログイン中であれば登録状況）
+func GetChannel(w http.ResponseWriter, r *http.Request) {
+	channelID, err := parsePathID(r, "userID")
+	if err != nil {
+		http.Error(w, "無効なユーザーIDです", http.StatusBadRequest)
+		return
+	}
+
+	userID, _ := common.GetOptionalUserIDFromContext(r.Context())
+	writeChannel(w, channelID, userID)
+}
+
+func Subscribe(w http.ResponseWriter, r *http.Request) {
+	userID, channelID, ok := parseSubscriptionRequest(w, r)
+	if !ok {
+		return
+	}
+	if userID == channelID {
+		http.Error(w, "自分のチャンネルは登録できません", http.StatusBadRequest)
+		return
+	}
+
+	if err := models.Subscribe(userID, channelID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "チャンネルが見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "チャンネル登録に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeChannel(w, channelID, userID)
+}
+
+func Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	userID, channelID, ok := parseSubscriptionRequest(w, r)
+	if !ok {
+		return
+	}
+
+	if err := models.Unsubscribe(userID, channelID); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "チャンネル登録の解除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeChannel(w, channelID, userID)
+}
+
+// ログインユーザーが登録しているチャンネルの一覧
+func ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	channels, err := models.ListSubscribedChannels(userID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "登録チャンネルの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, channels)
+}
+
+// 登録チャンネルの新着動画（cursor でページング）
+func GetSubscriptionFeed(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	limit, _ := parsePagination(r)
+	page, err := models.GetSubscriptionFeed(userID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeCursorListError(w, err, "新着動画の取得に失敗しました")
+		return
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range page.Videos {
+		addPendingViews(&page.Videos[i])
+		if err := presignVideoFiles(storageService, &page.Videos[i]); err != nil {
+			common.LogVideoHubError(err)
+			http.Error(w, "動画URLの取得に失敗しました", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func parseSubscriptionRequest(w http.ResponseWriter, r *http.Request) (uint, uint, bool) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return 0, 0, false
+	}
+
+	channelID, err := parsePathID(r, "userID")
+	if err != nil {
+		http.Error(w, "無効なユーザーIDです", http.StatusBadRequest)
+		return 0, 0, false
+	}
+	return userID, channelID, true
+}
+
+func writeChannel(w http.ResponseWriter, channelID, userID uint) {
+	channel, err := models.GetChannel(channelID, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "チャンネルが見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "チャンネルの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, channel)
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"live/common"
+	"live/videohub/models"
+	"live/videohub/services"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+func ListVideosByTag(w http.ResponseWriter, r *http.Request) {
+	name := strings.ToLower(strings.TrimSpace(mux.Vars(r)["name"]))
+	limit, offset := parsePagination(r)
+
+	videos, err := models.GetVideosByTag(name, limit, offset)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeVideoList(w, r, videos)
+}
+
+func ListCategories(w http.ResponseWriter, r *http.Request) {
+	categories, err := models.GetAllCategories()
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "カテゴリの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(categories); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "カテゴリのJSON変換に失敗しました", http.StatusInternalServerError)
+		return
+	}
+}
+
+func ListVideosByCategory(w http.ResponseWriter, r *http.Request) {
+	category, err := models.GetCategoryBySlug(mux.Vars(r)["slug"])
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "カテゴリが見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "カテゴリの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	limit, offset := parsePagination(r)
+	videos, err := models.GetVideosByCategory(category.ID, limit, offset)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeVideoList(w, r, videos)
+}
+
+func AutocompleteTags(w http.ResponseWriter, r *http.Request) {
+	prefix := strings.ToLower(strings.TrimLeft(strings.TrimSpace(r.URL.Query().Get("q")), "#"))
+	if prefix == "" {
+		http.Error(w, "検索文字列を指定してください", http.StatusBadRequest)
+		return
+	}
+
+	limit, _ := parsePagination(r)
+	tags, err := models.SearchTags(prefix, limit)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "タグの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeTagList(w, tags)
+}
+
+func PopularTags(w http.ResponseWriter, r *http.Request) {
+	limit, _ := parsePagination(r)
+	tags, err := models.GetPopularTags(limit)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "タグの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeTagList(w, tags)
+}
+
+func writeTagList(w http.ResponseWriter, tags []models.TagUsage) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(tags); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "タグのJSON変換に失敗しました", http.StatusInternalServerError)
+		return
+	}
+}
+
+// 動画の署名付きURLを生成し、タイトルと説明を視聴者の言語に合わせて一覧を返す
+func writeVideoList(w http.ResponseWriter, r *http.Request, videos []models.Video) {
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	for i := range videos {
+		addPendingViews(&videos[i])
+		if err := presignVideoFiles(storageService, &videos[i]); err != nil {
+			common.LogVideoHubError(err)
+			http.Error(w, "動画URLの取得に失敗しました", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	if err := models.LocalizeVideos(videos, common.PreferredLanguages(r)); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の翻訳の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Add("Vary", "Accept-Language")
+	if err := json.NewEncoder(w).Encode(videos); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画のJSON変換に失敗しました", http.StatusInternalServerError)
+		return
+	}
+}
+
+// クエリパラメータ limit, offset を取得する関数
+func parsePagination(r *http.Request) (int, int) {
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit <= 0 {
+		limit = defaultPageSize
+	}
+	if limit > maxPageSize {
+		limit = maxPageSize
+	}
+
+	offset, err := strconv.Atoi(r.URL.Query().Get("offset"))
+	if err != nil || offset < 0 {
+		offset = 0
+	}
+	return limit, offset
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"errors"
+	"live/common"
+	"live/videohub/models"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// 動画の翻訳の保存リクエスト（description を省略した場合は原文の説明を表示する）
+type SaveTranslationRequest struct {
+	Title       string `json:"title" validate:"required,max=255"`
+	Description string `json:"description"`
+}
+
+// 動画の翻訳の一覧
+func ListTranslations(w http.ResponseWriter, r *http.Request) {
+	video, ok := loadViewableVideo(w, r)
+	if !ok {
+		return
+	}
+
+	translations, err := models.GetVideoTranslations(video.ID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の翻訳の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, translations)
+}
+
+// 動画の翻訳を保存する（投稿者のみ）
+func SaveTranslation(w http.ResponseWriter, r *http.Request) {
+	var req SaveTranslationRequest
+	if !decodeAndValidate(w, r, &req) {
+		return
+	}
+
+	video, lang, ok := loadVideoForTranslation(w, r)
+	if !ok {
+		return
+	}
+
+	translation, err := models.SaveVideoTranslation(video.ID, lang, req.Title, req.Description)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の翻訳の保存に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, translation)
+}
+
+// 動画の翻訳を削除する（投稿者のみ）
+func DeleteTranslation(w http.ResponseWriter, r *http.Request) {
+	video, lang, ok := loadVideoForTranslation(w, r)
+	if !ok {
+		return
+	}
+
+	deleted, err := models.DeleteVideoTranslation(video.ID, lang)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の翻訳の削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "動画の翻訳が見つかりません", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 翻訳を編集する動画と言語コードを取得する
+// 失敗した場合はエラーレスポンスを書き込み false を返す
+func loadVideoForTranslation(w http.ResponseWriter, r *http.Request) (*models.Video, string, bool) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return nil, "", false
+	}
+
+	videoID, err := parseVideoID(r)
+	if err != nil {
+		http.Error(w, "無効な動画IDです", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	lang, err := common.NormalizeLanguageTag(mux.Vars(r)["language"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	video, err := models.GetVideoByID(videoID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return nil, "", false
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return nil, "", false
+	}
+
+	// 動画の所有者のみ編集できる
+	if video.UserID != userID {
+		http.Error(w, "この動画を編集する権限がありません", http.StatusForbidden)
+		return nil, "", false
+	}
+	return video, lang, true
+}
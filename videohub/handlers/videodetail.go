@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"live/common"
+	"live/videohub/models"
+	"live/videohub/services"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 動画詳細のレスポンス
+type VideoDetailResponse struct {
+	*models.Video
+	Chapters       []models.VideoChapter
+	Captions       []CaptionTrack
+	Streaming      *StreamingURLs `json:",omitempty"` // HLS / DASH の再生用URL（配信用の動画の作成前は含めない）
+	ResumePosition *uint          `json:",omitempty"` // ログイン中の視聴者が続きから再生する位置（秒）
+}
+
+// 字幕トラック（URL は WebVTT ファイルの署名付きURL）
+type CaptionTrack struct {
+	Language string
+	Label    string
+	URL      string
+}
+
+func GetVideoDetails(w http.ResponseWriter, r *http.Request) {
+	videoID, err := parseVideoID(r)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "無効な動画IDです", http.StatusBadRequest)
+		return
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	video, err := models.GetVideoWithFiles(videoID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	userID, loggedIn := common.GetOptionalUserIDFromContext(r.Context())
+	if !video.CanView(userID, loggedIn) {
+		// 非公開動画の存在を知られないよう 404 を返す
+		http.Error(w, "動画が見つかりません", http.StatusNotFound)
+		return
+	}
+
+	addPendingViews(video)
+
+	// 動画の署名付きURLを生成
+	if err := presignVideoFiles(storageService, video); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画URLの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	// タイトルと説明を視聴者の言語に合わせる
+	if err := models.LocalizeVideo(video, common.PreferredLanguages(r)); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の翻訳の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	response := VideoDetailResponse{Video: video}
+	response.Chapters, err = models.GetVideoChapters(video.ID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "チャプターの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	response.Captions, err = presignCaptions(storageService, video.ID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "字幕の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	response.Streaming, err = streamingURLs(video.ID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "レンディションの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	if loggedIn {
+		response.ResumePosition, err = models.GetResumePosition(userID, video.ID)
+		if err != nil {
+			common.LogVideoHubError(err)
+			http.Error(w, "再生位置の取得に失敗しました", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", video.ETag())
+	w.Header().Add("Vary", "Accept-Language")
+	if video.Translation != "" {
+		w.Header().Set("Content-Language", video.Translation)
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画のJSON変換に失敗しました", http.StatusInternalServerError)
+		return
+	}
+}
+
+// URLパスから動画IDを取得する関数
+func parseVideoID(r *http.Request) (uint, error) {
+	return parsePathID(r, "id")
+}
+
+// 動画ファイルとサムネイルのパスを署名付きURLに置き換える関数
+func presignVideoFiles(storageService *services.StorageService, video *models.Video) error {
+	for i, file := range video.Files {
+		if file.FilePath != "" {
+			url, err := storageService.GetVideoPresignedURL(file.FilePath)
+			if err != nil {
+				return err
+			}
+			video.Files[i].FilePath = url
+		}
+		thumbnailURL, err := presignThumbnailPath(storageService, file.ThumbnailPath)
+		if err != nil {
+			return err
+		}
+		video.Files[i].ThumbnailPath = thumbnailURL
+	}
+	for i, thumbnail := range video.Thumbnails {
+		url, err := storageService.GetVideoPresignedURL(thumbnail.FilePath)
+		if err != nil {
+			return err
+		}
+		video.Thumbnails[i].FilePath = url
+	}
+	return nil
+}
+
+// サムネイルのオブジェクトキーを署名付きURLに置き換える関数
+// 以前はURLをそのまま保存していたため、URLの場合は変更しない
+func presignThumbnailPath(storageService *services.StorageService, path string) (string, error) {
+	if path == "" || strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path, nil
+	}
+	return storageService.GetVideoPresignedURL(path)
+}
+
+// 動画の字幕を署名付きURL付きで取得する関数
+func presignCaptions(storageService *services.StorageService, videoID uint) ([]CaptionTrack, error) {
+	captions, err := models.GetVideoCaptions(videoID)
+	if err != nil {
+		return nil, err
+	}
+
+	tracks := make([]CaptionTrack, 0, len(captions))
+	for _, c := range captions {
+		url, err := storageService.GetVideoPresignedURL(c.FilePath)
+		if err != nil {
+			return nil, err
+		}
+		tracks = append(tracks, CaptionTrack{Language: c.Language, Label: c.Label, URL: url})
+	}
+	return tracks, nil
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"live/common"
+	notificationServices "live/notification/services"
+	"live/videohub/models"
+	"live/videohub/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+// 動画情報の更新リクエスト（省略した項目は変更しない）
+type UpdateVideoRequest struct {
+	Title                   *string    `json:"title" validate:"omitempty,min=1,max=255"`
+	Description             *string    `json:"description"`
+	Language                *string    `json:"language"` // タイトル・説明の言語コード（空文字で解除）
+	Visibility              *string    `json:"visibility" validate:"omitempty,oneof=public unlisted private"`
+	Status                  *string    `json:"status" validate:"omitempty,oneof=draft published"`
+	PublishAt               *time.Time `json:"publish_at"` // 予約公開する日時（RFC 3339）
+	Category                *string    `json:"category"`   // カテゴリのスラッグ（空文字で解除）
+	Tags                    *[]string  `json:"tags"`
+	CommentsRequireApproval *bool      `json:"comments_require_approval"`
+}
+
+func UpdateVideo(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	videoID, err := parseVideoID(r)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "無効な動画IDです", http.StatusBadRequest)
+		return
+	}
+
+	var req UpdateVideoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "リクエストの解析に失敗しました", http.StatusBadRequest)
+		return
+	}
+
+	// バリデーションの実行
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		common.LogVideoHubError(err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "Validation failed", "details": err.Error()})
+		return
+	}
+
+	// 同時編集による上書きを防ぐため If-Match を必須とする
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "If-Match ヘッダーが必要です", http.StatusPreconditionRequired)
+		return
+	}
+
+	video, err := models.GetVideoByID(videoID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	// 動画の所有者のみ編集できる
+	if video.UserID != userID {
+		http.Error(w, "この動画を編集する権限がありません", http.StatusForbidden)
+		return
+	}
+
+	if !matchesETag(ifMatch, video.ETag()) {
+		w.Header().Set("ETag", video.ETag())
+		http.Error(w, "動画は他のユーザーによって更新されています", http.StatusPreconditionFailed)
+		return
+	}
+
+	update := models.VideoUpdate{
+		Title:                   video.Title,
+		Description:             video.Description,
+		Language:                video.Language,
+		Visibility:              video.Visibility,
+		Status:                  video.Status,
+		PublishAt:               video.PublishAt,
+		CategoryID:              video.CategoryID,
+		CommentsRequireApproval: video.CommentsRequireApproval,
+	}
+	if req.Title != nil {
+		update.Title = *req.Title
+	}
+	if req.Description != nil {
+		update.Description = *req.Description
+	}
+	if req.Visibility != nil {
+		update.Visibility = *req.Visibility
+	}
+	if req.PublishAt != nil {
+		if req.Status != nil {
+			http.Error(w, "公開状態と公開日時は同時に指定できません", http.StatusBadRequest)
+			return
+		}
+		if video.Status == models.VideoStatusPublished {
+			http.Error(w, "公開済みの動画は予約公開にできません", http.StatusBadRequest)
+			return
+		}
+		if !req.PublishAt.After(time.Now()) {
+			http.Error(w, "公開日時には未来の日時を指定してください", http.StatusBadRequest)
+			return
+		}
+		publishAt := req.PublishAt.Truncate(time.Second)
+		update.Status = models.VideoStatusScheduled
+		update.PublishAt = &publishAt
+	}
+	if req.Status != nil {
+		update.Status = *req.Status
+		update.PublishAt = nil
+	}
+	if req.Language != nil {
+		update.Language = nil
+		if *req.Language != "" {
+			lang, err := common.NormalizeLanguageTag(*req.Language)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			update.Language = &lang
+		}
+	}
+	if req.CommentsRequireApproval != nil {
+		update.CommentsRequireApproval = *req.CommentsRequireApproval
+	}
+	if req.Category != nil {
+		update.CategoryID = nil
+		if *req.Category != "" {
+			category, err := models.GetCategoryBySlug(*req.Category)
+			if err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					http.Error(w, "無効なカテゴリです", http.StatusBadRequest)
+					return
+				}
+				common.LogVideoHubError(err)
+				http.Error(w, "カテゴリの取得に失敗しました", http.StatusInternalServerError)
+				return
+			}
+			update.CategoryID = &category.ID
+		}
+	}
+	if req.Tags != nil {
+		update.Tags, err = common.NormalizeTags(*req.Tags)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	wasScheduled := video.Status == models.VideoStatusScheduled
+	firstPublish := video.Published == nil && update.Status == models.VideoStatusPublished
+	if err := models.UpdateVideoMetadata(video, update); err != nil {
+		if errors.Is(err, models.ErrVideoModified) {
+			http.Error(w, "動画は他のユーザーによって更新されています", http.StatusPreconditionFailed)
+			return
+		}
+		common.LogVideoHubError(err)
+		http.Error(w, "動画情報の更新に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	// 公開日時が変わった場合は予約公開の待機時間を計算し直す
+	if wasScheduled || update.Status == models.VideoStatusScheduled {
+		services.Publisher.Reschedule()
+	}
+	// 初めて公開した場合はチャンネル登録者に通知する
+	if firstPublish && update.Visibility == models.VideoVisibilityPublic {
+		go notificationServices.NotifyNewUpload(video.UserID, video.ID, video.Title)
+	}
+
+	// 更新後のタグとカテゴリを含めて返す
+	video, err = models.GetVideoByID(videoID)
+	if err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	common.LogVideoHubInfo("Video updated: " + video.ETag())
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", video.ETag())
+	if err := json.NewEncoder(w).Encode(video); err != nil {
+		common.LogVideoHubError(err)
+		http.Error(w, "動画のJSON変換に失敗しました", http.StatusInternalServerError)
+		return
+	}
+}
+
+// If-Match ヘッダーのいずれかのETagが現在のETagと一致するか判定する
+// If-Match は強い比較のため、弱いETag（W/）は一致とみなさない
+func matchesETag(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
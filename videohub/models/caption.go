@@ -0,0 +1,25 @@
+package models
+
+import (
+	"live/common"
+	"time"
+)
+
+type VideoCaption struct {
+	ID       uint      `gorm:"primary_key"`
+	VideoID  uint      `gorm:"not null"`
+	Language string    `gorm:"type:varchar(35);not null"`
+	Label    string    `gorm:"type:varchar(100);not null"`
+	FilePath string    `gorm:"type:varchar(255);not null"`
+	Created  time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	Modified time.Time `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+}
+
+// 動画の字幕を言語コードの順に取得する関数
+func GetVideoCaptions(videoID uint) ([]VideoCaption, error) {
+	captions := []VideoCaption{}
+	if err := common.DB.Where("video_id = ?", videoID).Order("language").Find(&captions).Error; err != nil {
+		return nil, err
+	}
+	return captions, nil
+}
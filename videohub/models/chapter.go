@@ -0,0 +1,84 @@
+package models
+
+import (
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ChapterSourceManual      = "manual"      // 投稿者がAPIで指定したチャプター
+	ChapterSourceDescription = "description" // 概要欄から読み取ったチャプター
+)
+
+type VideoChapter struct {
+	ID           uint      `gorm:"primary_key"`
+	VideoID      uint      `gorm:"not null"`
+	StartSeconds uint      `gorm:"not null"`
+	Title        string    `gorm:"type:varchar(255);not null"`
+	Source       string    `gorm:"type:enum('manual','description');not null"`
+	Created      time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// 動画のチャプターを開始位置の順に取得する関数
+func GetVideoChapters(videoID uint) ([]VideoChapter, error) {
+	chapters := []VideoChapter{}
+	if err := common.DB.Where("video_id = ?", videoID).Order("start_seconds").Find(&chapters).Error; err != nil {
+		return nil, err
+	}
+	return chapters, nil
+}
+
+// 投稿者が指定したチャプターで置き換える関数
+// markers が空の場合は指定したチャプターを削除し、概要欄から読み取ったチャプターに戻す
+func ReplaceVideoChapters(video *Video, markers []common.ChapterMarker) ([]VideoChapter, error) {
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("video_id = ?", video.ID).Delete(&VideoChapter{}).Error; err != nil {
+			return err
+		}
+
+		source := ChapterSourceManual
+		if len(markers) == 0 {
+			source = ChapterSourceDescription
+			markers = common.ParseDescriptionChapters(video.Description)
+		}
+		return createChaptersWithTransaction(tx, video.ID, markers, source)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return GetVideoChapters(video.ID)
+}
+
+// 概要欄から読み取ったチャプターを更新する関数
+// 投稿者がチャプターを指定している場合はそちらを優先し、変更しない
+func syncDescriptionChaptersWithTransaction(tx *gorm.DB, videoID uint, description string) error {
+	var manual int64
+	err := tx.Model(&VideoChapter{}).
+		Where("video_id = ? AND source = ?", videoID, ChapterSourceManual).
+		Count(&manual).Error
+	if err != nil {
+		return err
+	}
+	if manual > 0 {
+		return nil
+	}
+
+	if err := tx.Where("video_id = ?", videoID).Delete(&VideoChapter{}).Error; err != nil {
+		return err
+	}
+	return createChaptersWithTransaction(tx, videoID, common.ParseDescriptionChapters(description), ChapterSourceDescription)
+}
+
+func createChaptersWithTransaction(tx *gorm.DB, videoID uint, markers []common.ChapterMarker, source string) error {
+	if len(markers) == 0 {
+		return nil
+	}
+
+	chapters := make([]VideoChapter, 0, len(markers))
+	for _, m := range markers {
+		chapters = append(chapters, VideoChapter{VideoID: videoID, StartSeconds: m.StartSeconds, Title: m.Title, Source: source})
+	}
+	return tx.Create(&chapters).Error
+}
@@ -0,0 +1,435 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"live/common"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	CommentStatusApproved = "approved"
+	CommentStatusPending  = "pending"
+	CommentStatusHidden   = "hidden"
+
+	CommentSortNewest = "newest"
+	CommentSortTop    = "top"
+
+	// 投稿後にコメントを編集できる期間
+	CommentEditWindow = 15 * time.Minute
+)
+
+var (
+	// 編集可能な期間を過ぎたコメントを編集しようとした場合のエラー
+	ErrCommentEditWindowExpired = errors.New("comment edit window has expired")
+	// ページングのカーソルが不正な場合のエラー
+	ErrInvalidCursor = errors.New("invalid cursor")
+)
+
+// コメントの投稿者（usersテーブルの公開してよい項目のみ）
+type CommentAuthor struct {
+	ID   uint
+	Name string
+}
+
+func (CommentAuthor) TableName() string {
+	return "users"
+}
+
+type Comment struct {
+	ID         uint           `gorm:"primary_key"`
+	VideoID    uint           `gorm:"not null"`
+	UserID     uint           `gorm:"not null"`
+	ParentID   *uint          `gorm:"default:NULL"`
+	Body       string         `gorm:"type:text;not null"`
+	Status     string         `gorm:"type:enum('approved','pending','hidden');default:'approved'"`
+	Pinned     bool           `gorm:"not null;default:0"`
+	LikeCount  uint           `gorm:"not null;default:0"`
+	ReplyCount uint           `gorm:"not null;default:0"`
+	Edited     *time.Time     `gorm:"default:NULL"`
+	Created    time.Time      `gorm:"default:CURRENT_TIMESTAMP"`
+	Modified   time.Time      `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+	Deleted    *time.Time     `gorm:"default:NULL"`
+	Author     *CommentAuthor `gorm:"foreignKey:UserID"`
+}
+
+type CommentLike struct {
+	CommentID uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"primaryKey"`
+	Created   time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// カーソル付きのコメント一覧
+type CommentPage struct {
+	Comments   []Comment
+	NextCursor string // 次のページがない場合は空文字
+}
+
+// コメントを投稿する関数
+// 返信への返信は、返信先のコメントと同じスレッドへの返信として扱う
+func CreateComment(video *Video, userID uint, parentID *uint, body string) (*Comment, error) {
+	comment := Comment{
+		VideoID: video.ID,
+		UserID:  userID,
+		Body:    body,
+		Status:  CommentStatusApproved,
+	}
+	// 承認制の動画では投稿者以外のコメントを承認待ちにする
+	if video.CommentsRequireApproval && userID != video.UserID {
+		comment.Status = CommentStatusPending
+	}
+
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		if parentID != nil {
+			var parent Comment
+			err := tx.Where("video_id = ? AND deleted IS NULL", video.ID).First(&parent, *parentID).Error
+			if err != nil {
+				return err
+			}
+			threadID := parent.ID
+			if parent.ParentID != nil {
+				threadID = *parent.ParentID
+			}
+			comment.ParentID = &threadID
+		}
+
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+
+		if comment.ParentID != nil && comment.Status == CommentStatusApproved {
+			return adjustReplyCount(tx, *comment.ParentID, 1)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return GetCommentByID(comment.ID)
+}
+
+func GetCommentByID(commentID uint) (*Comment, error) {
+	var comment Comment
+	if err := common.DB.Preload("Author").First(&comment, commentID).Error; err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+// 動画のコメント（返信を除く）を取得する関数
+// 最初のページでは固定されたコメントを先頭に含める
+func ListComments(videoID uint, sort, cursor string, limit int) (*CommentPage, error) {
+	values, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	query := common.DB.Preload("Author").
+		Where("video_id = ? AND parent_id IS NULL AND status = ? AND pinned = 0", videoID, CommentStatusApproved).
+		// 返信が残っている削除済みコメントはスレッドを保つため表示する
+		Where("(deleted IS NULL OR reply_count > 0)")
+
+	switch sort {
+	case CommentSortTop:
+		if len(values) == 2 {
+			query = query.Where("(like_count < ? OR (like_count = ? AND id < ?))", values[0], values[0], values[1])
+		} else if len(values) != 0 {
+			return nil, ErrInvalidCursor
+		}
+		query = query.Order("like_count DESC, id DESC")
+	default:
+		if len(values) == 1 {
+			query = query.Where("id < ?", values[0])
+		} else if len(values) != 0 {
+			return nil, ErrInvalidCursor
+		}
+		query = query.Order("id DESC")
+	}
+
+	var comments []Comment
+	if err := query.Limit(limit + 1).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	page := &CommentPage{}
+	if len(comments) > limit {
+		comments = comments[:limit]
+		last := comments[len(comments)-1]
+		if sort == CommentSortTop {
+			page.NextCursor = encodeCursor(uint64(last.LikeCount), uint64(last.ID))
+		} else {
+			page.NextCursor = encodeCursor(uint64(last.ID))
+		}
+	}
+
+	if cursor == "" {
+		var pinned []Comment
+		err := common.DB.Preload("Author").
+			Where("video_id = ? AND parent_id IS NULL AND status = ? AND pinned = 1 AND deleted IS NULL", videoID, CommentStatusApproved).
+			Find(&pinned).Error
+		if err != nil {
+			return nil, err
+		}
+		comments = append(pinned, comments...)
+	}
+
+	page.Comments = maskDeletedComments(comments)
+	return page, nil
+}
+
+// スレッドの返信を古い順に取得する関数
+func ListReplies(parentID uint, cursor string, limit int) (*CommentPage, error) {
+	values, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	query := common.DB.Preload("Author").
+		Where("parent_id = ? AND status = ? AND deleted IS NULL", parentID, CommentStatusApproved)
+	if len(values) == 1 {
+		query = query.Where("id > ?", values[0])
+	} else if len(values) != 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	var comments []Comment
+	if err := query.Order("id").Limit(limit + 1).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	page := &CommentPage{}
+	if len(comments) > limit {
+		comments = comments[:limit]
+		page.NextCursor = encodeCursor(uint64(comments[len(comments)-1].ID))
+	}
+	page.Comments = comments
+	return page, nil
+}
+
+// 動画の投稿者向けに、承認待ちまたは非表示のコメントを新しい順に取得する関数
+func ListCommentsByStatus(videoID uint, status, cursor string, limit int) (*CommentPage, error) {
+	values, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	query := common.DB.Preload("Author").
+		Where("video_id = ? AND status = ? AND deleted IS NULL", videoID, status)
+	if len(values) == 1 {
+		query = query.Where("id < ?", values[0])
+	} else if len(values) != 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	var comments []Comment
+	if err := query.Order("id DESC").Limit(limit + 1).Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	page := &CommentPage{}
+	if len(comments) > limit {
+		comments = comments[:limit]
+		page.NextCursor = encodeCursor(uint64(comments[len(comments)-1].ID))
+	}
+	page.Comments = comments
+	return page, nil
+}
+
+// 編集可能な期間内であればコメント本文を更新する関数
+func UpdateCommentBody(comment *Comment, body string) error {
+	if time.Since(comment.Created) > CommentEditWindow {
+		return ErrCommentEditWindowExpired
+	}
+
+	now := time.Now()
+	err := common.DB.Model(&Comment{}).
+		Where("id = ? AND deleted IS NULL", comment.ID).
+		Updates(map[string]interface{}{"body": body, "edited": now}).Error
+	if err != nil {
+		return err
+	}
+
+	comment.Body = body
+	comment.Edited = &now
+	return nil
+}
+
+// コメントを論理削除する関数
+func DeleteComment(comment *Comment) error {
+	return common.DB.Transaction(func(tx *gorm.DB) error {
+		return deleteCommentWithTransaction(tx, comment)
+	})
+}
+
+func deleteCommentWithTransaction(tx *gorm.DB, comment *Comment) error {
+	now := time.Now()
+	err := tx.Model(&Comment{}).
+		Where("id = ? AND deleted IS NULL", comment.ID).
+		Updates(map[string]interface{}{"deleted": now, "pinned": false}).Error
+	if err != nil {
+		return err
+	}
+	comment.Deleted = &now
+
+	if comment.ParentID != nil && comment.Status == CommentStatusApproved {
+		return adjustReplyCount(tx, *comment.ParentID, -1)
+	}
+	return nil
+}
+
+// コメントを固定・固定解除する関数（固定できるのは動画ごとに1件のみ）
+func SetCommentPinned(comment *Comment, pinned bool) error {
+	return common.DB.Transaction(func(tx *gorm.DB) error {
+		if pinned {
+			err := tx.Model(&Comment{}).
+				Where("video_id = ? AND pinned = 1", comment.VideoID).
+				Update("pinned", false).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&Comment{}).Where("id = ?", comment.ID).Update("pinned", pinned).Error; err != nil {
+			return err
+		}
+		comment.Pinned = pinned
+		return nil
+	})
+}
+
+// コメントのモデレーション状態を変更する関数
+func SetCommentStatus(comment *Comment, status string) error {
+	if comment.Status == status {
+		return nil
+	}
+
+	return common.DB.Transaction(func(tx *gorm.DB) error {
+		return setCommentStatusWithTransaction(tx, comment, status)
+	})
+}
+
+func setCommentStatusWithTransaction(tx *gorm.DB, comment *Comment, status string) error {
+	if comment.Status == status {
+		return nil
+	}
+
+	updates := map[string]interface{}{"status": status}
+	if status != CommentStatusApproved {
+		updates["pinned"] = false
+	}
+	if err := tx.Model(&Comment{}).Where("id = ?", comment.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	// 公開中の返信数を調整
+	if comment.ParentID != nil && comment.Deleted == nil {
+		if status == CommentStatusApproved {
+			if err := adjustReplyCount(tx, *comment.ParentID, 1); err != nil {
+				return err
+			}
+		} else if comment.Status == CommentStatusApproved {
+			if err := adjustReplyCount(tx, *comment.ParentID, -1); err != nil {
+				return err
+			}
+		}
+	}
+
+	comment.Status = status
+	if status != CommentStatusApproved {
+		comment.Pinned = false
+	}
+	return nil
+}
+
+// コメントの高評価を切り替え、評価後の状態と高評価数を返す関数
+func ToggleCommentLike(commentID, userID uint) (bool, uint, error) {
+	var liked bool
+	var comment Comment
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("deleted IS NULL AND status = ?", CommentStatusApproved).
+			First(&comment, commentID).Error
+		if err != nil {
+			return err
+		}
+
+		result := tx.Where("comment_id = ? AND user_id = ?", commentID, userID).Delete(&CommentLike{})
+		if result.Error != nil {
+			return result.Error
+		}
+
+		if result.RowsAffected > 0 {
+			comment.LikeCount = applyDelta(comment.LikeCount, -1)
+		} else {
+			if err := tx.Create(&CommentLike{CommentID: commentID, UserID: userID}).Error; err != nil {
+				return err
+			}
+			comment.LikeCount++
+			liked = true
+		}
+
+		return tx.Model(&Comment{}).Where("id = ?", commentID).Update("like_count", comment.LikeCount).Error
+	})
+	if err != nil {
+		return false, 0, err
+	}
+	return liked, comment.LikeCount, nil
+}
+
+func adjustReplyCount(tx *gorm.DB, parentID uint, delta int) error {
+	expr := gorm.Expr("reply_count + 1")
+	if delta < 0 {
+		expr = gorm.Expr("IF(reply_count > 0, reply_count - 1, 0)")
+	}
+	return tx.Model(&Comment{}).Where("id = ?", parentID).Update("reply_count", expr).Error
+}
+
+// 削除済みコメントの本文と投稿者を表示しないようにする
+func maskDeletedComments(comments []Comment) []Comment {
+	for i := range comments {
+		if comments[i].Deleted != nil {
+			comments[i].Body = ""
+			comments[i].Author = nil
+		}
+	}
+	return comments
+}
+
+// ページングのカーソルを生成する
+func encodeCursor(values ...uint64) string {
+	parts := make([]string, 0, len(values))
+	for _, v := range values {
+		parts = append(parts, strconv.FormatUint(v, 10))
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, ":")))
+}
+
+// ページングのカーソルを解析する（空文字の場合は最初のページ）
+func decodeCursor(cursor string) ([]uint64, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+	}
+
+	parts := strings.Split(string(raw), ":")
+	values := make([]uint64, 0, len(parts))
+	for _, part := range parts {
+		v, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCursor, err)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
@@ -0,0 +1,169 @@
+package models
+
+import (
+	"errors"
+	"live/common"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ReactionLike    = "like"
+	ReactionDislike = "dislike"
+)
+
+type VideoReaction struct {
+	VideoID  uint      `gorm:"primaryKey"`
+	UserID   uint      `gorm:"primaryKey"`
+	Reaction string    `gorm:"type:enum('like','dislike');not null"`
+	Created  time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	Modified time.Time `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+}
+
+// 日別に集計した再生数
+type DailyViewCount struct {
+	VideoID uint
+	Date    time.Time
+	Views   uint64
+}
+
+// 評価の切り替え結果
+type ReactionResult struct {
+	Reaction     string // 評価を取り消した場合は空文字
+	LikeCount    uint
+	DislikeCount uint
+}
+
+// バッファした再生数をまとめて書き込む関数
+// videos.modified はETagに使うため、カウンタの更新では変更しない
+func FlushViewCounts(counts []DailyViewCount) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	totals := map[uint]uint64{}
+	dailyValues := make([]string, 0, len(counts))
+	dailyArgs := make([]interface{}, 0, len(counts)*3)
+	for _, c := range counts {
+		totals[c.VideoID] += c.Views
+		dailyValues = append(dailyValues, "(?, ?, ?)")
+		dailyArgs = append(dailyArgs, c.VideoID, c.Date.Format("2006-01-02"), c.Views)
+	}
+
+	caseSQL := make([]string, 0, len(totals))
+	caseArgs := make([]interface{}, 0, len(totals)*2)
+	videoIDs := make([]uint, 0, len(totals))
+	for videoID, views := range totals {
+		caseSQL = append(caseSQL, "WHEN ? THEN ?")
+		caseArgs = append(caseArgs, videoID, views)
+		videoIDs = append(videoIDs, videoID)
+	}
+
+	return common.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(
+			"INSERT INTO video_daily_views (video_id, view_date, views) VALUES "+strings.Join(dailyValues, ", ")+
+				" ON DUPLICATE KEY UPDATE views = views + VALUES(views)",
+			dailyArgs...,
+		).Error
+		if err != nil {
+			return err
+		}
+
+		args := append(caseArgs, videoIDs)
+		return tx.Exec(
+			"UPDATE videos SET view_count = view_count + CASE id "+strings.Join(caseSQL, " ")+" ELSE 0 END, modified = modified WHERE id IN ?",
+			args...,
+		).Error
+	})
+}
+
+// 高評価・低評価を切り替える関数
+// 同じ評価をもう一度送ると評価を取り消す
+func ToggleReaction(videoID, userID uint, reaction string) (*ReactionResult, error) {
+	result := &ReactionResult{}
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		var video Video
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "like_count", "dislike_count").
+			Where("deleted IS NULL").
+			First(&video, videoID).Error
+		if err != nil {
+			return err
+		}
+
+		var current VideoReaction
+		err = tx.Where("video_id = ? AND user_id = ?", videoID, userID).First(&current).Error
+		hasCurrent := err == nil
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		likeDelta, dislikeDelta := 0, 0
+		switch {
+		case hasCurrent && current.Reaction == reaction:
+			// 同じ評価の場合は取り消し
+			if err := tx.Where("video_id = ? AND user_id = ?", videoID, userID).Delete(&VideoReaction{}).Error; err != nil {
+				return err
+			}
+			likeDelta, dislikeDelta = reactionDelta(reaction, -1)
+		case hasCurrent:
+			if err := tx.Model(&VideoReaction{}).Where("video_id = ? AND user_id = ?", videoID, userID).Update("reaction", reaction).Error; err != nil {
+				return err
+			}
+			likeDelta, dislikeDelta = reactionDelta(reaction, 1)
+			oldLike, oldDislike := reactionDelta(current.Reaction, -1)
+			likeDelta += oldLike
+			dislikeDelta += oldDislike
+			result.Reaction = reaction
+		default:
+			if err := tx.Create(&VideoReaction{VideoID: videoID, UserID: userID, Reaction: reaction}).Error; err != nil {
+				return err
+			}
+			likeDelta, dislikeDelta = reactionDelta(reaction, 1)
+			result.Reaction = reaction
+		}
+
+		result.LikeCount = applyDelta(video.LikeCount, likeDelta)
+		result.DislikeCount = applyDelta(video.DislikeCount, dislikeDelta)
+
+		return tx.Model(&Video{}).Where("id = ?", videoID).Updates(map[string]interface{}{
+			"like_count":    result.LikeCount,
+			"dislike_count": result.DislikeCount,
+			"modified":      gorm.Expr("modified"),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ユーザーの評価を取得する関数（未評価の場合は空文字）
+func GetUserReaction(videoID, userID uint) (string, error) {
+	var reaction VideoReaction
+	err := common.DB.Where("video_id = ? AND user_id = ?", videoID, userID).First(&reaction).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return reaction.Reaction, nil
+}
+
+func reactionDelta(reaction string, sign int) (int, int) {
+	if reaction == ReactionLike {
+		return sign, 0
+	}
+	return 0, sign
+}
+
+func applyDelta(count uint, delta int) uint {
+	if delta < 0 && count < uint(-delta) {
+		return 0
+	}
+	return uint(int(count) + delta)
+}
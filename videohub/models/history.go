@@ -0,0 +1,155 @@
+package models
+
+import (
+	"errors"
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// 残りがこの秒数以下になったら視聴済みとみなす
+	watchCompletedRemaining = 10
+	// 再生時間のこの割合まで再生したら視聴済みとみなす
+	watchCompletedRatio = 0.95
+	// 「続きから見る」に表示する最小の再生位置（秒）
+	continueWatchingMinPosition = 5
+)
+
+type WatchHistory struct {
+	UserID          uint      `gorm:"primaryKey"`
+	VideoID         uint      `gorm:"primaryKey"`
+	PositionSeconds uint      `gorm:"not null;default:0"`
+	DurationSeconds *uint     `gorm:"default:NULL"`
+	Completed       bool      `gorm:"not null;default:0"`
+	Watched         time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	Created         time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	Video           *Video    `gorm:"foreignKey:VideoID"`
+}
+
+func (WatchHistory) TableName() string {
+	return "watch_history"
+}
+
+// カーソル付きの視聴履歴
+type WatchHistoryPage struct {
+	Items      []WatchHistory
+	NextCursor string // 次のページがない場合は空文字
+}
+
+// 再生位置を記録する関数
+// duration が nil の場合は動画ファイルの再生時間を使用する
+func SaveWatchProgress(userID, videoID uint, position uint, duration *uint) (*WatchHistory, error) {
+	if duration == nil {
+		fileDuration, err := GetVideoDuration(videoID)
+		if err != nil {
+			return nil, err
+		}
+		if fileDuration > 0 {
+			duration = &fileDuration
+		}
+	}
+
+	history := WatchHistory{
+		UserID:          userID,
+		VideoID:         videoID,
+		PositionSeconds: position,
+		DurationSeconds: duration,
+		Completed:       isWatchCompleted(position, duration),
+		Watched:         time.Now(),
+	}
+
+	err := common.DB.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"position_seconds", "duration_seconds", "completed", "watched"}),
+	}).Create(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// 続きから再生する位置を取得する関数（履歴がない、または視聴済みの場合は nil）
+func GetResumePosition(userID, videoID uint) (*uint, error) {
+	var history WatchHistory
+	err := common.DB.Where("user_id = ? AND video_id = ?", userID, videoID).First(&history).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if history.Completed {
+		return nil, nil
+	}
+	return &history.PositionSeconds, nil
+}
+
+// 視聴履歴を新しい順に取得する関数
+func ListWatchHistory(userID uint, cursor string, limit int) (*WatchHistoryPage, error) {
+	return listWatchHistory(userID, common.DB.Where("watch_history.user_id = ?", userID), cursor, limit)
+}
+
+// 途中まで視聴した動画を新しい順に取得する関数
+func ListContinueWatching(userID uint, cursor string, limit int) (*WatchHistoryPage, error) {
+	query := common.DB.Where("watch_history.user_id = ? AND watch_history.completed = 0 AND watch_history.position_seconds >= ?",
+		userID, continueWatchingMinPosition)
+	return listWatchHistory(userID, query, cursor, limit)
+}
+
+func DeleteWatchHistory(userID, videoID uint) error {
+	return common.DB.Where("user_id = ? AND video_id = ?", userID, videoID).Delete(&WatchHistory{}).Error
+}
+
+func ClearWatchHistory(userID uint) error {
+	return common.DB.Where("user_id = ?", userID).Delete(&WatchHistory{}).Error
+}
+
+func listWatchHistory(userID uint, query *gorm.DB, cursor string, limit int) (*WatchHistoryPage, error) {
+	values, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 2 {
+		watched := time.Unix(int64(values[0]), 0)
+		query = query.Where("(watch_history.watched < ? OR (watch_history.watched = ? AND watch_history.video_id < ?))",
+			watched, watched, values[1])
+	} else if len(values) != 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	// 削除・非表示になった動画と、視聴後に非公開になった他人の動画は履歴に表示しない
+	var items []WatchHistory
+	err = query.Joins("JOIN videos ON videos.id = watch_history.video_id AND videos.deleted IS NULL AND videos.hidden IS NULL "+
+		"AND (videos.visibility <> ? AND videos.status = ? OR videos.user_id = ?)", VideoVisibilityPrivate, VideoStatusPublished, userID).
+		Preload("Video", func(db *gorm.DB) *gorm.DB {
+			return preloadVideoRelations(db.Where("hidden IS NULL AND (visibility <> ? AND status = ? OR user_id = ?)",
+				VideoVisibilityPrivate, VideoStatusPublished, userID))
+		}).
+		Order("watch_history.watched DESC, watch_history.video_id DESC").
+		Limit(limit + 1).
+		Find(&items).Error
+	if err != nil {
+		return nil, err
+	}
+
+	page := &WatchHistoryPage{}
+	if len(items) > limit {
+		items = items[:limit]
+		last := items[len(items)-1]
+		page.NextCursor = encodeCursor(uint64(last.Watched.Unix()), uint64(last.VideoID))
+	}
+	page.Items = items
+	return page, nil
+}
+
+func isWatchCompleted(position uint, duration *uint) bool {
+	if duration == nil || *duration == 0 {
+		return false
+	}
+	if position+watchCompletedRemaining >= *duration {
+		return true
+	}
+	return float64(position) >= float64(*duration)*watchCompletedRatio
+}
@@ -0,0 +1,319 @@
+package models
+
+import (
+	"errors"
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	PlaylistVisibilityPublic   = "public"
+	PlaylistVisibilityUnlisted = "unlisted"
+	PlaylistVisibilityPrivate  = "private"
+)
+
+// 既にプレイリストに追加済みの動画を追加しようとした場合のエラー
+var ErrVideoAlreadyInPlaylist = errors.New("video is already in the playlist")
+
+type Playlist struct {
+	ID          uint             `gorm:"primary_key"`
+	UserID      uint             `gorm:"not null"`
+	Title       string           `gorm:"type:varchar(255);not null"`
+	Description string           `gorm:"type:text"`
+	Visibility  string           `gorm:"type:enum('public','unlisted','private');default:'private'"`
+	Created     time.Time        `gorm:"default:CURRENT_TIMESTAMP"`
+	Modified    time.Time        `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+	Deleted     *time.Time       `gorm:"default:NULL"`
+	Editors     []PlaylistEditor `gorm:"foreignKey:PlaylistID"`
+	Items       []PlaylistItem   `gorm:"foreignKey:PlaylistID"`
+}
+
+type PlaylistItem struct {
+	ID         uint      `gorm:"primary_key"`
+	PlaylistID uint      `gorm:"not null"`
+	VideoID    uint      `gorm:"not null"`
+	SortOrder  uint      `gorm:"not null"`
+	AddedBy    uint      `gorm:"not null"`
+	Created    time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	Video      *Video    `gorm:"foreignKey:VideoID"`
+}
+
+type PlaylistEditor struct {
+	PlaylistID uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"primaryKey"`
+	Created    time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// 所有者または共同編集者であれば編集できる
+func (p *Playlist) CanEdit(userID uint) bool {
+	if p.UserID == userID {
+		return true
+	}
+	for _, editor := range p.Editors {
+		if editor.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// 非公開のプレイリストは所有者と共同編集者のみ閲覧できる
+func (p *Playlist) CanView(userID uint, loggedIn bool) bool {
+	if p.Visibility != PlaylistVisibilityPrivate {
+		return true
+	}
+	return loggedIn && p.CanEdit(userID)
+}
+
+func CreatePlaylist(userID uint, title, description, visibility string) (*Playlist, error) {
+	playlist := Playlist{
+		UserID:      userID,
+		Title:       title,
+		Description: description,
+		Visibility:  visibility,
+	}
+
+	if err := common.DB.Create(&playlist).Error; err != nil {
+		return nil, err
+	}
+
+	return &playlist, nil
+}
+
+func GetPlaylistByID(playlistID uint) (*Playlist, error) {
+	var playlist Playlist
+	err := common.DB.Preload("Editors").
+		Where("deleted IS NULL").
+		First(&playlist, playlistID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &playlist, nil
+}
+
+// 動画を並び順で含めてプレイリストを取得する関数
+// 削除済み・非公開・非表示の動画は Video が nil になる
+func GetPlaylistWithItems(playlistID uint) (*Playlist, error) {
+	var playlist Playlist
+	err := common.DB.Preload("Editors").
+		Preload("Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("sort_order")
+		}).
+		Preload("Items.Video", "deleted IS NULL AND hidden IS NULL AND visibility <> ? AND status = ?", VideoVisibilityPrivate, VideoStatusPublished).
+		Preload("Items.Video.Files", "deleted IS NULL AND source_file_id IS NULL").
+		Preload("Items.Video.Thumbnails").
+		Preload("Items.Video.Category").
+		Preload("Items.Video.Tags").
+		Where("deleted IS NULL").
+		First(&playlist, playlistID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &playlist, nil
+}
+
+// ユーザーが所有または共同編集しているプレイリストを取得する関数
+func GetEditablePlaylists(userID uint) ([]Playlist, error) {
+	var playlists []Playlist
+	err := common.DB.Preload("Editors").
+		Where("deleted IS NULL").
+		Where("(user_id = ? OR id IN (?))", userID,
+			common.DB.Table("playlist_editors").Select("playlist_id").Where("user_id = ?", userID)).
+		Order("modified DESC, id DESC").
+		Find(&playlists).Error
+	if err != nil {
+		return nil, err
+	}
+	return playlists, nil
+}
+
+// ユーザーの公開プレイリストを取得する関数
+func GetPublicPlaylistsByUser(userID uint) ([]Playlist, error) {
+	var playlists []Playlist
+	err := common.DB.Where("user_id = ? AND visibility = ? AND deleted IS NULL", userID, PlaylistVisibilityPublic).
+		Order("modified DESC, id DESC").
+		Find(&playlists).Error
+	if err != nil {
+		return nil, err
+	}
+	return playlists, nil
+}
+
+func UpdatePlaylist(playlist *Playlist, title, description, visibility string) error {
+	err := common.DB.Model(&Playlist{}).
+		Where("id = ? AND deleted IS NULL", playlist.ID).
+		Updates(map[string]interface{}{
+			"title":       title,
+			"description": description,
+			"visibility":  visibility,
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	playlist.Title = title
+	playlist.Description = description
+	playlist.Visibility = visibility
+	return nil
+}
+
+func DeletePlaylist(playlistID uint) error {
+	return common.DB.Model(&Playlist{}).
+		Where("id = ? AND deleted IS NULL", playlistID).
+		Update("deleted", time.Now()).Error
+}
+
+// 指定した位置に動画を挿入する関数（position が nil の場合は末尾に追加）
+func InsertPlaylistItem(playlistID, videoID, addedBy uint, position *int) (*PlaylistItem, error) {
+	var item PlaylistItem
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		count, err := lockPlaylistItems(tx, playlistID)
+		if err != nil {
+			return err
+		}
+
+		var exists int64
+		if err := tx.Model(&PlaylistItem{}).Where("playlist_id = ? AND video_id = ?", playlistID, videoID).Count(&exists).Error; err != nil {
+			return err
+		}
+		if exists > 0 {
+			return ErrVideoAlreadyInPlaylist
+		}
+
+		pos := int(count)
+		if position != nil {
+			pos = clampPosition(*position, int(count))
+		}
+
+		// 挿入位置以降の動画を後ろにずらす
+		err = tx.Model(&PlaylistItem{}).
+			Where("playlist_id = ? AND sort_order >= ?", playlistID, pos).
+			Update("sort_order", gorm.Expr("sort_order + 1")).Error
+		if err != nil {
+			return err
+		}
+
+		item = PlaylistItem{
+			PlaylistID: playlistID,
+			VideoID:    videoID,
+			SortOrder:  uint(pos),
+			AddedBy:    addedBy,
+		}
+		return tx.Create(&item).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// 動画の並び順を変更する関数
+func MovePlaylistItem(playlistID, itemID uint, position int) (*PlaylistItem, error) {
+	var item PlaylistItem
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		count, err := lockPlaylistItems(tx, playlistID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.Where("playlist_id = ?", playlistID).First(&item, itemID).Error; err != nil {
+			return err
+		}
+
+		from := int(item.SortOrder)
+		to := clampPosition(position, int(count)-1)
+		if from == to {
+			return nil
+		}
+
+		// 移動元と移動先の間にある動画を1つずつずらす
+		query := tx.Model(&PlaylistItem{}).Where("playlist_id = ? AND id <> ?", playlistID, itemID)
+		if to < from {
+			err = query.Where("sort_order >= ? AND sort_order < ?", to, from).
+				Update("sort_order", gorm.Expr("sort_order + 1")).Error
+		} else {
+			err = query.Where("sort_order > ? AND sort_order <= ?", from, to).
+				Update("sort_order", gorm.Expr("sort_order - 1")).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		item.SortOrder = uint(to)
+		return tx.Model(&item).Update("sort_order", item.SortOrder).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// プレイリストから動画を削除し、後続の並び順を詰める関数
+func RemovePlaylistItem(playlistID, itemID uint) error {
+	return common.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockPlaylistItems(tx, playlistID); err != nil {
+			return err
+		}
+
+		var item PlaylistItem
+		if err := tx.Where("playlist_id = ?", playlistID).First(&item, itemID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Delete(&item).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&PlaylistItem{}).
+			Where("playlist_id = ? AND sort_order > ?", playlistID, item.SortOrder).
+			Update("sort_order", gorm.Expr("sort_order - 1")).Error
+	})
+}
+
+func AddPlaylistEditor(playlistID, userID uint) error {
+	var count int64
+	if err := common.DB.Table("users").Where("id = ? AND deleted_at IS NULL", userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	editor := PlaylistEditor{PlaylistID: playlistID, UserID: userID}
+	return common.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&editor).Error
+}
+
+func RemovePlaylistEditor(playlistID, userID uint) error {
+	return common.DB.Where("playlist_id = ? AND user_id = ?", playlistID, userID).
+		Delete(&PlaylistEditor{}).Error
+}
+
+// 共同編集者による同時更新で並び順が崩れないよう、プレイリストの行をロックして動画数を返す
+func lockPlaylistItems(tx *gorm.DB, playlistID uint) (int64, error) {
+	var playlist Playlist
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("deleted IS NULL").
+		First(&playlist, playlistID).Error
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	if err := tx.Model(&PlaylistItem{}).Where("playlist_id = ?", playlistID).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+func clampPosition(position, max int) int {
+	if position < 0 {
+		return 0
+	}
+	if position > max {
+		return max
+	}
+	return position
+}
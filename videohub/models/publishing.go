@@ -0,0 +1,92 @@
+package models
+
+import (
+	"errors"
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 予約公開した動画
+type PublishedVideo struct {
+	ID         uint
+	UserID     uint
+	Title      string
+	Visibility string
+	FirstTime  bool // 初めての公開（一度公開して下書きに戻した動画は false）
+}
+
+// 公開日時を過ぎた予約公開の動画を公開する関数
+// 公開日時を公開した日時として記録し、公開した動画を返す
+func PublishDueVideos(now time.Time, limit int) ([]PublishedVideo, error) {
+	var published []PublishedVideo
+
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		var videos []Video
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Select("id", "user_id", "title", "visibility", "publish_at", "published").
+			Where("status = ? AND publish_at <= ? AND deleted IS NULL", VideoStatusScheduled, now).
+			Order("publish_at, id").
+			Limit(limit).
+			Find(&videos).Error
+		if err != nil {
+			return err
+		}
+
+		for _, v := range videos {
+			err := tx.Model(&Video{}).Where("id = ?", v.ID).Updates(map[string]interface{}{
+				"status":     VideoStatusPublished,
+				"publish_at": nil,
+				"published":  gorm.Expr("COALESCE(published, ?)", v.PublishAt),
+			}).Error
+			if err != nil {
+				return err
+			}
+			published = append(published, PublishedVideo{
+				ID:         v.ID,
+				UserID:     v.UserID,
+				Title:      v.Title,
+				Visibility: v.Visibility,
+				FirstTime:  v.Published == nil,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return published, nil
+}
+
+// 次に予約公開する日時を取得する関数（予約公開の動画がない場合は nil）
+func GetNextPublishAt() (*time.Time, error) {
+	var video Video
+	err := common.DB.Select("id", "publish_at").
+		Where("status = ? AND deleted IS NULL", VideoStatusScheduled).
+		Order("publish_at").
+		Take(&video).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return video.PublishAt, nil
+}
+
+// 投稿者の動画を下書き・予約公開を含めて新しい順に取得する関数
+func GetVideosByUser(userID uint, limit, offset int) ([]Video, error) {
+	var videos []Video
+	err := preloadVideoRelations(common.DB).
+		Where("videos.user_id = ? AND videos.deleted IS NULL", userID).
+		Order("videos.created DESC, videos.id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&videos).Error
+	if err != nil {
+		return nil, err
+	}
+	return videos, nil
+}
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	RankingPeriodDaily  = "daily"
+	RankingPeriodWeekly = "weekly"
+	// 全カテゴリのランキングに使うカテゴリID
+	RankingAllCategories = 0
+)
+
+type VideoRanking struct {
+	Period     string    `gorm:"primaryKey;type:enum('daily','weekly')"`
+	CategoryID uint      `gorm:"primaryKey"`
+	RankOrder  uint      `gorm:"primaryKey"`
+	VideoID    uint      `gorm:"not null"`
+	Score      float64   `gorm:"not null;default:0"`
+	Computed   time.Time `gorm:"not null"`
+	Video      *Video    `gorm:"foreignKey:VideoID"`
+}
+
+// 動画ごと・日ごとの再生数、高評価数、コメント数
+type DailyActivity struct {
+	VideoID  uint
+	Date     time.Time
+	Views    uint64
+	Likes    uint64
+	Comments uint64
+}
+
+// ランキングの集計対象になる動画
+type RankableVideo struct {
+	ID         uint
+	CategoryID *uint
+}
+
+// since 以降の再生数、高評価数、コメント数を動画ごと・日ごとに集計する関数
+func GetDailyActivity(since time.Time) ([]DailyActivity, error) {
+	type row struct {
+		VideoID uint
+		Date    time.Time
+		Count   uint64
+	}
+	sinceDate := since.Format("2006-01-02")
+
+	var views []row
+	err := common.DB.Table("video_daily_views").
+		Select("video_id, view_date AS date, views AS count").
+		Where("view_date >= ?", sinceDate).
+		Scan(&views).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var likes []row
+	err = common.DB.Table("video_reactions").
+		Select("video_id, DATE(created) AS date, COUNT(*) AS count").
+		Where("reaction = ? AND created >= ?", ReactionLike, sinceDate).
+		Group("video_id, DATE(created)").
+		Scan(&likes).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var comments []row
+	err = common.DB.Table("comments").
+		Select("video_id, DATE(created) AS date, COUNT(*) AS count").
+		Where("status = ? AND deleted IS NULL AND created >= ?", CommentStatusApproved, sinceDate).
+		Group("video_id, DATE(created)").
+		Scan(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	type activityKey struct {
+		VideoID uint
+		Date    string
+	}
+	activities := []DailyActivity{}
+	index := map[activityKey]int{}
+	entry := func(r row) *DailyActivity {
+		key := activityKey{VideoID: r.VideoID, Date: r.Date.Format("2006-01-02")}
+		i, ok := index[key]
+		if !ok {
+			i = len(activities)
+			index[key] = i
+			activities = append(activities, DailyActivity{VideoID: r.VideoID, Date: r.Date})
+		}
+		return &activities[i]
+	}
+	for _, r := range views {
+		entry(r).Views += r.Count
+	}
+	for _, r := range likes {
+		entry(r).Likes += r.Count
+	}
+	for _, r := range comments {
+		entry(r).Comments += r.Count
+	}
+	return activities, nil
+}
+
+// ランキングに載せられる動画（公開中で削除されていない動画）とカテゴリを取得する関数
+func GetRankableVideos(videoIDs []uint) ([]RankableVideo, error) {
+	var videos []RankableVideo
+	if len(videoIDs) == 0 {
+		return videos, nil
+	}
+	err := common.DB.Table("videos").
+		Select("id, category_id").
+		Scopes(publicVideos).
+		Where("videos.id IN ?", videoIDs).
+		Scan(&videos).Error
+	if err != nil {
+		return nil, err
+	}
+	return videos, nil
+}
+
+// 集計期間のランキングをまとめて置き換える関数
+func ReplaceRankings(period string, rankings []VideoRanking) error {
+	return common.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("period = ?", period).Delete(&VideoRanking{}).Error; err != nil {
+			return err
+		}
+		if len(rankings) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(rankings, 500).Error
+	})
+}
+
+// 集計済みのランキングを順位順に取得する関数（集計後に削除・非公開にされた動画は除く）
+func GetRankings(period string, categoryID uint, limit, offset int) ([]VideoRanking, error) {
+	var rankings []VideoRanking
+	err := common.DB.
+		Joins("JOIN videos ON videos.id = video_rankings.video_id").
+		Scopes(publicVideos).
+		Preload("Video", func(db *gorm.DB) *gorm.DB {
+			return preloadVideoRelations(db)
+		}).
+		Where("video_rankings.period = ? AND video_rankings.category_id = ?", period, categoryID).
+		Order("video_rankings.rank_order").
+		Limit(limit).
+		Offset(offset).
+		Find(&rankings).Error
+	if err != nil {
+		return nil, err
+	}
+	return rankings, nil
+}
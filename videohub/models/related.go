@@ -0,0 +1,97 @@
+package models
+
+import (
+	"live/common"
+)
+
+// 関連動画の候補とスコア
+type RelatedCandidate struct {
+	VideoID uint
+	Score   float64
+}
+
+// 共通のタグが多い動画を候補として取得する関数（スコアは共通のタグ数）
+func GetSharedTagCandidates(videoID uint, limit int) ([]RelatedCandidate, error) {
+	var candidates []RelatedCandidate
+	err := common.DB.Table("video_tags AS source").
+		Select("videos.id AS video_id, COUNT(*) AS score").
+		Joins("JOIN video_tags ON video_tags.tag_id = source.tag_id AND video_tags.video_id <> source.video_id").
+		Joins("JOIN videos ON videos.id = video_tags.video_id").
+		Scopes(publicVideos).
+		Where("source.video_id = ?", videoID).
+		Group("videos.id").
+		Order("score DESC, videos.id DESC").
+		Limit(limit).
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// 同じ投稿者の動画を新しい順に候補として取得する関数（スコアは新しいほど高い）
+func GetSameUploaderCandidates(videoID, userID uint, limit int) ([]RelatedCandidate, error) {
+	var videoIDs []uint
+	err := common.DB.Model(&Video{}).
+		Scopes(publicVideos).
+		Where("videos.user_id = ? AND videos.id <> ?", userID, videoID).
+		Order("videos.created DESC, videos.id DESC").
+		Limit(limit).
+		Pluck("videos.id", &videoIDs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]RelatedCandidate, 0, len(videoIDs))
+	for i, id := range videoIDs {
+		candidates = append(candidates, RelatedCandidate{VideoID: id, Score: float64(len(videoIDs) - i)})
+	}
+	return candidates, nil
+}
+
+// この動画を視聴したユーザーが他に視聴した動画を候補として取得する関数（スコアは共通の視聴者数）
+func GetCoWatchedCandidates(videoID uint, limit int) ([]RelatedCandidate, error) {
+	var candidates []RelatedCandidate
+	err := common.DB.Table("watch_history AS source").
+		Select("videos.id AS video_id, COUNT(*) AS score").
+		Joins("JOIN watch_history ON watch_history.user_id = source.user_id AND watch_history.video_id <> source.video_id").
+		Joins("JOIN videos ON videos.id = watch_history.video_id").
+		Scopes(publicVideos).
+		Where("source.video_id = ?", videoID).
+		Group("videos.id").
+		Order("score DESC, videos.id DESC").
+		Limit(limit).
+		Scan(&candidates).Error
+	if err != nil {
+		return nil, err
+	}
+	return candidates, nil
+}
+
+// 指定した順番で公開中の動画を取得する関数（公開中でない動画は除く）
+func GetPublicVideosByIDs(videoIDs []uint) ([]Video, error) {
+	videos := []Video{}
+	if len(videoIDs) == 0 {
+		return videos, nil
+	}
+
+	var found []Video
+	err := preloadVideoRelations(common.DB).
+		Scopes(publicVideos).
+		Where("videos.id IN ?", videoIDs).
+		Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]Video, len(found))
+	for _, video := range found {
+		byID[video.ID] = video
+	}
+	for _, id := range videoIDs {
+		if video, ok := byID[id]; ok {
+			videos = append(videos, video)
+		}
+	}
+	return videos, nil
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"live/common"
+)
+
+// HLS / DASH で配信するレンディション（画質ごとに変換した動画ファイル）
+// FilePath は HLS のメディアプレイリストのパス
+type VideoRendition struct {
+	ID           uint     `gorm:"primary_key"`
+	VideoID      uint     `gorm:"not null"`
+	SourceFileID uint     `gorm:"not null"`
+	Rendition    string   `gorm:"type:varchar(20)"`
+	Bandwidth    uint     // セグメントの最大のビットレート（bps）
+	FilePath     string   `gorm:"type:varchar(255);not null"`
+	Duration     uint     `gorm:"type:int"`
+	Width        *uint    `gorm:"default:NULL"`
+	Height       *uint    `gorm:"default:NULL"`
+	FrameRate    *float64 `gorm:"type:decimal(8,3);default:NULL"`
+	AudioCodec   *string  `gorm:"type:varchar(50);default:NULL"`
+}
+
+func (VideoRendition) TableName() string {
+	return "video_files"
+}
+
+// 動画の最新の動画ファイルのレンディションを高画質の順に取得する関数
+func GetVideoRenditions(videoID uint) ([]VideoRendition, error) {
+	renditions := []VideoRendition{}
+	err := common.DB.
+		Where("video_id = ? AND source_file_id IS NOT NULL AND deleted IS NULL", videoID).
+		Where("source_file_id IN (SELECT id FROM video_files WHERE video_id = ? AND deleted IS NULL)", videoID).
+		Order("source_file_id DESC, bandwidth DESC").
+		Find(&renditions).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 動画ファイルが複数ある場合は最新の動画ファイルのレンディションのみを使う
+	for i, r := range renditions {
+		if r.SourceFileID != renditions[0].SourceFileID {
+			return renditions[:i], nil
+		}
+	}
+	return renditions, nil
+}
@@ -0,0 +1,307 @@
+package models
+
+import (
+	"errors"
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ReportTargetVideo   = "video"
+	ReportTargetComment = "comment"
+	ReportTargetUser    = "user"
+
+	ReportStatusOpen      = "open"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+
+	// 通報を却下する
+	ModerationActionDismiss = "dismiss"
+	// 投稿者以外から見えないようにする
+	ModerationActionHide = "hide"
+	// 削除する
+	ModerationActionTakeDown = "take_down"
+	// 投稿者を利用停止にする
+	ModerationActionSuspend = "suspend"
+)
+
+// 同じ対象を未対応のまま再度通報した場合のエラー
+var ErrAlreadyReported = errors.New("target has already been reported by the user")
+
+type Report struct {
+	ID         uint       `gorm:"primary_key"`
+	ReporterID uint       `gorm:"not null"`
+	TargetType string     `gorm:"type:enum('video','comment');not null"`
+	TargetID   uint       `gorm:"not null"`
+	Reason     string     `gorm:"type:enum('spam','harassment','hate','violence','sexual','copyright','misinformation','other');not null"`
+	Details    string     `gorm:"type:text"`
+	Status     string     `gorm:"type:enum('open','resolved','dismissed');default:'open'"`
+	ResolvedBy *uint      `gorm:"default:NULL"`
+	ResolvedAt *time.Time `gorm:"default:NULL"`
+	Created    time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+type ModerationAction struct {
+	ID          uint      `gorm:"primary_key"`
+	ModeratorID uint      `gorm:"not null"`
+	TargetType  string    `gorm:"type:enum('video','comment','user');not null"`
+	TargetID    uint      `gorm:"not null"`
+	Action      string    `gorm:"type:enum('dismiss','hide','take_down','suspend');not null"`
+	Reason      string    `gorm:"type:text;not null"`
+	Created     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// 対象ごとにまとめた通報
+type ReportGroup struct {
+	TargetType    string
+	TargetID      uint
+	ReportCount   int64
+	Reasons       map[string]int64 `gorm:"-"` // 通報理由ごとの件数
+	FirstReported time.Time
+	LastReported  time.Time
+	OwnerID       *uint  `gorm:"-"` // 動画の投稿者・コメントの投稿者（対象が見つからない場合は nil）
+	Summary       string `gorm:"-"` // 動画のタイトル・コメントの本文
+}
+
+// 通報を作成する関数
+// 対象が存在しない場合は gorm.ErrRecordNotFound、未対応の通報が既にある場合は ErrAlreadyReported を返す
+func CreateReport(reporterID uint, targetType string, targetID uint, reason, details string) (*Report, error) {
+	report := Report{
+		ReporterID: reporterID,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+		Details:    details,
+		Status:     ReportStatusOpen,
+	}
+
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		if _, err := lockReportTarget(tx, targetType, targetID); err != nil {
+			return err
+		}
+
+		var count int64
+		err := tx.Model(&Report{}).
+			Where("reporter_id = ? AND target_type = ? AND target_id = ? AND status = ?", reporterID, targetType, targetID, ReportStatusOpen).
+			Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAlreadyReported
+		}
+
+		return tx.Create(&report).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// 通報を対象ごとにまとめ、通報数の多い順に取得する関数
+// targetType が空の場合はすべての種類を対象にする
+func ListReportGroups(status, targetType string, limit, offset int) ([]ReportGroup, error) {
+	query := common.DB.Model(&Report{}).Where("status = ?", status)
+	if targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+
+	groups := []ReportGroup{}
+	err := query.Select("target_type, target_id, COUNT(*) AS report_count, MIN(created) AS first_reported, MAX(created) AS last_reported").
+		Group("target_type, target_id").
+		Order("report_count DESC, last_reported DESC").
+		Limit(limit).
+		Offset(offset).
+		Scan(&groups).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(groups) == 0 {
+		return groups, nil
+	}
+
+	index := map[string]map[uint]*ReportGroup{ReportTargetVideo: {}, ReportTargetComment: {}}
+	for i := range groups {
+		groups[i].Reasons = map[string]int64{}
+		index[groups[i].TargetType][groups[i].TargetID] = &groups[i]
+	}
+
+	// 通報理由ごとの件数
+	for targetType, byID := range index {
+		if len(byID) == 0 {
+			continue
+		}
+		ids := make([]uint, 0, len(byID))
+		for id := range byID {
+			ids = append(ids, id)
+		}
+
+		var reasons []struct {
+			TargetID uint
+			Reason   string
+			Count    int64
+		}
+		err := common.DB.Model(&Report{}).
+			Select("target_id, reason, COUNT(*) AS count").
+			Where("status = ? AND target_type = ? AND target_id IN ?", status, targetType, ids).
+			Group("target_id, reason").
+			Scan(&reasons).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range reasons {
+			byID[r.TargetID].Reasons[r.Reason] = r.Count
+		}
+
+		// モデレーターが内容を確認できるよう対象の概要を付ける
+		var targets []struct {
+			ID      uint
+			UserID  uint
+			Summary string
+		}
+		table, column := "videos", "title"
+		if targetType == ReportTargetComment {
+			table, column = "comments", "body"
+		}
+		err = common.DB.Table(table).Select("id, user_id, "+column+" AS summary").Where("id IN ?", ids).Scan(&targets).Error
+		if err != nil {
+			return nil, err
+		}
+		for _, t := range targets {
+			ownerID := t.UserID
+			byID[t.ID].OwnerID = &ownerID
+			byID[t.ID].Summary = t.Summary
+		}
+	}
+	return groups, nil
+}
+
+// 対象への通報を新しい順に取得する関数
+func GetReportsForTarget(targetType string, targetID uint) ([]Report, error) {
+	reports := []Report{}
+	err := common.DB.Where("target_type = ? AND target_id = ?", targetType, targetID).
+		Order("id DESC").
+		Find(&reports).Error
+	if err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// 対応履歴を新しい順に取得する関数（targetType が空の場合はすべて）
+func ListModerationActions(targetType string, targetID uint, limit, offset int) ([]ModerationAction, error) {
+	query := common.DB.Model(&ModerationAction{})
+	if targetType != "" {
+		query = query.Where("target_type = ? AND target_id = ?", targetType, targetID)
+	}
+
+	actions := []ModerationAction{}
+	if err := query.Order("id DESC").Limit(limit).Offset(offset).Find(&actions).Error; err != nil {
+		return nil, err
+	}
+	return actions, nil
+}
+
+// 通報対象に対応し、対象への未対応の通報を締めて対応履歴を記録する関数
+// suspend の場合は対象の投稿者を利用停止にし、ユーザーへの対応として記録する
+func ApplyModerationAction(moderatorID uint, targetType string, targetID uint, action, reason string) (*ModerationAction, error) {
+	record := ModerationAction{
+		ModeratorID: moderatorID,
+		TargetType:  targetType,
+		TargetID:    targetID,
+		Action:      action,
+		Reason:      reason,
+	}
+
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		ownerID, err := lockReportTarget(tx, targetType, targetID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		switch action {
+		case ModerationActionHide:
+			err = hideReportTarget(tx, targetType, targetID, now)
+		case ModerationActionTakeDown:
+			err = takeDownReportTarget(tx, targetType, targetID, now)
+		case ModerationActionSuspend:
+			err = tx.Table("users").
+				Where("id = ? AND suspended_at IS NULL", ownerID).
+				Update("suspended_at", now).Error
+			record.TargetType = ReportTargetUser
+			record.TargetID = ownerID
+		}
+		if err != nil {
+			return err
+		}
+
+		reportStatus := ReportStatusResolved
+		if action == ModerationActionDismiss {
+			reportStatus = ReportStatusDismissed
+		}
+		err = tx.Model(&Report{}).
+			Where("target_type = ? AND target_id = ? AND status = ?", targetType, targetID, ReportStatusOpen).
+			Updates(map[string]interface{}{"status": reportStatus, "resolved_by": moderatorID, "resolved_at": now}).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Create(&record).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// 通報対象の行をロックし、投稿者のユーザーIDを返す
+func lockReportTarget(tx *gorm.DB, targetType string, targetID uint) (uint, error) {
+	locking := clause.Locking{Strength: "UPDATE"}
+	switch targetType {
+	case ReportTargetVideo:
+		var video Video
+		if err := tx.Clauses(locking).Select("id", "user_id").Where("deleted IS NULL").First(&video, targetID).Error; err != nil {
+			return 0, err
+		}
+		return video.UserID, nil
+	case ReportTargetComment:
+		var comment Comment
+		if err := tx.Clauses(locking).Select("id", "user_id").Where("deleted IS NULL").First(&comment, targetID).Error; err != nil {
+			return 0, err
+		}
+		return comment.UserID, nil
+	}
+	return 0, gorm.ErrRecordNotFound
+}
+
+func hideReportTarget(tx *gorm.DB, targetType string, targetID uint, now time.Time) error {
+	if targetType == ReportTargetVideo {
+		// ETagを変えないよう更新日時は変更しない
+		return tx.Model(&Video{}).Where("id = ?", targetID).
+			Updates(map[string]interface{}{"hidden": now, "modified": gorm.Expr("modified")}).Error
+	}
+
+	var comment Comment
+	if err := tx.First(&comment, targetID).Error; err != nil {
+		return err
+	}
+	return setCommentStatusWithTransaction(tx, &comment, CommentStatusHidden)
+}
+
+func takeDownReportTarget(tx *gorm.DB, targetType string, targetID uint, now time.Time) error {
+	if targetType == ReportTargetVideo {
+		return tx.Model(&Video{}).Where("id = ?", targetID).
+			Updates(map[string]interface{}{"deleted": now, "modified": gorm.Expr("modified")}).Error
+	}
+
+	var comment Comment
+	if err := tx.First(&comment, targetID).Error; err != nil {
+		return err
+	}
+	return deleteCommentWithTransaction(tx, &comment)
+}
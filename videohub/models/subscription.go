@@ -0,0 +1,132 @@
+package models
+
+import (
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Subscription struct {
+	SubscriberID uint      `gorm:"primaryKey"`
+	ChannelID    uint      `gorm:"primaryKey"`
+	Created      time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// チャンネルの情報と登録状況
+type Channel struct {
+	ID              uint
+	Name            string
+	SubscriberCount int64
+	Subscribed      bool // ログイン中のユーザーが登録しているか
+}
+
+// 登録中のチャンネル
+type SubscribedChannel struct {
+	ID         uint
+	Name       string
+	Subscribed time.Time // 登録日時
+}
+
+// カーソル付きの登録チャンネルの新着動画
+type SubscriptionFeedPage struct {
+	Videos     []Video
+	NextCursor string // 次のページがない場合は空文字
+}
+
+// チャンネルの情報を取得する関数
+// subscriberID が 0 の場合は登録状況を確認しない
+func GetChannel(channelID, subscriberID uint) (*Channel, error) {
+	var author CommentAuthor
+	if err := common.DB.Where("deleted_at IS NULL").First(&author, channelID).Error; err != nil {
+		return nil, err
+	}
+
+	channel := &Channel{ID: author.ID, Name: author.Name}
+	if err := common.DB.Model(&Subscription{}).Where("channel_id = ?", channelID).Count(&channel.SubscriberCount).Error; err != nil {
+		return nil, err
+	}
+
+	if subscriberID != 0 {
+		var count int64
+		err := common.DB.Model(&Subscription{}).
+			Where("subscriber_id = ? AND channel_id = ?", subscriberID, channelID).
+			Count(&count).Error
+		if err != nil {
+			return nil, err
+		}
+		channel.Subscribed = count > 0
+	}
+	return channel, nil
+}
+
+// チャンネルを登録する関数（登録済みの場合は何もしない）
+func Subscribe(subscriberID, channelID uint) error {
+	var count int64
+	if err := common.DB.Table("users").Where("id = ? AND deleted_at IS NULL", channelID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	subscription := Subscription{SubscriberID: subscriberID, ChannelID: channelID}
+	return common.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&subscription).Error
+}
+
+func Unsubscribe(subscriberID, channelID uint) error {
+	return common.DB.Where("subscriber_id = ? AND channel_id = ?", subscriberID, channelID).
+		Delete(&Subscription{}).Error
+}
+
+// 登録中のチャンネルを登録日時の新しい順に取得する関数
+func ListSubscribedChannels(subscriberID uint) ([]SubscribedChannel, error) {
+	channels := []SubscribedChannel{}
+	err := common.DB.Table("subscriptions").
+		Select("users.id, users.name, subscriptions.created AS subscribed").
+		Joins("JOIN users ON users.id = subscriptions.channel_id AND users.deleted_at IS NULL").
+		Where("subscriptions.subscriber_id = ?", subscriberID).
+		Order("subscriptions.created DESC, users.id DESC").
+		Scan(&channels).Error
+	if err != nil {
+		return nil, err
+	}
+	return channels, nil
+}
+
+// 登録中のチャンネルの公開動画を公開日時の新しい順に取得する関数
+// 投稿時に配信先へ書き込むのではなく、読み込み時に登録中のチャンネルから集める
+func GetSubscriptionFeed(subscriberID uint, cursor string, limit int) (*SubscriptionFeedPage, error) {
+	query := preloadVideoRelations(common.DB).
+		Scopes(publicVideos).
+		Where("videos.user_id IN (?)", common.DB.Model(&Subscription{}).Select("channel_id").Where("subscriber_id = ?", subscriberID))
+
+	values, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 2 {
+		published := time.Unix(int64(values[0]), 0)
+		query = query.Where("(videos.published < ? OR (videos.published = ? AND videos.id < ?))", published, published, values[1])
+	} else if len(values) != 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	var videos []Video
+	err = query.Order("videos.published DESC, videos.id DESC").
+		Limit(limit + 1).
+		Find(&videos).Error
+	if err != nil {
+		return nil, err
+	}
+
+	page := &SubscriptionFeedPage{}
+	if len(videos) > limit {
+		videos = videos[:limit]
+		last := videos[len(videos)-1]
+		page.NextCursor = encodeCursor(uint64(last.Published.Unix()), uint64(last.ID))
+	}
+	page.Videos = videos
+	return page, nil
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"live/common"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Category struct {
+	ID        uint   `gorm:"primary_key"`
+	Slug      string `gorm:"type:varchar(50);unique;not null"`
+	Name      string `gorm:"type:varchar(100);not null"`
+	SortOrder uint   `gorm:"not null;default:0"`
+}
+
+type Tag struct {
+	ID      uint      `gorm:"primary_key"`
+	Name    string    `gorm:"type:varchar(50);unique;not null"`
+	Created time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// 利用数付きのタグ
+type TagUsage struct {
+	ID         uint
+	Name       string
+	UsageCount int64
+}
+
+func GetAllCategories() ([]Category, error) {
+	var categories []Category
+	if err := common.DB.Order("sort_order, id").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+	return categories, nil
+}
+
+func GetCategoryBySlug(slug string) (*Category, error) {
+	var category Category
+	if err := common.DB.Where("slug = ?", slug).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// タグが付いた動画を新しい順に取得する関数
+func GetVideosByTag(name string, limit, offset int) ([]Video, error) {
+	var videos []Video
+	err := preloadVideoRelations(common.DB).
+		Joins("JOIN video_tags ON video_tags.video_id = videos.id").
+		Joins("JOIN tags ON tags.id = video_tags.tag_id").
+		Scopes(publicVideos).
+		Where("tags.name = ?", name).
+		Order("videos.created DESC, videos.id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&videos).Error
+	if err != nil {
+		return nil, err
+	}
+	return videos, nil
+}
+
+// カテゴリに属する動画を新しい順に取得する関数
+func GetVideosByCategory(categoryID uint, limit, offset int) ([]Video, error) {
+	var videos []Video
+	err := preloadVideoRelations(common.DB).
+		Scopes(publicVideos).
+		Where("videos.category_id = ?", categoryID).
+		Order("videos.created DESC, videos.id DESC").
+		Limit(limit).
+		Offset(offset).
+		Find(&videos).Error
+	if err != nil {
+		return nil, err
+	}
+	return videos, nil
+}
+
+// 前方一致でタグを検索し、利用数の多い順に返す関数
+func SearchTags(prefix string, limit int) ([]TagUsage, error) {
+	var tags []TagUsage
+	err := tagUsageQuery().
+		Where("tags.name LIKE ?", escapeLike(prefix)+"%").
+		Limit(limit).
+		Scan(&tags).Error
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// 利用数の多いタグを取得する関数
+func GetPopularTags(limit int) ([]TagUsage, error) {
+	var tags []TagUsage
+	if err := tagUsageQuery().Limit(limit).Scan(&tags).Error; err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// 動画のタグを指定したタグで置き換える関数
+func ReplaceVideoTagsWithTransaction(tx *gorm.DB, videoID uint, names []string) error {
+	if err := tx.Exec("DELETE FROM video_tags WHERE video_id = ?", videoID).Error; err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	tags := make([]Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, Tag{Name: name})
+	}
+	// 既存のタグはそのまま利用する
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return err
+	}
+
+	var tagIDs []uint
+	if err := tx.Model(&Tag{}).Where("name IN ?", names).Pluck("id", &tagIDs).Error; err != nil {
+		return err
+	}
+
+	rows := make([]map[string]interface{}, 0, len(tagIDs))
+	for _, tagID := range tagIDs {
+		rows = append(rows, map[string]interface{}{"video_id": videoID, "tag_id": tagID})
+	}
+	return tx.Table("video_tags").Create(rows).Error
+}
+
+// 動画一覧で共通して読み込む関連データ
+func preloadVideoRelations(db *gorm.DB) *gorm.DB {
+	return db.Preload("Files", "deleted IS NULL AND source_file_id IS NULL").
+		Preload("Thumbnails").
+		Preload("Category").
+		Preload("Tags")
+}
+
+// 公開中の動画に付いているタグの利用数を集計するクエリ
+func tagUsageQuery() *gorm.DB {
+	return common.DB.Table("tags").
+		Select("tags.id, tags.name, COUNT(*) AS usage_count").
+		Joins("JOIN video_tags ON video_tags.tag_id = tags.id").
+		Joins("JOIN videos ON videos.id = video_tags.video_id AND videos.visibility = ? AND videos.status = ? AND videos.hidden IS NULL AND videos.deleted IS NULL", VideoVisibilityPublic, VideoStatusPublished).
+		Group("tags.id, tags.name").
+		Order("usage_count DESC, tags.name")
+}
+
+// LIKE 検索用に特殊文字をエスケープする
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
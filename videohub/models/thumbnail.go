@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// 動画のサムネイル（アップロードされた画像から表示サイズごとに作成したもの）
+type VideoThumbnail struct {
+	ID          uint      `gorm:"primary_key"`
+	VideoID     uint      `gorm:"not null"`
+	Variant     string    `gorm:"type:enum('small','medium','large','webp');not null"` // small / medium / large / webp
+	FilePath    string    `gorm:"type:varchar(255);not null"`
+	Width       uint      `gorm:"not null"`
+	Height      uint      `gorm:"not null"`
+	ContentType string    `gorm:"type:varchar(50);not null"`
+	Created     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
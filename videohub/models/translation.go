@@ -0,0 +1,115 @@
+package models
+
+import (
+	"live/common"
+	"time"
+
+	"golang.org/x/text/language"
+	"gorm.io/gorm/clause"
+)
+
+type VideoTranslation struct {
+	VideoID     uint      `gorm:"primaryKey"`
+	Language    string    `gorm:"primaryKey;type:varchar(35)"`
+	Title       string    `gorm:"type:varchar(255);not null"`
+	Description string    `gorm:"type:text"`
+	Created     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	Modified    time.Time `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+}
+
+// 動画の翻訳を言語コードの順に取得する関数
+func GetVideoTranslations(videoID uint) ([]VideoTranslation, error) {
+	translations := []VideoTranslation{}
+	if err := common.DB.Where("video_id = ?", videoID).Order("language").Find(&translations).Error; err != nil {
+		return nil, err
+	}
+	return translations, nil
+}
+
+// 動画の翻訳を保存する関数（同じ言語の翻訳がある場合は上書きする）
+func SaveVideoTranslation(videoID uint, lang, title, description string) (*VideoTranslation, error) {
+	translation := VideoTranslation{VideoID: videoID, Language: lang, Title: title, Description: description}
+	err := common.DB.Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"title", "description"}),
+	}).Create(&translation).Error
+	if err != nil {
+		return nil, err
+	}
+	return &translation, nil
+}
+
+// 動画の翻訳を削除する関数（削除した場合は true を返す）
+func DeleteVideoTranslation(videoID uint, lang string) (bool, error) {
+	result := common.DB.Where("video_id = ? AND language = ?", videoID, lang).Delete(&VideoTranslation{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// 希望する言語に最も近い翻訳でタイトルと説明を置き換える関数
+// 原文の言語の方が近い場合や、近い翻訳がない場合は原文のままにする
+func LocalizeVideos(videos []Video, preferred []language.Tag) error {
+	if len(videos) == 0 || len(preferred) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, 0, len(videos))
+	for _, v := range videos {
+		ids = append(ids, v.ID)
+	}
+
+	var translations []VideoTranslation
+	if err := common.DB.Where("video_id IN ?", ids).Find(&translations).Error; err != nil {
+		return err
+	}
+	byVideo := map[uint][]VideoTranslation{}
+	for _, t := range translations {
+		byVideo[t.VideoID] = append(byVideo[t.VideoID], t)
+	}
+
+	for i := range videos {
+		localizeVideo(&videos[i], byVideo[videos[i].ID], preferred)
+	}
+	return nil
+}
+
+// 1件の動画のタイトルと説明を希望する言語に合わせる関数
+func LocalizeVideo(video *Video, preferred []language.Tag) error {
+	if len(preferred) == 0 {
+		return nil
+	}
+
+	translations, err := GetVideoTranslations(video.ID)
+	if err != nil {
+		return err
+	}
+	localizeVideo(video, translations, preferred)
+	return nil
+}
+
+func localizeVideo(video *Video, translations []VideoTranslation, preferred []language.Tag) {
+	if len(translations) == 0 {
+		return
+	}
+
+	// 原文の言語がわかる場合は候補に含め、選ばれた場合は原文を表示する
+	var available []string
+	offset := 0
+	if video.Language != nil {
+		available = append(available, *video.Language)
+		offset = 1
+	}
+	for _, t := range translations {
+		available = append(available, t.Language)
+	}
+
+	index, ok := common.MatchLanguage(preferred, available)
+	if !ok || index < offset {
+		return
+	}
+
+	t := translations[index-offset]
+	video.Title = t.Title
+	if t.Description != "" {
+		video.Description = t.Description
+	}
+	video.Translation = t.Language
+}
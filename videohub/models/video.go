@@ -1,18 +1,51 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"live/common"
 	"time"
+
+	"gorm.io/gorm"
 )
 
+const (
+	VideoVisibilityPublic   = "public"
+	VideoVisibilityUnlisted = "unlisted" // URLを知っている人のみ視聴でき、一覧には表示しない
+	VideoVisibilityPrivate  = "private"  // 投稿者のみ視聴できる
+
+	VideoStatusDraft     = "draft"     // 下書き（投稿者のみ視聴できる）
+	VideoStatusScheduled = "scheduled" // 予約公開（公開日時まで投稿者のみ視聴できる）
+	VideoStatusPublished = "published"
+)
+
+// 楽観的排他制御で更新が競合した場合のエラー
+var ErrVideoModified = errors.New("video has been modified by another request")
+
 type Video struct {
-	ID          uint        `gorm:"primary_key"`
-	Title       string      `gorm:"type:varchar(255);not null"`
-	Description string      `gorm:"type:text"`
-	Created     time.Time   `gorm:"default:CURRENT_TIMESTAMP"`
-	Modified    time.Time   `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
-	Deleted     *time.Time  `gorm:"default:NULL"`
-	Files       []VideoFile `gorm:"foreignKey:VideoID"` // ここで動画ファイルとのリレーションを設定
+	ID                      uint             `gorm:"primary_key"`
+	UserID                  uint             `gorm:"not null"`
+	Title                   string           `gorm:"type:varchar(255);not null"`
+	Description             string           `gorm:"type:text"`
+	Language                *string          `gorm:"type:varchar(35);default:NULL"` // タイトル・説明の言語
+	Visibility              string           `gorm:"type:enum('public','unlisted','private');default:'public'"`
+	Status                  string           `gorm:"type:enum('draft','scheduled','published');default:'published'"`
+	PublishAt               *time.Time       `gorm:"default:NULL"`
+	Published               *time.Time       `gorm:"default:NULL"`
+	CategoryID              *uint            `gorm:"default:NULL"`
+	ViewCount               uint64           `gorm:"not null;default:0"`
+	LikeCount               uint             `gorm:"not null;default:0"`
+	DislikeCount            uint             `gorm:"not null;default:0"`
+	CommentsRequireApproval bool             `gorm:"not null;default:0"`
+	Created                 time.Time        `gorm:"default:CURRENT_TIMESTAMP"`
+	Modified                time.Time        `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+	Hidden                  *time.Time       `gorm:"default:NULL"`
+	Deleted                 *time.Time       `gorm:"default:NULL"`
+	Files                   []VideoFile      `gorm:"foreignKey:VideoID"` // ここで動画ファイルとのリレーションを設定
+	Thumbnails              []VideoThumbnail `gorm:"foreignKey:VideoID"`
+	Category                *Category        `gorm:"foreignKey:CategoryID"`
+	Tags                    []Tag            `gorm:"many2many:video_tags;"`
+	Translation             string           `gorm:"-"` // 表示中の翻訳の言語（原文の場合は空）
 }
 
 type VideoFile struct {
@@ -20,14 +53,35 @@ type VideoFile struct {
 	VideoID       uint       `gorm:"not null"`
 	FilePath      string     `gorm:"type:varchar(255);not null"`
 	ThumbnailPath string     `gorm:"type:varchar(255)"`
+	Duration      uint       `gorm:"type:int"` // 再生時間（秒）
+	Width         *uint      `gorm:"default:NULL"`
+	Height        *uint      `gorm:"default:NULL"`
+	FrameRate     *float64   `gorm:"type:decimal(8,3);default:NULL"`
+	VideoCodec    *string    `gorm:"type:varchar(50);default:NULL"`
+	AudioCodec    *string    `gorm:"type:varchar(50);default:NULL"`
 	Created       time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
 	Modified      time.Time  `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
 	Deleted       *time.Time `gorm:"default:NULL"`
 }
 
+// ETag は更新日時から動画のETagを生成する
+func (v *Video) ETag() string {
+	return fmt.Sprintf(`"%d-%d"`, v.ID, v.Modified.Unix())
+}
+
+// CanView はユーザーが動画を視聴できるか判定する
+// 非公開・未公開の動画とモデレーターが非表示にした動画は投稿者のみ視聴できる
+func (v *Video) CanView(userID uint, loggedIn bool) bool {
+	if v.Visibility != VideoVisibilityPrivate && v.Status == VideoStatusPublished && v.Hidden == nil {
+		return true
+	}
+	return loggedIn && v.UserID == userID
+}
+
+// 公開中の動画を取得する関数
 func GetAllVideos() ([]Video, error) {
 	var videos []Video
-	if err := common.DB.Preload("Files").Find(&videos).Error; err != nil {
+	if err := preloadVideoRelations(common.DB).Scopes(publicVideos).Find(&videos).Error; err != nil {
 		return nil, err
 	}
 	return videos, nil
@@ -35,8 +89,118 @@ func GetAllVideos() ([]Video, error) {
 
 func GetVideoByID(videoID uint) (*Video, error) {
 	var video Video
-	if err := common.DB.First(&video, videoID).Error; err != nil {
+	err := common.DB.Preload("Category").
+		Preload("Tags").
+		Where("deleted IS NULL").
+		First(&video, videoID).Error
+	if err != nil {
+		return nil, err
+	}
+	return &video, nil
+}
+
+// 動画ファイルを含めて動画を取得する関数
+func GetVideoWithFiles(videoID uint) (*Video, error) {
+	var video Video
+	err := preloadVideoRelations(common.DB).
+		Where("deleted IS NULL").
+		First(&video, videoID).Error
+	if err != nil {
 		return nil, err
 	}
 	return &video, nil
 }
+
+// 動画情報の更新内容
+type VideoUpdate struct {
+	Title                   string
+	Description             string
+	Language                *string
+	Visibility              string
+	Status                  string
+	PublishAt               *time.Time // 予約公開の場合のみ
+	CategoryID              *uint
+	Tags                    []string // nil の場合はタグを変更しない
+	CommentsRequireApproval bool
+}
+
+// 取得時の更新日時と一致する場合のみ動画情報を更新する関数
+// 他のリクエストが先に更新していた場合は ErrVideoModified を返す
+func UpdateVideoMetadata(video *Video, update VideoUpdate) error {
+	// DATETIME は秒精度のため、同一秒内の更新でもETagが変わるように更新日時を必ず進める
+	modified := time.Now().Truncate(time.Second)
+	if !modified.After(video.Modified) {
+		modified = video.Modified.Add(time.Second)
+	}
+
+	values := map[string]interface{}{
+		"title":                     update.Title,
+		"description":               update.Description,
+		"language":                  update.Language,
+		"visibility":                update.Visibility,
+		"status":                    update.Status,
+		"publish_at":                update.PublishAt,
+		"category_id":               update.CategoryID,
+		"modified":                  modified,
+		"comments_require_approval": update.CommentsRequireApproval,
+	}
+	// 初めて公開した日時を記録する
+	published := video.Published
+	if update.Status == VideoStatusPublished && published == nil {
+		published = &modified
+		values["published"] = modified
+	}
+
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Video{}).
+			Where("id = ? AND modified = ? AND deleted IS NULL", video.ID, video.Modified).
+			Updates(values)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVideoModified
+		}
+
+		if update.Description != video.Description {
+			if err := syncDescriptionChaptersWithTransaction(tx, video.ID, update.Description); err != nil {
+				return err
+			}
+		}
+
+		if update.Tags != nil {
+			return ReplaceVideoTagsWithTransaction(tx, video.ID, update.Tags)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	video.Title = update.Title
+	video.Description = update.Description
+	video.Language = update.Language
+	video.Visibility = update.Visibility
+	video.Status = update.Status
+	video.PublishAt = update.PublishAt
+	video.Published = published
+	video.CategoryID = update.CategoryID
+	video.CommentsRequireApproval = update.CommentsRequireApproval
+	video.Modified = modified
+	return nil
+}
+
+// 動画ファイルの再生時間（秒）を取得する関数（不明な場合は 0）
+func GetVideoDuration(videoID uint) (uint, error) {
+	var duration uint
+	err := common.DB.Table("video_files").
+		Select("COALESCE(MAX(duration), 0)").
+		Where("video_id = ? AND deleted IS NULL", videoID).
+		Scan(&duration).Error
+	return duration, err
+}
+
+// 一覧に表示できる動画（公開済みで非表示・削除されていない動画）に絞り込むスコープ
+func publicVideos(db *gorm.DB) *gorm.DB {
+	return db.Where("videos.visibility = ? AND videos.status = ? AND videos.hidden IS NULL AND videos.deleted IS NULL", VideoVisibilityPublic, VideoStatusPublished)
+}
@@ -1,14 +1,106 @@
 package videohub
 
 import (
+	"live/common"
 	"live/videohub/handlers"
+	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
-func RegisterRoutes(router *mux.Router) {
+// ログインが必要な書き込みのルートには ActiveUserMiddleware を付け、利用停止中のユーザーの操作を拒否する
+// 他のパッケージが /api/v1/videos 以下にルートを追加できるよう、動画のサブルーターを返す
+func RegisterRoutes(router *mux.Router) *mux.Router {
 	videohubRouter := router.PathPrefix("/api/v1/videos").Subrouter()
 
 	videohubRouter.HandleFunc("/list", handlers.ListVideos).Methods("GET")
-	// videohubRouter.HandleFunc("/details/{id}", handlers.GetVideoDetails).Methods("GET")
+	videohubRouter.Handle("/mine", common.AuthMiddleware(http.HandlerFunc(handlers.ListMyVideos))).Methods("GET")
+	videohubRouter.Handle("/details/{id:[0-9]+}", common.OptionalAuthMiddleware(http.HandlerFunc(handlers.GetVideoDetails))).Methods("GET")
+	videohubRouter.HandleFunc("/tags/{name}", handlers.ListVideosByTag).Methods("GET")
+	videohubRouter.HandleFunc("/categories", handlers.ListCategories).Methods("GET")
+	videohubRouter.HandleFunc("/categories/{slug}", handlers.ListVideosByCategory).Methods("GET")
+	videohubRouter.Handle("/{id:[0-9]+}", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.UpdateVideo)))).Methods("PATCH")
+	videohubRouter.Handle("/{id:[0-9]+}/views", common.OptionalAuthMiddleware(http.HandlerFunc(handlers.RecordView))).Methods("POST")
+	videohubRouter.Handle("/{id:[0-9]+}/reaction", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.ToggleReaction)))).Methods("PUT")
+	videohubRouter.Handle("/{id:[0-9]+}/related", common.OptionalAuthMiddleware(http.HandlerFunc(handlers.ListRelatedVideos))).Methods("GET")
+	videohubRouter.Handle("/{id:[0-9]+}/chapters", common.OptionalAuthMiddleware(http.HandlerFunc(handlers.ListChapters))).Methods("GET")
+	videohubRouter.Handle("/{id:[0-9]+}/chapters", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.ReplaceChapters)))).Methods("PUT")
+	videohubRouter.Handle("/{id:[0-9]+}/chapters.vtt", common.QueryTokenMiddleware(common.OptionalAuthMiddleware(http.HandlerFunc(handlers.GetChaptersTrack)))).Methods("GET")
+	videohubRouter.Handle("/{id:[0-9]+}/hls/master.m3u8", common.QueryTokenMiddleware(common.OptionalAuthMiddleware(http.HandlerFunc(handlers.GetHLSMasterPlaylist)))).Methods("GET")
+	videohubRouter.Handle("/{id:[0-9]+}/hls/{fileID:[0-9]+}.m3u8", common.OptionalAuthMiddleware(http.HandlerFunc(handlers.GetHLSMediaPlaylist))).Methods("GET")
+	videohubRouter.Handle("/{id:[0-9]+}/dash/manifest.mpd", common.QueryTokenMiddleware(common.OptionalAuthMiddleware(http.HandlerFunc(handlers.GetDASHManifest)))).Methods("GET")
+	videohubRouter.Handle("/{id:[0-9]+}/translations", common.OptionalAuthMiddleware(http.HandlerFunc(handlers.ListTranslations))).Methods("GET")
+	videohubRouter.Handle("/{id:[0-9]+}/translations/{language}", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.SaveTranslation)))).Methods("PUT")
+	videohubRouter.Handle("/{id:[0-9]+}/translations/{language}", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.DeleteTranslation)))).Methods("DELETE")
+	videohubRouter.Handle("/{id:[0-9]+}/progress", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.SaveWatchProgress)))).Methods("PUT")
+	videohubRouter.Handle("/{id:[0-9]+}/comments", common.OptionalAuthMiddleware(http.HandlerFunc(handlers.ListComments))).Methods("GET")
+	videohubRouter.Handle("/{id:[0-9]+}/comments", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.CreateComment)))).Methods("POST")
+	videohubRouter.Handle("/{id:[0-9]+}/comments/moderation", common.AuthMiddleware(http.HandlerFunc(handlers.ListModerationComments))).Methods("GET")
+
+	tagRouter := router.PathPrefix("/api/v1/tags").Subrouter()
+
+	tagRouter.HandleFunc("/autocomplete", handlers.AutocompleteTags).Methods("GET")
+	tagRouter.HandleFunc("/popular", handlers.PopularTags).Methods("GET")
+
+	rankingRouter := router.PathPrefix("/api/v1/rankings").Subrouter()
+
+	rankingRouter.HandleFunc("/{period}", handlers.ListRankings).Methods("GET")
+
+	playlistRouter := router.PathPrefix("/api/v1/playlists").Subrouter()
+
+	playlistRouter.Handle("", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.CreatePlaylist)))).Methods("POST")
+	playlistRouter.Handle("/mine", common.AuthMiddleware(http.HandlerFunc(handlers.ListMyPlaylists))).Methods("GET")
+	playlistRouter.HandleFunc("/users/{userID:[0-9]+}", handlers.ListUserPlaylists).Methods("GET")
+	playlistRouter.Handle("/{id:[0-9]+}", common.OptionalAuthMiddleware(http.HandlerFunc(handlers.GetPlaylist))).Methods("GET")
+	playlistRouter.Handle("/{id:[0-9]+}", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.UpdatePlaylist)))).Methods("PATCH")
+	playlistRouter.Handle("/{id:[0-9]+}", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.DeletePlaylist)))).Methods("DELETE")
+	playlistRouter.Handle("/{id:[0-9]+}/items", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.AddPlaylistItem)))).Methods("POST")
+	playlistRouter.Handle("/{id:[0-9]+}/items/{itemID:[0-9]+}/position", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.MovePlaylistItem)))).Methods("PUT")
+	playlistRouter.Handle("/{id:[0-9]+}/items/{itemID:[0-9]+}", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.RemovePlaylistItem)))).Methods("DELETE")
+	playlistRouter.Handle("/{id:[0-9]+}/editors", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.AddPlaylistEditor)))).Methods("POST")
+	playlistRouter.Handle("/{id:[0-9]+}/editors/{userID:[0-9]+}", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.RemovePlaylistEditor)))).Methods("DELETE")
+
+	historyRouter := router.PathPrefix("/api/v1/history").Subrouter()
+	historyRouter.Use(common.AuthMiddleware)
+
+	historyRouter.HandleFunc("", handlers.ListWatchHistory).Methods("GET")
+	historyRouter.Handle("", common.ActiveUserMiddleware(http.HandlerFunc(handlers.ClearWatchHistory))).Methods("DELETE")
+	historyRouter.HandleFunc("/continue", handlers.ListContinueWatching).Methods("GET")
+	historyRouter.Handle("/{id:[0-9]+}", common.ActiveUserMiddleware(http.HandlerFunc(handlers.DeleteWatchHistory))).Methods("DELETE")
+
+	channelRouter := router.PathPrefix("/api/v1/channels").Subrouter()
+
+	channelRouter.Handle("/{userID:[0-9]+}", common.OptionalAuthMiddleware(http.HandlerFunc(handlers.GetChannel))).Methods("GET")
+	channelRouter.Handle("/{userID:[0-9]+}/subscription", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.Subscribe)))).Methods("PUT")
+	channelRouter.Handle("/{userID:[0-9]+}/subscription", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.Unsubscribe)))).Methods("DELETE")
+
+	subscriptionRouter := router.PathPrefix("/api/v1/subscriptions").Subrouter()
+	subscriptionRouter.Use(common.AuthMiddleware)
+
+	subscriptionRouter.HandleFunc("", handlers.ListSubscriptions).Methods("GET")
+	subscriptionRouter.HandleFunc("/feed", handlers.GetSubscriptionFeed).Methods("GET")
+
+	reportRouter := router.PathPrefix("/api/v1/reports").Subrouter()
+
+	reportRouter.Handle("", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.CreateReport)))).Methods("POST")
+
+	moderationRouter := router.PathPrefix("/api/v1/moderation").Subrouter()
+	moderationRouter.Use(common.AuthMiddleware, common.ModeratorMiddleware)
+
+	moderationRouter.HandleFunc("/reports", handlers.ListReportQueue).Methods("GET")
+	moderationRouter.HandleFunc("/reports/{type}/{id:[0-9]+}", handlers.GetReportTarget).Methods("GET")
+	moderationRouter.HandleFunc("/actions", handlers.ApplyModerationAction).Methods("POST")
+	moderationRouter.HandleFunc("/actions", handlers.ListModerationActions).Methods("GET")
+
+	commentRouter := router.PathPrefix("/api/v1/comments").Subrouter()
+
+	commentRouter.Handle("/{commentID:[0-9]+}/replies", common.OptionalAuthMiddleware(http.HandlerFunc(handlers.ListReplies))).Methods("GET")
+	commentRouter.Handle("/{commentID:[0-9]+}", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.UpdateComment)))).Methods("PATCH")
+	commentRouter.Handle("/{commentID:[0-9]+}", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.DeleteComment)))).Methods("DELETE")
+	commentRouter.Handle("/{commentID:[0-9]+}/pin", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.PinComment)))).Methods("PUT")
+	commentRouter.Handle("/{commentID:[0-9]+}/pin", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.UnpinComment)))).Methods("DELETE")
+	commentRouter.Handle("/{commentID:[0-9]+}/status", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.ModerateComment)))).Methods("PUT")
+	commentRouter.Handle("/{commentID:[0-9]+}/like", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.ToggleCommentLike)))).Methods("PUT")
+
+	return videohubRouter
 }
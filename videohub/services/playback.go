@@ -0,0 +1,61 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"live/common"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 再生用トークンの有効期間
+// 再生の途中で画質を切り替えた場合もメディアプレイリストを取得できるよう、動画1本分の再生より長くする
+const playbackTokenTTL = 4 * time.Hour
+
+// NewPlaybackToken は動画のメディアプレイリストの取得にだけ使える再生用トークンを作成する
+// ログイン用のトークン（JWT）の代わりにプレイリストの URI に含め、CDN やプロキシのログに JWT を残さない
+func NewPlaybackToken(videoID, userID uint, now time.Time) string {
+	payload := fmt.Sprintf("%d.%d.%d", videoID, userID, now.Add(playbackTokenTTL).Unix())
+	return payload + "." + playbackSignature(payload)
+}
+
+// VerifyPlaybackToken は再生用トークンを検証し、トークンを作成したユーザーのIDを返す
+// 署名が正しくない場合、別の動画のトークンの場合、有効期限を過ぎた場合は false を返す
+func VerifyPlaybackToken(token string, videoID uint, now time.Time) (uint, bool) {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return 0, false
+	}
+	payload, signature := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(signature), []byte(playbackSignature(payload))) {
+		return 0, false
+	}
+
+	fields := strings.Split(payload, ".")
+	if len(fields) != 3 {
+		return 0, false
+	}
+	tokenVideoID, err := strconv.ParseUint(fields[0], 10, 64)
+	if err != nil || uint(tokenVideoID) != videoID {
+		return 0, false
+	}
+	userID, err := strconv.ParseUint(fields[1], 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	expires, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil || now.Unix() > expires {
+		return 0, false
+	}
+	return uint(userID), true
+}
+
+// ログイン用のトークンと取り違えないよう、用途を含めて署名する
+func playbackSignature(payload string) string {
+	mac := hmac.New(sha256.New, common.JwtKey)
+	mac.Write([]byte("playback:" + payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"fmt"
+	"live/common"
+	notificationServices "live/notification/services"
+	"live/videohub/models"
+	"sync"
+	"time"
+)
+
+const (
+	// 予約公開の動画を確認する最大の間隔（他のサーバーで予約された動画も拾えるようにする）
+	publishPollInterval = time.Minute
+	// 1回の処理で公開する動画の最大数
+	publishBatchSize = 100
+)
+
+// PublishJob は公開日時を過ぎた予約公開の動画を公開し、チャンネル登録者に通知する
+type PublishJob struct {
+	mu      sync.Mutex
+	wakeCh  chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	started bool
+}
+
+// アプリケーション全体で共有する予約公開ジョブ
+var Publisher = NewPublishJob()
+
+func NewPublishJob() *PublishJob {
+	return &PublishJob{
+		wakeCh: make(chan struct{}, 1),
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// Start は予約公開の処理を開始する
+// 次の公開日時まで待機し、公開日時がない場合も一定間隔ごとに確認する
+func (j *PublishJob) Start() {
+	j.mu.Lock()
+	if j.started {
+		j.mu.Unlock()
+		return
+	}
+	j.started = true
+	j.mu.Unlock()
+
+	go func() {
+		defer close(j.doneCh)
+
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-timer.C:
+				j.Run()
+			case <-j.wakeCh:
+				if !timer.Stop() {
+					select {
+					case <-timer.C:
+					default:
+					}
+				}
+			case <-j.stopCh:
+				return
+			}
+			timer.Reset(j.nextWait())
+		}
+	}()
+}
+
+// Stop は予約公開の処理を止める
+func (j *PublishJob) Stop() {
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return
+	}
+	close(j.stopCh)
+	<-j.doneCh
+}
+
+// Reschedule は公開日時が変更されたことを知らせ、次に待機する時間を計算し直させる
+func (j *PublishJob) Reschedule() {
+	select {
+	case j.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// Run は公開日時を過ぎた動画をすべて公開する
+func (j *PublishJob) Run() {
+	for {
+		videos, err := models.PublishDueVideos(time.Now(), publishBatchSize)
+		if err != nil {
+			common.LogVideoHubError(fmt.Errorf("Failed to publish scheduled videos: %w", err))
+			return
+		}
+
+		for _, v := range videos {
+			common.LogVideoHubInfo(fmt.Sprintf("Scheduled video published: %d", v.ID))
+			// 一覧に表示される動画を初めて公開した場合のみ通知する
+			if v.FirstTime && v.Visibility == models.VideoVisibilityPublic {
+				notificationServices.NotifyNewUpload(v.UserID, v.ID, v.Title)
+			}
+		}
+
+		if len(videos) < publishBatchSize {
+			return
+		}
+	}
+}
+
+// 次の公開日時までの待機時間
+func (j *PublishJob) nextWait() time.Duration {
+	next, err := models.GetNextPublishAt()
+	if err != nil {
+		common.LogVideoHubError(fmt.Errorf("Failed to get next publish time: %w", err))
+		return publishPollInterval
+	}
+	if next == nil {
+		return publishPollInterval
+	}
+
+	wait := time.Until(*next)
+	if wait < 0 {
+		wait = 0
+	}
+	if wait > publishPollInterval {
+		wait = publishPollInterval
+	}
+	return wait
+}
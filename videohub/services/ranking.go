@@ -0,0 +1,184 @@
+package services
+
+import (
+	"fmt"
+	"live/common"
+	"live/videohub/models"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// ランキングを集計し直す間隔
+	rankingInterval = 15 * time.Minute
+	// 1つのランキングに保存する動画の最大数
+	rankingSize = 100
+
+	// スコアに対する高評価・コメント1件あたりの重み（再生1回を1とする）
+	rankingLikeWeight    = 5.0
+	rankingCommentWeight = 10.0
+)
+
+// 集計期間ごとの設定
+type rankingPeriod struct {
+	Name     string
+	Window   time.Duration // 集計対象にする期間
+	HalfLife time.Duration // スコアが半分になるまでの時間
+}
+
+var rankingPeriods = []rankingPeriod{
+	{Name: models.RankingPeriodDaily, Window: 48 * time.Hour, HalfLife: 12 * time.Hour},
+	{Name: models.RankingPeriodWeekly, Window: 7 * 24 * time.Hour, HalfLife: 72 * time.Hour},
+}
+
+// RankingJob は定期的に急上昇スコアを計算し、ランキングを保存する
+type RankingJob struct {
+	mu      sync.Mutex
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	started bool
+}
+
+// アプリケーション全体で共有するランキング集計ジョブ
+var Rankings = NewRankingJob()
+
+func NewRankingJob() *RankingJob {
+	return &RankingJob{
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// Start は起動直後と一定間隔ごとの集計を開始する
+func (j *RankingJob) Start() {
+	j.mu.Lock()
+	if j.started {
+		j.mu.Unlock()
+		return
+	}
+	j.started = true
+	j.mu.Unlock()
+
+	go func() {
+		defer close(j.doneCh)
+		j.Run()
+
+		ticker := time.NewTicker(rankingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.Run()
+			case <-j.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop は定期的な集計を止める
+func (j *RankingJob) Stop() {
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return
+	}
+
+	close(j.stopCh)
+	<-j.doneCh
+}
+
+// Run はすべての集計期間のランキングを計算して保存する
+func (j *RankingJob) Run() {
+	now := time.Now()
+	for _, period := range rankingPeriods {
+		if err := computeRanking(period, now); err != nil {
+			common.LogVideoHubError(fmt.Errorf("Failed to compute %s ranking: %w", period.Name, err))
+		}
+	}
+}
+
+type scoredVideo struct {
+	VideoID    uint
+	CategoryID uint
+	Score      float64
+}
+
+// 集計期間内の活動を時間減衰させて合計し、全体とカテゴリごとのランキングを作成する
+func computeRanking(period rankingPeriod, now time.Time) error {
+	activities, err := models.GetDailyActivity(now.Add(-period.Window))
+	if err != nil {
+		return err
+	}
+
+	scores := map[uint]float64{}
+	for _, a := range activities {
+		// 日別の集計は日の途中で発生したものとみなす
+		age := now.Sub(a.Date.Add(12 * time.Hour))
+		if age < 0 {
+			age = 0
+		}
+		decay := math.Pow(0.5, age.Hours()/period.HalfLife.Hours())
+		points := float64(a.Views) + float64(a.Likes)*rankingLikeWeight + float64(a.Comments)*rankingCommentWeight
+		scores[a.VideoID] += points * decay
+	}
+
+	videoIDs := make([]uint, 0, len(scores))
+	for videoID := range scores {
+		videoIDs = append(videoIDs, videoID)
+	}
+	videos, err := models.GetRankableVideos(videoIDs)
+	if err != nil {
+		return err
+	}
+
+	scored := make([]scoredVideo, 0, len(videos))
+	for _, video := range videos {
+		s := scoredVideo{VideoID: video.ID, Score: scores[video.ID]}
+		if video.CategoryID != nil {
+			s.CategoryID = *video.CategoryID
+		}
+		scored = append(scored, s)
+	}
+	sort.Slice(scored, func(i, k int) bool {
+		if scored[i].Score != scored[k].Score {
+			return scored[i].Score > scored[k].Score
+		}
+		return scored[i].VideoID > scored[k].VideoID
+	})
+
+	// 全体のランキングとカテゴリごとのランキングを上位から埋める
+	counts := map[uint]uint{}
+	rankings := []models.VideoRanking{}
+	add := func(categoryID uint, s scoredVideo) {
+		if counts[categoryID] >= rankingSize {
+			return
+		}
+		counts[categoryID]++
+		rankings = append(rankings, models.VideoRanking{
+			Period:     period.Name,
+			CategoryID: categoryID,
+			RankOrder:  counts[categoryID],
+			VideoID:    s.VideoID,
+			Score:      s.Score,
+			Computed:   now,
+		})
+	}
+	for _, s := range scored {
+		add(models.RankingAllCategories, s)
+		if s.CategoryID != models.RankingAllCategories {
+			add(s.CategoryID, s)
+		}
+	}
+
+	if err := models.ReplaceRankings(period.Name, rankings); err != nil {
+		return err
+	}
+
+	common.LogVideoHubInfo(fmt.Sprintf("Computed %s ranking for %d videos", period.Name, len(scored)))
+	return nil
+}
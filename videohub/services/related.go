@@ -0,0 +1,160 @@
+package services
+
+import (
+	"errors"
+	"live/videohub/models"
+	"sort"
+	"sync"
+)
+
+// 存在しない推薦方式を指定した場合のエラー
+var ErrUnknownRelatedStrategy = errors.New("unknown related video strategy")
+
+// 既定の推薦方式
+const DefaultRelatedStrategy = "combined"
+
+// 1つの信号から取得する候補の最大数
+const relatedCandidateLimit = 200
+
+// RelatedStrategy は関連動画の候補にスコアを付ける推薦方式
+type RelatedStrategy interface {
+	// Candidates は動画に関連する候補をスコアの高い順に返す（元の動画は含めない）
+	Candidates(video *models.Video, limit int) ([]models.RelatedCandidate, error)
+}
+
+// RelatedStrategyFunc は関数を RelatedStrategy として使うための型
+type RelatedStrategyFunc func(video *models.Video, limit int) ([]models.RelatedCandidate, error)
+
+func (f RelatedStrategyFunc) Candidates(video *models.Video, limit int) ([]models.RelatedCandidate, error) {
+	return f(video, limit)
+}
+
+var (
+	relatedMu         sync.RWMutex
+	relatedStrategies = map[string]RelatedStrategy{}
+)
+
+func init() {
+	tags := RelatedStrategyFunc(func(video *models.Video, limit int) ([]models.RelatedCandidate, error) {
+		return models.GetSharedTagCandidates(video.ID, limit)
+	})
+	uploader := RelatedStrategyFunc(func(video *models.Video, limit int) ([]models.RelatedCandidate, error) {
+		return models.GetSameUploaderCandidates(video.ID, video.UserID, limit)
+	})
+	coWatch := RelatedStrategyFunc(func(video *models.Video, limit int) ([]models.RelatedCandidate, error) {
+		return models.GetCoWatchedCandidates(video.ID, limit)
+	})
+
+	RegisterRelatedStrategy("tags", tags)
+	RegisterRelatedStrategy("uploader", uploader)
+	RegisterRelatedStrategy("cowatch", coWatch)
+	RegisterRelatedStrategy(DefaultRelatedStrategy, &WeightedStrategy{
+		Signals: []WeightedSignal{
+			{Strategy: tags, Weight: 0.5},
+			{Strategy: coWatch, Weight: 0.35},
+			{Strategy: uploader, Weight: 0.15},
+		},
+	})
+}
+
+// RegisterRelatedStrategy は推薦方式を名前で登録する（同じ名前の場合は置き換える）
+func RegisterRelatedStrategy(name string, strategy RelatedStrategy) {
+	relatedMu.Lock()
+	defer relatedMu.Unlock()
+	relatedStrategies[name] = strategy
+}
+
+// RelatedStrategyNames は登録されている推薦方式の名前を返す
+func RelatedStrategyNames() []string {
+	relatedMu.RLock()
+	defer relatedMu.RUnlock()
+
+	names := make([]string, 0, len(relatedStrategies))
+	for name := range relatedStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetRelatedVideos は指定した推薦方式で関連動画を取得する
+// 候補は公開中の動画のみで、スコアの高い順に返す
+func GetRelatedVideos(video *models.Video, strategyName string, limit int) ([]models.Video, error) {
+	if strategyName == "" {
+		strategyName = DefaultRelatedStrategy
+	}
+
+	relatedMu.RLock()
+	strategy, ok := relatedStrategies[strategyName]
+	relatedMu.RUnlock()
+	if !ok {
+		return nil, ErrUnknownRelatedStrategy
+	}
+
+	candidates, err := strategy.Candidates(video, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	videoIDs := make([]uint, 0, limit)
+	for _, c := range candidates {
+		if c.VideoID == video.ID {
+			continue
+		}
+		videoIDs = append(videoIDs, c.VideoID)
+		if len(videoIDs) >= limit {
+			break
+		}
+	}
+	return models.GetPublicVideosByIDs(videoIDs)
+}
+
+// WeightedSignal は重み付きで組み合わせる推薦方式
+type WeightedSignal struct {
+	Strategy RelatedStrategy
+	Weight   float64
+}
+
+// WeightedStrategy は複数の推薦方式のスコアを正規化し、重み付きで合計する
+type WeightedStrategy struct {
+	Signals []WeightedSignal
+}
+
+func (s *WeightedStrategy) Candidates(video *models.Video, limit int) ([]models.RelatedCandidate, error) {
+	scores := map[uint]float64{}
+	for _, signal := range s.Signals {
+		candidates, err := signal.Strategy.Candidates(video, relatedCandidateLimit)
+		if err != nil {
+			return nil, err
+		}
+
+		// 信号ごとにスコアの尺度が異なるため、最大値で 0〜1 に正規化する
+		var max float64
+		for _, c := range candidates {
+			if c.Score > max {
+				max = c.Score
+			}
+		}
+		if max == 0 {
+			continue
+		}
+		for _, c := range candidates {
+			scores[c.VideoID] += signal.Weight * c.Score / max
+		}
+	}
+
+	combined := make([]models.RelatedCandidate, 0, len(scores))
+	for videoID, score := range scores {
+		combined = append(combined, models.RelatedCandidate{VideoID: videoID, Score: score})
+	}
+	sort.Slice(combined, func(i, k int) bool {
+		if combined[i].Score != combined[k].Score {
+			return combined[i].Score > combined[k].Score
+		}
+		return combined[i].VideoID > combined[k].VideoID
+	})
+	if len(combined) > limit {
+		combined = combined[:limit]
+	}
+	return combined, nil
+}
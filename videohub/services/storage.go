@@ -1,7 +1,9 @@
 package services
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"live/common"
 	"os"
 	"time"
@@ -97,6 +99,14 @@ func InitMinioService() (*StorageService, error) {
 	}, nil
 }
 
+// Initialize storage service according to ENV_MODE
+func InitStorageService() (*StorageService, error) {
+	if os.Getenv("ENV_MODE") == "local" {
+		return InitMinioService()
+	}
+	return NewStorageService()
+}
+
 func (s *StorageService) GetVideoPresignedURL(videoPath string) (string, error) {
 
 	if s.MinioClient != nil {
@@ -126,3 +136,33 @@ func (s *StorageService) GetVideoPresignedURL(videoPath string) (string, error)
 		return "", fmt.Errorf("Storage service is not initialized")
 	}
 }
+
+// ストレージに保存されたファイルを読み込む（maxSize バイトを超える部分は読み込まない）
+func (s *StorageService) ReadFile(ctx context.Context, objectName string, maxSize int64) ([]byte, error) {
+	var body io.ReadCloser
+	if s.MinioClient != nil {
+		object, err := s.MinioClient.GetObject(ctx, s.Bucket, objectName, minio.GetObjectOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get object from MinIO: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		body = object
+	} else if s.Client != nil {
+		output, err := s.Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(objectName),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get object from S3: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		body = output.Body
+	} else {
+		return nil, fmt.Errorf("Storage service is not initialized")
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, maxSize))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read object: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+	}
+	return data, nil
+}
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"fmt"
+	"live/common"
+	"live/videohub/models"
+	"sync"
+	"time"
+)
+
+const (
+	// 同じ視聴者の再生を1回とみなす期間
+	viewDedupWindow = 30 * time.Minute
+	// バッファした再生数をDBに書き込む間隔
+	viewFlushInterval = 10 * time.Second
+	// この件数を超えたら間隔を待たずに書き込む
+	viewFlushBatchSize = 500
+)
+
+// 再生数を日別に集計するためのキー
+type viewKey struct {
+	VideoID uint
+	Date    string
+}
+
+// ViewCounter は再生数を重複排除しながらメモリ上にバッファし、まとめてDBに書き込む
+type ViewCounter struct {
+	mu      sync.Mutex
+	seen    map[string]time.Time // 重複排除キーごとの有効期限
+	pending map[viewKey]uint64
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	started bool
+}
+
+// アプリケーション全体で共有する再生数カウンタ
+var Views = NewViewCounter()
+
+func NewViewCounter() *ViewCounter {
+	return &ViewCounter{
+		seen:    map[string]time.Time{},
+		pending: map[viewKey]uint64{},
+		flushCh: make(chan struct{}, 1),
+		stopCh:  make(chan struct{}),
+		doneCh:  make(chan struct{}),
+	}
+}
+
+// Record は再生を記録する。期間内に同じ視聴者が再生していた場合は false を返す
+// 視聴者はログインユーザーであればユーザーID、それ以外はIPアドレスで識別する
+func (c *ViewCounter) Record(videoID uint, userID uint, loggedIn bool, ip string) bool {
+	viewer := "ip:" + ip
+	if loggedIn {
+		viewer = fmt.Sprintf("user:%d", userID)
+	}
+	dedupKey := fmt.Sprintf("%d:%s", videoID, viewer)
+	now := time.Now()
+
+	c.mu.Lock()
+	if expires, ok := c.seen[dedupKey]; ok && now.Before(expires) {
+		c.mu.Unlock()
+		return false
+	}
+	c.seen[dedupKey] = now.Add(viewDedupWindow)
+	c.pending[viewKey{VideoID: videoID, Date: now.Format("2006-01-02")}]++
+	full := len(c.pending) >= viewFlushBatchSize
+	c.mu.Unlock()
+
+	if full {
+		select {
+		case c.flushCh <- struct{}{}:
+		default:
+		}
+	}
+	return true
+}
+
+// Pending はまだDBに書き込まれていない再生数を返す
+func (c *ViewCounter) Pending(videoID uint) uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var views uint64
+	for key, count := range c.pending {
+		if key.VideoID == videoID {
+			views += count
+		}
+	}
+	return views
+}
+
+// Start は定期的な書き込みを開始する
+func (c *ViewCounter) Start() {
+	c.mu.Lock()
+	if c.started {
+		c.mu.Unlock()
+		return
+	}
+	c.started = true
+	c.mu.Unlock()
+
+	go func() {
+		defer close(c.doneCh)
+		ticker := time.NewTicker(viewFlushInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.Flush()
+			case <-c.flushCh:
+				c.Flush()
+			case <-c.stopCh:
+				c.Flush()
+				return
+			}
+		}
+	}()
+}
+
+// Stop は定期的な書き込みを止め、残っている再生数を書き込む
+func (c *ViewCounter) Stop() {
+	c.mu.Lock()
+	started := c.started
+	c.mu.Unlock()
+	if !started {
+		c.Flush()
+		return
+	}
+
+	close(c.stopCh)
+	<-c.doneCh
+}
+
+// Flush はバッファした再生数をDBに書き込む
+// 書き込みに失敗した場合は次回の書き込みで再試行する
+func (c *ViewCounter) Flush() {
+	now := time.Now()
+
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = map[viewKey]uint64{}
+	// 期限切れの重複排除キーを削除
+	for key, expires := range c.seen {
+		if !now.Before(expires) {
+			delete(c.seen, key)
+		}
+	}
+	c.mu.Unlock()
+
+	if len(pending) == 0 {
+		return
+	}
+
+	counts := make([]models.DailyViewCount, 0, len(pending))
+	for key, views := range pending {
+		date, err := time.ParseInLocation("2006-01-02", key.Date, time.Local)
+		if err != nil {
+			continue
+		}
+		counts = append(counts, models.DailyViewCount{VideoID: key.VideoID, Date: date, Views: views})
+	}
+
+	if err := models.FlushViewCounts(counts); err != nil {
+		common.LogVideoHubError(fmt.Errorf("Failed to flush view counts: %w", err))
+
+		c.mu.Lock()
+		for key, views := range pending {
+			c.pending[key] += views
+		}
+		c.mu.Unlock()
+		return
+	}
+
+	common.LogVideoHubInfo(fmt.Sprintf("Flushed view counts for %d videos", len(counts)))
+}
@@ -0,0 +1,19 @@
+package videohub
+
+import (
+	"live/videohub/services"
+)
+
+// StartWorkers はバックグラウンド処理を開始する
+func StartWorkers() {
+	services.Views.Start()
+	services.Rankings.Start()
+	services.Publisher.Start()
+}
+
+// StopWorkers はバックグラウンド処理を止め、バッファしているデータを書き込む
+func StopWorkers() {
+	services.Publisher.Stop()
+	services.Rankings.Stop()
+	services.Views.Stop()
+}
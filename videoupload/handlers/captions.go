@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"live/common"
+	"live/videoupload/models"
+	"live/videoupload/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+	"gorm.io/gorm"
+)
+
+// 字幕ファイル（SRT または WebVTT）をアップロードする
+// フォーム: file（字幕ファイル）、language（言語コード）、label（表示名、省略時は言語名または既存の表示名）
+func UploadCaption(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, common.MaxSubtitleFileSize+(1<<20))
+	if err := r.ParseMultipartForm(common.MaxSubtitleFileSize); err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "リクエストの解析に失敗しました", http.StatusBadRequest)
+		return
+	}
+
+	video, ok := loadOwnVideo(w, r)
+	if !ok {
+		return
+	}
+
+	lang, err := common.NormalizeLanguageTag(r.FormValue("language"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	label := strings.TrimSpace(r.FormValue("label"))
+	if utf8.RuneCountInString(label) > common.MaxSubtitleLabelLength {
+		http.Error(w, "字幕の表示名は"+strconv.Itoa(common.MaxSubtitleLabelLength)+"文字以内で指定してください", http.StatusBadRequest)
+		return
+	}
+
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "字幕ファイルの取得に失敗しました", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, common.MaxSubtitleFileSize+1))
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "字幕ファイルの読み込みに失敗しました", http.StatusBadRequest)
+		return
+	}
+	if len(data) > common.MaxSubtitleFileSize {
+		http.Error(w, "字幕ファイルが大きすぎます", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	vtt, err := common.ConvertSubtitleToWebVTT(data)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	filePath, err := storageService.UploadCaptionFile(vtt)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "字幕ファイルのアップロードに失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	// 表示名の既定値は言語名（例: 日本語、English）
+	defaultLabel := display.Self.Name(language.Make(lang))
+	if defaultLabel == "" {
+		defaultLabel = lang
+	}
+
+	caption, oldPath, err := models.SaveCaption(video.ID, lang, label, defaultLabel, filePath)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		deleteStoredFile(storageService, filePath)
+		http.Error(w, "字幕情報の保存に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	// 置き換えた字幕のファイルを削除する
+	if oldPath != "" {
+		deleteStoredFile(storageService, oldPath)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(caption); err != nil {
+		common.LogVideoUploadError(err)
+	}
+}
+
+// 字幕を削除する
+func DeleteCaption(w http.ResponseWriter, r *http.Request) {
+	video, ok := loadOwnVideo(w, r)
+	if !ok {
+		return
+	}
+
+	lang, err := common.NormalizeLanguageTag(mux.Vars(r)["language"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	filePath, err := models.DeleteCaption(video.ID, lang)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "字幕が見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoUploadError(err)
+		http.Error(w, "字幕の削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	if storageService, err := services.InitStorageService(); err != nil {
+		common.LogVideoUploadError(err)
+	} else {
+		deleteStoredFile(storageService, filePath)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 自分の動画を取得する
+// 失敗した場合はエラーレスポンスを書き込み false を返す
+func loadOwnVideo(w http.ResponseWriter, r *http.Request) (*models.Video, bool) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	videoID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "無効な動画IDです", http.StatusBadRequest)
+		return nil, false
+	}
+
+	video, err := models.GetVideoByID(uint(videoID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "動画が見つかりません", http.StatusNotFound)
+			return nil, false
+		}
+		common.LogVideoUploadError(err)
+		http.Error(w, "動画の取得に失敗しました", http.StatusInternalServerError)
+		return nil, false
+	}
+
+	// 動画の所有者のみ編集できる
+	if video.UserID != userID {
+		http.Error(w, "この動画を編集する権限がありません", http.StatusForbidden)
+		return nil, false
+	}
+	return video, true
+}
+
+// 不要になったファイルを削除する（失敗してもリクエストは成功として扱う）
+func deleteStoredFile(storageService *services.StorageService, filePath string) {
+	if err := storageService.DeleteFile(filePath); err != nil {
+		common.LogVideoUploadError(err)
+	}
+}
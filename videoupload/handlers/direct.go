@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"live/common"
+	"live/videoupload/models"
+	"live/videoupload/services"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const (
+	// 署名付きURLの有効期限（過ぎても完了しないアップロードは削除する）
+	directUploadExpiration = 12 * time.Hour
+	// このサイズを超えるファイルはマルチパートアップロードにする（パートのサイズも同じ）
+	directUploadPartSize int64 = 64 << 20
+)
+
+// 直接アップロードの開始リクエスト
+type InitiateDirectUploadRequest struct {
+	Filename    string   `json:"filename" validate:"required"`
+	ContentType string   `json:"content_type" validate:"required"`
+	Size        int64    `json:"size" validate:"required,gt=0"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Visibility  string   `json:"visibility"`
+	Category    string   `json:"category"`
+	Tags        []string `json:"tags"`
+	Language    string   `json:"language"`
+	Status      string   `json:"status"`
+	PublishAt   string   `json:"publish_at"`
+	Duration    uint     `json:"duration"`
+}
+
+// 直接アップロードの開始レスポンス
+// ファイルが小さい場合は url に1回で PUT し、大きい場合は parts の各URLに part_size ずつ PUT する
+type DirectUploadResponse struct {
+	VideoID  uint               `json:"video_id"`
+	FileID   uint               `json:"file_id"`
+	Method   string             `json:"method"`
+	URL      string             `json:"url,omitempty"`
+	PartSize int64              `json:"part_size,omitempty"`
+	Parts    []DirectUploadPart `json:"parts,omitempty"`
+	Headers  map[string]string  `json:"headers"`
+	Expires  time.Time          `json:"expires"`
+}
+
+type DirectUploadPart struct {
+	PartNumber int    `json:"part_number"`
+	URL        string `json:"url"`
+}
+
+// 動画ファイルをストレージに直接アップロードするための署名付きURLを発行する
+// 動画と動画ファイルはアップロードが完了するまで下書きとして作成する
+func InitiateDirectUpload(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	var req InitiateDirectUploadRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "無効なリクエストです", http.StatusBadRequest)
+		return
+	}
+	validate := validator.New()
+	if err := validate.Struct(req); err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "入力内容に誤りがあります", http.StatusBadRequest)
+		return
+	}
+	// アップロードの上限の確認（署名付きURLを発行する前に行う）
+	// 動画ファイルを記録するまでは容量を確保し、同時に開始したアップロードが上限を超えないようにする
+	_, reservation, ok := reserveUploadQuota(w, userID, req.Size, req.Duration, 0)
+	if !ok {
+		return
+	}
+	defer releaseUploadQuota(reservation)
+	mediaType, _, err := mime.ParseMediaType(req.ContentType)
+	if err != nil || !strings.HasPrefix(mediaType, "video/") {
+		http.Error(w, "動画ファイルの Content-Type を指定してください", http.StatusBadRequest)
+		return
+	}
+
+	objectName, err := services.VideoObjectName(req.Filename)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 動画情報の検証
+	fields := map[string]string{
+		"title":       req.Title,
+		"description": req.Description,
+		"visibility":  req.Visibility,
+		"category":    req.Category,
+		"language":    req.Language,
+		"status":      req.Status,
+		"publish_at":  req.PublishAt,
+		"duration":    strconv.FormatUint(uint64(req.Duration), 10),
+	}
+	input, err := parseVideoInput(userID, func(key string) string { return fields[key] }, req.Tags, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	expires := time.Now().Add(directUploadExpiration).Truncate(time.Second)
+	upload := &models.DirectUpload{
+		UserID:      userID,
+		FileSize:    req.Size,
+		ContentType: req.ContentType,
+		Expires:     expires,
+	}
+	response := DirectUploadResponse{
+		Method:  http.MethodPut,
+		Headers: map[string]string{"Content-Type": req.ContentType},
+		Expires: expires,
+	}
+
+	// 署名付きURLの発行（大きいファイルはマルチパートアップロードを開始して各パートのURLを発行する）
+	if req.Size > directUploadPartSize {
+		uploadID, err := storageService.CreateMultipartUpload(r.Context(), objectName, req.ContentType)
+		if err != nil {
+			common.LogVideoUploadError(err)
+			http.Error(w, "アップロードの開始に失敗しました", http.StatusInternalServerError)
+			return
+		}
+		upload.UploadID = &uploadID
+		upload.PartSize = directUploadPartSize
+
+		partCount := int((req.Size + directUploadPartSize - 1) / directUploadPartSize)
+		for number := 1; number <= partCount; number++ {
+			url, err := storageService.PresignUploadPart(objectName, uploadID, number, directUploadExpiration)
+			if err != nil {
+				common.LogVideoUploadError(err)
+				abortDirectUpload(storageService, objectName, uploadID)
+				http.Error(w, "署名付きURLの発行に失敗しました", http.StatusInternalServerError)
+				return
+			}
+			response.Parts = append(response.Parts, DirectUploadPart{PartNumber: number, URL: url})
+		}
+		response.PartSize = directUploadPartSize
+	} else {
+		response.URL, err = storageService.PresignVideoPut(objectName, req.ContentType, directUploadExpiration)
+		if err != nil {
+			common.LogVideoUploadError(err)
+			http.Error(w, "署名付きURLの発行に失敗しました", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// アップロード前の動画と動画ファイルを作成する
+	videoFile := &models.VideoFile{
+		FilePath: objectName,
+		Duration: input.Duration,
+		FileSize: uint64(req.Size),
+		Format:   req.ContentType,
+	}
+	if err := models.CreateDirectUpload(input.Video, input.Tags, videoFile, upload); err != nil {
+		common.LogVideoUploadError(err)
+		if upload.UploadID != nil {
+			abortDirectUpload(storageService, objectName, *upload.UploadID)
+		}
+		http.Error(w, "動画情報の保存に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	response.VideoID = input.Video.ID
+	response.FileID = videoFile.ID
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		common.LogVideoUploadError(err)
+	}
+}
+
+// 直接アップロードしたファイルを確認し、アップロードを完了する
+// ファイルが存在し、サイズと Content-Type が開始時の指定と一致し、内容が動画の形式である場合のみ動画を公開状態にする
+func CompleteDirectUpload(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	fileID, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		http.Error(w, "無効なアップロードIDです", http.StatusBadRequest)
+		return
+	}
+
+	upload, err := models.GetDirectUpload(uint(fileID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "アップロードが見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	if upload.UserID != userID {
+		http.Error(w, "アップロードが見つかりません", http.StatusNotFound)
+		return
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	// マルチパートアップロードはすべてのパートが揃っていることを確認してから結合する
+	if upload.UploadID != nil {
+		if message, err := completeDirectMultipartUpload(r.Context(), storageService, upload); err != nil {
+			common.LogVideoUploadError(err)
+			http.Error(w, "アップロードの完了に失敗しました", http.StatusInternalServerError)
+			return
+		} else if message != "" {
+			http.Error(w, message, http.StatusBadRequest)
+			return
+		}
+	}
+
+	stored, err := storageService.StatFile(r.Context(), upload.FilePath)
+	if err != nil {
+		if errors.Is(err, services.ErrFileNotFound) {
+			http.Error(w, "ファイルがアップロードされていません", http.StatusBadRequest)
+			return
+		}
+		common.LogVideoUploadError(err)
+		http.Error(w, "ファイル情報の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	// 開始時の指定と異なるファイルは削除する（有効期限内であれば再度アップロードできる）
+	if stored.Size != upload.FileSize || !sameMediaType(stored.ContentType, upload.ContentType) {
+		deleteStoredFile(storageService, upload.FilePath)
+		http.Error(w, fmt.Sprintf("アップロードされたファイルが開始時の指定（%d バイト、%s）と一致しません", upload.FileSize, upload.ContentType), http.StatusBadRequest)
+		return
+	}
+
+	// ファイルの内容から形式を判別する
+	head, err := storageService.ReadFileHead(r.Context(), upload.FilePath, services.SniffLength)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ファイルの読み込みに失敗しました", http.StatusInternalServerError)
+		return
+	}
+	format, err := services.ValidateVideoContent(upload.FilePath, head)
+	if err != nil {
+		var formatErr *services.FormatError
+		if errors.As(err, &formatErr) {
+			deleteStoredFile(storageService, upload.FilePath)
+			writeFormatError(w, formatErr)
+			return
+		}
+		common.LogVideoUploadError(err)
+		http.Error(w, "ファイルの形式の確認に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	videoFile := &models.VideoFile{FilePath: upload.FilePath, Format: format}
+	probeVideoFile(videoFile, storageService.NewFileReaderAt(r.Context(), upload.FilePath), stored.Size)
+
+	// 解析した再生時間が上限を超える場合はファイルを削除する
+	quota, err := services.LoadUploadQuota(userID)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの上限の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	if err := quota.CheckDuration(videoFile.Duration); err != nil {
+		deleteStoredFile(storageService, upload.FilePath)
+		writeQuotaError(w, err)
+		return
+	}
+
+	video, err := models.CompleteDirectUpload(upload, videoFile)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "アップロードが見つかりません", http.StatusNotFound)
+			return
+		}
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの完了に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	afterVideoSaved(video)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(map[string]interface{}{"video_id": video.ID, "video_url": upload.FilePath})
+}
+
+// アップロード済みのパートを確認してマルチパートアップロードを結合する
+// パートが揃っていない場合はクライアントに返すメッセージを返す
+func completeDirectMultipartUpload(ctx context.Context, storageService *services.StorageService, upload *models.DirectUpload) (string, error) {
+	parts, err := storageService.ListUploadedParts(ctx, upload.FilePath, *upload.UploadID)
+	if err != nil {
+		// 前回の完了処理で結合済みの場合は、ファイルの確認に進む
+		if services.IsNoSuchUpload(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	partCount := int((upload.FileSize + upload.PartSize - 1) / upload.PartSize)
+	if len(parts) != partCount {
+		return fmt.Sprintf("アップロードされたパートが足りません（%d / %d）", len(parts), partCount), nil
+	}
+	for i, part := range parts {
+		expected := upload.PartSize
+		if i == partCount-1 {
+			expected = upload.FileSize - upload.PartSize*int64(partCount-1)
+		}
+		if part.Number != i+1 || part.Size != expected {
+			return fmt.Sprintf("パート %d のサイズが正しくありません", i+1), nil
+		}
+	}
+
+	if err := storageService.CompleteMultipartUpload(ctx, upload.FilePath, *upload.UploadID, parts); err != nil {
+		return "", err
+	}
+	return "", nil
+}
+
+// 開始したマルチパートアップロードを中止する（失敗してもアップロードの削除ジョブで削除される）
+func abortDirectUpload(storageService *services.StorageService, objectName, uploadID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := storageService.AbortMultipartUpload(ctx, objectName, uploadID); err != nil {
+		common.LogVideoUploadError(err)
+	}
+}
+
+// Content-Type のパラメーター（charset など）を除いて比較する
+func sameMediaType(a, b string) bool {
+	mediaA, _, errA := mime.ParseMediaType(a)
+	mediaB, _, errB := mime.ParseMediaType(b)
+	return errA == nil && errB == nil && strings.EqualFold(mediaA, mediaB)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"live/common"
+	"live/videoupload/models"
+	"live/videoupload/services"
+	"net/http"
+	"time"
+)
+
+// 動画の処理状況のレスポンス
+type ProcessingStatusResponse struct {
+	VideoID uint                   `json:"video_id"`
+	Status  string                 `json:"status"` // 動画ファイル全体の状態（pending / processing / completed / failed）
+	Files   []ProcessingFileStatus `json:"files"`
+}
+
+// 動画ファイルの処理状況
+type ProcessingFileStatus struct {
+	FileID      uint       `json:"file_id"`
+	Status      string     `json:"status"`
+	Uploaded    bool       `json:"uploaded"`
+	Step        *string    `json:"step"`  // 実行中または失敗した処理の段階
+	Error       *string    `json:"error"` // 最後に失敗した処理のエラー内容
+	Attempts    uint       `json:"attempts"`
+	NextAttempt *time.Time `json:"next_attempt"`
+	Processed   *time.Time `json:"processed"`
+}
+
+// 動画の処理状況を取得する（投稿者のみ）
+func GetProcessingStatus(w http.ResponseWriter, r *http.Request) {
+	video, ok := loadOwnVideo(w, r)
+	if !ok {
+		return
+	}
+	writeProcessingStatus(w, video.ID, http.StatusOK)
+}
+
+// 処理に失敗した動画ファイルを再試行する（投稿者のみ）
+func RetryProcessing(w http.ResponseWriter, r *http.Request) {
+	video, ok := loadOwnVideo(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := models.RetryFailedVideoFiles(video.ID)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "処理の再試行に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	if n == 0 {
+		http.Error(w, "処理に失敗した動画ファイルがありません", http.StatusConflict)
+		return
+	}
+	services.Processor.Wake()
+
+	writeProcessingStatus(w, video.ID, http.StatusAccepted)
+}
+
+func writeProcessingStatus(w http.ResponseWriter, videoID uint, status int) {
+	files, err := models.GetVideoFilesByVideoID(videoID)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "処理状況の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	response := ProcessingStatusResponse{
+		VideoID: videoID,
+		Status:  overallProcessingStatus(files),
+		Files:   make([]ProcessingFileStatus, 0, len(files)),
+	}
+	for _, f := range files {
+		response.Files = append(response.Files, ProcessingFileStatus{
+			FileID:      f.ID,
+			Status:      f.Status,
+			Uploaded:    f.Uploaded != nil,
+			Step:        f.ProcessingStep,
+			Error:       f.ProcessingError,
+			Attempts:    f.ProcessingAttempts,
+			NextAttempt: f.NextAttempt,
+			Processed:   f.Processed,
+		})
+	}
+
+	// ポーリングで最新の状況を取得できるようキャッシュさせない
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		common.LogVideoUploadError(err)
+	}
+}
+
+// 動画ファイル全体の状態（失敗したファイルがあれば failed、すべて完了していれば completed）
+func overallProcessingStatus(files []models.VideoFile) string {
+	status := models.VideoFileStatusCompleted
+	if len(files) == 0 {
+		status = models.VideoFileStatusPending
+	}
+	for _, f := range files {
+		switch f.Status {
+		case models.VideoFileStatusFailed:
+			return models.VideoFileStatusFailed
+		case models.VideoFileStatusProcessing:
+			status = models.VideoFileStatusProcessing
+		case models.VideoFileStatusPending:
+			if status != models.VideoFileStatusProcessing {
+				status = models.VideoFileStatusPending
+			}
+		}
+	}
+	return status
+}
@@ -0,0 +1,307 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"live/common"
+	"live/videoupload/models"
+	"live/videoupload/services"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+// アップロードの上限と使用量のレスポンス（上限と残りが null の場合は無制限）
+type UploadQuotaResponse struct {
+	UserID    uint                 `json:"user_id"`
+	Plan      string               `json:"plan"`
+	Limits    UploadQuotaLimits    `json:"limits"`
+	Usage     UploadQuotaUsage     `json:"usage"`
+	Remaining UploadQuotaRemaining `json:"remaining"`
+	Override  *UploadQuotaOverride `json:"override,omitempty"` // 管理者が設定した上限（管理者向けのレスポンスのみ）
+}
+
+type UploadQuotaLimits struct {
+	MaxTotalBytes    *uint64 `json:"max_total_bytes"`
+	MaxFileSize      uint64  `json:"max_file_size"`
+	MaxDuration      *uint   `json:"max_duration"`
+	MaxUploadsPerDay *uint   `json:"max_uploads_per_day"`
+}
+
+type UploadQuotaUsage struct {
+	TotalBytes   uint64 `json:"total_bytes"`
+	UploadsToday uint   `json:"uploads_today"` // 24時間以内に開始したアップロードの数
+}
+
+type UploadQuotaRemaining struct {
+	Bytes         *uint64 `json:"bytes"`
+	UploadsToday  *uint   `json:"uploads_today"`
+	MaxUploadSize int64   `json:"max_upload_size"` // 次にアップロードできるファイルの最大サイズ
+}
+
+// 管理者が設定するユーザーのプランと上限（上限が null の場合はプランの上限を使う）
+type UploadQuotaOverride struct {
+	Plan             *string `json:"plan"` // null の場合は既定のプラン
+	MaxTotalBytes    *uint64 `json:"max_total_bytes"`
+	MaxFileSize      *uint64 `json:"max_file_size" validate:"omitempty,gt=0"`
+	MaxDuration      *uint   `json:"max_duration" validate:"omitempty,gt=0"`
+	MaxUploadsPerDay *uint   `json:"max_uploads_per_day"`
+	Note             string  `json:"note" validate:"max=255"`
+}
+
+// アップロードのプラン
+type UploadPlanResponse struct {
+	Name             string  `json:"name"`
+	MaxTotalBytes    *uint64 `json:"max_total_bytes"`
+	MaxFileSize      *uint64 `json:"max_file_size"`
+	MaxDuration      *uint   `json:"max_duration"`
+	MaxUploadsPerDay *uint   `json:"max_uploads_per_day"`
+	Default          bool    `json:"default"`
+}
+
+// 自分のアップロードの上限と使用量を取得する
+func GetMyUploadQuota(w http.ResponseWriter, r *http.Request) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+	writeUploadQuota(w, userID, nil)
+}
+
+// アップロードのプランの一覧を取得する（管理者のみ）
+func ListUploadPlans(w http.ResponseWriter, r *http.Request) {
+	plans, err := models.GetUploadPlans()
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "プランの取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	response := make([]UploadPlanResponse, 0, len(plans))
+	for _, p := range plans {
+		response = append(response, UploadPlanResponse{
+			Name:             p.Name,
+			MaxTotalBytes:    p.MaxTotalBytes,
+			MaxFileSize:      p.MaxFileSize,
+			MaxDuration:      p.MaxDuration,
+			MaxUploadsPerDay: p.MaxUploadsPerDay,
+			Default:          p.IsDefault,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		common.LogVideoUploadError(err)
+	}
+}
+
+// ユーザーのアップロードの上限と使用量を、管理者が設定した上限とともに取得する（管理者のみ）
+func GetUserUploadQuota(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseQuotaUserID(w, r)
+	if !ok {
+		return
+	}
+	_, override, err := models.GetUserUploadPlan(userID)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの上限の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	writeUploadQuota(w, userID, quotaOverrideResponse(override))
+}
+
+// ユーザーのプランと上限を設定する（管理者のみ）
+func UpdateUserUploadQuota(w http.ResponseWriter, r *http.Request) {
+	adminID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+	userID, ok := parseQuotaUserID(w, r)
+	if !ok {
+		return
+	}
+
+	var req UploadQuotaOverride
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "無効なリクエストです", http.StatusBadRequest)
+		return
+	}
+	if err := validator.New().Struct(req); err != nil {
+		http.Error(w, "入力内容に誤りがあります", http.StatusBadRequest)
+		return
+	}
+
+	quota := &models.UserUploadQuota{
+		UserID:           userID,
+		MaxTotalBytes:    req.MaxTotalBytes,
+		MaxFileSize:      req.MaxFileSize,
+		MaxDuration:      req.MaxDuration,
+		MaxUploadsPerDay: req.MaxUploadsPerDay,
+		Note:             req.Note,
+		UpdatedBy:        &adminID,
+	}
+	if req.Plan != nil {
+		plan, err := models.GetUploadPlanByName(*req.Plan)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				http.Error(w, "プランが見つかりません", http.StatusBadRequest)
+				return
+			}
+			common.LogVideoUploadError(err)
+			http.Error(w, "プランの取得に失敗しました", http.StatusInternalServerError)
+			return
+		}
+		quota.PlanID = &plan.ID
+	}
+	if err := models.SaveUserUploadQuota(quota); err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの上限の保存に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	writeUploadQuota(w, userID, &req)
+}
+
+// ユーザーのプランと上限の設定を削除し、既定のプランに戻す（管理者のみ）
+func DeleteUserUploadQuota(w http.ResponseWriter, r *http.Request) {
+	userID, ok := parseQuotaUserID(w, r)
+	if !ok {
+		return
+	}
+	deleted, err := models.DeleteUserUploadQuota(userID)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの上限の削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		http.Error(w, "アップロードの上限は設定されていません", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// URLパスから対象のユーザーIDを取得し、ユーザーが存在することを確認する
+// 失敗した場合はエラーレスポンスを書き込み false を返す
+func parseQuotaUserID(w http.ResponseWriter, r *http.Request) (uint, bool) {
+	id, err := strconv.ParseUint(mux.Vars(r)["userID"], 10, 64)
+	if err != nil {
+		http.Error(w, "無効なユーザーIDです", http.StatusBadRequest)
+		return 0, false
+	}
+	if _, err := common.GetUserStatus(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "ユーザーが見つかりません", http.StatusNotFound)
+			return 0, false
+		}
+		common.LogVideoUploadError(err)
+		http.Error(w, "ユーザーの取得に失敗しました", http.StatusInternalServerError)
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func quotaOverrideResponse(quota *models.UserUploadQuota) *UploadQuotaOverride {
+	if quota == nil {
+		return nil
+	}
+	override := &UploadQuotaOverride{
+		MaxTotalBytes:    quota.MaxTotalBytes,
+		MaxFileSize:      quota.MaxFileSize,
+		MaxDuration:      quota.MaxDuration,
+		MaxUploadsPerDay: quota.MaxUploadsPerDay,
+		Note:             quota.Note,
+	}
+	if quota.PlanID != nil {
+		override.Plan = &quota.Plan.Name
+	}
+	return override
+}
+
+func writeUploadQuota(w http.ResponseWriter, userID uint, override *UploadQuotaOverride) {
+	quota, err := services.LoadUploadQuota(userID)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの上限の取得に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	response := UploadQuotaResponse{
+		UserID: userID,
+		Plan:   quota.Plan,
+		Limits: UploadQuotaLimits{
+			MaxTotalBytes:    quota.MaxTotalBytes,
+			MaxFileSize:      quota.MaxFileSize,
+			MaxDuration:      quota.MaxDuration,
+			MaxUploadsPerDay: quota.MaxUploadsPerDay,
+		},
+		Usage: UploadQuotaUsage{
+			TotalBytes:   quota.Usage.TotalBytes,
+			UploadsToday: quota.Usage.UploadsToday,
+		},
+		Remaining: UploadQuotaRemaining{
+			Bytes:         quota.RemainingBytes(),
+			UploadsToday:  quota.RemainingUploads(),
+			MaxUploadSize: quota.MaxUploadSize(),
+		},
+		Override: override,
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		common.LogVideoUploadError(err)
+	}
+}
+
+// アップロードの上限を超えた場合のエラーを返す（本文は「エラーコード: メッセージ」）
+// 1日あたりの数は 429、再生時間は 400、サイズは 413 を返し、上限の超過以外のエラーは 500 を返す
+func writeQuotaError(w http.ResponseWriter, err error) {
+	var quotaErr *services.QuotaError
+	if !errors.As(err, &quotaErr) {
+		common.LogVideoUploadError(err)
+		http.Error(w, "内部サーバーエラー", http.StatusInternalServerError)
+		return
+	}
+	status := http.StatusRequestEntityTooLarge
+	switch quotaErr.Code {
+	case services.QuotaErrorDaily:
+		status = http.StatusTooManyRequests
+	case services.QuotaErrorDuration:
+		status = http.StatusBadRequest
+	}
+	http.Error(w, quotaErr.Error(), status)
+}
+
+// アップロードを開始できるか確認し、受信するファイルのために容量を確保する
+// 開始できない場合はエラーレスポンスを書き込み false を返す
+// 確保した容量は releaseUploadQuota で解放する
+func reserveUploadQuota(w http.ResponseWriter, userID uint, size int64, duration uint, sizeLimit int64) (*services.UploadQuota, *models.UploadReservation, bool) {
+	quota, reservation, err := services.ReserveUpload(userID, size, duration, sizeLimit)
+	if err != nil {
+		var quotaErr *services.QuotaError
+		if errors.As(err, &quotaErr) {
+			writeQuotaError(w, err)
+			return nil, nil, false
+		}
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの上限の取得に失敗しました", http.StatusInternalServerError)
+		return nil, nil, false
+	}
+	return quota, reservation, true
+}
+
+// 確保した容量を解放する
+func releaseUploadQuota(reservation *models.UploadReservation) {
+	if err := services.ReleaseUploadReservation(reservation); err != nil {
+		common.LogVideoUploadError(err)
+	}
+}
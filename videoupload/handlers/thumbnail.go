@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"live/common"
+	"live/videoupload/models"
+	"live/videoupload/services"
+	"net/http"
+)
+
+// 動画のサムネイル画像をアップロードする
+// フォーム: file（画像ファイル）
+// 表示サイズごとに縮小した画像（small / medium / large / webp）を作成し、既存のサムネイルを置き換える
+func UploadThumbnail(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, services.MaxThumbnailFileSize+(1<<20))
+	if err := r.ParseMultipartForm(services.MaxThumbnailFileSize); err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "リクエストの解析に失敗しました", uploadReadErrorStatus(err))
+		return
+	}
+
+	video, ok := loadOwnVideo(w, r)
+	if !ok {
+		return
+	}
+
+	file, fileHeader, err := r.FormFile("file")
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "サムネイル画像の取得に失敗しました", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := readThumbnailFile(file)
+	if err != nil {
+		writeThumbnailError(w, err)
+		return
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	thumbnails, err := storageService.UploadThumbnailFile(r.Context(), fileHeader.Filename, data)
+	if err != nil {
+		writeThumbnailError(w, err)
+		return
+	}
+
+	oldPaths, err := models.ReplaceVideoThumbnails(video.ID, thumbnails)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		storageService.DeleteThumbnailFiles(thumbnails)
+		http.Error(w, "サムネイル情報の保存に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	// 置き換えたサムネイルのファイルを削除する
+	for _, path := range oldPaths {
+		deleteStoredFile(storageService, path)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(thumbnails); err != nil {
+		common.LogVideoUploadError(err)
+	}
+}
+
+// サムネイル画像を最大サイズまで読み込む
+func readThumbnailFile(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, services.MaxThumbnailFileSize+1))
+	if err != nil {
+		return nil, &services.UploadReadError{Err: err}
+	}
+	if len(data) > services.MaxThumbnailFileSize {
+		return nil, errThumbnailFileTooLarge
+	}
+	return data, nil
+}
+
+var errThumbnailFileTooLarge = errors.New("サムネイル画像が大きすぎます")
+
+// サムネイルの読み込み・作成・アップロードのエラーをレスポンスに書き込む
+func writeThumbnailError(w http.ResponseWriter, err error) {
+	var formatErr *services.FormatError
+	var readErr *services.UploadReadError
+	switch {
+	case errors.As(err, &formatErr):
+		writeFormatError(w, formatErr)
+	case errors.Is(err, errThumbnailFileTooLarge), errors.Is(err, services.ErrThumbnailTooLarge):
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case errors.As(err, &readErr):
+		common.LogVideoUploadError(err)
+		http.Error(w, "サムネイル画像の受信に失敗しました", uploadReadErrorStatus(err))
+	default:
+		common.LogVideoUploadError(err)
+		http.Error(w, "サムネイル画像のアップロードに失敗しました", http.StatusInternalServerError)
+	}
+}
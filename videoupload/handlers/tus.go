@@ -0,0 +1,494 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"live/common"
+	"live/videoupload/models"
+	"live/videoupload/services"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	"gorm.io/gorm"
+)
+
+const (
+	tusVersion    = "1.0.0"
+	tusExtensions = "creation,creation-with-upload,termination,expiration"
+	// 最後にデータを受信してからアップロードを削除するまでの時間
+	tusExpiration = 24 * time.Hour
+	// アップロードを作成した後に再開するためのURL
+	tusBasePath = "/api/v1/videoupload/tus/"
+)
+
+// tus のサーバーの対応状況を返す（認証は不要）
+func TusOptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	w.Header().Set("Tus-Version", tusVersion)
+	w.Header().Set("Tus-Extension", tusExtensions)
+	w.Header().Set("Tus-Max-Size", strconv.FormatInt(services.MaxVideoFileSize, 10))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// アップロードを作成する（creation / creation-with-upload）
+// 動画情報は Upload-Metadata で指定する（filename、filetype、title、description、visibility、category、tags、language、status、publish_at、duration）
+func TusCreate(w http.ResponseWriter, r *http.Request) {
+	if !checkTusResumable(w, r) {
+		return
+	}
+
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return
+	}
+
+	if r.Header.Get("Upload-Defer-Length") != "" {
+		http.Error(w, "Upload-Defer-Length には対応していません", http.StatusBadRequest)
+		return
+	}
+	length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		http.Error(w, "Upload-Length ヘッダーが正しくありません", http.StatusBadRequest)
+		return
+	}
+	rawMetadata := r.Header.Get("Upload-Metadata")
+	metadata, err := parseTusMetadata(rawMetadata)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if _, err := services.VideoObjectName(metadata["filename"]); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// 完了後に失敗しないよう、動画情報は作成時に検証する
+	input, err := parseVideoInput(userID, tusMetadataValue(metadata), []string{metadata["tags"]}, true)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// アップロードの上限の確認（受信中のアップロードもサイズと数に含める）
+	// アップロードを記録するまでは容量を確保し、同時に作成したアップロードが上限を超えないようにする
+	_, reservation, ok := reserveUploadQuota(w, userID, length, input.Duration, 0)
+	if !ok {
+		return
+	}
+
+	store, err := services.NewTusStore()
+	if err != nil {
+		releaseUploadQuota(reservation)
+		common.LogVideoUploadError(err)
+		http.Error(w, "内部サーバーエラー", http.StatusInternalServerError)
+		return
+	}
+
+	upload := &models.TusUpload{
+		ID:           uuid.New().String(),
+		UserID:       userID,
+		UploadLength: length,
+		Metadata:     rawMetadata,
+		Expires:      time.Now().Add(tusExpiration).Truncate(time.Second),
+	}
+	if err := store.Create(upload.ID); err != nil {
+		releaseUploadQuota(reservation)
+		common.LogVideoUploadError(err)
+		http.Error(w, "内部サーバーエラー", http.StatusInternalServerError)
+		return
+	}
+	// 記録したアップロードは使用量に含まれるため、確保した容量はここで解放する
+	err = models.CreateTusUpload(upload)
+	releaseUploadQuota(reservation)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		store.Remove(upload.ID)
+		http.Error(w, "アップロードの作成に失敗しました", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", tusBasePath+upload.ID)
+
+	// 作成と同時に送られたデータを書き込む
+	if r.Header.Get("Content-Type") == "application/offset+octet-stream" && r.ContentLength != 0 {
+		if !writeTusChunk(w, r, store, upload) {
+			return
+		}
+	}
+
+	setTusUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusCreated)
+}
+
+// 受信済みのサイズを返す
+func TusHead(w http.ResponseWriter, r *http.Request) {
+	if !checkTusResumable(w, r) {
+		return
+	}
+
+	upload, ok := loadTusUpload(w, r)
+	if !ok {
+		return
+	}
+
+	if upload.VideoID == nil {
+		store, err := services.NewTusStore()
+		if err != nil {
+			common.LogVideoUploadError(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if err := syncTusUploadOffset(store, upload); err != nil {
+			common.LogVideoUploadError(err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	if upload.Metadata != "" {
+		w.Header().Set("Upload-Metadata", upload.Metadata)
+	}
+	setTusUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusOK)
+}
+
+// 続きのデータを書き込み、すべて受信したら動画を作成する
+func TusPatch(w http.ResponseWriter, r *http.Request) {
+	if !checkTusResumable(w, r) {
+		return
+	}
+
+	if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+		http.Error(w, "Content-Type は application/offset+octet-stream を指定してください", http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		http.Error(w, "Upload-Offset ヘッダーが正しくありません", http.StatusBadRequest)
+		return
+	}
+
+	upload, unlock, ok := lockTusUpload(w, r)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	store, err := services.NewTusStore()
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "内部サーバーエラー", http.StatusInternalServerError)
+		return
+	}
+	if upload.VideoID == nil {
+		if err := syncTusUploadOffset(store, upload); err != nil {
+			common.LogVideoUploadError(err)
+			http.Error(w, "内部サーバーエラー", http.StatusInternalServerError)
+			return
+		}
+	}
+	if offset != upload.UploadOffset {
+		setTusUploadHeaders(w, upload)
+		http.Error(w, "Upload-Offset が受信済みのサイズと一致しません", http.StatusConflict)
+		return
+	}
+
+	if upload.UploadOffset < upload.UploadLength {
+		if !writeTusChunk(w, r, store, upload) {
+			return
+		}
+	} else if upload.VideoID == nil {
+		// 前回の完了処理が失敗した場合は、データを送らずに再度完了させられる
+		if !completeTusUpload(w, r, store, upload) {
+			return
+		}
+	}
+
+	setTusUploadHeaders(w, upload)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// アップロードを中止してファイルを削除する（termination）
+// 完了済みのアップロードを削除しても作成した動画は削除しない
+func TusDelete(w http.ResponseWriter, r *http.Request) {
+	if !checkTusResumable(w, r) {
+		return
+	}
+
+	upload, unlock, ok := lockTusUpload(w, r)
+	if !ok {
+		return
+	}
+	defer unlock()
+
+	store, err := services.NewTusStore()
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "内部サーバーエラー", http.StatusInternalServerError)
+		return
+	}
+	if err := store.Remove(upload.ID); err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	if err := models.DeleteTusUpload(upload.ID); err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの削除に失敗しました", http.StatusInternalServerError)
+		return
+	}
+	services.ForgetTusUploadLock(upload.ID)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ディスクに残っているファイルがデータベースの記録より短い場合は、残っているところから再開させる
+// ファイルが失われている場合は最初から送り直させる
+func syncTusUploadOffset(store *services.TusStore, upload *models.TusUpload) error {
+	size, err := store.Size(upload.ID)
+	if err != nil {
+		return err
+	}
+	if size < upload.UploadOffset {
+		return models.UpdateTusUploadOffset(upload, size, upload.Expires)
+	}
+	return nil
+}
+
+// リクエストのデータを書き込み、受信済みのサイズを記録する
+// すべて受信した場合は動画を作成する。失敗した場合はエラーレスポンスを書き込み false を返す
+func writeTusChunk(w http.ResponseWriter, r *http.Request, store *services.TusStore, upload *models.TusUpload) bool {
+	remaining := upload.UploadLength - upload.UploadOffset
+	if r.ContentLength > remaining {
+		http.Error(w, "Upload-Length を超えるデータは送信できません", http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	written, copyErr := store.Append(upload.ID, upload.UploadOffset, r.Body, remaining)
+	// 接続が切れた場合も受信できたところまでは記録し、続きから再開できるようにする
+	if written > 0 {
+		if err := models.UpdateTusUploadOffset(upload, upload.UploadOffset+written, time.Now().Add(tusExpiration).Truncate(time.Second)); err != nil {
+			common.LogVideoUploadError(err)
+			http.Error(w, "内部サーバーエラー", http.StatusInternalServerError)
+			return false
+		}
+	}
+	if copyErr != nil {
+		common.LogVideoUploadError(copyErr)
+		http.Error(w, "データの受信に失敗しました", http.StatusInternalServerError)
+		return false
+	}
+
+	if upload.UploadOffset == upload.UploadLength {
+		return completeTusUpload(w, r, store, upload)
+	}
+	return true
+}
+
+// すべて受信したファイルをストレージにアップロードし、動画と動画ファイルの情報を保存する
+func completeTusUpload(w http.ResponseWriter, r *http.Request, store *services.TusStore, upload *models.TusUpload) bool {
+	metadata, err := parseTusMetadata(upload.Metadata)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	input, err := parseVideoInput(upload.UserID, tusMetadataValue(metadata), []string{metadata["tags"]}, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	storageService, err := services.InitStorageService()
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
+		return false
+	}
+
+	file, err := store.Open(upload.ID)
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "内部サーバーエラー", http.StatusInternalServerError)
+		return false
+	}
+	defer file.Close()
+
+	uploaded, err := storageService.UploadVideoStream(r.Context(), file, metadata["filename"])
+	if err != nil {
+		var formatErr *services.FormatError
+		if errors.As(err, &formatErr) {
+			// 形式が正しくないファイルは再開しても完了できないため、アップロードを削除する
+			store.Remove(upload.ID)
+			if err := models.DeleteTusUpload(upload.ID); err != nil {
+				common.LogVideoUploadError(err)
+			}
+			writeFormatError(w, formatErr)
+			return false
+		}
+		common.LogVideoUploadError(err)
+		http.Error(w, "動画ファイルのアップロードに失敗しました", http.StatusInternalServerError)
+		return false
+	}
+
+	videoFile := &models.VideoFile{
+		FilePath: uploaded.Path,
+		Duration: input.Duration,
+		FileSize: uint64(upload.UploadLength),
+		Format:   uploaded.Format,
+	}
+	probeVideoFile(videoFile, file, upload.UploadLength)
+
+	// 解析した再生時間が上限を超える場合は、再開しても完了できないためアップロードを削除する
+	quota, err := services.LoadUploadQuota(upload.UserID)
+	if err != nil {
+		releaseVideoFile(storageService, uploaded.Path)
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの上限の取得に失敗しました", http.StatusInternalServerError)
+		return false
+	}
+	if err := quota.CheckDuration(videoFile.Duration); err != nil {
+		releaseVideoFile(storageService, uploaded.Path)
+		store.Remove(upload.ID)
+		if err := models.DeleteTusUpload(upload.ID); err != nil {
+			common.LogVideoUploadError(err)
+		}
+		services.ForgetTusUploadLock(upload.ID)
+		writeQuotaError(w, err)
+		return false
+	}
+
+	if err := models.CompleteTusUpload(upload, input.Video, input.Tags, videoFile); err != nil {
+		releaseVideoFile(storageService, uploaded.Path)
+		if errors.Is(err, models.ErrTusUploadCompleted) {
+			return true
+		}
+		common.LogVideoUploadError(err)
+		http.Error(w, "動画情報の保存に失敗しました", http.StatusInternalServerError)
+		return false
+	}
+
+	// 完了したアップロードの記録は有効期限まで残し、ファイルとロックのみ削除する
+	if err := store.Remove(upload.ID); err != nil {
+		common.LogVideoUploadError(err)
+	}
+	services.ForgetTusUploadLock(upload.ID)
+	common.LogVideoUploadInfo(fmt.Sprintf("tus upload %s completed: video %d", upload.ID, input.Video.ID))
+
+	afterVideoSaved(input.Video)
+	return true
+}
+
+// 自分のアップロードを取得する（他のユーザーのアップロードは存在しないものとして扱う）
+// 失敗した場合はエラーレスポンスを書き込み false を返す
+func loadTusUpload(w http.ResponseWriter, r *http.Request) (*models.TusUpload, bool) {
+	userID, err := common.GetUserIDFromContext(r.Context())
+	if err != nil {
+		common.LogVideoUploadError(err)
+		http.Error(w, "ユーザー情報の取得に失敗しました", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	upload, err := models.GetTusUpload(mux.Vars(r)["id"])
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "アップロードが見つかりません", http.StatusNotFound)
+			return nil, false
+		}
+		common.LogVideoUploadError(err)
+		http.Error(w, "アップロードの取得に失敗しました", http.StatusInternalServerError)
+		return nil, false
+	}
+	if upload.UserID != userID {
+		http.Error(w, "アップロードが見つかりません", http.StatusNotFound)
+		return nil, false
+	}
+	if upload.VideoID == nil && !upload.Expires.After(time.Now()) {
+		http.Error(w, "アップロードの有効期限が切れています", http.StatusGone)
+		return nil, false
+	}
+	return upload, true
+}
+
+// 自分のアップロードを取得し、他のリクエストが同時に書き込まないようロックする
+// 存在しないアップロードや他のユーザーのアップロードにはロックを作成しない
+// 失敗した場合はエラーレスポンスを書き込み false を返す
+func lockTusUpload(w http.ResponseWriter, r *http.Request) (*models.TusUpload, func(), bool) {
+	upload, ok := loadTusUpload(w, r)
+	if !ok {
+		return nil, nil, false
+	}
+	unlock, ok := services.LockTusUpload(upload.ID)
+	if !ok {
+		http.Error(w, "アップロードは他のリクエストで処理中です", http.StatusLocked)
+		return nil, nil, false
+	}
+
+	// ロックするまでの間に他のリクエストが書き込んだ場合や削除した場合に備えて取得し直す
+	upload, ok = loadTusUpload(w, r)
+	if !ok {
+		services.ForgetTusUploadLock(mux.Vars(r)["id"])
+		unlock()
+		return nil, nil, false
+	}
+	return upload, unlock, true
+}
+
+// Tus-Resumable ヘッダーを確認する
+// 対応していないバージョンの場合はエラーレスポンスを書き込み false を返す
+func checkTusResumable(w http.ResponseWriter, r *http.Request) bool {
+	w.Header().Set("Tus-Resumable", tusVersion)
+	if r.Header.Get("Tus-Resumable") != tusVersion {
+		w.Header().Set("Tus-Version", tusVersion)
+		http.Error(w, "対応していない tus のバージョンです", http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func setTusUploadHeaders(w http.ResponseWriter, upload *models.TusUpload) {
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.UploadOffset, 10))
+	w.Header().Set("Upload-Length", strconv.FormatInt(upload.UploadLength, 10))
+	if upload.VideoID != nil {
+		// 完了したアップロードから作成した動画
+		w.Header().Set("X-Video-Id", strconv.FormatUint(uint64(*upload.VideoID), 10))
+	} else {
+		w.Header().Set("Upload-Expires", upload.Expires.UTC().Format(http.TimeFormat))
+	}
+}
+
+// Upload-Metadata ヘッダー（「キー base64の値」をカンマで区切った形式）を解析する
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, errors.New("Upload-Metadata ヘッダーが正しくありません")
+		}
+		value := ""
+		if len(fields) == 2 {
+			decoded, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, errors.New("Upload-Metadata ヘッダーが正しくありません")
+			}
+			value = string(decoded)
+		}
+		metadata[fields[0]] = value
+	}
+	return metadata, nil
+}
+
+func tusMetadataValue(metadata map[string]string) func(string) string {
+	return func(key string) string {
+		return metadata[key]
+	}
+}
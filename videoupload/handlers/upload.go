@@ -1,24 +1,26 @@
 package handlers
 
 import (
+	"errors"
 	"io"
 	"live/common"
 	"live/videoupload/models"
 	"live/videoupload/services"
+	"mime/multipart"
 	"net/http"
-	"os"
-	"strconv"
-)
+	"net/url"
 
-func Upload(w http.ResponseWriter, r *http.Request) {
+	"gorm.io/gorm"
+)
 
-	err := r.ParseMultipartForm(10 << 20)
-	if err != nil {
-		common.LogVideoUploadError(err)
-		http.Error(w, "リクエストの解析に失敗しました", http.StatusBadRequest)
-		return
-	}
+// 動画ファイル以外のフォームの値の合計の最大サイズ
+const maxUploadFieldsSize = 1 << 20
 
+// 動画をアップロードする
+// ファイル全体をメモリやディスクに保持しないよう、フォームを先頭から順に読み込み、動画ファイルはそのままストレージに送る
+// そのため動画情報（title など）は動画ファイル（file）より前に送信する必要がある
+// サムネイル画像（thumbnail）は省略でき、フォームのどこに含めてもよい
+func Upload(w http.ResponseWriter, r *http.Request) {
 	// ユーザーIDの取得
 	userID, err := common.GetUserIDFromContext(r.Context())
 	if err != nil {
@@ -27,86 +29,211 @@ func Upload(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// DBトランザクションの開始
-	tx := common.DB.Begin()
-	if tx.Error != nil {
-		common.LogVideoUploadError(tx.Error)
-		http.Error(w, "内部サーバーエラー", http.StatusInternalServerError)
+	// アップロードの上限の確認と容量の確保（本文を受信する前に行い、保存を終えたら解放する）
+	quota, reservation, ok := reserveUploadQuota(w, userID, 0, 0, r.ContentLength)
+	if !ok {
+		return
+	}
+	defer releaseUploadQuota(reservation)
+	maxBodySize := quota.MaxUploadSize() + services.MaxThumbnailFileSize + maxUploadFieldsSize
+	if r.ContentLength > maxBodySize {
+		writeQuotaError(w, quota.CheckSize(quota.MaxUploadSize()+1))
 		return
 	}
 
-	// 動画情報の保存
-	title := r.FormValue("title")
-	description := r.FormValue("description")
-
-	video, err := models.SaveVideoWithTransaction(tx, userID, title, description)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodySize)
+	reader, err := r.MultipartReader()
 	if err != nil {
 		common.LogVideoUploadError(err)
-		tx.Rollback()
-		http.Error(w, "動画情報の保存に失敗しました", http.StatusInternalServerError)
+		http.Error(w, "リクエストの解析に失敗しました", http.StatusBadRequest)
 		return
 	}
 
-	// ENV_MODEの取得
-	envMode := os.Getenv("ENV_MODE")
-
-	var storageService *services.StorageService
-
-	if envMode == "local" {
-		storageService, err = services.InitMinioService()
-	} else {
-		storageService, err = services.NewStorageService()
-	}
-
+	storageService, err := services.InitStorageService()
 	if err != nil {
 		common.LogVideoUploadError(err)
-		tx.Rollback()
 		http.Error(w, "ストレージサービスの初期化に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	// 動画ファイルの処理
-	file, fileHeader, err := r.FormFile("file")
-	if err != nil {
-		common.LogVideoUploadError(err)
-		tx.Rollback()
-		http.Error(w, "動画ファイルの取得に失敗しました", http.StatusBadRequest)
+	form, ok := streamUploadForm(w, r, reader, userID, storageService, quota)
+	if !ok {
 		return
 	}
-	defer file.Close()
-
-	// 動画ファイルのアップロード
-	fileURL, err := storageService.UploadFile(file, fileHeader)
+	input, videoFile := form.input, form.videoFile
+
+	// 動画情報と動画ファイル情報、サムネイル情報の保存
+	err = common.DB.Transaction(func(tx *gorm.DB) error {
+		if err := models.SaveUploadedVideoWithTransaction(tx, input.Video, input.Tags, videoFile); err != nil {
+			return err
+		}
+		if len(form.thumbnails) == 0 {
+			return nil
+		}
+		_, err := models.SaveVideoThumbnailsWithTransaction(tx, input.Video.ID, form.thumbnails)
+		return err
+	})
 	if err != nil {
 		common.LogVideoUploadError(err)
-		tx.Rollback()
-		http.Error(w, "動画ファイルのアップロードに失敗しました", http.StatusInternalServerError)
+		form.cleanup(storageService)
+		http.Error(w, "動画情報の保存に失敗しました", http.StatusInternalServerError)
 		return
 	}
 
-	// 動画ファイル情報の保存
-	durationStr := r.FormValue("duration")
-	duration, _ := strconv.Atoi(durationStr)
-	fileSize := uint64(fileHeader.Size)
-	format := fileHeader.Header.Get("Content-Type")
+	afterVideoSaved(input.Video)
 
-	_, err = models.SaveVideoFileWithTransaction(tx, video.ID, fileURL, uint(duration), fileSize, format)
-	if err != nil {
-		common.LogVideoUploadError(err)
-		tx.Rollback()
-		http.Error(w, "動画ファイル情報の保存に失敗しました", http.StatusInternalServerError)
-		return
+	// 成功レスポンスを返す
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	io.WriteString(w, `{"video_url":"`+videoFile.FilePath+`"}`)
+}
+
+// 読み込んだアップロードのフォーム
+type uploadForm struct {
+	input      *videoInput
+	videoFile  *models.VideoFile
+	thumbnails []models.VideoThumbnail
+}
+
+// アップロード済みの動画ファイルとサムネイルを削除する
+func (f *uploadForm) cleanup(storageService *services.StorageService) {
+	if f.videoFile != nil {
+		releaseVideoFile(storageService, f.videoFile.FilePath)
 	}
+	storageService.DeleteThumbnailFiles(f.thumbnails)
+}
 
-	// トランザクションのコミット
-	if err := tx.Commit().Error; err != nil {
+// 保存しなかった動画ファイルの参照を解放する（同じ内容の動画ファイルが他にない場合はファイルを削除する）
+func releaseVideoFile(storageService *services.StorageService, filePath string) {
+	if err := storageService.ReleaseVideoFile(filePath); err != nil {
 		common.LogVideoUploadError(err)
-		http.Error(w, "内部サーバーエラー", http.StatusInternalServerError)
-		return
 	}
+}
 
-	// 成功レスポンスを返す
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	io.WriteString(w, `{"video_url":"`+fileURL+`"}`)
+// フォームを読み込み、動画情報を検証してから動画ファイルをストレージにアップロードする
+// 動画ファイルはアップロードの上限を超えた時点で受信を止める
+// 失敗した場合はアップロードしたファイルを削除し、エラーレスポンスを書き込み false を返す
+func streamUploadForm(w http.ResponseWriter, r *http.Request, reader *multipart.Reader, userID uint, storageService *services.StorageService, quota *services.UploadQuota) (*uploadForm, bool) {
+	values := url.Values{}
+	var fieldsSize int64
+	form := &uploadForm{}
+
+	fail := func(message string, status int) (*uploadForm, bool) {
+		form.cleanup(storageService)
+		http.Error(w, message, status)
+		return nil, false
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			common.LogVideoUploadError(err)
+			return fail("リクエストの解析に失敗しました", uploadReadErrorStatus(err))
+		}
+
+		if part.FormName() == "thumbnail" && part.FileName() != "" {
+			if form.thumbnails != nil {
+				return fail("サムネイル画像は1つだけ送信してください", http.StatusBadRequest)
+			}
+			data, err := readThumbnailFile(part)
+			if err == nil {
+				form.thumbnails, err = storageService.UploadThumbnailFile(r.Context(), part.FileName(), data)
+			}
+			if err != nil {
+				form.cleanup(storageService)
+				writeThumbnailError(w, err)
+				return nil, false
+			}
+			continue
+		}
+
+		if part.FormName() == "file" && part.FileName() != "" {
+			if form.videoFile != nil {
+				return fail("動画ファイルは1つだけ送信してください", http.StatusBadRequest)
+			}
+			// 動画情報の検証（ファイルを受信する前に行う）
+			form.input, err = parseVideoInput(userID, values.Get, values["tags"], true)
+			if err != nil {
+				return fail(err.Error(), http.StatusBadRequest)
+			}
+			if err := quota.CheckDuration(form.input.Duration); err != nil {
+				form.cleanup(storageService)
+				writeQuotaError(w, err)
+				return nil, false
+			}
+
+			// 動画ファイルのアップロード（形式はファイルの内容から判別する）
+			uploaded, err := storageService.UploadVideoStream(r.Context(), quota.LimitReader(part), part.FileName())
+			if err != nil {
+				var quotaErr *services.QuotaError
+				if errors.As(err, &quotaErr) {
+					form.cleanup(storageService)
+					writeQuotaError(w, quotaErr)
+					return nil, false
+				}
+				var formatErr *services.FormatError
+				if errors.As(err, &formatErr) {
+					form.cleanup(storageService)
+					writeFormatError(w, formatErr)
+					return nil, false
+				}
+				common.LogVideoUploadError(err)
+				var readErr *services.UploadReadError
+				if errors.As(err, &readErr) {
+					return fail("動画ファイルの受信に失敗しました", uploadReadErrorStatus(err))
+				}
+				return fail("動画ファイルのアップロードに失敗しました", http.StatusInternalServerError)
+			}
+			form.videoFile = &models.VideoFile{
+				FilePath: uploaded.Path,
+				Duration: form.input.Duration,
+				FileSize: uint64(uploaded.Size),
+				Format:   uploaded.Format,
+			}
+			probeVideoFile(form.videoFile, storageService.NewFileReaderAt(r.Context(), uploaded.Path), uploaded.Size)
+			// 解析した再生時間が上限を超える場合は保存しない
+			if err := quota.CheckDuration(form.videoFile.Duration); err != nil {
+				form.cleanup(storageService)
+				writeQuotaError(w, err)
+				return nil, false
+			}
+			continue
+		}
+
+		if form.videoFile != nil {
+			return fail("動画情報は動画ファイルより前に送信してください", http.StatusBadRequest)
+		}
+		value, err := io.ReadAll(io.LimitReader(part, maxUploadFieldsSize-fieldsSize+1))
+		if err != nil {
+			common.LogVideoUploadError(err)
+			return fail("リクエストの解析に失敗しました", uploadReadErrorStatus(err))
+		}
+		fieldsSize += int64(len(value))
+		if fieldsSize > maxUploadFieldsSize {
+			return fail("動画情報が大きすぎます", http.StatusRequestEntityTooLarge)
+		}
+		values.Add(part.FormName(), string(value))
+	}
+
+	if form.videoFile == nil {
+		return fail("動画ファイルの取得に失敗しました", http.StatusBadRequest)
+	}
+	return form, true
+}
+
+// 形式が正しくないファイルのエラーを返す（本文は「エラーコード: メッセージ」）
+func writeFormatError(w http.ResponseWriter, err *services.FormatError) {
+	http.Error(w, err.Error(), http.StatusUnsupportedMediaType)
+}
+
+// 受信に失敗した原因がサイズの超過であれば 413、それ以外は 400 を返す
+func uploadReadErrorStatus(err error) int {
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
 }
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"live/common"
+	notificationServices "live/notification/services"
+	videohubServices "live/videohub/services"
+	"live/videoupload/models"
+	"live/videoupload/probe"
+	"live/videoupload/services"
+	"strconv"
+	"time"
+)
+
+// 動画情報の入力値（フォームまたは tus の Upload-Metadata から読み取る）
+type videoInput struct {
+	Video    *models.Video
+	Tags     []string
+	Duration uint
+}
+
+// 動画情報の入力値を検証する関数
+// value は項目名から値を返す関数、rawTags はタグの値の一覧
+// futurePublishAt が false の場合、過ぎた公開日時はエラーにせず、すぐに公開する
+func parseVideoInput(userID uint, value func(string) string, rawTags []string, futurePublishAt bool) (*videoInput, error) {
+	// タグとカテゴリの検証
+	tags, err := common.NormalizeTags(rawTags)
+	if err != nil {
+		return nil, err
+	}
+
+	var categoryID *uint
+	if slug := value("category"); slug != "" {
+		category, err := models.GetCategoryBySlug(slug)
+		if err != nil {
+			common.LogVideoUploadError(err)
+			return nil, errors.New("無効なカテゴリです")
+		}
+		categoryID = &category.ID
+	}
+
+	// 公開範囲の検証（省略時は公開）
+	visibility := value("visibility")
+	switch visibility {
+	case "":
+		visibility = models.VideoVisibilityPublic
+	case models.VideoVisibilityPublic, models.VideoVisibilityUnlisted, models.VideoVisibilityPrivate:
+	default:
+		return nil, errors.New("無効な公開範囲です")
+	}
+
+	// タイトル・説明の言語（省略可）
+	var language *string
+	if lang := value("language"); lang != "" {
+		normalized, err := common.NormalizeLanguageTag(lang)
+		if err != nil {
+			return nil, err
+		}
+		language = &normalized
+	}
+
+	// 公開状態（下書きまたは予約公開、省略時はすぐに公開）
+	status := models.VideoStatusPublished
+	switch value("status") {
+	case "", models.VideoStatusPublished:
+	case models.VideoStatusDraft:
+		status = models.VideoStatusDraft
+	default:
+		return nil, errors.New("無効な公開状態です")
+	}
+
+	var publishAt *time.Time
+	if v := value("publish_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return nil, errors.New("公開日時は RFC 3339 形式で指定してください")
+		}
+		if status == models.VideoStatusDraft {
+			return nil, errors.New("公開状態と公開日時は同時に指定できません")
+		}
+		if t.After(time.Now()) {
+			t = t.Truncate(time.Second)
+			publishAt = &t
+			status = models.VideoStatusScheduled
+		} else if futurePublishAt {
+			return nil, errors.New("公開日時には未来の日時を指定してください")
+		}
+	}
+
+	// 再生時間はアップロード後にファイルから取得する（取得できない形式の場合のみ入力値を使う）
+	var duration uint64
+	if v := value("duration"); v != "" {
+		duration, err = strconv.ParseUint(v, 10, 32)
+		if err != nil {
+			return nil, errors.New("再生時間は0以上の整数（秒）で指定してください")
+		}
+	}
+
+	return &videoInput{
+		Video: &models.Video{
+			UserID:      userID,
+			Title:       value("title"),
+			Description: value("description"),
+			Language:    language,
+			Visibility:  visibility,
+			Status:      status,
+			PublishAt:   publishAt,
+			CategoryID:  categoryID,
+		},
+		Tags:     tags,
+		Duration: uint(duration),
+	}, nil
+}
+
+// 動画を保存した後の処理（動画ファイルの処理の開始、予約公開の登録とチャンネル登録者への通知）
+func afterVideoSaved(video *models.Video) {
+	services.Processor.Wake()
+
+	switch {
+	case video.Status == models.VideoStatusScheduled:
+		// 公開日時に公開されるよう予約公開の待機時間を計算し直す
+		videohubServices.Publisher.Reschedule()
+	case video.Status == models.VideoStatusPublished && video.Visibility == models.VideoVisibilityPublic:
+		// 公開した動画はチャンネル登録者に通知する
+		go notificationServices.NotifyNewUpload(video.UserID, video.ID, video.Title)
+	}
+}
+
+// 動画ファイルを解析し、再生時間・解像度・フレームレート・コーデックを設定する関数
+// 解析できない形式や壊れたファイルの場合はアップロードを失敗させず、入力された再生時間をそのまま使う
+func probeVideoFile(videoFile *models.VideoFile, r io.ReaderAt, size int64) {
+	info, err := probe.Probe(r, size)
+	if err != nil {
+		if !errors.Is(err, probe.ErrUnsupported) {
+			common.LogVideoUploadError(fmt.Errorf("動画ファイルの解析に失敗しました: %w | Path: %s", err, videoFile.FilePath))
+		}
+		return
+	}
+
+	videoFile.SetMediaInfo(info)
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"errors"
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type VideoCaption struct {
+	ID       uint      `gorm:"primary_key"`
+	VideoID  uint      `gorm:"not null"`
+	Language string    `gorm:"type:varchar(35);not null"`
+	Label    string    `gorm:"type:varchar(100);not null"`
+	FilePath string    `gorm:"type:varchar(255);not null"`
+	Created  time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	Modified time.Time `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+}
+
+func GetVideoByID(videoID uint) (*Video, error) {
+	var video Video
+	if err := common.DB.Where("deleted IS NULL").First(&video, videoID).Error; err != nil {
+		return nil, err
+	}
+	return &video, nil
+}
+
+// 動画の字幕を保存する関数
+// 同じ言語の字幕が既にある場合は置き換え、置き換える前のファイルのパスを返す
+// label が空の場合、新規の字幕は defaultLabel を使い、既存の字幕は表示名を変更しない
+func SaveCaption(videoID uint, language, label, defaultLabel, filePath string) (*VideoCaption, string, error) {
+	var caption VideoCaption
+	var oldPath string
+
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("video_id = ? AND language = ?", videoID, language).
+			First(&caption).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if label == "" {
+				label = defaultLabel
+			}
+			caption = VideoCaption{VideoID: videoID, Language: language, Label: label, FilePath: filePath}
+			return tx.Create(&caption).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		oldPath = caption.FilePath
+		if label == "" {
+			label = caption.Label
+		}
+		caption.Label = label
+		caption.FilePath = filePath
+		return tx.Model(&caption).Updates(map[string]interface{}{"label": label, "file_path": filePath}).Error
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return &caption, oldPath, nil
+}
+
+// 動画の字幕を削除し、削除した字幕のファイルのパスを返す関数
+// 字幕がない場合は gorm.ErrRecordNotFound を返す
+func DeleteCaption(videoID uint, language string) (string, error) {
+	var caption VideoCaption
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("video_id = ? AND language = ?", videoID, language).
+			First(&caption).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&caption).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return caption.FilePath, nil
+}
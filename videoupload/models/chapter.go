@@ -0,0 +1,30 @@
+package models
+
+import (
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type VideoChapter struct {
+	ID           uint      `gorm:"primary_key"`
+	VideoID      uint      `gorm:"not null"`
+	StartSeconds uint      `gorm:"not null"`
+	Title        string    `gorm:"type:varchar(255);not null"`
+	Source       string    `gorm:"type:enum('manual','description');not null"`
+	Created      time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// トランザクションを使用して概要欄から読み取ったチャプターを保存する関数
+func SaveDescriptionChaptersWithTransaction(tx *gorm.DB, videoID uint, markers []common.ChapterMarker) error {
+	if len(markers) == 0 {
+		return nil
+	}
+
+	chapters := make([]VideoChapter, 0, len(markers))
+	for _, m := range markers {
+		chapters = append(chapters, VideoChapter{VideoID: videoID, StartSeconds: m.StartSeconds, Title: m.Title, Source: "description"})
+	}
+	return tx.Create(&chapters).Error
+}
@@ -0,0 +1,155 @@
+package models
+
+import (
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 署名付きURLによるストレージへの直接アップロード（完了すると削除する）
+type DirectUpload struct {
+	VideoFileID uint       `gorm:"primaryKey"`
+	UserID      uint       `gorm:"not null"`
+	UploadID    *string    `gorm:"type:varchar(255);default:NULL"`
+	PartSize    int64      `gorm:"not null;default:0"`
+	FileSize    int64      `gorm:"not null"`
+	ContentType string     `gorm:"type:varchar(100);not null"`
+	Status      string     `gorm:"type:enum('draft','scheduled','published');default:'published'"`
+	PublishAt   *time.Time `gorm:"default:NULL"`
+	Expires     time.Time  `gorm:"not null"`
+	Created     time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
+	VideoID     uint       `gorm:"->"` // video_files から取得する
+	FilePath    string     `gorm:"->"` // video_files から取得する
+}
+
+// アップロード前の動画と動画ファイルを作成し、直接アップロードを登録する関数
+// 動画はアップロードが完了するまで下書きとして保存し、指定された公開状態は完了時に反映する
+func CreateDirectUpload(video *Video, tags []string, videoFile *VideoFile, upload *DirectUpload) error {
+	upload.Status = video.Status
+	upload.PublishAt = video.PublishAt
+	video.Status = VideoStatusDraft
+	video.PublishAt = nil
+
+	return common.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveVideoWithFileTransaction(tx, video, tags, videoFile); err != nil {
+			return err
+		}
+		upload.VideoFileID = videoFile.ID
+		upload.VideoID = video.ID
+		upload.FilePath = videoFile.FilePath
+		return tx.Create(upload).Error
+	})
+}
+
+func directUploadsWithFile(db *gorm.DB) *gorm.DB {
+	return db.Table("direct_uploads").
+		Select("direct_uploads.*, video_files.video_id, video_files.file_path").
+		Joins("JOIN video_files ON video_files.id = direct_uploads.video_file_id")
+}
+
+func GetDirectUpload(videoFileID uint) (*DirectUpload, error) {
+	var upload DirectUpload
+	err := directUploadsWithFile(common.DB).
+		Where("direct_uploads.video_file_id = ?", videoFileID).
+		Take(&upload).Error
+	if err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// 直接アップロードを完了し、動画ファイルをアップロード済みにして動画を指定された公開状態にする関数
+// videoFile にはファイルの内容から判別した形式と解析結果を指定する（再生時間が 0 の場合は開始時の入力値のまま）
+// 既に完了または削除されている場合は gorm.ErrRecordNotFound を返す
+func CompleteDirectUpload(upload *DirectUpload, videoFile *VideoFile) (*Video, error) {
+	var video Video
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		var locked DirectUpload
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("video_file_id = ?", upload.VideoFileID).
+			First(&locked).Error
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().Truncate(time.Second)
+		fileUpdates := map[string]interface{}{
+			"uploaded":    now,
+			"format":      videoFile.Format,
+			"width":       videoFile.Width,
+			"height":      videoFile.Height,
+			"frame_rate":  videoFile.FrameRate,
+			"video_codec": videoFile.VideoCodec,
+			"audio_codec": videoFile.AudioCodec,
+		}
+		if videoFile.Duration > 0 {
+			fileUpdates["duration"] = videoFile.Duration
+		}
+		if err := tx.Model(&VideoFile{}).Where("id = ?", upload.VideoFileID).Updates(fileUpdates).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("id = ? AND deleted IS NULL", upload.VideoID).First(&video).Error; err != nil {
+			return err
+		}
+
+		// 予約公開の日時を過ぎていればすぐに公開する
+		status, publishAt := locked.Status, locked.PublishAt
+		if status == VideoStatusScheduled && publishAt != nil && !publishAt.After(now) {
+			status, publishAt = VideoStatusPublished, nil
+		}
+		updates := map[string]interface{}{"status": status, "publish_at": publishAt}
+		if status == VideoStatusPublished && video.Published == nil {
+			updates["published"] = now
+		}
+		if err := tx.Model(&video).Updates(updates).Error; err != nil {
+			return err
+		}
+		video.Status, video.PublishAt = status, publishAt
+		if _, ok := updates["published"]; ok {
+			video.Published = &now
+		}
+
+		return tx.Where("video_file_id = ?", upload.VideoFileID).Delete(&DirectUpload{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &video, nil
+}
+
+// 有効期限を過ぎても完了しない直接アップロードを取得する関数
+func GetExpiredDirectUploads(before time.Time, limit int) ([]DirectUpload, error) {
+	var uploads []DirectUpload
+	err := directUploadsWithFile(common.DB).
+		Where("direct_uploads.expires <= ?", before).
+		Order("direct_uploads.expires").
+		Limit(limit).
+		Find(&uploads).Error
+	if err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
+
+// 完了しなかった直接アップロードを削除し、作成した動画と動画ファイルを削除済みにする関数
+func DeleteExpiredDirectUpload(upload *DirectUpload) error {
+	return common.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("video_file_id = ?", upload.VideoFileID).Delete(&DirectUpload{})
+		if result.Error != nil {
+			return result.Error
+		}
+		// 同時に完了した場合は何もしない
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		now := time.Now().Truncate(time.Second)
+		if err := tx.Model(&VideoFile{}).Where("id = ?", upload.VideoFileID).Update("deleted", now).Error; err != nil {
+			return err
+		}
+		return tx.Model(&Video{}).Where("id = ? AND deleted IS NULL", upload.VideoID).Update("deleted", now).Error
+	})
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"errors"
+	"live/common"
+	"live/videoupload/probe"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SetMediaInfo は動画ファイルの解析結果を設定する（取得できなかった項目は変更しない）
+func (f *VideoFile) SetMediaInfo(info *probe.Info) {
+	if info.Duration > 0 {
+		f.Duration = uint(math.Round(info.Duration))
+	}
+	if info.Width > 0 && info.Height > 0 {
+		f.Width, f.Height = &info.Width, &info.Height
+	}
+	if info.FrameRate > 0 {
+		f.FrameRate = &info.FrameRate
+	}
+	if info.VideoCodec != "" {
+		f.VideoCodec = &info.VideoCodec
+	}
+	if info.AudioCodec != "" {
+		f.AudioCodec = &info.AudioCodec
+	}
+}
+
+// 処理を待っている動画ファイルを1つ取得し、処理中にする関数
+// アップロードが完了し、再試行の日時を過ぎたファイルのみが対象で、ない場合は nil を返す
+func ClaimNextVideoFile(now time.Time) (*VideoFile, error) {
+	var file VideoFile
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("status = ? AND uploaded IS NOT NULL AND deleted IS NULL AND source_file_id IS NULL", VideoFileStatusPending).
+			Where("next_attempt IS NULL OR next_attempt <= ?", now).
+			Where("video_id IN (SELECT id FROM videos WHERE deleted IS NULL)").
+			Order("id").
+			First(&file).Error
+		if err != nil {
+			return err
+		}
+
+		file.Status = VideoFileStatusProcessing
+		file.ProcessingStep = nil
+		file.ProcessingAttempts++
+		file.ProcessingStarted = &now
+		file.NextAttempt = nil
+		return tx.Model(&VideoFile{}).Where("id = ?", file.ID).Updates(map[string]interface{}{
+			"status":              file.Status,
+			"processing_step":     nil,
+			"processing_attempts": file.ProcessingAttempts,
+			"processing_started":  now,
+			"next_attempt":        nil,
+		}).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// 実行中の処理の段階を記録する関数
+func SetVideoFileProcessingStep(fileID uint, step string) error {
+	return common.DB.Model(&VideoFile{}).Where("id = ?", fileID).Update("processing_step", step).Error
+}
+
+// 動画ファイルの解析結果を保存する関数
+func UpdateVideoFileMediaInfo(file *VideoFile) error {
+	return common.DB.Model(&VideoFile{}).Where("id = ?", file.ID).Updates(map[string]interface{}{
+		"duration":    file.Duration,
+		"width":       file.Width,
+		"height":      file.Height,
+		"frame_rate":  file.FrameRate,
+		"video_codec": file.VideoCodec,
+		"audio_codec": file.AudioCodec,
+	}).Error
+}
+
+// 動画ファイルを配信用に書き出したファイルに置き換え、参照が不要になったファイルのパスを返す関数
+// 最初に置き換える元の動画ファイルは source_path に残し（同じ内容のアップロードで再利用するため）、
+// 再試行で書き出し直した場合は前回書き出したファイルのパスを返す（不要なファイルがない場合は空文字を返す）
+func ReplaceVideoFileObject(fileID uint, filePath string, fileSize uint64, format string) (string, error) {
+	var released string
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		var file VideoFile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&file, fileID).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{
+			"file_path": filePath,
+			"file_size": fileSize,
+			"format":    format,
+		}
+		if file.SourcePath == nil {
+			updates["source_path"] = file.FilePath
+		} else {
+			released = file.FilePath
+		}
+		return tx.Model(&VideoFile{}).Where("id = ?", fileID).Updates(updates).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return released, nil
+}
+
+// 動画ファイルの処理を完了にする関数
+func CompleteVideoFileProcessing(fileID uint, now time.Time) error {
+	return common.DB.Model(&VideoFile{}).Where("id = ?", fileID).Updates(map[string]interface{}{
+		"status":           VideoFileStatusCompleted,
+		"processing_step":  nil,
+		"processing_error": nil,
+		"next_attempt":     nil,
+		"processed":        now,
+	}).Error
+}
+
+// 動画ファイルの処理の失敗を記録する関数
+// retryAt を指定した場合はその日時に再試行し、nil の場合は失敗として処理を終える
+func FailVideoFileProcessing(fileID uint, step, message string, retryAt *time.Time) error {
+	status := VideoFileStatusFailed
+	if retryAt != nil {
+		status = VideoFileStatusPending
+	}
+	return common.DB.Model(&VideoFile{}).Where("id = ?", fileID).Updates(map[string]interface{}{
+		"status":           status,
+		"processing_step":  step,
+		"processing_error": message,
+		"next_attempt":     retryAt,
+	}).Error
+}
+
+// 中断した処理を試行回数に数えずに待ち状態へ戻す関数（サーバーの停止時に使う）
+func ReleaseVideoFileProcessing(fileID uint) error {
+	return common.DB.Model(&VideoFile{}).
+		Where("id = ? AND status = ?", fileID, VideoFileStatusProcessing).
+		Updates(map[string]interface{}{
+			"status":              VideoFileStatusPending,
+			"processing_step":     nil,
+			"processing_attempts": gorm.Expr("GREATEST(processing_attempts, 1) - 1"),
+		}).Error
+}
+
+// 処理中のまま長時間経過した動画ファイル（処理中にサーバーが停止したもの）を待ち状態へ戻す関数
+func ResetStaleVideoFileProcessing(startedBefore time.Time) (int64, error) {
+	result := common.DB.Model(&VideoFile{}).
+		Where("status = ? AND processing_started < ?", VideoFileStatusProcessing, startedBefore).
+		Updates(map[string]interface{}{
+			"status":          VideoFileStatusPending,
+			"processing_step": nil,
+		})
+	return result.RowsAffected, result.Error
+}
+
+// 動画の動画ファイルを処理の状態とともに取得する関数（レンディションは含めない）
+func GetVideoFilesByVideoID(videoID uint) ([]VideoFile, error) {
+	files := []VideoFile{}
+	if err := common.DB.Where("video_id = ? AND deleted IS NULL AND source_file_id IS NULL", videoID).Order("id").Find(&files).Error; err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// 処理に失敗した動画ファイルを再試行の待ち状態に戻し、戻したファイルの数を返す関数
+func RetryFailedVideoFiles(videoID uint) (int64, error) {
+	result := common.DB.Model(&VideoFile{}).
+		Where("video_id = ? AND status = ? AND deleted IS NULL", videoID, VideoFileStatusFailed).
+		Updates(map[string]interface{}{
+			"status":              VideoFileStatusPending,
+			"processing_attempts": 0,
+			"next_attempt":        nil,
+		})
+	return result.RowsAffected, result.Error
+}
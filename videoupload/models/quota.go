@@ -0,0 +1,225 @@
+package models
+
+import (
+	"errors"
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// アップロードの上限を定めたプラン（上限が nil の場合は無制限）
+type UploadPlan struct {
+	ID               uint      `gorm:"primary_key"`
+	Name             string    `gorm:"type:varchar(50);not null;unique"`
+	MaxTotalBytes    *uint64   `gorm:"default:NULL"`
+	MaxFileSize      *uint64   `gorm:"default:NULL"`
+	MaxDuration      *uint     `gorm:"default:NULL"`
+	MaxUploadsPerDay *uint     `gorm:"default:NULL"`
+	IsDefault        bool      `gorm:"not null;default:false"`
+	Created          time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	Modified         time.Time `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+}
+
+// 管理者が設定したユーザーごとのプランと上限（上限が nil の場合はプランの上限を使う）
+type UserUploadQuota struct {
+	UserID           uint       `gorm:"primaryKey;autoIncrement:false"`
+	PlanID           *uint      `gorm:"default:NULL"`
+	MaxTotalBytes    *uint64    `gorm:"default:NULL"`
+	MaxFileSize      *uint64    `gorm:"default:NULL"`
+	MaxDuration      *uint      `gorm:"default:NULL"`
+	MaxUploadsPerDay *uint      `gorm:"default:NULL"`
+	Note             string     `gorm:"type:varchar(255);not null;default:''"`
+	UpdatedBy        *uint      `gorm:"default:NULL"`
+	Created          time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
+	Modified         time.Time  `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+	Plan             UploadPlan `gorm:"foreignKey:PlanID"`
+}
+
+func (UserUploadQuota) TableName() string {
+	return "user_upload_quotas"
+}
+
+// 受信を始めたアップロードのために確保した容量（アップロードを終えたら解放する）
+type UploadReservation struct {
+	ID      uint64    `gorm:"primary_key"`
+	UserID  uint      `gorm:"not null"`
+	Size    uint64    `gorm:"not null"`
+	Expires time.Time `gorm:"not null"`
+	Created time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// ユーザーのアップロードの使用量
+type UploadUsage struct {
+	TotalBytes   uint64 // 保存している動画ファイルとアップロード中のファイルの合計サイズ
+	UploadsToday uint   // 24時間以内に開始したアップロードの数（削除した動画も含む）
+}
+
+// プランを ID の順に取得する関数
+func GetUploadPlans() ([]UploadPlan, error) {
+	plans := []UploadPlan{}
+	if err := common.DB.Order("id").Find(&plans).Error; err != nil {
+		return nil, err
+	}
+	return plans, nil
+}
+
+// プランを名前で取得する関数
+func GetUploadPlanByName(name string) (*UploadPlan, error) {
+	var plan UploadPlan
+	if err := common.DB.Where("name = ?", name).First(&plan).Error; err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// ユーザーに適用するプランと、管理者が設定した上限（設定がない場合は nil）を取得する関数
+func GetUserUploadPlan(userID uint) (*UploadPlan, *UserUploadQuota, error) {
+	var quota UserUploadQuota
+	found := true
+	err := common.DB.Preload("Plan").Where("user_id = ?", userID).First(&quota).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		found = false
+	} else if err != nil {
+		return nil, nil, err
+	}
+	if found && quota.PlanID != nil {
+		return &quota.Plan, &quota, nil
+	}
+
+	var plan UploadPlan
+	if err := common.DB.Where("is_default = ?", true).Order("id").First(&plan).Error; err != nil {
+		return nil, nil, err
+	}
+	if !found {
+		return &plan, nil, nil
+	}
+	return &plan, &quota, nil
+}
+
+// ユーザーのプランと上限を保存する関数（既に設定がある場合は置き換える）
+// nil の項目は INSERT から省かれるため、更新する列は明示して NULL に戻せるようにする
+func SaveUserUploadQuota(quota *UserUploadQuota) error {
+	return common.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"plan_id":             quota.PlanID,
+			"max_total_bytes":     quota.MaxTotalBytes,
+			"max_file_size":       quota.MaxFileSize,
+			"max_duration":        quota.MaxDuration,
+			"max_uploads_per_day": quota.MaxUploadsPerDay,
+			"note":                quota.Note,
+			"updated_by":          quota.UpdatedBy,
+		}),
+	}).Omit("Plan", "Created").Create(quota).Error
+}
+
+// ユーザーのプランと上限の設定を削除し、既定のプランに戻す関数
+func DeleteUserUploadQuota(userID uint) (bool, error) {
+	result := common.DB.Where("user_id = ?", userID).Delete(&UserUploadQuota{})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ユーザーのアップロードの使用量を取得する関数
+// 合計サイズには投稿者がアップロードした動画ファイル（配信用に作成したレンディションは含めない）と、
+// 受信中の tus アップロード、確保中の容量を含める
+func GetUploadUsage(userID uint, since time.Time) (*UploadUsage, error) {
+	return getUploadUsage(common.DB, userID, since)
+}
+
+// ユーザーの行をロックして使用量を確認し、アップロードのために容量を確保する関数
+// reserve は使用量から上限を超えないか確認し、確保するサイズを返す（エラーを返した場合は確保しない）
+// 同じユーザーの確保は順に行われるため、同時に始めたアップロードが上限を超えることはない
+func ReserveUpload(userID uint, since, expires time.Time, reserve func(usage *UploadUsage) (uint64, error)) (*UploadReservation, error) {
+	var reservation *UploadReservation
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		var lockedID uint
+		err := tx.Raw("SELECT id FROM users WHERE id = ? FOR UPDATE", userID).Scan(&lockedID).Error
+		if err != nil {
+			return err
+		}
+		if lockedID == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// 解放されずに期限が切れた確保を削除する
+		if err := tx.Where("user_id = ? AND expires <= ?", userID, time.Now()).Delete(&UploadReservation{}).Error; err != nil {
+			return err
+		}
+
+		usage, err := getUploadUsage(tx, userID, since)
+		if err != nil {
+			return err
+		}
+		size, err := reserve(usage)
+		if err != nil {
+			return err
+		}
+		reservation = &UploadReservation{UserID: userID, Size: size, Expires: expires}
+		return tx.Create(reservation).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// 確保した容量を解放する関数
+func ReleaseUploadReservation(id uint64) error {
+	return common.DB.Delete(&UploadReservation{}, id).Error
+}
+
+func getUploadUsage(db *gorm.DB, userID uint, since time.Time) (*UploadUsage, error) {
+	usage := &UploadUsage{}
+	now := time.Now()
+
+	var fileBytes, tusBytes, reservedBytes uint64
+	err := db.Table("video_files").
+		Select("CAST(COALESCE(SUM(video_files.file_size), 0) AS UNSIGNED)").
+		Joins("JOIN videos ON videos.id = video_files.video_id").
+		Where("videos.user_id = ? AND videos.deleted IS NULL", userID).
+		Where("video_files.deleted IS NULL AND video_files.source_file_id IS NULL").
+		Scan(&fileBytes).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Model(&TusUpload{}).
+		Select("CAST(COALESCE(SUM(upload_length), 0) AS UNSIGNED)").
+		Where("user_id = ? AND video_id IS NULL AND expires > ?", userID, now).
+		Scan(&tusBytes).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Model(&UploadReservation{}).
+		Select("CAST(COALESCE(SUM(size), 0) AS UNSIGNED)").
+		Where("user_id = ? AND expires > ?", userID, now).
+		Scan(&reservedBytes).Error
+	if err != nil {
+		return nil, err
+	}
+	usage.TotalBytes = fileBytes + tusBytes + reservedBytes
+
+	// 削除してアップロードし直すことで上限を超えられないよう、削除した動画ファイルも数える
+	var fileCount, tusCount, reservedCount int64
+	err = db.Table("video_files").
+		Joins("JOIN videos ON videos.id = video_files.video_id").
+		Where("videos.user_id = ? AND video_files.source_file_id IS NULL AND video_files.created >= ?", userID, since).
+		Count(&fileCount).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Model(&TusUpload{}).
+		Where("user_id = ? AND video_id IS NULL AND created >= ?", userID, since).
+		Count(&tusCount).Error
+	if err != nil {
+		return nil, err
+	}
+	err = db.Model(&UploadReservation{}).
+		Where("user_id = ? AND expires > ?", userID, now).
+		Count(&reservedCount).Error
+	if err != nil {
+		return nil, err
+	}
+	usage.UploadsToday = uint(fileCount + tusCount + reservedCount)
+	return usage, nil
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// HLS のメディアプレイリストの形式（レンディションの行の format）
+const RenditionFormat = "application/vnd.apple.mpegurl"
+
+// 動画ファイルのレンディションを置き換え、置き換える前のレンディションを返す関数
+// 置き換える前のレンディションは削除済みにする
+func ReplaceVideoFileRenditions(sourceFileID uint, renditions []VideoFile) ([]VideoFile, error) {
+	var old []VideoFile
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		// 変換元の動画ファイルの行をロックして、同じ動画ファイルのレンディションの保存を順に行う
+		var source VideoFile
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&source, sourceFileID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Where("source_file_id = ? AND deleted IS NULL", sourceFileID).Find(&old).Error; err != nil {
+			return err
+		}
+		if len(old) > 0 {
+			err := tx.Model(&VideoFile{}).
+				Where("source_file_id = ? AND deleted IS NULL", sourceFileID).
+				Update("deleted", time.Now()).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		for i := range renditions {
+			renditions[i].SourceFileID = &sourceFileID
+			if err := tx.Create(&renditions[i]).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return old, nil
+}
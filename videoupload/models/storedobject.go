@@ -0,0 +1,91 @@
+package models
+
+import (
+	"errors"
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 内容の SHA-256 をキーに保存した動画ファイルのオブジェクト
+// 同じ内容の動画ファイルは1つのオブジェクトを共有し、RefCount は参照している動画ファイルの数
+type StoredObject struct {
+	ID        uint64    `gorm:"primary_key"`
+	SHA256    string    `gorm:"column:sha256;type:char(64);not null;unique"`
+	ObjectKey string    `gorm:"type:varchar(255);not null;unique"`
+	FileSize  uint64    `gorm:"not null"`
+	Format    string    `gorm:"type:varchar(50);not null"`
+	RefCount  uint      `gorm:"not null;default:0"`
+	Created   time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+	Modified  time.Time `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+}
+
+// 同じ内容のオブジェクトが記録されていれば参照数を増やして返す関数
+// 記録されていない場合は nil を返す
+func ReuseStoredObject(sha256 string) (*StoredObject, error) {
+	result := common.DB.Model(&StoredObject{}).Where("sha256 = ?", sha256).Update("ref_count", gorm.Expr("ref_count + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	// 参照を増やしたため、取得するまでの間に削除されることはない
+	var object StoredObject
+	if err := common.DB.Where("sha256 = ?", sha256).First(&object).Error; err != nil {
+		return nil, err
+	}
+	return &object, nil
+}
+
+// 保存したオブジェクトを記録し、参照数を増やす関数
+// 保存している間に同じ内容のオブジェクトが記録された場合は、その参照数を増やす
+// ストレージへの保存はこの関数を呼ぶ前にトランザクションの外で行い、ロックは短い間しか保持しない
+func AddStoredObject(object *StoredObject) (*StoredObject, error) {
+	var stored StoredObject
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		record := *object
+		record.RefCount = 1
+		err := tx.Clauses(clause.OnConflict{
+			DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("ref_count + 1")}),
+		}).Create(&record).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("sha256 = ?", object.SHA256).First(&stored).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+// オブジェクトの参照をやめ、参照数を減らす関数
+// 最後の参照だった場合は remove でオブジェクトを削除してから行を削除する
+// 削除し終わるまで行をロックするため、AddStoredObject で同じ内容を記録し直すのは削除の後になる
+// オブジェクトキーが記録されていない（内容のハッシュで保存していない）場合は false を返す
+func ReleaseStoredObject(objectKey string, remove func() error) (bool, error) {
+	found := true
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		var object StoredObject
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("object_key = ?", objectKey).First(&object).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			found = false
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if object.RefCount > 1 {
+			return tx.Model(&object).Update("ref_count", gorm.Expr("ref_count - 1")).Error
+		}
+		if err := remove(); err != nil {
+			return err
+		}
+		return tx.Delete(&object).Error
+	})
+	return found, err
+}
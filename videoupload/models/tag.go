@@ -0,0 +1,56 @@
+package models
+
+import (
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Category struct {
+	ID   uint   `gorm:"primary_key"`
+	Slug string `gorm:"type:varchar(50);unique;not null"`
+	Name string `gorm:"type:varchar(100);not null"`
+}
+
+type Tag struct {
+	ID      uint      `gorm:"primary_key"`
+	Name    string    `gorm:"type:varchar(50);unique;not null"`
+	Created time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+func GetCategoryBySlug(slug string) (*Category, error) {
+	var category Category
+	if err := common.DB.Where("slug = ?", slug).First(&category).Error; err != nil {
+		return nil, err
+	}
+	return &category, nil
+}
+
+// トランザクションを使用して動画にタグを付ける関数
+func SaveVideoTagsWithTransaction(tx *gorm.DB, videoID uint, names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	tags := make([]Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, Tag{Name: name})
+	}
+	// 既存のタグはそのまま利用する
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return err
+	}
+
+	var tagIDs []uint
+	if err := tx.Model(&Tag{}).Where("name IN ?", names).Pluck("id", &tagIDs).Error; err != nil {
+		return err
+	}
+
+	rows := make([]map[string]interface{}, 0, len(tagIDs))
+	for _, tagID := range tagIDs {
+		rows = append(rows, map[string]interface{}{"video_id": videoID, "tag_id": tagID})
+	}
+	return tx.Table("video_tags").Clauses(clause.OnConflict{DoNothing: true}).Create(rows).Error
+}
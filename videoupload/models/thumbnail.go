@@ -0,0 +1,119 @@
+package models
+
+import (
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 動画ファイルの thumbnail_path に設定するサムネイルの種類
+const defaultThumbnailVariant = "large"
+
+type VideoThumbnail struct {
+	ID          uint      `gorm:"primary_key"`
+	VideoID     uint      `gorm:"not null"`
+	Variant     string    `gorm:"type:enum('small','medium','large','webp');not null"`
+	FilePath    string    `gorm:"type:varchar(255);not null"`
+	Width       uint      `gorm:"not null"`
+	Height      uint      `gorm:"not null"`
+	ContentType string    `gorm:"type:varchar(50);not null"`
+	Created     time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+// 動画のサムネイルを置き換え、置き換える前のファイルのパスを返す関数
+// 動画ファイルの thumbnail_path も大きいサイズのサムネイルに更新する
+func SaveVideoThumbnailsWithTransaction(tx *gorm.DB, videoID uint, thumbnails []VideoThumbnail) ([]string, error) {
+	// 動画の行をロックして、同じ動画のサムネイルの保存を順に行う
+	if err := lockVideo(tx, videoID); err != nil {
+		return nil, err
+	}
+
+	var old []VideoThumbnail
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("video_id = ?", videoID).
+		Find(&old).Error
+	if err != nil {
+		return nil, err
+	}
+
+	oldPaths := make([]string, 0, len(old))
+	for _, t := range old {
+		oldPaths = append(oldPaths, t.FilePath)
+	}
+	if len(old) > 0 {
+		if err := tx.Where("video_id = ?", videoID).Delete(&VideoThumbnail{}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	for i := range thumbnails {
+		thumbnails[i].VideoID = videoID
+		if err := tx.Create(&thumbnails[i]).Error; err != nil {
+			return nil, err
+		}
+		if thumbnails[i].Variant == defaultThumbnailVariant {
+			err := tx.Model(&VideoFile{}).
+				Where("video_id = ?", videoID).
+				Update("thumbnail_path", thumbnails[i].FilePath).Error
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return oldPaths, nil
+}
+
+// 動画のサムネイルを置き換える関数
+func ReplaceVideoThumbnails(videoID uint, thumbnails []VideoThumbnail) ([]string, error) {
+	var oldPaths []string
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		oldPaths, err = SaveVideoThumbnailsWithTransaction(tx, videoID, thumbnails)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return oldPaths, nil
+}
+
+// 動画にサムネイルがあるか確認する関数
+func HasVideoThumbnails(videoID uint) (bool, error) {
+	var count int64
+	if err := common.DB.Model(&VideoThumbnail{}).Where("video_id = ?", videoID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// 処理中に動画から作成したサムネイルを保存する関数
+// 投稿者がサムネイルを既にアップロードしている場合は保存せず false を返す
+func SaveGeneratedVideoThumbnails(videoID uint, thumbnails []VideoThumbnail) (bool, error) {
+	saved := false
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		// 同時にアップロードされたサムネイルを上書きしないよう、動画の行をロックしてから確認する
+		if err := lockVideo(tx, videoID); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&VideoThumbnail{}).Where("video_id = ?", videoID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+		if _, err := SaveVideoThumbnailsWithTransaction(tx, videoID, thumbnails); err != nil {
+			return err
+		}
+		saved = true
+		return nil
+	})
+	return saved, err
+}
+
+func lockVideo(tx *gorm.DB, videoID uint) error {
+	var video Video
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&video, videoID).Error
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"live/common"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 既に動画を作成したアップロードを再度完了しようとした場合のエラー
+var ErrTusUploadCompleted = errors.New("tus upload has already been completed")
+
+type TusUpload struct {
+	ID           string    `gorm:"primaryKey;type:char(36)"`
+	UserID       uint      `gorm:"not null"`
+	UploadLength int64     `gorm:"not null"`
+	UploadOffset int64     `gorm:"not null;default:0"`
+	Metadata     string    `gorm:"type:text"`
+	VideoID      *uint     `gorm:"default:NULL"`
+	Expires      time.Time `gorm:"not null"`
+	Created      time.Time `gorm:"default:CURRENT_TIMESTAMP"`
+}
+
+func CreateTusUpload(upload *TusUpload) error {
+	return common.DB.Create(upload).Error
+}
+
+func GetTusUpload(id string) (*TusUpload, error) {
+	var upload TusUpload
+	if err := common.DB.Where("id = ?", id).First(&upload).Error; err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// 受信済みのサイズと有効期限を更新する関数
+func UpdateTusUploadOffset(upload *TusUpload, offset int64, expires time.Time) error {
+	err := common.DB.Model(&TusUpload{}).
+		Where("id = ?", upload.ID).
+		Updates(map[string]interface{}{"upload_offset": offset, "expires": expires}).Error
+	if err != nil {
+		return err
+	}
+	upload.UploadOffset = offset
+	upload.Expires = expires
+	return nil
+}
+
+// 完了したアップロードから動画の情報を保存する関数
+// 同じアップロードから動画を二重に作成しないよう、アップロードの行をロックして動画IDを記録する
+func CompleteTusUpload(upload *TusUpload, video *Video, tags []string, videoFile *VideoFile) error {
+	err := common.DB.Transaction(func(tx *gorm.DB) error {
+		var locked TusUpload
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", upload.ID).First(&locked).Error; err != nil {
+			return err
+		}
+		if locked.VideoID != nil {
+			video.ID = *locked.VideoID
+			return ErrTusUploadCompleted
+		}
+
+		if err := SaveUploadedVideoWithTransaction(tx, video, tags, videoFile); err != nil {
+			return err
+		}
+		return tx.Model(&TusUpload{}).Where("id = ?", upload.ID).Update("video_id", video.ID).Error
+	})
+	if err != nil {
+		return err
+	}
+	upload.VideoID = &video.ID
+	return nil
+}
+
+func DeleteTusUpload(id string) error {
+	return common.DB.Where("id = ?", id).Delete(&TusUpload{}).Error
+}
+
+// 有効期限を過ぎたアップロードを取得する関数
+func GetExpiredTusUploads(now time.Time, limit int) ([]TusUpload, error) {
+	var uploads []TusUpload
+	err := common.DB.Where("expires <= ?", now).Order("expires").Limit(limit).Find(&uploads).Error
+	if err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
@@ -7,28 +7,65 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	VideoVisibilityPublic   = "public"
+	VideoVisibilityUnlisted = "unlisted"
+	VideoVisibilityPrivate  = "private"
+
+	VideoStatusDraft     = "draft"
+	VideoStatusScheduled = "scheduled"
+	VideoStatusPublished = "published"
+
+	VideoFileStatusPending    = "pending"
+	VideoFileStatusProcessing = "processing"
+	VideoFileStatusCompleted  = "completed"
+	VideoFileStatusFailed     = "failed"
+)
+
 type Video struct {
 	ID          uint       `gorm:"primary_key"`
 	UserID      uint       `gorm:"not null"`
 	Title       string     `gorm:"type:varchar(255);not null"`
 	Description string     `gorm:"type:text"`
+	Language    *string    `gorm:"type:varchar(35);default:NULL"`
+	Visibility  string     `gorm:"type:enum('public','unlisted','private');default:'public'"`
+	Status      string     `gorm:"type:enum('draft','scheduled','published');default:'published'"`
+	PublishAt   *time.Time `gorm:"default:NULL"`
+	Published   *time.Time `gorm:"default:NULL"`
+	CategoryID  *uint      `gorm:"default:NULL"`
 	Created     time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
 	Modified    time.Time  `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
 	Deleted     *time.Time `gorm:"default:NULL"`
 }
 
 type VideoFile struct {
-	ID            uint       `gorm:"primary_key"`
-	VideoID       uint       `gorm:"not null"`
-	FilePath      string     `gorm:"type:varchar(255);not null"`
-	ThumbnailPath string     `gorm:"type:varchar(255)"`
-	Duration      uint       `gorm:"type:int"`
-	FileSize      uint64     `gorm:"type:bigint"`
-	Format        string     `gorm:"type:varchar(50);not null"`
-	Status        string     `gorm:"type:enum('pending','processing','completed','failed');default:'pending'"`
-	Created       time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
-	Modified      time.Time  `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
-	Deleted       *time.Time `gorm:"default:NULL"`
+	ID                 uint       `gorm:"primary_key"`
+	VideoID            uint       `gorm:"not null"`
+	SourceFileID       *uint      `gorm:"default:NULL"` // レンディションの変換元の動画ファイル（元の動画ファイルの場合は nil）
+	Rendition          *string    `gorm:"type:varchar(20);default:NULL"`
+	Bandwidth          *uint      `gorm:"default:NULL"`
+	FilePath           string     `gorm:"type:varchar(255);not null"`
+	SourcePath         *string    `gorm:"type:varchar(255);default:NULL"` // 配信用に書き出す前の元の動画ファイル（書き出していない場合は nil）
+	ThumbnailPath      string     `gorm:"type:varchar(255)"`
+	Duration           uint       `gorm:"type:int"`
+	Width              *uint      `gorm:"default:NULL"`
+	Height             *uint      `gorm:"default:NULL"`
+	FrameRate          *float64   `gorm:"type:decimal(8,3);default:NULL"`
+	VideoCodec         *string    `gorm:"type:varchar(50);default:NULL"`
+	AudioCodec         *string    `gorm:"type:varchar(50);default:NULL"`
+	FileSize           uint64     `gorm:"type:bigint"`
+	Format             string     `gorm:"type:varchar(50);not null"`
+	Status             string     `gorm:"type:enum('pending','processing','completed','failed');default:'pending'"`
+	ProcessingStep     *string    `gorm:"type:varchar(20);default:NULL"`
+	ProcessingError    *string    `gorm:"type:text;default:NULL"`
+	ProcessingAttempts uint       `gorm:"not null;default:0"`
+	ProcessingStarted  *time.Time `gorm:"default:NULL"`
+	NextAttempt        *time.Time `gorm:"default:NULL"`
+	Processed          *time.Time `gorm:"default:NULL"`
+	Uploaded           *time.Time `gorm:"default:NULL"`
+	Created            time.Time  `gorm:"default:CURRENT_TIMESTAMP"`
+	Modified           time.Time  `gorm:"default:CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP"`
+	Deleted            *time.Time `gorm:"default:NULL"`
 }
 
 func SaveVideo(userID uint, title, description string) (*Video, error) {
@@ -63,18 +100,13 @@ func SaveVideoFile(videoID uint, filePath, thumbnailPath string, duration uint,
 }
 
 // トランザクションを使用して動画情報を保存する関数
-func SaveVideoWithTransaction(tx *gorm.DB, userID uint, title, description string) (*Video, error) {
-	video := Video{
-		UserID:      userID,
-		Title:       title,
-		Description: description,
-	}
-
-	if err := tx.Create(&video).Error; err != nil {
-		return nil, err
+// 公開済みの動画は公開した日時を記録する
+func SaveVideoWithTransaction(tx *gorm.DB, video *Video) error {
+	if video.Status == VideoStatusPublished && video.Published == nil {
+		published := time.Now().Truncate(time.Second)
+		video.Published = &published
 	}
-
-	return &video, nil
+	return tx.Create(video).Error
 }
 
 // トランザクションを使用して動画ファイル情報を保存する関数
@@ -93,3 +125,54 @@ func SaveVideoFileWithTransaction(tx *gorm.DB, videoID uint, filePath string, du
 
 	return &videoFile, nil
 }
+
+// トランザクションを使用してアップロードした動画の情報（動画・タグ・チャプター・動画ファイル）を保存する関数
+func SaveUploadedVideoWithTransaction(tx *gorm.DB, video *Video, tags []string, videoFile *VideoFile) error {
+	uploaded := time.Now().Truncate(time.Second)
+	videoFile.Uploaded = &uploaded
+	return saveVideoWithFileTransaction(tx, video, tags, videoFile)
+}
+
+// 動画・タグ・チャプター・動画ファイルを保存する
+func saveVideoWithFileTransaction(tx *gorm.DB, video *Video, tags []string, videoFile *VideoFile) error {
+	if err := SaveVideoWithTransaction(tx, video); err != nil {
+		return err
+	}
+
+	if err := SaveVideoTagsWithTransaction(tx, video.ID, tags); err != nil {
+		return err
+	}
+
+	// 概要欄の「0:00 タイトル」形式の行をチャプターとして登録する
+	if err := SaveDescriptionChaptersWithTransaction(tx, video.ID, common.ParseDescriptionChapters(video.Description)); err != nil {
+		return err
+	}
+
+	videoFile.VideoID = video.ID
+	return tx.Create(videoFile).Error
+}
+
+// 削除した動画の、まだ削除済みにしていない動画ファイルとレンディションを取得する関数
+// アップロード途中のファイルと処理中のファイルは含めない
+func GetFilesOfDeletedVideos(limit int) ([]VideoFile, error) {
+	var files []VideoFile
+	err := common.DB.
+		Where("deleted IS NULL AND uploaded IS NOT NULL AND status <> ?", VideoFileStatusProcessing).
+		Where("video_id IN (SELECT id FROM videos WHERE deleted IS NOT NULL)").
+		Order("id").
+		Limit(limit).
+		Find(&files).Error
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// 動画ファイルを削除済みにする関数
+// 既に削除済みの場合は false を返す
+func DeleteVideoFile(fileID uint) (bool, error) {
+	result := common.DB.Model(&VideoFile{}).
+		Where("id = ? AND deleted IS NULL", fileID).
+		Update("deleted", time.Now().Truncate(time.Second))
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,272 @@
+package probe
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"strings"
+)
+
+// Matroska / WebM の要素ID
+const (
+	ebmlIDHeader          = 0x1A45DFA3
+	ebmlIDSegment         = 0x18538067
+	ebmlIDInfo            = 0x1549A966
+	ebmlIDTimecodeScale   = 0x2AD7B1
+	ebmlIDDuration        = 0x4489
+	ebmlIDTracks          = 0x1654AE6B
+	ebmlIDTrackEntry      = 0xAE
+	ebmlIDTrackType       = 0x83
+	ebmlIDCodecID         = 0x86
+	ebmlIDDefaultDuration = 0x23E383
+	ebmlIDVideo           = 0xE0
+	ebmlIDPixelWidth      = 0xB0
+	ebmlIDPixelHeight     = 0xBA
+	ebmlIDCluster         = 0x1F43B675
+)
+
+// TrackType の値
+const (
+	matroskaTrackVideo = 1
+	matroskaTrackAudio = 2
+)
+
+// サイズが不明な要素（ライブ配信の録画などで使われる）
+const ebmlUnknownSize = -1
+
+// EBML ヘッダーの後の Segment から Info と Tracks を読み取る
+// 通常は Cluster より前にあるため、Cluster に達した時点で読み込みをやめる
+func probeMatroska(r io.ReaderAt, size int64) (*Info, error) {
+	var offset int64
+	segmentEnd := int64(-1)
+	var infoData, tracksData []byte
+
+	for offset < size {
+		id, dataSize, headerSize, err := readElementHeader(r, offset, size)
+		if err != nil {
+			return nil, err
+		}
+		dataStart := offset + headerSize
+
+		if segmentEnd < 0 {
+			// Segment の中身を読み始めるまではトップレベルの要素を飛ばす
+			switch {
+			case id == ebmlIDSegment:
+				segmentEnd = size
+				if dataSize != ebmlUnknownSize && dataStart+dataSize < size {
+					segmentEnd = dataStart + dataSize
+				}
+				offset = dataStart
+				continue
+			case offset == 0 && id != ebmlIDHeader, dataSize == ebmlUnknownSize:
+				return nil, ErrInvalid
+			}
+			offset = dataStart + dataSize
+			continue
+		}
+
+		if offset >= segmentEnd {
+			break
+		}
+		switch id {
+		case ebmlIDInfo, ebmlIDTracks:
+			if dataSize == ebmlUnknownSize {
+				return nil, ErrInvalid
+			}
+			data, err := readFull(r, dataStart, dataSize)
+			if err != nil {
+				return nil, err
+			}
+			if id == ebmlIDInfo {
+				infoData = data
+			} else {
+				tracksData = data
+			}
+		case ebmlIDCluster:
+			if infoData != nil && tracksData != nil {
+				return parseMatroska(infoData, tracksData)
+			}
+		}
+		if infoData != nil && tracksData != nil {
+			break
+		}
+		if dataSize == ebmlUnknownSize {
+			break
+		}
+		offset = dataStart + dataSize
+	}
+
+	if infoData == nil && tracksData == nil {
+		return nil, ErrInvalid
+	}
+	return parseMatroska(infoData, tracksData)
+}
+
+// offset にある要素のID、データサイズ、ヘッダーのサイズを読み取る
+func readElementHeader(r io.ReaderAt, offset, size int64) (uint32, int64, int64, error) {
+	n := int64(12)
+	if size-offset < n {
+		n = size - offset
+	}
+	header, err := readFull(r, offset, n)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+
+	idLength := vintLength(header[0])
+	if idLength == 0 || idLength > 4 || idLength >= len(header) {
+		return 0, 0, 0, ErrInvalid
+	}
+	var id uint32
+	for _, b := range header[:idLength] {
+		id = id<<8 | uint32(b)
+	}
+
+	dataSize, sizeLength, ok := readVint(header[idLength:])
+	if !ok {
+		return 0, 0, 0, ErrInvalid
+	}
+	headerSize := int64(idLength + sizeLength)
+	if dataSize != ebmlUnknownSize && dataSize > size-offset-headerSize {
+		return 0, 0, 0, ErrInvalid
+	}
+	return id, dataSize, headerSize, nil
+}
+
+// 可変長整数の先頭バイトから長さを求める（不正な場合は 0）
+func vintLength(b byte) int {
+	for i := 0; i < 8; i++ {
+		if b&(0x80>>i) != 0 {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// 可変長整数のデータサイズを読み取る（すべてのビットが1の場合はサイズ不明）
+func readVint(data []byte) (int64, int, bool) {
+	if len(data) == 0 {
+		return 0, 0, false
+	}
+	length := vintLength(data[0])
+	if length == 0 || length > len(data) {
+		return 0, 0, false
+	}
+	mask := byte(0xFF >> length)
+	value := int64(data[0] & mask)
+	allOnes := data[0]&mask == mask
+	for _, b := range data[1:length] {
+		value = value<<8 | int64(b)
+		allOnes = allOnes && b == 0xFF
+	}
+	if allOnes {
+		return ebmlUnknownSize, length, true
+	}
+	return value, length, true
+}
+
+// data に含まれる子要素を順に fn に渡す
+func eachElement(data []byte, fn func(id uint32, payload []byte)) error {
+	for len(data) > 0 {
+		idLength := vintLength(data[0])
+		if idLength == 0 || idLength > 4 || idLength > len(data) {
+			return ErrInvalid
+		}
+		var id uint32
+		for _, b := range data[:idLength] {
+			id = id<<8 | uint32(b)
+		}
+		dataSize, sizeLength, ok := readVint(data[idLength:])
+		if !ok || dataSize == ebmlUnknownSize || dataSize > int64(len(data)-idLength-sizeLength) {
+			return ErrInvalid
+		}
+		start := idLength + sizeLength
+		fn(id, data[start:start+int(dataSize)])
+		data = data[start+int(dataSize):]
+	}
+	return nil
+}
+
+func readUint(data []byte) uint64 {
+	var v uint64
+	for _, b := range data {
+		v = v<<8 | uint64(b)
+	}
+	return v
+}
+
+func readFloat(data []byte) float64 {
+	switch len(data) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(data)))
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(data))
+	}
+	return 0
+}
+
+func parseMatroska(infoData, tracksData []byte) (*Info, error) {
+	info := &Info{}
+
+	timecodeScale := uint64(1000000) // 既定値は1ミリ秒
+	var duration float64
+	err := eachElement(infoData, func(id uint32, payload []byte) {
+		switch id {
+		case ebmlIDTimecodeScale:
+			if v := readUint(payload); v > 0 {
+				timecodeScale = v
+			}
+		case ebmlIDDuration:
+			duration = readFloat(payload)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	info.Duration = duration * float64(timecodeScale) / 1e9
+
+	err = eachElement(tracksData, func(id uint32, payload []byte) {
+		if id != ebmlIDTrackEntry {
+			return
+		}
+		var trackType uint64
+		var codecID string
+		var defaultDuration uint64
+		var width, height uint
+		eachElement(payload, func(id uint32, payload []byte) {
+			switch id {
+			case ebmlIDTrackType:
+				trackType = readUint(payload)
+			case ebmlIDCodecID:
+				codecID = strings.TrimRight(string(payload), "\x00")
+			case ebmlIDDefaultDuration:
+				defaultDuration = readUint(payload)
+			case ebmlIDVideo:
+				eachElement(payload, func(id uint32, payload []byte) {
+					switch id {
+					case ebmlIDPixelWidth:
+						width = uint(readUint(payload))
+					case ebmlIDPixelHeight:
+						height = uint(readUint(payload))
+					}
+				})
+			}
+		})
+
+		switch {
+		case trackType == matroskaTrackVideo && info.VideoCodec == "":
+			info.VideoCodec = codecName(codecID)
+			info.Width, info.Height = width, height
+			// DefaultDuration は1フレームの長さ（ナノ秒）
+			if defaultDuration > 0 {
+				info.FrameRate = roundFrameRate(1e9 / float64(defaultDuration))
+			}
+		case trackType == matroskaTrackAudio && info.AudioCodec == "":
+			info.AudioCodec = codecName(codecID)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
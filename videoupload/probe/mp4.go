@@ -0,0 +1,362 @@
+package probe
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// MP4 / QuickTime のトラックから読み取った情報
+type mp4Track struct {
+	handler     string // vide / soun など
+	timescale   uint32
+	duration    uint64 // timescale 単位
+	sampleEntry string
+	width       uint
+	height      uint
+	sampleCount uint64
+	objectType  byte // mp4a の esds に含まれるオブジェクトタイプ
+}
+
+// トップレベルのボックスを順に読み、moov ボックスを解析する
+// moov は mdat の後ろ（ファイルの末尾）にある場合もあるため、mdat の中身は読まずに飛ばす
+func probeMP4(r io.ReaderAt, size int64) (*Info, error) {
+	var offset int64
+	for offset+8 <= size {
+		n := int64(16)
+		if size-offset < n {
+			n = size - offset
+		}
+		header, err := readFull(r, offset, n)
+		if err != nil {
+			return nil, err
+		}
+
+		boxSize := int64(binary.BigEndian.Uint32(header[0:4]))
+		boxType := string(header[4:8])
+		headerSize := int64(8)
+		switch boxSize {
+		case 0: // ファイルの終端まで
+			boxSize = size - offset
+		case 1: // 64ビットのサイズ
+			if len(header) < 16 {
+				return nil, ErrInvalid
+			}
+			boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if boxSize < headerSize || boxSize > size-offset {
+			return nil, ErrInvalid
+		}
+
+		if boxType == "moov" {
+			data, err := readFull(r, offset+headerSize, boxSize-headerSize)
+			if err != nil {
+				return nil, err
+			}
+			return parseMoov(data)
+		}
+		offset += boxSize
+	}
+	return nil, ErrInvalid
+}
+
+// data に含まれる子ボックスを順に fn に渡す
+func eachBox(data []byte, fn func(boxType string, payload []byte) error) error {
+	for len(data) >= 8 {
+		boxSize := uint64(binary.BigEndian.Uint32(data[0:4]))
+		boxType := string(data[4:8])
+		headerSize := uint64(8)
+		switch boxSize {
+		case 0:
+			boxSize = uint64(len(data))
+		case 1:
+			if len(data) < 16 {
+				return ErrInvalid
+			}
+			boxSize = binary.BigEndian.Uint64(data[8:16])
+			headerSize = 16
+		}
+		if boxSize < headerSize || boxSize > uint64(len(data)) {
+			return ErrInvalid
+		}
+		if err := fn(boxType, data[headerSize:boxSize]); err != nil {
+			return err
+		}
+		data = data[boxSize:]
+	}
+	return nil
+}
+
+func parseMoov(data []byte) (*Info, error) {
+	var timescale uint32
+	var duration, fragmentDuration uint64
+	var tracks []mp4Track
+
+	err := eachBox(data, func(boxType string, payload []byte) error {
+		switch boxType {
+		case "mvhd":
+			var ok bool
+			timescale, duration, ok = parseTimeHeader(payload)
+			if !ok {
+				return ErrInvalid
+			}
+		case "mvex":
+			// 断片化した MP4 では mvhd の再生時間が 0 になり、mehd に全体の再生時間が入る
+			return eachBox(payload, func(boxType string, payload []byte) error {
+				if boxType == "mehd" && len(payload) >= 8 {
+					if payload[0] == 1 && len(payload) >= 12 {
+						fragmentDuration = binary.BigEndian.Uint64(payload[4:12])
+					} else {
+						fragmentDuration = uint64(binary.BigEndian.Uint32(payload[4:8]))
+					}
+				}
+				return nil
+			})
+		case "trak":
+			track, err := parseTrak(payload)
+			if err != nil {
+				return err
+			}
+			tracks = append(tracks, track)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := &Info{}
+	if duration == 0 {
+		duration = fragmentDuration
+	}
+	if timescale > 0 {
+		info.Duration = float64(duration) / float64(timescale)
+	}
+
+	for _, t := range tracks {
+		switch {
+		case t.handler == "vide" && info.VideoCodec == "":
+			info.VideoCodec = codecName(t.sampleEntry)
+			info.Width, info.Height = t.width, t.height
+			if t.timescale > 0 && t.duration > 0 && t.sampleCount > 0 {
+				info.FrameRate = roundFrameRate(float64(t.sampleCount) * float64(t.timescale) / float64(t.duration))
+			}
+		case t.handler == "soun" && info.AudioCodec == "":
+			info.AudioCodec = audioCodecName(t)
+		default:
+			continue
+		}
+		// mvhd に再生時間がない場合はトラックの再生時間を使う
+		if info.Duration == 0 && t.timescale > 0 {
+			info.Duration = float64(t.duration) / float64(t.timescale)
+		}
+	}
+	return info, nil
+}
+
+// mvhd / mdhd から timescale と再生時間を読み取る
+func parseTimeHeader(payload []byte) (uint32, uint64, bool) {
+	if len(payload) < 4 {
+		return 0, 0, false
+	}
+	if payload[0] == 1 {
+		if len(payload) < 32 {
+			return 0, 0, false
+		}
+		return binary.BigEndian.Uint32(payload[20:24]), binary.BigEndian.Uint64(payload[24:32]), true
+	}
+	if len(payload) < 20 {
+		return 0, 0, false
+	}
+	return binary.BigEndian.Uint32(payload[12:16]), uint64(binary.BigEndian.Uint32(payload[16:20])), true
+}
+
+func parseTrak(data []byte) (mp4Track, error) {
+	var t mp4Track
+	var tkhdWidth, tkhdHeight uint
+
+	err := eachBox(data, func(boxType string, payload []byte) error {
+		switch boxType {
+		case "tkhd":
+			// 表示サイズ（16.16 固定小数点）は末尾の8バイト
+			if len(payload) >= 84 {
+				tkhdWidth = uint(binary.BigEndian.Uint32(payload[len(payload)-8:]) >> 16)
+				tkhdHeight = uint(binary.BigEndian.Uint32(payload[len(payload)-4:]) >> 16)
+			}
+		case "mdia":
+			return eachBox(payload, func(boxType string, payload []byte) error {
+				switch boxType {
+				case "mdhd":
+					var ok bool
+					t.timescale, t.duration, ok = parseTimeHeader(payload)
+					if !ok {
+						return ErrInvalid
+					}
+				case "hdlr":
+					if len(payload) >= 12 {
+						t.handler = string(payload[8:12])
+					}
+				case "minf":
+					return eachBox(payload, func(boxType string, payload []byte) error {
+						if boxType == "stbl" {
+							return parseStbl(payload, &t)
+						}
+						return nil
+					})
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return t, err
+	}
+
+	if t.width == 0 || t.height == 0 {
+		t.width, t.height = tkhdWidth, tkhdHeight
+	}
+	return t, nil
+}
+
+func parseStbl(data []byte, t *mp4Track) error {
+	return eachBox(data, func(boxType string, payload []byte) error {
+		switch boxType {
+		case "stsd":
+			// 最初のサンプルエントリのみ読み取る
+			if len(payload) < 16 {
+				return nil
+			}
+			entrySize := binary.BigEndian.Uint32(payload[8:12])
+			if entrySize < 8 || uint64(entrySize) > uint64(len(payload)-8) {
+				return ErrInvalid
+			}
+			t.sampleEntry = string(payload[12:16])
+			parseSampleEntry(payload[16:8+entrySize], t)
+		case "stts":
+			if len(payload) < 8 {
+				return nil
+			}
+			count := binary.BigEndian.Uint32(payload[4:8])
+			entries := payload[8:]
+			for i := uint32(0); i < count && len(entries) >= 8; i++ {
+				t.sampleCount += uint64(binary.BigEndian.Uint32(entries[0:4]))
+				entries = entries[8:]
+			}
+		}
+		return nil
+	})
+}
+
+// サンプルエントリから映像のサイズと音声のオブジェクトタイプを読み取る
+func parseSampleEntry(entry []byte, t *mp4Track) {
+	switch t.handler {
+	case "vide":
+		if len(entry) >= 28 {
+			t.width = uint(binary.BigEndian.Uint16(entry[24:26]))
+			t.height = uint(binary.BigEndian.Uint16(entry[26:28]))
+		}
+	case "soun":
+		if len(entry) < 28 {
+			return
+		}
+		// QuickTime のサウンド記述はバージョンによってフィールドが追加される
+		offset := 28
+		switch binary.BigEndian.Uint16(entry[8:10]) {
+		case 1:
+			offset += 16
+		case 2:
+			offset += 36
+		}
+		if offset > len(entry) {
+			return
+		}
+		var findESDS func(data []byte) error
+		findESDS = func(data []byte) error {
+			return eachBox(data, func(boxType string, payload []byte) error {
+				switch boxType {
+				case "esds":
+					t.objectType = esdsObjectType(payload)
+				case "wave":
+					return findESDS(payload)
+				}
+				return nil
+			})
+		}
+		findESDS(entry[offset:])
+	}
+}
+
+// esds ボックスの DecoderConfigDescriptor からオブジェクトタイプを取得する
+func esdsObjectType(payload []byte) byte {
+	if len(payload) < 4 {
+		return 0
+	}
+	data := payload[4:]
+	for len(data) >= 2 {
+		tag := data[0]
+		data = data[1:]
+		// 記述子の長さは最大4バイトの可変長
+		length := 0
+		for i := 0; i < 4 && len(data) > 0; i++ {
+			b := data[0]
+			data = data[1:]
+			length = length<<7 | int(b&0x7F)
+			if b&0x80 == 0 {
+				break
+			}
+		}
+		switch tag {
+		case 0x03: // ES_Descriptor（子の記述子を続けて読む）
+			if len(data) < 3 {
+				return 0
+			}
+			flags := data[2]
+			data = data[3:]
+			if flags&0x80 != 0 {
+				data = skip(data, 2)
+			}
+			if flags&0x40 != 0 && len(data) > 0 {
+				data = skip(data, 1+int(data[0]))
+			}
+			if flags&0x20 != 0 {
+				data = skip(data, 2)
+			}
+		case 0x04: // DecoderConfigDescriptor
+			if len(data) == 0 {
+				return 0
+			}
+			return data[0]
+		default:
+			data = skip(data, length)
+		}
+	}
+	return 0
+}
+
+func skip(data []byte, n int) []byte {
+	if n > len(data) {
+		return nil
+	}
+	return data[n:]
+}
+
+func audioCodecName(t mp4Track) string {
+	if t.sampleEntry == "mp4a" {
+		switch t.objectType {
+		case 0x69, 0x6B:
+			return "mp3"
+		case 0xA5:
+			return "ac3"
+		case 0xA6:
+			return "eac3"
+		}
+	}
+	return codecName(t.sampleEntry)
+}
+
+// フレームレートを小数点以下3桁に丸める
+func roundFrameRate(fps float64) float64 {
+	return math.Round(fps*1000) / 1000
+}
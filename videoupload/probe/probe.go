@@ -0,0 +1,135 @@
+// Package probe は動画ファイルのコンテナを解析し、再生時間や解像度、コーデックを取得する
+// 外部コマンドを使わず、MP4 / QuickTime のボックスと Matroska / WebM の EBML を読み込む
+package probe
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	// 解析に対応していないコンテナ形式
+	ErrUnsupported = errors.New("probe: unsupported container")
+	// コンテナの構造が壊れている
+	ErrInvalid = errors.New("probe: invalid container")
+)
+
+// 解析のために一度に読み込む要素の最大サイズ（moov ボックスや Tracks 要素など）
+const maxElementSize = 64 << 20
+
+// 動画ファイルの解析結果（取得できなかった項目はゼロ値）
+type Info struct {
+	Duration   float64 // 再生時間（秒）
+	Width      uint
+	Height     uint
+	FrameRate  float64 // フレームレート（fps）
+	VideoCodec string
+	AudioCodec string
+}
+
+// Probe は r から size バイトの動画ファイルを解析する
+// 対応しているのは MP4 / QuickTime / 3GP と Matroska / WebM で、それ以外は ErrUnsupported を返す
+func Probe(r io.ReaderAt, size int64) (*Info, error) {
+	head := make([]byte, 12)
+	n, err := r.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	head = head[:n]
+
+	switch {
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		return probeMatroska(r, size)
+	case len(head) >= 8 && isTopLevelBox(string(head[4:8])):
+		return probeMP4(r, size)
+	}
+	return nil, ErrUnsupported
+}
+
+// MP4 / QuickTime のファイルの先頭に置かれるボックス
+func isTopLevelBox(boxType string) bool {
+	switch boxType {
+	case "ftyp", "moov", "mdat", "wide", "free", "skip", "pnot":
+		return true
+	}
+	return false
+}
+
+// r の offset から n バイトを読み込む（ファイルの終端を超える場合は ErrInvalid）
+func readFull(r io.ReaderAt, offset int64, n int64) ([]byte, error) {
+	if n > maxElementSize {
+		return nil, ErrInvalid
+	}
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, offset)
+	if int64(read) < n {
+		if err == nil || err == io.EOF {
+			return nil, ErrInvalid
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// MP4 のサンプルエントリと Matroska の CodecID をよく使われるコーデック名にそろえる
+var codecNames = map[string]string{
+	// MP4 / QuickTime のサンプルエントリ
+	"avc1": "h264",
+	"avc3": "h264",
+	"hvc1": "hevc",
+	"hev1": "hevc",
+	"vp08": "vp8",
+	"vp09": "vp9",
+	"av01": "av1",
+	"mp4v": "mpeg4",
+	"apcn": "prores",
+	"apch": "prores",
+	"apcs": "prores",
+	"apco": "prores",
+	"ap4h": "prores",
+	"mp4a": "aac",
+	".mp3": "mp3",
+	"ac-3": "ac3",
+	"ec-3": "eac3",
+	"Opus": "opus",
+	"fLaC": "flac",
+	"alac": "alac",
+	"lpcm": "pcm",
+	"sowt": "pcm",
+	"twos": "pcm",
+
+	// Matroska / WebM の CodecID
+	"V_MPEG4/ISO/AVC":  "h264",
+	"V_MPEGH/ISO/HEVC": "hevc",
+	"V_VP8":            "vp8",
+	"V_VP9":            "vp9",
+	"V_AV1":            "av1",
+	"V_MPEG4/ISO/SP":   "mpeg4",
+	"V_MPEG4/ISO/ASP":  "mpeg4",
+	"V_MPEG2":          "mpeg2video",
+	"V_PRORES":         "prores",
+	"A_AAC":            "aac",
+	"A_MPEG/L3":        "mp3",
+	"A_AC3":            "ac3",
+	"A_EAC3":           "eac3",
+	"A_OPUS":           "opus",
+	"A_VORBIS":         "vorbis",
+	"A_FLAC":           "flac",
+	"A_ALAC":           "alac",
+	"A_PCM/INT/LIT":    "pcm",
+	"A_PCM/INT/BIG":    "pcm",
+	"A_PCM/FLOAT/IEEE": "pcm",
+}
+
+func codecName(id string) string {
+	if name, ok := codecNames[id]; ok {
+		return name
+	}
+	// A_AAC/MPEG4/LC などのプロファイル付きの指定
+	if strings.HasPrefix(id, "A_AAC/") {
+		return "aac"
+	}
+	return strings.ToLower(strings.TrimSpace(id))
+}
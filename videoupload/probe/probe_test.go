@@ -0,0 +1,131 @@
+package probe
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func u16(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+func u32(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+func zeros(n int) []byte  { return make([]byte, n) }
+
+// MP4 のボックスを作成する
+func box(boxType string, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	return append(append(u32(uint32(8+len(body))), boxType...), body...)
+}
+
+// 12.5秒、1280x720、30fps の H.264 / AAC の MP4（moov ボックスを末尾に置く）
+func buildMP4() []byte {
+	mvhd := box("mvhd", zeros(4), zeros(8), u32(1000), u32(12500), zeros(80))
+	tkhdV := box("tkhd", zeros(4), zeros(72), u32(1280<<16), u32(720<<16))
+	mdhdV := box("mdhd", zeros(4), zeros(8), u32(30000), u32(375000), zeros(4))
+	hdlrV := box("hdlr", zeros(4), zeros(4), []byte("vide"), zeros(12), []byte("v\x00"))
+	avc1 := box("avc1", zeros(6), u16(1), zeros(16), u16(1280), u16(720), zeros(50))
+	sttsV := box("stts", zeros(4), u32(1), u32(375), u32(1000))
+	trakV := box("trak", tkhdV, box("mdia", mdhdV, hdlrV, box("minf", box("stbl", box("stsd", zeros(4), u32(1), avc1), sttsV))))
+	mdhdA := box("mdhd", zeros(4), zeros(8), u32(48000), u32(600000), zeros(4))
+	hdlrA := box("hdlr", zeros(4), zeros(4), []byte("soun"), zeros(12), []byte("s\x00"))
+	mp4a := box("mp4a", zeros(6), u16(1), zeros(8), u16(2), u16(16), zeros(4), u32(48000<<16))
+	trakA := box("trak", box("mdia", mdhdA, hdlrA, box("minf", box("stbl", box("stsd", zeros(4), u32(1), mp4a)))))
+	ftyp := box("ftyp", []byte("isom"), zeros(4), []byte("isomavc1"))
+	mdat := box("mdat", bytes.Repeat([]byte{0xAA}, 1000))
+	return bytes.Join([][]byte{ftyp, mdat, box("moov", mvhd, trakV, trakA)}, nil)
+}
+
+// EBML の要素を作成する
+func ebml(id []byte, parts ...[]byte) []byte {
+	body := bytes.Join(parts, nil)
+	size := binary.BigEndian.AppendUint64(nil, uint64(len(body))|1<<56)
+	return append(append(append([]byte{}, id...), size...), body...)
+}
+
+func ebmlUint(id []byte, v uint64) []byte {
+	return ebml(id, binary.BigEndian.AppendUint64(nil, v))
+}
+
+// 61.5秒、640x360、約23.976fps の VP9 / Opus の WebM（Segment のサイズは不明）
+func buildWebM() []byte {
+	header := ebml([]byte{0x1A, 0x45, 0xDF, 0xA3}, ebml([]byte{0x42, 0x82}, []byte("webm")))
+	info := ebml([]byte{0x15, 0x49, 0xA9, 0x66},
+		ebmlUint([]byte{0x2A, 0xD7, 0xB1}, 1000000),
+		ebml([]byte{0x44, 0x89}, binary.BigEndian.AppendUint64(nil, math.Float64bits(61500))))
+	video := ebml([]byte{0xAE},
+		ebmlUint([]byte{0x83}, 1),
+		ebml([]byte{0x86}, []byte("V_VP9")),
+		ebmlUint([]byte{0x23, 0xE3, 0x83}, 41708333),
+		ebml([]byte{0xE0}, ebmlUint([]byte{0xB0}, 640), ebmlUint([]byte{0xBA}, 360)))
+	audio := ebml([]byte{0xAE}, ebmlUint([]byte{0x83}, 2), ebml([]byte{0x86}, []byte("A_OPUS")))
+	tracks := ebml([]byte{0x16, 0x54, 0xAE, 0x6B}, video, audio)
+	cluster := ebml([]byte{0x1F, 0x43, 0xB6, 0x75}, bytes.Repeat([]byte{0xBB}, 1000))
+	segment := append([]byte{0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}, bytes.Join([][]byte{info, tracks, cluster}, nil)...)
+	return append(header, segment...)
+}
+
+func TestProbe(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want Info
+	}{
+		{
+			name: "mp4",
+			data: buildMP4(),
+			want: Info{Duration: 12.5, Width: 1280, Height: 720, FrameRate: 30, VideoCodec: "h264", AudioCodec: "aac"},
+		},
+		{
+			name: "webm",
+			data: buildWebM(),
+			want: Info{Duration: 61.5, Width: 640, Height: 360, FrameRate: 23.976, VideoCodec: "vp9", AudioCodec: "opus"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if err != nil {
+				t.Fatalf("Probe() error = %v", err)
+			}
+			if math.Abs(info.Duration-tt.want.Duration) > 0.001 || math.Abs(info.FrameRate-tt.want.FrameRate) > 0.001 {
+				t.Errorf("Duration, FrameRate = %v, %v, want %v, %v", info.Duration, info.FrameRate, tt.want.Duration, tt.want.FrameRate)
+			}
+			if info.Width != tt.want.Width || info.Height != tt.want.Height {
+				t.Errorf("size = %dx%d, want %dx%d", info.Width, info.Height, tt.want.Width, tt.want.Height)
+			}
+			if info.VideoCodec != tt.want.VideoCodec || info.AudioCodec != tt.want.AudioCodec {
+				t.Errorf("codecs = %q, %q, want %q, %q", info.VideoCodec, info.AudioCodec, tt.want.VideoCodec, tt.want.AudioCodec)
+			}
+		})
+	}
+}
+
+func TestProbeErrors(t *testing.T) {
+	mp4 := buildMP4()
+	// moov ボックスの途中で切れたファイル
+	truncated := mp4[:len(mp4)-100]
+	// サイズが親のボックスを超える子のボックス
+	broken := bytes.Join([][]byte{box("ftyp", []byte("isom"), zeros(4)), u32(0xFFFFFF00), []byte("moov")}, nil)
+
+	tests := []struct {
+		name string
+		data []byte
+		want error
+	}{
+		{name: "empty", data: nil, want: ErrUnsupported},
+		{name: "avi", data: []byte("RIFF\x00\x00\x00\x00AVI LIST"), want: ErrUnsupported},
+		{name: "truncated mp4", data: truncated, want: ErrInvalid},
+		{name: "oversized box", data: broken, want: ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Probe(bytes.NewReader(tt.data), int64(len(tt.data)))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Probe() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -3,13 +3,44 @@ package videoupload
 import (
 	"live/common"
 	"live/videoupload/handlers"
+	"live/videoupload/services"
+	"net/http"
 
 	"github.com/gorilla/mux"
 )
 
 func RegisterRoutes(router *mux.Router) {
+	// tus のサーバー情報は認証なしで取得できる
+	router.HandleFunc("/api/v1/videoupload/tus", handlers.TusOptions).Methods("OPTIONS")
+
 	videouploadRouter := router.PathPrefix("/api/v1/videoupload").Subrouter()
-	videouploadRouter.Use(common.AuthMiddleware)
+	videouploadRouter.Use(common.AuthMiddleware, common.ActiveUserMiddleware)
 
 	videouploadRouter.HandleFunc("/upload", handlers.Upload).Methods("POST")
+	videouploadRouter.HandleFunc("/quota", handlers.GetMyUploadQuota).Methods("GET")
+	videouploadRouter.HandleFunc("/videos/{id:[0-9]+}/captions", handlers.UploadCaption).Methods("POST")
+	videouploadRouter.HandleFunc("/videos/{id:[0-9]+}/captions/{language}", handlers.DeleteCaption).Methods("DELETE")
+	videouploadRouter.HandleFunc("/videos/{id:[0-9]+}/processing", handlers.GetProcessingStatus).Methods("GET")
+	videouploadRouter.HandleFunc("/videos/{id:[0-9]+}/processing/retry", handlers.RetryProcessing).Methods("POST")
+	videouploadRouter.HandleFunc("/direct-uploads", handlers.InitiateDirectUpload).Methods("POST")
+	videouploadRouter.HandleFunc("/direct-uploads/{id:[0-9]+}/complete", handlers.CompleteDirectUpload).Methods("POST")
+	videouploadRouter.HandleFunc("/tus", handlers.TusCreate).Methods("POST")
+	videouploadRouter.HandleFunc("/tus/{id:"+services.TusUploadIDPattern+"}", handlers.TusHead).Methods("HEAD")
+	videouploadRouter.HandleFunc("/tus/{id:"+services.TusUploadIDPattern+"}", handlers.TusPatch).Methods("PATCH")
+	videouploadRouter.HandleFunc("/tus/{id:"+services.TusUploadIDPattern+"}", handlers.TusDelete).Methods("DELETE")
+
+	// ユーザーごとのアップロードの上限の設定（管理者のみ）
+	adminRouter := router.PathPrefix("/api/v1/admin").Subrouter()
+	adminRouter.Use(common.AuthMiddleware, common.AdminMiddleware)
+
+	adminRouter.HandleFunc("/upload-plans", handlers.ListUploadPlans).Methods("GET")
+	adminRouter.HandleFunc("/users/{userID:[0-9]+}/upload-quota", handlers.GetUserUploadQuota).Methods("GET")
+	adminRouter.HandleFunc("/users/{userID:[0-9]+}/upload-quota", handlers.UpdateUserUploadQuota).Methods("PUT")
+	adminRouter.HandleFunc("/users/{userID:[0-9]+}/upload-quota", handlers.DeleteUserUploadQuota).Methods("DELETE")
+}
+
+// RegisterVideoRoutes は videohub の動画のサブルーター（/api/v1/videos）にルートを追加する
+// サムネイルは動画の編集として /api/v1/videos 以下で受け付ける
+func RegisterVideoRoutes(videoRouter *mux.Router) {
+	videoRouter.Handle("/{id:[0-9]+}/thumbnail", common.AuthMiddleware(common.ActiveUserMiddleware(http.HandlerFunc(handlers.UploadThumbnail)))).Methods("PUT")
 }
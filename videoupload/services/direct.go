@@ -0,0 +1,302 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/minio/minio-go/v7"
+	minioCredentials "github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ストレージにファイルが存在しない場合のエラー
+var ErrFileNotFound = errors.New("file not found in storage")
+
+// ストレージに保存されたファイルの情報
+type StoredFile struct {
+	Size        int64
+	ContentType string
+}
+
+// ブラウザから直接アップロードするための PUT の署名付きURLを発行するメソッド
+// S3 では Content-Type も署名に含めるため、アップロード時に同じ Content-Type を指定する必要がある
+func (s *StorageService) PresignVideoPut(objectName, contentType string, expires time.Duration) (string, error) {
+	if s.MinioClient != nil { // MinIOを使用する場合
+		client, err := minioPresignClient()
+		if err != nil {
+			return "", err
+		}
+		u, err := client.PresignedPutObject(context.Background(), s.Bucket, objectName, expires)
+		if err != nil {
+			return "", fmt.Errorf("MinIOの署名付きURLの生成に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		return u.String(), nil
+	} else if s.Client != nil { // S3を使用する場合
+		req, _ := s.Client.PutObjectRequest(&s3.PutObjectInput{
+			Bucket:      aws.String(s.Bucket),
+			Key:         aws.String(objectName),
+			ContentType: aws.String(contentType),
+		})
+		u, err := req.Presign(expires)
+		if err != nil {
+			return "", fmt.Errorf("S3の署名付きURLの生成に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		return u, nil
+	} else {
+		return "", fmt.Errorf("ストレージクライアントが初期化されていません")
+	}
+}
+
+// マルチパートアップロードの1パートを PUT する署名付きURLを発行するメソッド
+func (s *StorageService) PresignUploadPart(objectName, uploadID string, number int, expires time.Duration) (string, error) {
+	if s.MinioClient != nil { // MinIOを使用する場合
+		client, err := minioPresignClient()
+		if err != nil {
+			return "", err
+		}
+		params := url.Values{}
+		params.Set("partNumber", strconv.Itoa(number))
+		params.Set("uploadId", uploadID)
+		u, err := client.Presign(context.Background(), http.MethodPut, s.Bucket, objectName, expires, params)
+		if err != nil {
+			return "", fmt.Errorf("MinIOの署名付きURLの生成に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		return u.String(), nil
+	} else if s.Client != nil { // S3を使用する場合
+		req, _ := s.Client.UploadPartRequest(&s3.UploadPartInput{
+			Bucket:     aws.String(s.Bucket),
+			Key:        aws.String(objectName),
+			UploadId:   aws.String(uploadID),
+			PartNumber: aws.Int64(int64(number)),
+		})
+		u, err := req.Presign(expires)
+		if err != nil {
+			return "", fmt.Errorf("S3の署名付きURLの生成に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		return u, nil
+	} else {
+		return "", fmt.Errorf("ストレージクライアントが初期化されていません")
+	}
+}
+
+// マルチパートアップロードでアップロード済みのパートをパート番号順に取得するメソッド
+func (s *StorageService) ListUploadedParts(ctx context.Context, objectName, uploadID string) ([]UploadedPart, error) {
+	var parts []UploadedPart
+
+	if s.MinioClient != nil { // MinIOを使用する場合
+		core := minio.Core{Client: s.MinioClient}
+		marker := 0
+		for {
+			result, err := core.ListObjectParts(ctx, s.Bucket, objectName, uploadID, marker, 1000)
+			if err != nil {
+				return nil, fmt.Errorf("MinIOのパートの取得に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+			}
+			for _, p := range result.ObjectParts {
+				parts = append(parts, UploadedPart{Number: p.PartNumber, ETag: p.ETag, Size: p.Size})
+			}
+			if !result.IsTruncated {
+				return parts, nil
+			}
+			marker = result.NextPartNumberMarker
+		}
+	} else if s.Client != nil { // S3を使用する場合
+		err := s.Client.ListPartsPagesWithContext(ctx, &s3.ListPartsInput{
+			Bucket:   aws.String(s.Bucket),
+			Key:      aws.String(objectName),
+			UploadId: aws.String(uploadID),
+		}, func(page *s3.ListPartsOutput, lastPage bool) bool {
+			for _, p := range page.Parts {
+				parts = append(parts, UploadedPart{
+					Number: int(aws.Int64Value(p.PartNumber)),
+					ETag:   aws.StringValue(p.ETag),
+					Size:   aws.Int64Value(p.Size),
+				})
+			}
+			return true
+		})
+		if err != nil {
+			return nil, fmt.Errorf("S3のパートの取得に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		return parts, nil
+	} else {
+		return nil, fmt.Errorf("ストレージクライアントが初期化されていません")
+	}
+}
+
+// ストレージに保存されたファイルのサイズと Content-Type を取得するメソッド
+// ファイルが存在しない場合は ErrFileNotFound を返す
+func (s *StorageService) StatFile(ctx context.Context, objectName string) (*StoredFile, error) {
+	if s.MinioClient != nil { // MinIOを使用する場合
+		info, err := s.MinioClient.StatObject(ctx, s.Bucket, objectName, minio.StatObjectOptions{})
+		if err != nil {
+			if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+				return nil, ErrFileNotFound
+			}
+			return nil, fmt.Errorf("MinIOのファイル情報の取得に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		return &StoredFile{Size: info.Size, ContentType: info.ContentType}, nil
+	} else if s.Client != nil { // S3を使用する場合
+		output, err := s.Client.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(objectName),
+		})
+		if err != nil {
+			var reqErr awserr.RequestFailure
+			if errors.As(err, &reqErr) && reqErr.StatusCode() == http.StatusNotFound {
+				return nil, ErrFileNotFound
+			}
+			return nil, fmt.Errorf("S3のファイル情報の取得に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		return &StoredFile{Size: aws.Int64Value(output.ContentLength), ContentType: aws.StringValue(output.ContentType)}, nil
+	} else {
+		return nil, fmt.Errorf("ストレージクライアントが初期化されていません")
+	}
+}
+
+// ストレージに保存されたファイルの先頭 n バイトを読み込むメソッド
+func (s *StorageService) ReadFileHead(ctx context.Context, objectName string, n int64) ([]byte, error) {
+	return s.ReadFileRange(ctx, objectName, 0, n)
+}
+
+// ストレージに保存されたファイルの offset から n バイトを読み込むメソッド
+// ファイルの終端を超える場合は終端までを返す
+func (s *StorageService) ReadFileRange(ctx context.Context, objectName string, offset, n int64) ([]byte, error) {
+	var body io.ReadCloser
+	if s.MinioClient != nil { // MinIOを使用する場合
+		opts := minio.GetObjectOptions{}
+		if err := opts.SetRange(offset, offset+n-1); err != nil {
+			return nil, err
+		}
+		object, err := s.MinioClient.GetObject(ctx, s.Bucket, objectName, opts)
+		if err != nil {
+			return nil, fmt.Errorf("MinIOのファイルの取得に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		body = object
+	} else if s.Client != nil { // S3を使用する場合
+		output, err := s.Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(objectName),
+			Range:  aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+n-1)),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("S3のファイルの取得に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		body = output.Body
+	} else {
+		return nil, fmt.Errorf("ストレージクライアントが初期化されていません")
+	}
+	defer body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(body, n))
+	if err != nil {
+		return nil, fmt.Errorf("ファイルの読み込みに失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+	}
+	return data, nil
+}
+
+// ストレージに保存されたファイルをローカルのファイルに保存するメソッド
+func (s *StorageService) DownloadFile(ctx context.Context, objectName, path string) error {
+	var body io.ReadCloser
+	if s.MinioClient != nil { // MinIOを使用する場合
+		object, err := s.MinioClient.GetObject(ctx, s.Bucket, objectName, minio.GetObjectOptions{})
+		if err != nil {
+			return fmt.Errorf("MinIOのファイルの取得に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		body = object
+	} else if s.Client != nil { // S3を使用する場合
+		output, err := s.Client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(objectName),
+		})
+		if err != nil {
+			return fmt.Errorf("S3のファイルの取得に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		body = output.Body
+	} else {
+		return fmt.Errorf("ストレージクライアントが初期化されていません")
+	}
+	defer body.Close()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, body); err != nil {
+		file.Close()
+		return fmt.Errorf("ファイルのダウンロードに失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+	}
+	return file.Close()
+}
+
+// ストレージに保存されたファイルを範囲を指定して読み込む io.ReaderAt
+// 動画ファイル全体をダウンロードせずに解析するために使う
+type FileReaderAt struct {
+	ctx        context.Context
+	storage    *StorageService
+	objectName string
+}
+
+func (s *StorageService) NewFileReaderAt(ctx context.Context, objectName string) *FileReaderAt {
+	return &FileReaderAt{ctx: ctx, storage: s, objectName: objectName}
+}
+
+func (f *FileReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	data, err := f.storage.ReadFileRange(f.ctx, f.objectName, off, int64(len(p)))
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, data)
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// マルチパートアップロードが既に完了または中止されている場合は true を返す
+func IsNoSuchUpload(err error) bool {
+	var minioErr minio.ErrorResponse
+	if errors.As(err, &minioErr) {
+		return minioErr.Code == "NoSuchUpload"
+	}
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code() == s3.ErrCodeNoSuchUpload
+	}
+	return false
+}
+
+// ブラウザからアクセスできるエンドポイントで署名するための MinIO クライアントを作成する
+// 署名にはホスト名が含まれるため、コンテナ内のエンドポイント（STORAGE_ENDPOINT）ではなく STORAGE_PUBLIC_ENDPOINT を使う
+func minioPresignClient() (*minio.Client, error) {
+	endpoint := os.Getenv("STORAGE_PUBLIC_ENDPOINT")
+	if endpoint == "" {
+		endpoint = "localhost:9000"
+	}
+	region := os.Getenv("STORAGE_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	// リージョンを指定し、署名のためにバケットの場所を問い合わせないようにする
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:  minioCredentials.NewStaticV4(os.Getenv("STORAGE_ACCESS_KEY"), os.Getenv("STORAGE_SECRET_KEY"), ""),
+		Secure: os.Getenv("STORAGE_USE_SSL") == "true",
+		Region: region,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("MinIOクライアントの初期化に失敗しました: %w", err)
+	}
+	return client, nil
+}
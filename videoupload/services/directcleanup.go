@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"live/common"
+	"live/videoupload/models"
+	"sync"
+	"time"
+)
+
+const (
+	// 完了しない直接アップロードを削除する間隔
+	directUploadCleanupInterval = time.Hour
+	// 1回の処理で削除するアップロードの最大数
+	directUploadCleanupBatchSize = 100
+	// 有効期限の直前に始まったアップロードが終わるのを待つ時間
+	directUploadCleanupGrace = time.Hour
+)
+
+// DirectUploadCleanupJob は有効期限を過ぎても完了しない直接アップロードと、そのために作成した動画を削除する
+type DirectUploadCleanupJob struct {
+	mu      sync.Mutex
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	started bool
+}
+
+// アプリケーション全体で共有する削除ジョブ
+var DirectUploadCleanup = NewDirectUploadCleanupJob()
+
+func NewDirectUploadCleanupJob() *DirectUploadCleanupJob {
+	return &DirectUploadCleanupJob{
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// Start は起動直後と一定間隔ごとの削除を開始する
+func (j *DirectUploadCleanupJob) Start() {
+	j.mu.Lock()
+	if j.started {
+		j.mu.Unlock()
+		return
+	}
+	j.started = true
+	j.mu.Unlock()
+
+	go func() {
+		defer close(j.doneCh)
+		j.Run()
+
+		ticker := time.NewTicker(directUploadCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.Run()
+			case <-j.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop は定期的な削除を止める
+func (j *DirectUploadCleanupJob) Stop() {
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return
+	}
+	close(j.stopCh)
+	<-j.doneCh
+}
+
+// Run は有効期限を過ぎたアップロードをすべて削除する
+func (j *DirectUploadCleanupJob) Run() {
+	storageService, err := InitStorageService()
+	if err != nil {
+		common.LogVideoUploadError(err)
+		return
+	}
+
+	for {
+		uploads, err := models.GetExpiredDirectUploads(time.Now().Add(-directUploadCleanupGrace), directUploadCleanupBatchSize)
+		if err != nil {
+			common.LogVideoUploadError(fmt.Errorf("Failed to get expired direct uploads: %w", err))
+			return
+		}
+
+		for i := range uploads {
+			upload := &uploads[i]
+			if err := removeDirectUploadFile(storageService, upload); err != nil {
+				common.LogVideoUploadError(fmt.Errorf("Failed to remove direct upload file %d: %w", upload.VideoFileID, err))
+				return
+			}
+			if err := models.DeleteExpiredDirectUpload(upload); err != nil {
+				common.LogVideoUploadError(fmt.Errorf("Failed to delete direct upload %d: %w", upload.VideoFileID, err))
+				return
+			}
+		}
+
+		if len(uploads) < directUploadCleanupBatchSize {
+			return
+		}
+	}
+}
+
+// アップロード途中のパートとアップロード済みのファイルを削除する
+func removeDirectUploadFile(storageService *StorageService, upload *models.DirectUpload) error {
+	if upload.UploadID != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), uploadAbortTimeout)
+		defer cancel()
+		if err := storageService.AbortMultipartUpload(ctx, upload.FilePath, *upload.UploadID); err != nil && !IsNoSuchUpload(err) {
+			return err
+		}
+	}
+	return storageService.DeleteFile(upload.FilePath)
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"live/common"
+	"live/videoupload/models"
+	"sync"
+	"time"
+)
+
+const (
+	// 削除した動画のファイルを解放する間隔
+	videoFileCleanupInterval = time.Hour
+	// 1回の処理で解放する動画ファイルの最大数
+	videoFileCleanupBatchSize = 100
+	// レンディションのファイルの削除のタイムアウト
+	renditionDeleteTimeout = time.Minute
+)
+
+// VideoFileCleanupJob は削除した動画（投稿者の削除やモデレーターの削除）の動画ファイルを削除済みにし、
+// 元の動画ファイルと配信用の動画ファイルの参照を解放してレンディションのファイルを削除する
+type VideoFileCleanupJob struct {
+	mu      sync.Mutex
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	started bool
+}
+
+// アプリケーション全体で共有する削除ジョブ
+var VideoFileCleanup = NewVideoFileCleanupJob()
+
+func NewVideoFileCleanupJob() *VideoFileCleanupJob {
+	return &VideoFileCleanupJob{
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// Start は起動直後と一定間隔ごとの削除を開始する
+func (j *VideoFileCleanupJob) Start() {
+	j.mu.Lock()
+	if j.started {
+		j.mu.Unlock()
+		return
+	}
+	j.started = true
+	j.mu.Unlock()
+
+	go func() {
+		defer close(j.doneCh)
+		j.Run()
+
+		ticker := time.NewTicker(videoFileCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.Run()
+			case <-j.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop は定期的な削除を止める
+func (j *VideoFileCleanupJob) Stop() {
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return
+	}
+	close(j.stopCh)
+	<-j.doneCh
+}
+
+// Run は削除した動画の動画ファイルをすべて解放する
+func (j *VideoFileCleanupJob) Run() {
+	storageService, err := InitStorageService()
+	if err != nil {
+		common.LogVideoUploadError(err)
+		return
+	}
+
+	for {
+		files, err := models.GetFilesOfDeletedVideos(videoFileCleanupBatchSize)
+		if err != nil {
+			common.LogVideoUploadError(fmt.Errorf("Failed to get files of deleted videos: %w", err))
+			return
+		}
+
+		for i := range files {
+			// 参照を二重に解放しないよう、先に削除済みにしてから解放する
+			// 解放に失敗したオブジェクトは残るが、他の動画が参照しているオブジェクトを削除することはない
+			deleted, err := models.DeleteVideoFile(files[i].ID)
+			if err != nil {
+				common.LogVideoUploadError(fmt.Errorf("Failed to delete video file %d: %w", files[i].ID, err))
+				return
+			}
+			if deleted {
+				releaseDeletedVideoFile(storageService, &files[i])
+			}
+		}
+
+		if len(files) < videoFileCleanupBatchSize {
+			return
+		}
+	}
+}
+
+// 削除済みにした動画ファイルのオブジェクトを解放する（失敗した場合は記録して続ける）
+func releaseDeletedVideoFile(storageService *StorageService, file *models.VideoFile) {
+	if file.SourceFileID != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), renditionDeleteTimeout)
+		defer cancel()
+		if err := storageService.DeleteRenditionFiles(ctx, file.FilePath); err != nil {
+			common.LogVideoUploadError(err)
+		}
+		return
+	}
+
+	paths := []string{file.FilePath}
+	if file.SourcePath != nil {
+		paths = append(paths, *file.SourcePath)
+	}
+	for _, path := range paths {
+		if err := storageService.ReleaseVideoFile(path); err != nil {
+			common.LogVideoUploadError(err)
+		}
+	}
+}
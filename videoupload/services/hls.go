@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"live/common"
+	"live/videoupload/models"
+	"live/videoupload/transcoder"
+	"math"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 読み込む HLS のメディアプレイリストの最大サイズ
+const maxHLSPlaylistSize = 1 << 20
+
+// HLS の出力ファイルの Content-Type
+var hlsContentTypes = map[string]string{
+	".m3u8": models.RenditionFormat,
+	".mp4":  "video/mp4",
+	".m4s":  "video/iso.segment",
+}
+
+// HLSStep は動画をレンディションごとに HLS のセグメントに分割してアップロードし、
+// レンディションを動画ファイルの行として保存する（DASH のマニフェストも同じセグメントから作成する）
+type HLSStep struct {
+	Renditions []transcoder.Rendition
+}
+
+func (HLSStep) Name() string { return "hls" }
+
+func (s HLSStep) Run(ctx context.Context, task *ProcessingTask) error {
+	if task.Info == nil {
+		return fmt.Errorf("動画ファイルの解析結果がありません")
+	}
+
+	prefix := "streams/" + uuid.New().String() + "/"
+	renditions := []models.VideoFile{}
+	uploaded := []string{}
+	for _, r := range transcoder.SelectRenditions(s.Renditions, task.Info.Height) {
+		rendition, objects, err := s.segment(ctx, task, r, prefix+r.Name+"/")
+		uploaded = append(uploaded, objects...)
+		if err != nil {
+			task.Storage.deleteFiles(uploaded)
+			return err
+		}
+		renditions = append(renditions, *rendition)
+	}
+
+	old, err := models.ReplaceVideoFileRenditions(task.File.ID, renditions)
+	if err != nil {
+		task.Storage.deleteFiles(uploaded)
+		return err
+	}
+
+	// 置き換えたレンディションのファイルを削除する（失敗しても処理は成功として扱う）
+	for _, f := range old {
+		if err := task.Storage.DeleteRenditionFiles(ctx, f.FilePath); err != nil {
+			common.LogVideoUploadError(err)
+		}
+	}
+	return nil
+}
+
+// レンディションを1つ作成してアップロードし、保存する動画ファイルとアップロードしたファイルのパスを返す
+func (s HLSStep) segment(ctx context.Context, task *ProcessingTask, r transcoder.Rendition, prefix string) (*models.VideoFile, []string, error) {
+	dir := filepath.Join(task.WorkDir, "hls", r.Name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+	if err := task.Transcoder.SegmentHLS(ctx, task.Output, dir, r); err != nil {
+		return nil, nil, fmt.Errorf("%s の HLS の作成に失敗しました: %w", r.Name, err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, transcoder.HLSPlaylistName))
+	if err != nil {
+		return nil, nil, err
+	}
+	playlist, err := transcoder.ParseHLSPlaylist(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s の HLS のプレイリストの読み込みに失敗しました: %w", r.Name, err)
+	}
+
+	// 初期化セグメントとセグメントをアップロードしてから、最後にプレイリストをアップロードする
+	uploaded := []string{}
+	var size uint64
+	upload := func(name string) (int, error) {
+		contentType, ok := hlsContentTypes[path.Ext(name)]
+		if !ok {
+			return 0, fmt.Errorf("HLS の出力に不明なファイルがあります: %s", name)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.Base(name)))
+		if err != nil {
+			return 0, err
+		}
+		objectName := prefix + path.Base(name)
+		if err := task.Storage.putObject(ctx, objectName, contentType, data); err != nil {
+			return 0, err
+		}
+		uploaded = append(uploaded, objectName)
+		size += uint64(len(data))
+		return len(data), nil
+	}
+
+	if playlist.Map != "" {
+		if _, err := upload(playlist.Map); err != nil {
+			return nil, uploaded, err
+		}
+	}
+	// セグメントのビットレートの最大値を BANDWIDTH にする
+	var bandwidth float64
+	for _, segment := range playlist.Segments {
+		n, err := upload(segment.URI)
+		if err != nil {
+			return nil, uploaded, err
+		}
+		if segment.Duration > 0 {
+			bandwidth = math.Max(bandwidth, float64(n)*8/segment.Duration)
+		}
+	}
+	if _, err := upload(transcoder.HLSPlaylistName); err != nil {
+		return nil, uploaded, err
+	}
+
+	now := time.Now().Truncate(time.Second)
+	name, bps, height := r.Name, uint(math.Ceil(bandwidth)), r.Height
+	videoCodec, audioCodec := "h264", "aac"
+	file := &models.VideoFile{
+		VideoID:    task.File.VideoID,
+		Rendition:  &name,
+		Bandwidth:  &bps,
+		FilePath:   prefix + transcoder.HLSPlaylistName,
+		Duration:   task.File.Duration,
+		Height:     &height,
+		FrameRate:  task.File.FrameRate,
+		VideoCodec: &videoCodec,
+		FileSize:   size,
+		Format:     models.RenditionFormat,
+		Status:     models.VideoFileStatusCompleted,
+		Processed:  &now,
+		Uploaded:   &now,
+	}
+	if task.Info.Width > 0 && task.Info.Height > 0 {
+		// 縦横比を保った偶数の幅（ffmpeg の scale=-2:高さ と同じ）
+		width := uint(math.Round(float64(task.Info.Width)*float64(r.Height)/float64(task.Info.Height)/2)) * 2
+		file.Width = &width
+	}
+	if task.Info.AudioCodec != "" {
+		file.AudioCodec = &audioCodec
+	}
+	return file, uploaded, nil
+}
+
+// レンディションのファイル（プレイリストと、プレイリストに含まれる初期化セグメント・セグメント）を削除するメソッド
+func (s *StorageService) DeleteRenditionFiles(ctx context.Context, playlistPath string) error {
+	data, err := s.ReadFileHead(ctx, playlistPath, maxHLSPlaylistSize)
+	if err != nil {
+		return err
+	}
+	playlist, err := transcoder.ParseHLSPlaylist(data)
+	if err != nil {
+		return fmt.Errorf("HLS のプレイリストの読み込みに失敗しました: %w | Key: %s", err, playlistPath)
+	}
+
+	dir := path.Dir(playlistPath)
+	objects := []string{}
+	if playlist.Map != "" {
+		objects = append(objects, path.Join(dir, playlist.Map))
+	}
+	for _, segment := range playlist.Segments {
+		objects = append(objects, path.Join(dir, segment.URI))
+	}
+	s.deleteFiles(append(objects, playlistPath))
+	return nil
+}
+
+// ファイルを削除するメソッド（失敗した場合はログに記録して続ける）
+func (s *StorageService) deleteFiles(objectNames []string) {
+	for _, objectName := range objectNames {
+		if err := s.DeleteFile(objectName); err != nil {
+			common.LogVideoUploadError(err)
+		}
+	}
+}
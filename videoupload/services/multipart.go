@@ -0,0 +1,343 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	// アップロードできる動画ファイルの最大サイズ
+	MaxVideoFileSize int64 = 10 << 30
+	// マルチパートアップロードの1パートのサイズ（S3 の最後以外のパートは 5MiB 以上）
+	uploadPartSize = 16 << 20
+	// 同時にアップロードするパートの数（メモリ使用量は uploadPartSize × uploadConcurrency 程度になる）
+	uploadConcurrency = 4
+	// 1パートのアップロードに失敗した場合に再試行する回数
+	uploadPartRetries = 3
+	// 中断時にマルチパートアップロードを破棄するまでの待ち時間
+	uploadAbortTimeout = 30 * time.Second
+)
+
+// アップロードの途中で読み込みが失敗した場合のエラー（クライアントの切断やサイズ超過など）
+type UploadReadError struct {
+	Err error
+}
+
+func (e *UploadReadError) Error() string {
+	return fmt.Sprintf("アップロードするデータの読み込みに失敗しました: %v", e.Err)
+}
+
+func (e *UploadReadError) Unwrap() error {
+	return e.Err
+}
+
+// アップロードした動画ファイル
+type UploadedVideo struct {
+	Path   string
+	Size   int64
+	Format string // ファイルの内容から判別した MIME タイプ
+	SHA256 string // ファイルの内容の SHA-256（16進数）
+}
+
+// マルチパートアップロードで完了したパート
+type UploadedPart struct {
+	Number int
+	ETag   string
+	Size   int64
+}
+
+// リクエストの本文などを読み込みながら、動画ファイルをマルチパートアップロードするメソッド
+// 読み込んだパートを並列にアップロードし、ファイル全体をメモリやディスクに保持しない
+// 失敗した場合や ctx がキャンセルされた場合は、アップロード済みのパートを破棄する
+// アップロードする前に先頭のバイト列から形式を判別し、拡張子と一致しない場合は *FormatError を返す
+// 読み込みながら SHA-256 を計算し、内容の SHA-256 のキー（movies/sha256/ 以下）に保存する
+// 同じ内容のファイルが既に保存されている場合はそのオブジェクトを参照し、返したパスは ReleaseVideoFile で解放する
+func (s *StorageService) UploadVideoStream(ctx context.Context, body io.Reader, filename string) (*UploadedVideo, error) {
+	objectName, err := VideoObjectName(filename)
+	if err != nil {
+		return nil, &FormatError{Code: FormatErrorExtension, Message: err.Error()}
+	}
+	if s.MinioClient == nil && s.Client == nil {
+		return nil, fmt.Errorf("ストレージクライアントが初期化されていません")
+	}
+
+	// クライアントが指定した Content-Type ではなく、ファイルの内容から判別した形式で保存する
+	reader := bufio.NewReaderSize(body, SniffLength)
+	head, err := reader.Peek(SniffLength)
+	if err != nil && err != io.EOF {
+		return nil, &UploadReadError{Err: err}
+	}
+	contentType, err := ValidateVideoContent(filename, head)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	body = io.TeeReader(reader, hash)
+
+	// 同時にアップロードするパートの数だけバッファを用意し、使い回す
+	buffers := make(chan []byte, uploadConcurrency)
+	for i := 0; i < uploadConcurrency; i++ {
+		buffers <- make([]byte, uploadPartSize)
+	}
+
+	// 最初のパートに収まる場合はマルチパートにせずアップロードする
+	first := <-buffers
+	n, err := io.ReadFull(body, first)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		// ファイル全体を読み込んでいるため、SHA-256 のキーに直接保存する
+		uploaded := &UploadedVideo{Size: int64(n), Format: contentType, SHA256: hex.EncodeToString(hash.Sum(nil))}
+		return s.storeVideoObject(ctx, uploaded, filepath.Ext(objectName), func(objectName string) error {
+			return s.putObject(ctx, objectName, contentType, first[:n])
+		})
+	}
+	if err != nil {
+		return nil, &UploadReadError{Err: err}
+	}
+
+	uploadID, err := s.CreateMultipartUpload(ctx, objectName, contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		parts    []UploadedPart
+		firstErr error
+	)
+	fail := func(err error) {
+		mu.Lock()
+		if firstErr == nil {
+			firstErr = err
+		}
+		mu.Unlock()
+		cancel()
+	}
+	upload := func(number int, buf []byte, data []byte) {
+		defer wg.Done()
+		defer func() { buffers <- buf }()
+
+		etag, err := s.uploadPartWithRetry(ctx, objectName, uploadID, number, data)
+		if err != nil {
+			fail(err)
+			return
+		}
+		mu.Lock()
+		parts = append(parts, UploadedPart{Number: number, ETag: etag, Size: int64(len(data))})
+		mu.Unlock()
+	}
+
+	size := int64(n)
+	wg.Add(1)
+	go upload(1, first, first[:n])
+
+	for number := 2; ; number++ {
+		var buf []byte
+		select {
+		case buf = <-buffers:
+		case <-ctx.Done():
+		}
+		if buf == nil {
+			break
+		}
+
+		n, err := io.ReadFull(body, buf)
+		if n > 0 {
+			size += int64(n)
+			wg.Add(1)
+			go upload(number, buf, buf[:n])
+		} else {
+			buffers <- buf
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			fail(&UploadReadError{Err: err})
+			break
+		}
+	}
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		firstErr = ctx.Err()
+	}
+	if firstErr == nil {
+		sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+		firstErr = s.CompleteMultipartUpload(ctx, objectName, uploadID, parts)
+	}
+	if firstErr != nil {
+		// リクエストが切断されていても、アップロード済みのパートは破棄する
+		abortCtx, abortCancel := context.WithTimeout(context.Background(), uploadAbortTimeout)
+		defer abortCancel()
+		if err := s.AbortMultipartUpload(abortCtx, objectName, uploadID); err != nil {
+			firstErr = errors.Join(firstErr, err)
+		}
+		return nil, firstErr
+	}
+
+	// 読み込み終わるまで SHA-256 がわからないため、一時的なキーにアップロードしてから移す
+	uploaded := &UploadedVideo{Path: objectName, Size: size, Format: contentType, SHA256: hex.EncodeToString(hash.Sum(nil))}
+	return s.moveToContentObject(ctx, uploaded)
+}
+
+// パートをアップロードし、失敗した場合は待ち時間を延ばしながら再試行する
+func (s *StorageService) uploadPartWithRetry(ctx context.Context, objectName, uploadID string, number int, data []byte) (string, error) {
+	var err error
+	wait := 500 * time.Millisecond
+	for attempt := 0; attempt <= uploadPartRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(wait):
+				wait *= 2
+			case <-ctx.Done():
+				return "", ctx.Err()
+			}
+		}
+
+		var etag string
+		etag, err = s.uploadPart(ctx, objectName, uploadID, number, data)
+		if err == nil {
+			return etag, nil
+		}
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+	}
+	return "", fmt.Errorf("パート %d のアップロードに失敗しました: %w", number, err)
+}
+
+func (s *StorageService) putObject(ctx context.Context, objectName, contentType string, data []byte) error {
+	if s.MinioClient != nil { // MinIOを使用する場合
+		_, err := s.MinioClient.PutObject(ctx, s.Bucket, objectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
+			ContentType: contentType,
+		})
+		if err != nil {
+			return fmt.Errorf("MinIOへのファイルのアップロードに失敗しました: %w | Bucket: %s, Key: %s, Content-Type: %s",
+				err, s.Bucket, objectName, contentType)
+		}
+		return nil
+	}
+
+	_, err := s.Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(objectName),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("S3へのファイルのアップロードに失敗しました: %w | Bucket: %s, Key: %s, Content-Type: %s",
+			err, s.Bucket, objectName, contentType)
+	}
+	return nil
+}
+
+func (s *StorageService) CreateMultipartUpload(ctx context.Context, objectName, contentType string) (string, error) {
+	if s.MinioClient != nil { // MinIOを使用する場合
+		uploadID, err := minio.Core{Client: s.MinioClient}.NewMultipartUpload(ctx, s.Bucket, objectName, minio.PutObjectOptions{
+			ContentType: contentType,
+		})
+		if err != nil {
+			return "", fmt.Errorf("MinIOのマルチパートアップロードの開始に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		return uploadID, nil
+	}
+
+	output, err := s.Client.CreateMultipartUploadWithContext(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(objectName),
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("S3のマルチパートアップロードの開始に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+	}
+	return aws.StringValue(output.UploadId), nil
+}
+
+func (s *StorageService) uploadPart(ctx context.Context, objectName, uploadID string, number int, data []byte) (string, error) {
+	if s.MinioClient != nil { // MinIOを使用する場合
+		part, err := minio.Core{Client: s.MinioClient}.PutObjectPart(ctx, s.Bucket, objectName, uploadID, number,
+			bytes.NewReader(data), int64(len(data)), minio.PutObjectPartOptions{})
+		if err != nil {
+			return "", err
+		}
+		return part.ETag, nil
+	}
+
+	output, err := s.Client.UploadPartWithContext(ctx, &s3.UploadPartInput{
+		Bucket:     aws.String(s.Bucket),
+		Key:        aws.String(objectName),
+		UploadId:   aws.String(uploadID),
+		PartNumber: aws.Int64(int64(number)),
+		Body:       bytes.NewReader(data),
+	})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(output.ETag), nil
+}
+
+func (s *StorageService) CompleteMultipartUpload(ctx context.Context, objectName, uploadID string, parts []UploadedPart) error {
+	if s.MinioClient != nil { // MinIOを使用する場合
+		completed := make([]minio.CompletePart, 0, len(parts))
+		for _, p := range parts {
+			completed = append(completed, minio.CompletePart{PartNumber: p.Number, ETag: p.ETag})
+		}
+		_, err := minio.Core{Client: s.MinioClient}.CompleteMultipartUpload(ctx, s.Bucket, objectName, uploadID, completed, minio.PutObjectOptions{})
+		if err != nil {
+			return fmt.Errorf("MinIOのマルチパートアップロードの完了に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		return nil
+	}
+
+	completed := make([]*s3.CompletedPart, 0, len(parts))
+	for _, p := range parts {
+		completed = append(completed, &s3.CompletedPart{PartNumber: aws.Int64(int64(p.Number)), ETag: aws.String(p.ETag)})
+	}
+	_, err := s.Client.CompleteMultipartUploadWithContext(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket:          aws.String(s.Bucket),
+		Key:             aws.String(objectName),
+		UploadId:        aws.String(uploadID),
+		MultipartUpload: &s3.CompletedMultipartUpload{Parts: completed},
+	})
+	if err != nil {
+		return fmt.Errorf("S3のマルチパートアップロードの完了に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+	}
+	return nil
+}
+
+func (s *StorageService) AbortMultipartUpload(ctx context.Context, objectName, uploadID string) error {
+	if s.MinioClient != nil { // MinIOを使用する場合
+		if err := (minio.Core{Client: s.MinioClient}).AbortMultipartUpload(ctx, s.Bucket, objectName, uploadID); err != nil {
+			return fmt.Errorf("MinIOのマルチパートアップロードの中止に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+		}
+		return nil
+	}
+
+	_, err := s.Client.AbortMultipartUploadWithContext(ctx, &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(s.Bucket),
+		Key:      aws.String(objectName),
+		UploadId: aws.String(uploadID),
+	})
+	if err != nil {
+		return fmt.Errorf("S3のマルチパートアップロードの中止に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
+	}
+	return nil
+}
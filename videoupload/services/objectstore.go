@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"live/common"
+	"live/videoupload/models"
+	"net/url"
+	"path/filepath"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	// 内容の SHA-256 で保存する動画ファイルのオブジェクトキーの接頭辞
+	contentObjectPrefix = "movies/sha256/"
+	// 1回のリクエストでコピーできるオブジェクトの最大サイズ（超える場合はパートに分けてコピーする）
+	maxCopyObjectSize = 5 << 30
+	// パートに分けてコピーする場合の1パートのサイズ
+	copyPartSize = 512 << 20
+)
+
+// 内容の SHA-256 と拡張子から動画ファイルのオブジェクトキーを決める
+func ContentObjectName(sha256, ext string) string {
+	return contentObjectPrefix + sha256 + ext
+}
+
+// アップロードした動画ファイルを内容の SHA-256 で保存し、参照を1つ増やすメソッド
+// 同じ内容のオブジェクトが既にあればそれを再利用し、なければ store で内容のキーに保存する
+// 返した UploadedVideo.Path の参照は、不要になったら ReleaseVideoFile で解放する
+func (s *StorageService) storeVideoObject(ctx context.Context, uploaded *UploadedVideo, ext string, store func(objectName string) error) (*UploadedVideo, error) {
+	stored, err := models.ReuseStoredObject(uploaded.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	if stored != nil {
+		common.LogVideoUploadInfo(fmt.Sprintf("Reusing stored object %s (references: %d)", stored.ObjectKey, stored.RefCount))
+		return &UploadedVideo{Path: stored.ObjectKey, Size: uploaded.Size, Format: stored.Format, SHA256: stored.SHA256}, nil
+	}
+
+	// 保存には時間がかかるため、ロックを保持しないまま保存してから記録する
+	object := &models.StoredObject{
+		SHA256:    uploaded.SHA256,
+		ObjectKey: ContentObjectName(uploaded.SHA256, ext),
+		FileSize:  uint64(uploaded.Size),
+		Format:    uploaded.Format,
+	}
+	if err := store(object.ObjectKey); err != nil {
+		return nil, err
+	}
+	stored, err = models.AddStoredObject(object)
+	if err != nil {
+		return nil, err
+	}
+	// 保存している間に同じ内容が別の拡張子のキーで記録された場合は、そちらを参照して保存したオブジェクトを削除する
+	if stored.ObjectKey != object.ObjectKey {
+		if err := s.DeleteFile(object.ObjectKey); err != nil {
+			common.LogVideoUploadError(err)
+		}
+	}
+	if stored.RefCount > 1 {
+		common.LogVideoUploadInfo(fmt.Sprintf("Reusing stored object %s (references: %d)", stored.ObjectKey, stored.RefCount))
+	} else if err := s.restoreIfRemoved(ctx, stored.ObjectKey, store); err != nil {
+		if err := s.ReleaseVideoFile(stored.ObjectKey); err != nil {
+			common.LogVideoUploadError(err)
+		}
+		return nil, err
+	}
+	return &UploadedVideo{Path: stored.ObjectKey, Size: uploaded.Size, Format: stored.Format, SHA256: stored.SHA256}, nil
+}
+
+// 保存してから記録するまでの間に、同じ内容の最後の参照が解放されてオブジェクトが削除された場合は保存し直す
+// 記録した後は参照があるため、それ以降に削除されることはない
+func (s *StorageService) restoreIfRemoved(ctx context.Context, objectName string, store func(objectName string) error) error {
+	_, err := s.StatFile(ctx, objectName)
+	if errors.Is(err, ErrFileNotFound) {
+		return store(objectName)
+	}
+	return err
+}
+
+// 動画ファイルの参照を1つ解放するメソッド
+// 内容の SHA-256 で保存したオブジェクトは最後の参照を解放した時点で削除し、それ以外のオブジェクト
+// （ブラウザから直接アップロードしたファイルや、重複の排除を導入する前のファイル）はそのまま削除する
+func (s *StorageService) ReleaseVideoFile(objectName string) error {
+	found, err := models.ReleaseStoredObject(objectName, func() error {
+		return s.DeleteFile(objectName)
+	})
+	if err != nil {
+		return err
+	}
+	if !found {
+		return s.DeleteFile(objectName)
+	}
+	return nil
+}
+
+// ストレージ内でファイルをコピーするメソッド
+// Content-Type は contentType に置き換え、size が大きい場合はパートに分けてコピーする
+func (s *StorageService) CopyFile(ctx context.Context, src, dst, contentType string, size int64) error {
+	if s.MinioClient != nil { // MinIOを使用する場合
+		dstOpts := minio.CopyDestOptions{
+			Bucket:          s.Bucket,
+			Object:          dst,
+			UserMetadata:    map[string]string{"Content-Type": contentType},
+			ReplaceMetadata: true,
+		}
+		srcOpts := minio.CopySrcOptions{Bucket: s.Bucket, Object: src}
+		var err error
+		if size <= maxCopyObjectSize {
+			_, err = s.MinioClient.CopyObject(ctx, dstOpts, srcOpts)
+		} else {
+			_, err = s.MinioClient.ComposeObject(ctx, dstOpts, srcOpts)
+		}
+		if err != nil {
+			return fmt.Errorf("MinIOのファイルのコピーに失敗しました: %w | Bucket: %s, Source: %s, Key: %s", err, s.Bucket, src, dst)
+		}
+		return nil
+	} else if s.Client != nil { // S3を使用する場合
+		copySource := url.PathEscape(s.Bucket) + "/" + (&url.URL{Path: src}).EscapedPath()
+		if size > maxCopyObjectSize {
+			return s.copyFileParts(ctx, copySource, dst, contentType, size)
+		}
+		_, err := s.Client.CopyObjectWithContext(ctx, &s3.CopyObjectInput{
+			Bucket:            aws.String(s.Bucket),
+			Key:               aws.String(dst),
+			CopySource:        aws.String(copySource),
+			ContentType:       aws.String(contentType),
+			MetadataDirective: aws.String(s3.MetadataDirectiveReplace),
+		})
+		if err != nil {
+			return fmt.Errorf("S3のファイルのコピーに失敗しました: %w | Bucket: %s, Source: %s, Key: %s", err, s.Bucket, src, dst)
+		}
+		return nil
+	} else {
+		return fmt.Errorf("ストレージクライアントが初期化されていません")
+	}
+}
+
+// S3 でファイルをパートに分けてコピーする（失敗した場合はコピー済みのパートを破棄する）
+func (s *StorageService) copyFileParts(ctx context.Context, copySource, dst, contentType string, size int64) error {
+	uploadID, err := s.CreateMultipartUpload(ctx, dst, contentType)
+	if err != nil {
+		return err
+	}
+
+	parts := []UploadedPart{}
+	for offset, number := int64(0), 1; offset < size; offset, number = offset+copyPartSize, number+1 {
+		end := min(offset+copyPartSize, size) - 1
+		var output *s3.UploadPartCopyOutput
+		output, err = s.Client.UploadPartCopyWithContext(ctx, &s3.UploadPartCopyInput{
+			Bucket:          aws.String(s.Bucket),
+			Key:             aws.String(dst),
+			UploadId:        aws.String(uploadID),
+			PartNumber:      aws.Int64(int64(number)),
+			CopySource:      aws.String(copySource),
+			CopySourceRange: aws.String(fmt.Sprintf("bytes=%d-%d", offset, end)),
+		})
+		if err != nil {
+			err = fmt.Errorf("S3のファイルのコピーに失敗しました: %w | Bucket: %s, Source: %s, Key: %s", err, s.Bucket, copySource, dst)
+			break
+		}
+		parts = append(parts, UploadedPart{Number: number, ETag: aws.StringValue(output.CopyPartResult.ETag), Size: end - offset + 1})
+	}
+	if err == nil {
+		err = s.CompleteMultipartUpload(ctx, dst, uploadID, parts)
+	}
+	if err != nil {
+		abortCtx, cancel := context.WithTimeout(context.Background(), uploadAbortTimeout)
+		defer cancel()
+		if err := s.AbortMultipartUpload(abortCtx, dst, uploadID); err != nil {
+			common.LogVideoUploadError(err)
+		}
+		return err
+	}
+	return nil
+}
+
+// 一時的なキーに保存した動画ファイルを内容の SHA-256 のキーに移し、一時的なファイルを削除する
+func (s *StorageService) moveToContentObject(ctx context.Context, uploaded *UploadedVideo) (*UploadedVideo, error) {
+	stored, err := s.storeVideoObject(ctx, uploaded, filepath.Ext(uploaded.Path), func(objectName string) error {
+		return s.CopyFile(ctx, uploaded.Path, objectName, uploaded.Format, uploaded.Size)
+	})
+	// 再利用した場合もコピーした場合も、一時的なファイルは不要になる
+	if err := s.DeleteFile(uploaded.Path); err != nil {
+		common.LogVideoUploadError(err)
+	}
+	return stored, err
+}
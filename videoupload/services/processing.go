@@ -0,0 +1,325 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"live/common"
+	notificationServices "live/notification/services"
+	"live/videoupload/models"
+	"live/videoupload/probe"
+	"live/videoupload/transcoder"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// 処理を待っている動画ファイルを確認する間隔（他のサーバーでアップロードされたファイルも拾えるようにする）
+	processingPollInterval = 30 * time.Second
+	// 処理を試みる最大の回数
+	processingMaxAttempts = 3
+	// 失敗した処理を再試行するまでの待ち時間（試行するたびに2倍にする）
+	processingRetryDelay = time.Minute
+	// 処理中のまま経過した場合に、処理中にサーバーが停止したとみなす時間
+	processingStaleTimeout = 6 * time.Hour
+	// 保存するエラー内容の最大の長さ（文字数）
+	maxProcessingErrorLength = 2000
+)
+
+// 処理の開始前に元の動画ファイルをダウンロードする段階の名前
+const processingStepDownload = "download"
+
+// ProcessingStep は動画ファイルの処理の段階
+type ProcessingStep interface {
+	// Name は処理の状態に記録する段階の名前
+	Name() string
+	// Run は処理を行う（失敗した場合は動画ファイルの処理を再試行する）
+	Run(ctx context.Context, task *ProcessingTask) error
+}
+
+// ProcessingTask は処理中の動画ファイルと、段階の間で受け渡す情報
+type ProcessingTask struct {
+	File       *models.VideoFile
+	Video      *models.Video
+	Storage    *StorageService
+	Transcoder transcoder.Transcoder
+	WorkDir    string      // 作業用のディレクトリ（処理の終了後に削除する）
+	Source     string      // ダウンロードした元の動画ファイル
+	Output     string      // 配信用に書き出す動画ファイル（変換しない場合は Source）
+	Info       *probe.Info // 解析結果（probe の段階で設定する）
+}
+
+// ProcessingQueue は処理する動画ファイルの取得と処理の状態の記録、元の動画ファイルの取得と結果の通知を行う
+// 既定ではデータベースとストレージを使う
+type ProcessingQueue interface {
+	// ResetStale は startedBefore より前から処理中のままの動画ファイルを処理待ちに戻し、戻した数を返す
+	ResetStale(startedBefore time.Time) (int64, error)
+	// ClaimNext は処理を待っている動画ファイルを1つ処理中にして返す（ない場合は nil）
+	ClaimNext(now time.Time) (*models.VideoFile, error)
+	// SetStep は実行中の段階を記録する
+	SetStep(fileID uint, step string) error
+	// Download は動画とストレージを task に設定し、元の動画ファイルを task.Source にダウンロードする
+	Download(ctx context.Context, task *ProcessingTask) error
+	// Complete は処理の完了を記録する
+	Complete(fileID uint, now time.Time) error
+	// Fail は処理の失敗を記録する（retryAt が nil の場合は再試行しない）
+	Fail(fileID uint, step, message string, retryAt *time.Time) error
+	// Release は中断した処理を試行回数に数えずに処理待ちに戻す
+	Release(fileID uint) error
+	// Notify は処理の結果を投稿者に通知する
+	Notify(videoID uint, succeeded bool)
+}
+
+// ProcessingJob はアップロードされた動画ファイルを順に処理し、video_files.status を更新する
+type ProcessingJob struct {
+	// 処理に使う Transcoder（nil の場合は Start で VIDEO_TRANSCODER 環境変数に応じて作成する）
+	Transcoder transcoder.Transcoder
+	// 順に実行する処理の段階
+	Steps []ProcessingStep
+	// 処理する動画ファイルの取得と状態の記録に使う ProcessingQueue
+	Queue ProcessingQueue
+
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wakeCh  chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	started bool
+}
+
+// アプリケーション全体で共有する動画ファイルの処理ジョブ
+var Processor = NewProcessingJob(nil, DefaultProcessingSteps()...)
+
+func NewProcessingJob(t transcoder.Transcoder, steps ...ProcessingStep) *ProcessingJob {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ProcessingJob{
+		Transcoder: t,
+		Steps:      steps,
+		Queue:      databaseProcessingQueue{},
+		ctx:        ctx,
+		cancel:     cancel,
+		wakeCh:     make(chan struct{}, 1),
+		stopCh:     make(chan struct{}),
+		doneCh:     make(chan struct{}),
+	}
+}
+
+// Start は動画ファイルの処理を開始する
+// Transcoder を作成できない場合（ffmpeg がない場合など）は開始せず、動画ファイルは処理待ちのままになる
+func (j *ProcessingJob) Start() {
+	j.mu.Lock()
+	if j.started {
+		j.mu.Unlock()
+		return
+	}
+	if j.Transcoder == nil {
+		t, err := transcoder.New()
+		if err != nil {
+			j.mu.Unlock()
+			common.LogVideoUploadError(fmt.Errorf("Video processing is disabled: %w", err))
+			return
+		}
+		j.Transcoder = t
+	}
+	j.started = true
+	j.mu.Unlock()
+
+	go func() {
+		defer close(j.doneCh)
+
+		ticker := time.NewTicker(processingPollInterval)
+		defer ticker.Stop()
+
+		for {
+			j.Run()
+			select {
+			case <-ticker.C:
+			case <-j.wakeCh:
+			case <-j.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop は処理を止める（処理中の動画ファイルは中断し、試行回数に数えずに処理待ちに戻す）
+func (j *ProcessingJob) Stop() {
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return
+	}
+	j.cancel()
+	close(j.stopCh)
+	<-j.doneCh
+}
+
+// Wake は新しい動画ファイルがアップロードされたことを知らせ、すぐに処理を始めさせる
+func (j *ProcessingJob) Wake() {
+	select {
+	case j.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+// Run は処理を待っている動画ファイルがなくなるまで順に処理する
+func (j *ProcessingJob) Run() {
+	if n, err := j.Queue.ResetStale(time.Now().Add(-processingStaleTimeout)); err != nil {
+		common.LogVideoUploadError(fmt.Errorf("Failed to reset stale video processing: %w", err))
+	} else if n > 0 {
+		common.LogVideoUploadInfo(fmt.Sprintf("Stale video processing reset: %d", n))
+	}
+
+	for j.ctx.Err() == nil {
+		file, err := j.Queue.ClaimNext(time.Now().Truncate(time.Second))
+		if err != nil {
+			common.LogVideoUploadError(fmt.Errorf("Failed to get video file to process: %w", err))
+			return
+		}
+		if file == nil {
+			return
+		}
+		j.process(file)
+	}
+}
+
+// 動画ファイルを処理し、結果を記録して投稿者に通知する
+func (j *ProcessingJob) process(file *models.VideoFile) {
+	step, err := j.runSteps(file)
+	if err == nil {
+		if err := j.Queue.Complete(file.ID, time.Now().Truncate(time.Second)); err != nil {
+			common.LogVideoUploadError(fmt.Errorf("Failed to complete video file processing %d: %w", file.ID, err))
+			return
+		}
+		common.LogVideoUploadInfo(fmt.Sprintf("Video file processed: %d", file.ID))
+		j.Queue.Notify(file.VideoID, true)
+		return
+	}
+
+	// サーバーの停止による中断は失敗として扱わない
+	if j.ctx.Err() != nil {
+		if err := j.Queue.Release(file.ID); err != nil {
+			common.LogVideoUploadError(fmt.Errorf("Failed to release video file processing %d: %w", file.ID, err))
+		}
+		return
+	}
+
+	common.LogVideoUploadError(fmt.Errorf("Video file processing failed at %s: %d: %w", step, file.ID, err))
+	var retryAt *time.Time
+	if file.ProcessingAttempts < processingMaxAttempts {
+		t := time.Now().Add(processingRetryDelay << (file.ProcessingAttempts - 1)).Truncate(time.Second)
+		retryAt = &t
+	}
+	if err := j.Queue.Fail(file.ID, step, truncateProcessingError(err.Error()), retryAt); err != nil {
+		common.LogVideoUploadError(fmt.Errorf("Failed to record video file processing failure %d: %w", file.ID, err))
+		return
+	}
+	if retryAt == nil {
+		j.Queue.Notify(file.VideoID, false)
+	}
+}
+
+// 元の動画ファイルをダウンロードし、処理の段階を順に実行する
+// 失敗した場合は失敗した段階の名前とエラーを返す
+func (j *ProcessingJob) runSteps(file *models.VideoFile) (string, error) {
+	workDir, err := os.MkdirTemp(os.Getenv("VIDEO_PROCESSING_DIR"), "video-processing-*")
+	if err != nil {
+		return processingStepDownload, err
+	}
+	defer os.RemoveAll(workDir)
+
+	task := &ProcessingTask{
+		File:       file,
+		Transcoder: j.Transcoder,
+		WorkDir:    workDir,
+		Source:     filepath.Join(workDir, "source"+filepath.Ext(file.FilePath)),
+	}
+	if err := j.runStep(file.ID, processingStepDownload, func() error {
+		return j.Queue.Download(j.ctx, task)
+	}); err != nil {
+		return processingStepDownload, err
+	}
+	task.Output = task.Source
+
+	for _, step := range j.Steps {
+		if err := j.runStep(file.ID, step.Name(), func() error {
+			return step.Run(j.ctx, task)
+		}); err != nil {
+			return step.Name(), err
+		}
+	}
+	return "", nil
+}
+
+// 実行中の段階を記録してから処理を行う
+func (j *ProcessingJob) runStep(fileID uint, name string, run func() error) error {
+	if err := j.Queue.SetStep(fileID, name); err != nil {
+		return err
+	}
+	if err := run(); err != nil {
+		return err
+	}
+	return j.ctx.Err()
+}
+
+// データベースに処理の状態を記録し、ストレージから元の動画ファイルを取得する ProcessingQueue
+type databaseProcessingQueue struct{}
+
+func (databaseProcessingQueue) ResetStale(startedBefore time.Time) (int64, error) {
+	return models.ResetStaleVideoFileProcessing(startedBefore)
+}
+
+func (databaseProcessingQueue) ClaimNext(now time.Time) (*models.VideoFile, error) {
+	return models.ClaimNextVideoFile(now)
+}
+
+func (databaseProcessingQueue) SetStep(fileID uint, step string) error {
+	return models.SetVideoFileProcessingStep(fileID, step)
+}
+
+func (databaseProcessingQueue) Download(ctx context.Context, task *ProcessingTask) error {
+	video, err := models.GetVideoByID(task.File.VideoID)
+	if err != nil {
+		return err
+	}
+	storageService, err := InitStorageService()
+	if err != nil {
+		return err
+	}
+	task.Video = video
+	task.Storage = storageService
+	return storageService.DownloadFile(ctx, task.File.FilePath, task.Source)
+}
+
+func (databaseProcessingQueue) Complete(fileID uint, now time.Time) error {
+	return models.CompleteVideoFileProcessing(fileID, now)
+}
+
+func (databaseProcessingQueue) Fail(fileID uint, step, message string, retryAt *time.Time) error {
+	return models.FailVideoFileProcessing(fileID, step, message, retryAt)
+}
+
+func (databaseProcessingQueue) Release(fileID uint) error {
+	return models.ReleaseVideoFileProcessing(fileID)
+}
+
+func (databaseProcessingQueue) Notify(videoID uint, succeeded bool) {
+	video, err := models.GetVideoByID(videoID)
+	if err != nil {
+		common.LogVideoUploadError(fmt.Errorf("Failed to get processed video %d: %w", videoID, err))
+		return
+	}
+	notificationServices.NotifyVideoProcessed(video.UserID, video.ID, video.Title, succeeded)
+}
+
+// エラー内容を保存できる長さに切り詰める
+func truncateProcessingError(message string) string {
+	if utf8.RuneCountInString(message) <= maxProcessingErrorLength {
+		return message
+	}
+	return string([]rune(message)[:maxProcessingErrorLength]) + "…"
+}
@@ -0,0 +1,353 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"live/videoupload/models"
+	"live/videoupload/transcoder"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// メモリ上で処理の状態を記録する ProcessingQueue
+type memoryProcessingQueue struct {
+	files       map[uint]*models.VideoFile
+	source      []byte
+	downloadErr error
+	steps       []string // 記録された段階
+	notified    []bool   // 通知した処理の結果
+}
+
+func (q *memoryProcessingQueue) ResetStale(startedBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (q *memoryProcessingQueue) ClaimNext(now time.Time) (*models.VideoFile, error) {
+	ids := make([]int, 0, len(q.files))
+	for id := range q.files {
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		file := q.files[uint(id)]
+		if file.Status != models.VideoFileStatusPending || (file.NextAttempt != nil && file.NextAttempt.After(now)) {
+			continue
+		}
+		file.Status = models.VideoFileStatusProcessing
+		file.ProcessingStep = nil
+		file.ProcessingAttempts++
+		file.ProcessingStarted = &now
+		file.NextAttempt = nil
+		claimed := *file
+		return &claimed, nil
+	}
+	return nil, nil
+}
+
+func (q *memoryProcessingQueue) SetStep(fileID uint, step string) error {
+	q.files[fileID].ProcessingStep = &step
+	q.steps = append(q.steps, step)
+	return nil
+}
+
+func (q *memoryProcessingQueue) Download(ctx context.Context, task *ProcessingTask) error {
+	if q.downloadErr != nil {
+		return q.downloadErr
+	}
+	task.Video = &models.Video{ID: task.File.VideoID}
+	return os.WriteFile(task.Source, q.source, 0o600)
+}
+
+func (q *memoryProcessingQueue) Complete(fileID uint, now time.Time) error {
+	file := q.files[fileID]
+	file.Status = models.VideoFileStatusCompleted
+	file.ProcessingStep = nil
+	file.ProcessingError = nil
+	file.NextAttempt = nil
+	file.Processed = &now
+	return nil
+}
+
+func (q *memoryProcessingQueue) Fail(fileID uint, step, message string, retryAt *time.Time) error {
+	file := q.files[fileID]
+	file.Status = models.VideoFileStatusFailed
+	if retryAt != nil {
+		file.Status = models.VideoFileStatusPending
+	}
+	file.ProcessingStep = &step
+	file.ProcessingError = &message
+	file.NextAttempt = retryAt
+	return nil
+}
+
+func (q *memoryProcessingQueue) Release(fileID uint) error {
+	file := q.files[fileID]
+	if file.Status == models.VideoFileStatusProcessing {
+		file.Status = models.VideoFileStatusPending
+		file.ProcessingStep = nil
+		if file.ProcessingAttempts > 0 {
+			file.ProcessingAttempts--
+		}
+	}
+	return nil
+}
+
+func (q *memoryProcessingQueue) Notify(videoID uint, succeeded bool) {
+	q.notified = append(q.notified, succeeded)
+}
+
+// 関数で処理を行う段階
+type funcStep struct {
+	name string
+	run  func(ctx context.Context, task *ProcessingTask) error
+}
+
+func (s funcStep) Name() string { return s.name }
+
+func (s funcStep) Run(ctx context.Context, task *ProcessingTask) error { return s.run(ctx, task) }
+
+// Transcoder で解析する段階（ProbeStep と異なり解析結果をデータベースに保存しない）
+var testProbeStep = funcStep{name: "probe", run: func(ctx context.Context, task *ProcessingTask) error {
+	info, err := task.Transcoder.Probe(ctx, task.Source)
+	task.Info = info
+	return err
+}}
+
+// Transcoder で配信用に書き出す段階（PackageStep と異なりストレージに保存しない）
+var testPackageStep = funcStep{name: "package", run: func(ctx context.Context, task *ProcessingTask) error {
+	return task.Transcoder.Package(ctx, task.Output, filepath.Join(task.WorkDir, "packaged.mp4"))
+}}
+
+// 解析できる最小限の H.264 の MP4（10秒、640x360）
+func testMP4() []byte {
+	box := func(boxType string, parts ...[]byte) []byte {
+		body := bytes.Join(parts, nil)
+		return append(append(binary.BigEndian.AppendUint32(nil, uint32(8+len(body))), boxType...), body...)
+	}
+	u16 := func(v uint16) []byte { return binary.BigEndian.AppendUint16(nil, v) }
+	u32 := func(v uint32) []byte { return binary.BigEndian.AppendUint32(nil, v) }
+	zeros := func(n int) []byte { return make([]byte, n) }
+
+	mvhd := box("mvhd", zeros(4), zeros(8), u32(1000), u32(10000), zeros(80))
+	tkhd := box("tkhd", zeros(4), zeros(72), u32(640<<16), u32(360<<16))
+	mdhd := box("mdhd", zeros(4), zeros(8), u32(30000), u32(300000), zeros(4))
+	hdlr := box("hdlr", zeros(4), zeros(4), []byte("vide"), zeros(12), []byte("v\x00"))
+	avc1 := box("avc1", zeros(6), u16(1), zeros(16), u16(640), u16(360), zeros(50))
+	trak := box("trak", tkhd, box("mdia", mdhd, hdlr, box("minf", box("stbl", box("stsd", zeros(4), u32(1), avc1)))))
+	ftyp := box("ftyp", []byte("isom"), zeros(4), []byte("isomavc1"))
+	return bytes.Join([][]byte{ftyp, box("moov", mvhd, trak), box("mdat", zeros(100))}, nil)
+}
+
+func TestProcessingJobTransitions(t *testing.T) {
+	errFake := errors.New("fake failure")
+
+	tests := []struct {
+		name         string
+		attempts     uint             // 処理を始める前の試行回数
+		errors       map[string]error // Transcoder の操作ごとのエラー
+		downloadErr  error
+		wantStatus   string
+		wantStep     string        // 記録される段階（空文字の場合は nil）
+		wantAttempts uint          // 処理後の試行回数
+		wantRetry    time.Duration // 再試行までの待ち時間（0 の場合は再試行しない）
+		wantNotified []bool
+		wantCalls    []string // Transcoder の呼び出し
+		wantSteps    []string // 実行した段階
+	}{
+		{
+			name:         "completed",
+			wantStatus:   models.VideoFileStatusCompleted,
+			wantAttempts: 1,
+			wantNotified: []bool{true},
+			wantCalls:    []string{"probe", "transcode", "package"},
+			wantSteps:    []string{"download", "probe", "transcode", "package"},
+		},
+		{
+			name:         "first failure is retried",
+			errors:       map[string]error{"transcode": errFake},
+			wantStatus:   models.VideoFileStatusPending,
+			wantStep:     "transcode",
+			wantAttempts: 1,
+			wantRetry:    processingRetryDelay,
+			wantCalls:    []string{"probe", "transcode"},
+			wantSteps:    []string{"download", "probe", "transcode"},
+		},
+		{
+			name:         "retry delay doubles",
+			attempts:     1,
+			errors:       map[string]error{"package": errFake},
+			wantStatus:   models.VideoFileStatusPending,
+			wantStep:     "package",
+			wantAttempts: 2,
+			wantRetry:    2 * processingRetryDelay,
+			wantCalls:    []string{"probe", "transcode", "package"},
+			wantSteps:    []string{"download", "probe", "transcode", "package"},
+		},
+		{
+			name:         "last attempt fails",
+			attempts:     processingMaxAttempts - 1,
+			errors:       map[string]error{"probe": errFake},
+			wantStatus:   models.VideoFileStatusFailed,
+			wantStep:     "probe",
+			wantAttempts: processingMaxAttempts,
+			wantNotified: []bool{false},
+			wantCalls:    []string{"probe"},
+			wantSteps:    []string{"download", "probe"},
+		},
+		{
+			name:         "download failure",
+			downloadErr:  errFake,
+			wantStatus:   models.VideoFileStatusPending,
+			wantStep:     processingStepDownload,
+			wantAttempts: 1,
+			wantRetry:    processingRetryDelay,
+			wantCalls:    []string{},
+			wantSteps:    []string{"download"},
+		},
+		{
+			name:         "completed on last attempt",
+			attempts:     processingMaxAttempts - 1,
+			wantStatus:   models.VideoFileStatusCompleted,
+			wantAttempts: processingMaxAttempts,
+			wantNotified: []bool{true},
+			wantCalls:    []string{"probe", "transcode", "package"},
+			wantSteps:    []string{"download", "probe", "transcode", "package"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("VIDEO_PROCESSING_DIR", t.TempDir())
+			fake := transcoder.NewFake()
+			for operation, err := range tt.errors {
+				fake.SetError(operation, err)
+			}
+			queue := &memoryProcessingQueue{
+				files: map[uint]*models.VideoFile{1: {
+					ID:                 1,
+					VideoID:            10,
+					FilePath:           "movies/source.webm",
+					Format:             "video/webm", // MP4 以外は変換する
+					Status:             models.VideoFileStatusPending,
+					ProcessingAttempts: tt.attempts,
+				}},
+				source:      testMP4(),
+				downloadErr: tt.downloadErr,
+			}
+			job := NewProcessingJob(fake, testProbeStep, TranscodeStep{Preset: transcoder.DefaultPreset}, testPackageStep)
+			job.Queue = queue
+
+			before := time.Now().Truncate(time.Second)
+			job.Run()
+			after := time.Now()
+
+			file := queue.files[1]
+			if file.Status != tt.wantStatus {
+				t.Errorf("Status = %s, want %s", file.Status, tt.wantStatus)
+			}
+			var step string
+			if file.ProcessingStep != nil {
+				step = *file.ProcessingStep
+			}
+			if step != tt.wantStep {
+				t.Errorf("ProcessingStep = %q, want %q", step, tt.wantStep)
+			}
+			if file.ProcessingAttempts != tt.wantAttempts {
+				t.Errorf("ProcessingAttempts = %d, want %d", file.ProcessingAttempts, tt.wantAttempts)
+			}
+			if tt.wantRetry == 0 {
+				if file.NextAttempt != nil {
+					t.Errorf("NextAttempt = %v, want nil", file.NextAttempt)
+				}
+			} else if file.NextAttempt == nil || file.NextAttempt.Before(before.Add(tt.wantRetry)) || file.NextAttempt.After(after.Add(tt.wantRetry)) {
+				t.Errorf("NextAttempt = %v, want %v after processing", file.NextAttempt, tt.wantRetry)
+			}
+			if tt.wantStatus == models.VideoFileStatusCompleted {
+				if file.ProcessingError != nil || file.Processed == nil {
+					t.Errorf("ProcessingError, Processed = %v, %v, want nil and set", file.ProcessingError, file.Processed)
+				}
+			} else if file.ProcessingError == nil || !strings.Contains(*file.ProcessingError, errFake.Error()) {
+				t.Errorf("ProcessingError = %v, want to contain %q", file.ProcessingError, errFake)
+			}
+			if !reflect.DeepEqual(queue.notified, tt.wantNotified) {
+				t.Errorf("notified = %v, want %v", queue.notified, tt.wantNotified)
+			}
+			if calls := append([]string{}, fake.Calls...); !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("Transcoder calls = %v, want %v", calls, tt.wantCalls)
+			}
+			if !reflect.DeepEqual(queue.steps, tt.wantSteps) {
+				t.Errorf("steps = %v, want %v", queue.steps, tt.wantSteps)
+			}
+		})
+	}
+}
+
+// 再試行の日時を過ぎた動画ファイルは再び処理し、処理を待っているファイルがなくなるまで続ける
+func TestProcessingJobRetriesDueFiles(t *testing.T) {
+	t.Setenv("VIDEO_PROCESSING_DIR", t.TempDir())
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	queue := &memoryProcessingQueue{
+		files: map[uint]*models.VideoFile{
+			1: {ID: 1, VideoID: 10, FilePath: "movies/a.mp4", Format: "video/webm", Status: models.VideoFileStatusPending, ProcessingAttempts: 1, NextAttempt: &past},
+			2: {ID: 2, VideoID: 20, FilePath: "movies/b.mp4", Format: "video/webm", Status: models.VideoFileStatusPending, ProcessingAttempts: 1, NextAttempt: &future},
+			3: {ID: 3, VideoID: 30, FilePath: "movies/c.mp4", Format: "video/webm", Status: models.VideoFileStatusPending},
+		},
+		source: testMP4(),
+	}
+	job := NewProcessingJob(transcoder.NewFake(), testProbeStep, testPackageStep)
+	job.Queue = queue
+	job.Run()
+
+	want := map[uint]string{1: models.VideoFileStatusCompleted, 2: models.VideoFileStatusPending, 3: models.VideoFileStatusCompleted}
+	for id, status := range want {
+		if queue.files[id].Status != status {
+			t.Errorf("file %d Status = %s, want %s", id, queue.files[id].Status, status)
+		}
+	}
+	if queue.files[2].ProcessingAttempts != 1 {
+		t.Errorf("file 2 was processed before its retry time")
+	}
+}
+
+// サーバーの停止で中断した処理は失敗として扱わず、試行回数に数えずに処理待ちに戻す
+func TestProcessingJobReleasesOnStop(t *testing.T) {
+	t.Setenv("VIDEO_PROCESSING_DIR", t.TempDir())
+	queue := &memoryProcessingQueue{
+		files:  map[uint]*models.VideoFile{1: {ID: 1, VideoID: 10, FilePath: "movies/a.mp4", Format: "video/webm", Status: models.VideoFileStatusPending, ProcessingAttempts: 1}},
+		source: testMP4(),
+	}
+	job := NewProcessingJob(transcoder.NewFake())
+	job.Steps = []ProcessingStep{testProbeStep, funcStep{name: "transcode", run: func(ctx context.Context, task *ProcessingTask) error {
+		job.cancel()
+		return ctx.Err()
+	}}}
+	job.Queue = queue
+	job.Run()
+
+	file := queue.files[1]
+	if file.Status != models.VideoFileStatusPending || file.ProcessingStep != nil || file.ProcessingAttempts != 1 {
+		t.Errorf("file = %s, %v, %d attempts, want pending, nil, 1 attempt", file.Status, file.ProcessingStep, file.ProcessingAttempts)
+	}
+	if file.ProcessingError != nil || file.NextAttempt != nil || len(queue.notified) != 0 {
+		t.Errorf("interrupted processing was recorded as a failure")
+	}
+}
+
+func TestTruncateProcessingError(t *testing.T) {
+	short := strings.Repeat("あ", maxProcessingErrorLength)
+	if got := truncateProcessingError(short); got != short {
+		t.Errorf("message within the limit was changed")
+	}
+	got := truncateProcessingError(short + "い")
+	if want := short + "…"; got != want {
+		t.Errorf("truncateProcessingError() length = %d, want %d", len([]rune(got)), len([]rune(want)))
+	}
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"live/common"
+	"live/videoupload/models"
+	"live/videoupload/transcoder"
+	"math"
+	"os"
+	"path/filepath"
+)
+
+// 動画から作成するサムネイルの位置（再生時間に対する割合と最大の秒数）
+const (
+	thumbnailPositionRatio = 0.1
+	thumbnailMaxPosition   = 30
+)
+
+// DefaultProcessingSteps は既定の処理の段階（解析、変換、サムネイルの作成、配信用の書き出し、HLS の作成）を返す
+func DefaultProcessingSteps() []ProcessingStep {
+	return []ProcessingStep{
+		ProbeStep{},
+		TranscodeStep{Preset: transcoder.DefaultPreset},
+		ThumbnailStep{},
+		PackageStep{},
+		HLSStep{Renditions: transcoder.DefaultRenditions},
+	}
+}
+
+// ProbeStep は動画ファイルを解析し、再生時間・解像度・コーデックを保存する
+type ProbeStep struct{}
+
+func (ProbeStep) Name() string { return "probe" }
+
+func (ProbeStep) Run(ctx context.Context, task *ProcessingTask) error {
+	info, err := task.Transcoder.Probe(ctx, task.Source)
+	if err != nil {
+		return fmt.Errorf("動画ファイルの解析に失敗しました: %w", err)
+	}
+	task.Info = info
+	task.File.SetMediaInfo(info)
+	return models.UpdateVideoFileMediaInfo(task.File)
+}
+
+// TranscodeStep はそのまま配信できない動画を H.264 / AAC の MP4 に変換する
+type TranscodeStep struct {
+	Preset transcoder.Preset
+}
+
+func (TranscodeStep) Name() string { return "transcode" }
+
+func (s TranscodeStep) Run(ctx context.Context, task *ProcessingTask) error {
+	if task.Info == nil {
+		return fmt.Errorf("動画ファイルの解析結果がありません")
+	}
+	if !transcoder.NeedsTranscode(task.Info, task.File.Format, s.Preset) {
+		return nil
+	}
+
+	output := filepath.Join(task.WorkDir, "transcoded.mp4")
+	if err := task.Transcoder.Transcode(ctx, task.Output, output, s.Preset); err != nil {
+		return fmt.Errorf("動画の変換に失敗しました: %w", err)
+	}
+	task.Output = output
+	return nil
+}
+
+// ThumbnailStep は投稿者がサムネイルをアップロードしていない場合に、動画のフレームからサムネイルを作成する
+type ThumbnailStep struct{}
+
+func (ThumbnailStep) Name() string { return "thumbnail" }
+
+func (ThumbnailStep) Run(ctx context.Context, task *ProcessingTask) error {
+	exists, err := models.HasVideoThumbnails(task.Video.ID)
+	if err != nil || exists {
+		return err
+	}
+
+	var at float64
+	if task.Info != nil {
+		at = math.Min(task.Info.Duration*thumbnailPositionRatio, thumbnailMaxPosition)
+	}
+	output := filepath.Join(task.WorkDir, "frame.jpg")
+	if err := task.Transcoder.Thumbnail(ctx, task.Output, output, at); err != nil {
+		return fmt.Errorf("フレームの書き出しに失敗しました: %w", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		return err
+	}
+
+	thumbnails, err := task.Storage.UploadThumbnailFile(ctx, filepath.Base(output), data)
+	if err != nil {
+		return fmt.Errorf("サムネイルのアップロードに失敗しました: %w", err)
+	}
+	saved, err := models.SaveGeneratedVideoThumbnails(task.Video.ID, thumbnails)
+	if err != nil || !saved {
+		// 処理中に投稿者がサムネイルをアップロードした場合はそちらを使う
+		task.Storage.DeleteThumbnailFiles(thumbnails)
+	}
+	return err
+}
+
+// PackageStep は動画を配信用の MP4 に書き出し、配信する動画ファイルを置き換える
+type PackageStep struct{}
+
+func (PackageStep) Name() string { return "package" }
+
+func (PackageStep) Run(ctx context.Context, task *ProcessingTask) error {
+	output := filepath.Join(task.WorkDir, "packaged.mp4")
+	if err := task.Transcoder.Package(ctx, task.Output, output); err != nil {
+		return fmt.Errorf("配信用の動画の書き出しに失敗しました: %w", err)
+	}
+
+	file, err := os.Open(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	uploaded, err := task.Storage.UploadVideoStream(ctx, file, filepath.Base(output))
+	if err != nil {
+		return fmt.Errorf("配信用の動画のアップロードに失敗しました: %w", err)
+	}
+
+	released, err := models.ReplaceVideoFileObject(task.File.ID, uploaded.Path, uint64(uploaded.Size), uploaded.Format)
+	if err != nil {
+		if err := task.Storage.ReleaseVideoFile(uploaded.Path); err != nil {
+			common.LogVideoUploadError(err)
+		}
+		return err
+	}
+	task.File.FilePath, task.File.FileSize, task.File.Format = uploaded.Path, uint64(uploaded.Size), uploaded.Format
+
+	// 前回書き出した動画ファイルの参照を解放する（失敗しても処理は成功として扱う）
+	// 元の動画ファイルの参照は動画を削除するまで残す
+	if released != "" {
+		if err := task.Storage.ReleaseVideoFile(released); err != nil {
+			common.LogVideoUploadError(err)
+		}
+	}
+	return nil
+}
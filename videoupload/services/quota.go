@@ -0,0 +1,229 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"live/videoupload/models"
+	"time"
+)
+
+// アップロードの上限を超えた場合のエラーコード
+const (
+	QuotaErrorFileSize = "file_too_large"      // 1ファイルの最大サイズを超えている
+	QuotaErrorStorage  = "storage_exceeded"    // 保存できる合計サイズを超えている
+	QuotaErrorDuration = "duration_too_long"   // 1ファイルの最大の再生時間を超えている
+	QuotaErrorDaily    = "daily_limit_reached" // 24時間にアップロードできる数を超えている
+)
+
+// 1日あたりのアップロード数を数える期間
+const uploadQuotaWindow = 24 * time.Hour
+
+// 確保した容量が解放されなかった場合（サーバーが停止した場合など）に無効になるまでの時間
+const uploadReservationExpiration = 6 * time.Hour
+
+// アップロードの上限を超えた場合のエラー
+type QuotaError struct {
+	Code    string
+	Message string
+}
+
+func (e *QuotaError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// UploadQuota はユーザーに適用するアップロードの上限と使用量
+// 上限が nil の場合は無制限（1ファイルの最大サイズは常に MaxVideoFileSize 以下）
+type UploadQuota struct {
+	Plan             string
+	MaxTotalBytes    *uint64
+	MaxFileSize      uint64
+	MaxDuration      *uint
+	MaxUploadsPerDay *uint
+	Usage            models.UploadUsage
+}
+
+// LoadUploadQuota はユーザーのプランと管理者が設定した上限を合わせ、現在の使用量とともに取得する
+func LoadUploadQuota(userID uint) (*UploadQuota, error) {
+	quota, err := loadUploadLimits(userID)
+	if err != nil {
+		return nil, err
+	}
+	usage, err := models.GetUploadUsage(userID, time.Now().Add(-uploadQuotaWindow))
+	if err != nil {
+		return nil, err
+	}
+	quota.Usage = *usage
+	return quota, nil
+}
+
+// ReserveUpload はアップロードを開始できるか確認し、受信するファイルのために容量を確保する
+// size と duration は申告されたファイルのサイズと再生時間で、不明な場合は 0 を指定する
+// サイズが不明な場合は受信できる最大のサイズ（sizeLimit が正でそれより小さい場合は sizeLimit）を確保する
+// 返した UploadQuota の使用量には確保した容量を含めないため、MaxUploadSize は確保したサイズ以下になる
+// 確保した容量は、アップロードを終えるか失敗した時点で ReleaseUploadReservation で解放する
+func ReserveUpload(userID uint, size int64, duration uint, sizeLimit int64) (*UploadQuota, *models.UploadReservation, error) {
+	quota, err := loadUploadLimits(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	reservation, err := models.ReserveUpload(userID, now.Add(-uploadQuotaWindow), now.Add(uploadReservationExpiration).Truncate(time.Second),
+		func(usage *models.UploadUsage) (uint64, error) {
+			quota.Usage = *usage
+			if err := quota.CheckUpload(size, duration); err != nil {
+				return 0, err
+			}
+			if size > 0 {
+				return uint64(size), nil
+			}
+			reserved := quota.MaxUploadSize()
+			if sizeLimit > 0 && sizeLimit < reserved {
+				reserved = sizeLimit
+			}
+			return uint64(reserved), nil
+		})
+	if err != nil {
+		return nil, nil, err
+	}
+	return quota, reservation, nil
+}
+
+// ReleaseUploadReservation は ReserveUpload で確保した容量を解放する
+func ReleaseUploadReservation(reservation *models.UploadReservation) error {
+	return models.ReleaseUploadReservation(reservation.ID)
+}
+
+// ユーザーのプランと管理者が設定した上限を合わせて取得する（使用量は含めない）
+func loadUploadLimits(userID uint) (*UploadQuota, error) {
+	plan, override, err := models.GetUserUploadPlan(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	quota := &UploadQuota{
+		Plan:             plan.Name,
+		MaxTotalBytes:    plan.MaxTotalBytes,
+		MaxFileSize:      uint64(MaxVideoFileSize),
+		MaxDuration:      plan.MaxDuration,
+		MaxUploadsPerDay: plan.MaxUploadsPerDay,
+	}
+	maxFileSize := plan.MaxFileSize
+	if override != nil {
+		if override.MaxTotalBytes != nil {
+			quota.MaxTotalBytes = override.MaxTotalBytes
+		}
+		if override.MaxFileSize != nil {
+			maxFileSize = override.MaxFileSize
+		}
+		if override.MaxDuration != nil {
+			quota.MaxDuration = override.MaxDuration
+		}
+		if override.MaxUploadsPerDay != nil {
+			quota.MaxUploadsPerDay = override.MaxUploadsPerDay
+		}
+	}
+	if maxFileSize != nil && *maxFileSize < quota.MaxFileSize {
+		quota.MaxFileSize = *maxFileSize
+	}
+	return quota, nil
+}
+
+// RemainingBytes は保存できる残りのサイズを返す（無制限の場合は nil）
+func (q *UploadQuota) RemainingBytes() *uint64 {
+	if q.MaxTotalBytes == nil {
+		return nil
+	}
+	var remaining uint64
+	if q.Usage.TotalBytes < *q.MaxTotalBytes {
+		remaining = *q.MaxTotalBytes - q.Usage.TotalBytes
+	}
+	return &remaining
+}
+
+// RemainingUploads は24時間以内にアップロードできる残りの数を返す（無制限の場合は nil）
+func (q *UploadQuota) RemainingUploads() *uint {
+	if q.MaxUploadsPerDay == nil {
+		return nil
+	}
+	var remaining uint
+	if q.Usage.UploadsToday < *q.MaxUploadsPerDay {
+		remaining = *q.MaxUploadsPerDay - q.Usage.UploadsToday
+	}
+	return &remaining
+}
+
+// MaxUploadSize は次にアップロードできるファイルの最大サイズ（1ファイルの上限と残りの容量の小さい方）を返す
+func (q *UploadQuota) MaxUploadSize() int64 {
+	size := q.MaxFileSize
+	if remaining := q.RemainingBytes(); remaining != nil && *remaining < size {
+		size = *remaining
+	}
+	return int64(size)
+}
+
+// CheckUpload はアップロードを開始できるか確認する
+// size と duration は申告されたファイルのサイズと再生時間で、不明な場合は 0 を指定する
+func (q *UploadQuota) CheckUpload(size int64, duration uint) error {
+	if remaining := q.RemainingUploads(); remaining != nil && *remaining == 0 {
+		return &QuotaError{Code: QuotaErrorDaily, Message: fmt.Sprintf("24時間にアップロードできる動画は %d 本までです", *q.MaxUploadsPerDay)}
+	}
+	if remaining := q.RemainingBytes(); remaining != nil && *remaining == 0 {
+		return q.storageError()
+	}
+	if size > 0 {
+		if err := q.CheckSize(size); err != nil {
+			return err
+		}
+	}
+	return q.CheckDuration(duration)
+}
+
+// CheckSize はファイルのサイズが上限以下か確認する
+func (q *UploadQuota) CheckSize(size int64) error {
+	if uint64(size) > q.MaxFileSize {
+		return &QuotaError{Code: QuotaErrorFileSize, Message: fmt.Sprintf("アップロードできるファイルのサイズは %d バイトまでです", q.MaxFileSize)}
+	}
+	if remaining := q.RemainingBytes(); remaining != nil && uint64(size) > *remaining {
+		return q.storageError()
+	}
+	return nil
+}
+
+// CheckDuration は再生時間（秒）が上限以下か確認する
+func (q *UploadQuota) CheckDuration(duration uint) error {
+	if q.MaxDuration != nil && duration > *q.MaxDuration {
+		return &QuotaError{Code: QuotaErrorDuration, Message: fmt.Sprintf("アップロードできる動画の再生時間は %d 秒までです", *q.MaxDuration)}
+	}
+	return nil
+}
+
+func (q *UploadQuota) storageError() *QuotaError {
+	return &QuotaError{Code: QuotaErrorStorage, Message: fmt.Sprintf("保存できる動画ファイルの合計サイズ（%d バイト）を超えます", *q.MaxTotalBytes)}
+}
+
+// LimitReader は上限を超えるデータを読み込んだ時点で *QuotaError を返す io.Reader を返す
+// ストリーミングでアップロードする場合に、上限を超えるデータをストレージに送らないために使う
+func (q *UploadQuota) LimitReader(r io.Reader) io.Reader {
+	return &quotaReader{r: r, remaining: q.MaxUploadSize(), quota: q}
+}
+
+type quotaReader struct {
+	r         io.Reader
+	remaining int64
+	quota     *UploadQuota
+}
+
+func (l *quotaReader) Read(p []byte) (int, error) {
+	// 上限ちょうどのファイルを受け付けるため、1バイト多く読み込んで超過を判定する
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.remaining {
+		n = int(l.remaining)
+		l.remaining = 0
+		return n, l.quota.CheckSize(l.quota.MaxUploadSize() + 1)
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
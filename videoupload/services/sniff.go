@@ -0,0 +1,224 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+)
+
+// ファイル形式の判別に使う先頭のバイト数
+const SniffLength = 4096
+
+// ファイル形式の検証エラーのコード
+const (
+	// 対応している形式として判別できない
+	FormatErrorUnknown = "unknown_format"
+	// 拡張子と実際の形式が一致しない
+	FormatErrorMismatch = "extension_mismatch"
+	// 対応していない拡張子
+	FormatErrorExtension = "unsupported_extension"
+)
+
+// ファイルの形式が受け付けられない場合のエラー
+type FormatError struct {
+	Code    string
+	Message string
+}
+
+func (e *FormatError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// 動画の MIME タイプ
+const (
+	mimeMP4       = "video/mp4"
+	mimeQuickTime = "video/quicktime"
+	mime3GPP      = "video/3gpp"
+	mime3GPP2     = "video/3gpp2"
+	mimeMatroska  = "video/x-matroska"
+	mimeWebM      = "video/webm"
+	mimeAVI       = "video/x-msvideo"
+	mimeFLV       = "video/x-flv"
+	mimeASF       = "video/x-ms-asf"
+	mimeMPEG      = "video/mpeg"
+	mimeMPEGTS    = "video/mp2t"
+)
+
+// 拡張子ごとに受け付ける動画の形式（同じコンテナ形式の仲間は相互に受け付ける）
+var videoExtensionTypes = map[string][]string{
+	".mp4":  {mimeMP4, mimeQuickTime, mime3GPP, mime3GPP2},
+	".m4v":  {mimeMP4, mimeQuickTime, mime3GPP, mime3GPP2},
+	".mov":  {mimeMP4, mimeQuickTime, mime3GPP, mime3GPP2},
+	".3gp":  {mimeMP4, mimeQuickTime, mime3GPP, mime3GPP2},
+	".mkv":  {mimeMatroska, mimeWebM},
+	".webm": {mimeWebM},
+	".avi":  {mimeAVI},
+	".flv":  {mimeFLV},
+	".wmv":  {mimeASF},
+	".mpeg": {mimeMPEG, mimeMPEGTS},
+	".mpg":  {mimeMPEG, mimeMPEGTS},
+}
+
+// 拡張子ごとに受け付ける画像の形式
+var imageExtensionTypes = map[string][]string{
+	".jpg":  {"image/jpeg"},
+	".jpeg": {"image/jpeg"},
+	".png":  {"image/png"},
+	".gif":  {"image/gif"},
+	".bmp":  {"image/bmp"},
+	".tiff": {"image/tiff"},
+	".webp": {"image/webp"},
+	".ico":  {"image/x-icon"},
+	".svg":  {"image/svg+xml"},
+}
+
+// ASF（WMV）のヘッダーオブジェクトの GUID
+var asfHeaderGUID = []byte{0x30, 0x26, 0xB2, 0x75, 0x8E, 0x66, 0xCF, 0x11, 0xA6, 0xD9, 0x00, 0xAA, 0x00, 0x62, 0xCE, 0x6C}
+
+// 拡張子のみで ISO BMFF（MP4 / QuickTime）と判別できるアトム
+var quickTimeAtoms = map[string]bool{"moov": true, "mdat": true, "wide": true, "free": true, "skip": true, "pnot": true}
+
+// DetectVideoType はファイルの先頭のバイト列からコンテナ形式を判別し、MIME タイプを返す
+// 判別できない場合は空文字を返す
+func DetectVideoType(head []byte) string {
+	switch {
+	case len(head) >= 12 && string(head[4:8]) == "ftyp":
+		return isoBrandType(string(head[8:12]))
+	case len(head) >= 8 && quickTimeAtoms[string(head[4:8])]:
+		return mimeQuickTime
+	case bytes.HasPrefix(head, []byte{0x1A, 0x45, 0xDF, 0xA3}):
+		if ebmlDocType(head) == "webm" {
+			return mimeWebM
+		}
+		return mimeMatroska
+	case len(head) >= 12 && string(head[0:4]) == "RIFF" && string(head[8:12]) == "AVI ":
+		return mimeAVI
+	case len(head) >= 4 && string(head[0:3]) == "FLV" && head[3] == 0x01:
+		return mimeFLV
+	case bytes.HasPrefix(head, asfHeaderGUID):
+		return mimeASF
+	case bytes.HasPrefix(head, []byte{0x00, 0x00, 0x01, 0xBA}), bytes.HasPrefix(head, []byte{0x00, 0x00, 0x01, 0xB3}):
+		return mimeMPEG
+	case isMPEGTS(head, 188, 0), isMPEGTS(head, 192, 4):
+		return mimeMPEGTS
+	}
+	return ""
+}
+
+// ValidateVideoContent はファイル名の拡張子と先頭のバイト列から判別した形式が一致するか確認し、判別した MIME タイプを返す
+func ValidateVideoContent(filename string, head []byte) (string, error) {
+	return validateContent(filename, head, videoExtensionTypes, DetectVideoType, "動画")
+}
+
+// ValidateImageContent はファイル名の拡張子と先頭のバイト列から判別した画像の形式が一致するか確認し、判別した MIME タイプを返す
+func ValidateImageContent(filename string, head []byte) (string, error) {
+	return validateContent(filename, head, imageExtensionTypes, DetectImageType, "画像")
+}
+
+func validateContent(filename string, head []byte, extensionTypes map[string][]string, detect func([]byte) string, kind string) (string, error) {
+	ext := strings.ToLower(filepath.Ext(filename))
+	allowed, ok := extensionTypes[ext]
+	if !ok {
+		return "", &FormatError{Code: FormatErrorExtension, Message: fmt.Sprintf("無効なファイル拡張子: %s", ext)}
+	}
+
+	detected := detect(head)
+	if detected == "" {
+		return "", &FormatError{Code: FormatErrorUnknown, Message: fmt.Sprintf("対応している%sの形式ではありません", kind)}
+	}
+	for _, t := range allowed {
+		if t == detected {
+			return detected, nil
+		}
+	}
+	return "", &FormatError{
+		Code:    FormatErrorMismatch,
+		Message: fmt.Sprintf("拡張子 %s とファイルの形式（%s）が一致しません", ext, detected),
+	}
+}
+
+// DetectImageType はファイルの先頭のバイト列から画像の形式を判別し、MIME タイプを返す
+// 判別できない場合は空文字を返す
+func DetectImageType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("II*\x00")), bytes.HasPrefix(head, []byte("MM\x00*")):
+		return "image/tiff"
+	case isSVG(head):
+		return "image/svg+xml"
+	}
+
+	detected := http.DetectContentType(head)
+	if _, ok := imageTypes[detected]; ok {
+		return detected
+	}
+	return ""
+}
+
+// http.DetectContentType で判別する画像の形式
+var imageTypes = map[string]bool{
+	"image/jpeg":   true,
+	"image/png":    true,
+	"image/gif":    true,
+	"image/bmp":    true,
+	"image/webp":   true,
+	"image/x-icon": true,
+}
+
+// ftyp ボックスのメジャーブランドから MIME タイプを決める
+func isoBrandType(brand string) string {
+	switch {
+	case brand == "qt  ":
+		return mimeQuickTime
+	case strings.HasPrefix(brand, "3g2"):
+		return mime3GPP2
+	case strings.HasPrefix(brand, "3gp"), strings.HasPrefix(brand, "3gs"):
+		return mime3GPP
+	default:
+		return mimeMP4
+	}
+}
+
+// EBML ヘッダーの DocType（matroska / webm）を取得する
+func ebmlDocType(head []byte) string {
+	if len(head) > 64 {
+		head = head[:64]
+	}
+	i := bytes.Index(head, []byte{0x42, 0x82})
+	if i < 0 || i+2 >= len(head) {
+		return ""
+	}
+	// サイズは1バイトの可変長整数（先頭ビットが1）のみ扱う
+	size := head[i+2]
+	if size&0x80 == 0 {
+		return ""
+	}
+	n := int(size & 0x7F)
+	start := i + 3
+	if start+n > len(head) {
+		return ""
+	}
+	return string(bytes.TrimRight(head[start:start+n], "\x00"))
+}
+
+// MPEG-TS（188 バイト）または M2TS（192 バイト）のパケットの同期バイトが並んでいるか確認する
+func isMPEGTS(head []byte, packetSize, offset int) bool {
+	count := 0
+	for i := offset; i < len(head) && count < 3; i += packetSize {
+		if head[i] != 0x47 {
+			return false
+		}
+		count++
+	}
+	return count >= 2
+}
+
+// SVG（XML 宣言やコメントの後に <svg 要素がある）か確認する
+func isSVG(head []byte) bool {
+	text := bytes.TrimSpace(bytes.TrimPrefix(head, []byte("\xef\xbb\xbf")))
+	if !bytes.HasPrefix(text, []byte("<")) {
+		return false
+	}
+	return bytes.Contains(bytes.ToLower(text), []byte("<svg"))
+}
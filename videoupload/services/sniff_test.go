@@ -0,0 +1,59 @@
+package services
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestValidateVideoContent(t *testing.T) {
+	mp4 := []byte("\x00\x00\x00\x18ftypisom\x00\x00\x02\x00isomavc1")
+	quickTime := []byte("\x00\x00\x00\x14ftypqt  \x00\x00\x02\x00qt  ")
+	webm := []byte("\x1A\x45\xDF\xA3\x9F\x42\x86\x81\x01\x42\x82\x84webm")
+	matroska := []byte("\x1A\x45\xDF\xA3\xA3\x42\x86\x81\x01\x42\x82\x88matroska")
+	avi := []byte("RIFF\x00\x00\x00\x00AVI LIST")
+	mpegTS := bytes.Repeat(append([]byte{0x47}, make([]byte, 187)...), 3)
+
+	tests := []struct {
+		name     string
+		filename string
+		head     []byte
+		want     string
+		wantCode string
+	}{
+		{name: "mp4", filename: "clip.mp4", head: mp4, want: "video/mp4"},
+		{name: "uppercase extension", filename: "CLIP.MP4", head: mp4, want: "video/mp4"},
+		{name: "quicktime as mp4", filename: "clip.mp4", head: quickTime, want: "video/quicktime"},
+		{name: "mov", filename: "clip.mov", head: quickTime, want: "video/quicktime"},
+		{name: "webm", filename: "clip.webm", head: webm, want: "video/webm"},
+		{name: "webm as mkv", filename: "clip.mkv", head: webm, want: "video/webm"},
+		{name: "mkv", filename: "clip.mkv", head: matroska, want: "video/x-matroska"},
+		{name: "avi", filename: "clip.avi", head: avi, want: "video/x-msvideo"},
+		{name: "mpeg-ts", filename: "clip.mpg", head: mpegTS, want: "video/mp2t"},
+		{name: "matroska as webm", filename: "clip.webm", head: matroska, wantCode: FormatErrorMismatch},
+		{name: "mp4 as avi", filename: "clip.avi", head: mp4, wantCode: FormatErrorMismatch},
+		{name: "unknown content", filename: "clip.mp4", head: []byte("<html></html>"), wantCode: FormatErrorUnknown},
+		{name: "empty", filename: "clip.mp4", head: nil, wantCode: FormatErrorUnknown},
+		{name: "unsupported extension", filename: "clip.exe", head: mp4, wantCode: FormatErrorExtension},
+		{name: "no extension", filename: "clip", head: mp4, wantCode: FormatErrorExtension},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateVideoContent(tt.filename, tt.head)
+			if tt.wantCode != "" {
+				var formatErr *FormatError
+				if !errors.As(err, &formatErr) || formatErr.Code != tt.wantCode {
+					t.Fatalf("ValidateVideoContent() error = %v, want code %s", err, tt.wantCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ValidateVideoContent() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateVideoContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -3,9 +3,11 @@ package services
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"live/common"
-	"mime/multipart"
+	"live/videoupload/models"
+	"live/videoupload/thumbnail"
 	"os"
 	"path/filepath"
 	"time"
@@ -14,10 +16,19 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/google/uuid"
 	"github.com/minio/minio-go/v7"
 	minioCredentials "github.com/minio/minio-go/v7/pkg/credentials"
 )
 
+const (
+	// アップロードできるサムネイル画像の最大サイズ
+	MaxThumbnailFileSize = 10 << 20
+)
+
+// サムネイル画像の解像度が大きすぎる場合のエラー
+var ErrThumbnailTooLarge = errors.New("サムネイル画像の解像度が大きすぎます")
+
 type StorageService struct {
 	Client      *s3.S3
 	MinioClient *minio.Client
@@ -104,61 +115,94 @@ func InitMinioService() (*StorageService, error) {
 	}, nil
 }
 
-func (s *StorageService) UploadFile(file multipart.File, fileHeader *multipart.FileHeader) (string, error) {
-	defer file.Close() // ファイルを閉じるためのdefer
-
-	objectName := "movies/" + common.GenerateUniqueFileName(fileHeader.Filename)
+// サムネイル画像を読み込み、表示サイズごとに縮小した画像をアップロードするメソッド
+// 保存するのは URL ではなくオブジェクトキー（thumbnails/<ID>/<種類>.<拡張子>）で、URL は表示のたびに署名して返す
+func (s *StorageService) UploadThumbnailFile(ctx context.Context, filename string, data []byte) ([]models.VideoThumbnail, error) {
+	// クライアントが指定した Content-Type ではなく、ファイルの内容から形式を判別する
+	head := data
+	if len(head) > SniffLength {
+		head = head[:SniffLength]
+	}
+	if _, err := ValidateImageContent(filename, head); err != nil {
+		return nil, err
+	}
 
-	validExtensions := map[string]bool{
-		".mp4":  true,
-		".avi":  true,
-		".mov":  true,
-		".wmv":  true,
-		".flv":  true,
-		".mkv":  true,
-		".webm": true,
-		".mpeg": true,
-		".mpg":  true,
-		".3gp":  true,
-		".m4v":  true,
+	images, err := thumbnail.Generate(data)
+	if err != nil {
+		if errors.Is(err, thumbnail.ErrUnsupported) {
+			return nil, &FormatError{Code: FormatErrorUnknown, Message: "サムネイルに使用できない画像の形式です（JPEG, PNG, GIF, BMP, TIFF, WebP に対応しています）"}
+		}
+		if errors.Is(err, thumbnail.ErrTooLarge) {
+			return nil, ErrThumbnailTooLarge
+		}
+		return nil, fmt.Errorf("サムネイルの作成に失敗しました: %w", err)
 	}
 
-	ext := filepath.Ext(objectName)
-	if !validExtensions[ext] {
-		return "", fmt.Errorf("無効なファイル拡張子: %s", ext)
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second) // タイムアウト設定
+	defer cancel()
+
+	prefix := "thumbnails/" + uuid.New().String() + "/"
+	thumbnails := make([]models.VideoThumbnail, 0, len(images))
+	for _, img := range images {
+		objectName := prefix + img.Variant + img.Extension
+		if err := s.putObject(ctx, objectName, img.ContentType, img.Data); err != nil {
+			// 途中まで保存したサムネイルを削除する
+			s.DeleteThumbnailFiles(thumbnails)
+			return nil, err
+		}
+		thumbnails = append(thumbnails, models.VideoThumbnail{
+			Variant:     img.Variant,
+			FilePath:    objectName,
+			Width:       uint(img.Width),
+			Height:      uint(img.Height),
+			ContentType: img.ContentType,
+		})
 	}
+	return thumbnails, nil
+}
 
-	contentType := fileHeader.Header.Get("Content-Type")
+// サムネイルのファイルを削除するメソッド（失敗した場合はログに記録して続ける）
+func (s *StorageService) DeleteThumbnailFiles(thumbnails []models.VideoThumbnail) {
+	for _, t := range thumbnails {
+		if err := s.DeleteFile(t.FilePath); err != nil {
+			common.LogVideoUploadError(err)
+		}
+	}
+}
 
-	// ファイルの内容をバイトスライスに変換
-	buf := new(bytes.Buffer)
-	_, err := buf.ReadFrom(file)
-	if err != nil {
-		return "", fmt.Errorf("ファイルの読み込みに失敗しました: %w", err)
+// ENV_MODE に応じてストレージサービスを初期化する
+func InitStorageService() (*StorageService, error) {
+	if os.Getenv("ENV_MODE") == "local" {
+		return InitMinioService()
 	}
+	return NewStorageService()
+}
+
+// WebVTT に変換した字幕をアップロードするメソッド
+func (s *StorageService) UploadCaptionFile(data []byte) (string, error) {
+	objectName := "captions/" + common.GenerateUniqueFileName(".vtt")
+	contentType := "text/vtt; charset=utf-8"
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second) // タイムアウト設定
 	defer cancel()
 
 	if s.MinioClient != nil { // MinIOを使用する場合
-		_, err = s.MinioClient.PutObject(ctx, s.Bucket, objectName, bytes.NewReader(buf.Bytes()), int64(buf.Len()), minio.PutObjectOptions{
+		_, err := s.MinioClient.PutObject(ctx, s.Bucket, objectName, bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{
 			ContentType: contentType,
 		})
 		if err != nil {
-			return "", fmt.Errorf("MinIOへのファイルのアップロードに失敗しました: %w | Bucket: %s, Key: %s, Content-Type: %s",
-				err, s.Bucket, objectName, contentType)
+			return "", fmt.Errorf("MinIOへの字幕のアップロードに失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
 		}
 		return objectName, nil
 	} else if s.Client != nil { // S3を使用する場合
-		_, err = s.Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		_, err := s.Client.PutObjectWithContext(ctx, &s3.PutObjectInput{
 			Bucket:      aws.String(s.Bucket),
 			Key:         aws.String(objectName),
-			Body:        bytes.NewReader(buf.Bytes()),
+			Body:        bytes.NewReader(data),
 			ContentType: aws.String(contentType),
 		})
 		if err != nil {
-			return "", fmt.Errorf("S3へのファイルのアップロードに失敗しました: %w | Bucket: %s, Key: %s, Content-Type: %s",
-				err, s.Bucket, objectName, contentType)
+			return "", fmt.Errorf("S3への字幕のアップロードに失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
 		}
 		return objectName, nil
 	} else {
@@ -166,68 +210,37 @@ func (s *StorageService) UploadFile(file multipart.File, fileHeader *multipart.F
 	}
 }
 
-// サムネイルをアップロードするメソッド
-func (s *StorageService) UploadThumbnailFile(file multipart.File, fileHeader *multipart.FileHeader) (string, error) {
-	// ファイル名を `thumbnails/` プレフィックスにする
-	objectName := "thumbnails/" + common.GenerateUniqueFileName(fileHeader.Filename)
-
-	ext := filepath.Ext(objectName)
-	validExtensions := map[string]bool{
-		".jpg":  true,
-		".jpeg": true,
-		".png":  true,
-		".gif":  true,
-		".bmp":  true,
-		".tiff": true,
-		".webp": true,
-		".ico":  true,
-		".svg":  true,
-	}
-
-	if !validExtensions[ext] {
-		err := fmt.Errorf("無効なファイル拡張子: %s", ext)
-		common.LogVideoUploadError(err)
-		return "", err
-	}
-
-	contentType := fileHeader.Header.Get("Content-Type")
-
-	// ファイルの内容をバイトスライスに変換
-	buf := new(bytes.Buffer)
-	_, err := buf.ReadFrom(file)
-	if err != nil {
-		common.LogVideoUploadError(fmt.Errorf("サムネイルファイルの読み込みに失敗しました: %w", err))
-		return "", err
-	}
-	fileBytes := buf.Bytes()
-
+// ファイルを削除するメソッド
+func (s *StorageService) DeleteFile(objectName string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second) // タイムアウト設定
 	defer cancel()
 
 	if s.MinioClient != nil { // MinIOを使用する場合
-		_, err = s.MinioClient.PutObject(ctx, s.Bucket, objectName, bytes.NewReader(fileBytes), int64(len(fileBytes)), minio.PutObjectOptions{
-			ContentType: contentType,
-		})
-		if err != nil {
-			common.LogVideoUploadError(fmt.Errorf("MinIOへのサムネイルのアップロードに失敗しました: %w", err))
-			return "", err
+		if err := s.MinioClient.RemoveObject(ctx, s.Bucket, objectName, minio.RemoveObjectOptions{}); err != nil {
+			return fmt.Errorf("MinIOのファイルの削除に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
 		}
-		fileURL := fmt.Sprintf("%s/%s/%s", os.Getenv("STORAGE_ENDPOINT"), s.Bucket, objectName)
-		return fileURL, nil
+		return nil
 	} else if s.Client != nil { // S3を使用する場合
-		_, err = s.Client.PutObject(&s3.PutObjectInput{
-			Bucket:      aws.String(s.Bucket),
-			Key:         aws.String(objectName),
-			Body:        bytes.NewReader(fileBytes),
-			ContentType: aws.String(contentType),
+		_, err := s.Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(objectName),
 		})
 		if err != nil {
-			common.LogVideoUploadError(fmt.Errorf("S3へのサムネイルのアップロードに失敗しました: %w", err))
-			return "", err
+			return fmt.Errorf("S3のファイルの削除に失敗しました: %w | Bucket: %s, Key: %s", err, s.Bucket, objectName)
 		}
-		fileURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, os.Getenv("AWS_REGION"), objectName)
-		return fileURL, nil
+		return nil
 	} else {
-		return "", fmt.Errorf("ストレージクライアントが初期化されていません。")
+		return fmt.Errorf("ストレージクライアントが初期化されていません")
+	}
+}
+
+// 元のファイル名から動画ファイルの保存先（movies/ 以下）を決める
+// 動画ファイルとして受け付けない拡張子の場合はエラーを返す
+func VideoObjectName(filename string) (string, error) {
+	objectName := "movies/" + common.GenerateUniqueFileName(filename)
+	ext := filepath.Ext(objectName)
+	if _, ok := videoExtensionTypes[ext]; !ok {
+		return "", fmt.Errorf("無効なファイル拡張子: %s", ext)
 	}
+	return objectName, nil
 }
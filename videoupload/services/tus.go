@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+)
+
+// TusUploadIDPattern はアップロードIDとして受け付ける形式（UUID）
+const TusUploadIDPattern = `[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}`
+
+var tusUploadIDPattern = regexp.MustCompile(`^` + TusUploadIDPattern + `$`)
+
+// 同じアップロードへの同時の書き込みを防ぐためのロック
+var tusLocks sync.Map
+
+// LockTusUpload は同じアップロードへの書き込みを1つに制限する（既に処理中の場合は false を返す）
+// ロックは記録されたアップロードにのみ作成するよう、存在と所有者を確認してから呼ぶ
+func LockTusUpload(id string) (func(), bool) {
+	value, _ := tusLocks.LoadOrStore(id, &sync.Mutex{})
+	mu := value.(*sync.Mutex)
+	if !mu.TryLock() {
+		return nil, false
+	}
+	return mu.Unlock, true
+}
+
+// ForgetTusUploadLock は完了または削除したアップロードのロックを破棄する（ロックを保持したまま呼ぶ）
+func ForgetTusUploadLock(id string) {
+	tusLocks.Delete(id)
+}
+
+// TusStore は tus のアップロード途中のファイルをサーバーのディスクに保存する
+// 受信済みのサイズはデータベースに記録し、ファイルは書き込みごとにディスクへ同期するため、再起動後も再開できる
+type TusStore struct {
+	Dir string
+}
+
+// TUS_UPLOAD_DIR にアップロード途中のファイルを保存する
+// 再起動後も再開できるよう、一時ディレクトリではなく永続化したディレクトリを指定する
+func NewTusStore() (*TusStore, error) {
+	dir := os.Getenv("TUS_UPLOAD_DIR")
+	if dir == "" {
+		return nil, fmt.Errorf("TUS_UPLOAD_DIR 環境変数が設定されていません")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("Failed to create tus upload directory: %w", err)
+	}
+	return &TusStore{Dir: dir}, nil
+}
+
+func (s *TusStore) path(id string) (string, error) {
+	if !tusUploadIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid tus upload id: %s", id)
+	}
+	return filepath.Join(s.Dir, id+".bin"), nil
+}
+
+// Create は空のファイルを作成する
+func (s *TusStore) Create(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Size は保存済みのファイルのサイズを返す
+// ファイルが失われている場合（ディレクトリを永続化していなかった場合など）は何も受信していないものとして 0 を返す
+func (s *TusStore) Size(id string) (int64, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return 0, err
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// Append は offset の位置から最大 max バイトを書き込み、書き込んだバイト数を返す
+// offset より後ろに残っている記録前のデータは切り捨て、ファイルが失われている場合は作成し直す
+// 途中で読み込みに失敗した場合も、それまでに受信したデータはディスクに同期して書き込んだバイト数を返す
+func (s *TusStore) Append(id string, offset int64, r io.Reader, max int64) (int64, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if err := f.Truncate(offset); err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	written, copyErr := io.Copy(f, io.LimitReader(r, max))
+	if err := f.Sync(); err != nil {
+		return 0, err
+	}
+	return written, copyErr
+}
+
+// Open は読み込み用にファイルを開く
+func (s *TusStore) Open(id string) (*os.File, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Remove はファイルを削除する（既に削除されている場合は何もしない）
+func (s *TusStore) Remove(id string) error {
+	path, err := s.path(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package services
+
+import (
+	"fmt"
+	"live/common"
+	"live/videoupload/models"
+	"sync"
+	"time"
+)
+
+const (
+	// 有効期限を過ぎたアップロードを削除する間隔
+	tusCleanupInterval = time.Hour
+	// 1回の処理で削除するアップロードの最大数
+	tusCleanupBatchSize = 100
+)
+
+// TusCleanupJob は有効期限を過ぎた tus のアップロードとアップロード途中のファイルを削除する
+type TusCleanupJob struct {
+	mu      sync.Mutex
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+	started bool
+}
+
+// アプリケーション全体で共有する削除ジョブ
+var TusCleanup = NewTusCleanupJob()
+
+func NewTusCleanupJob() *TusCleanupJob {
+	return &TusCleanupJob{
+		stopCh: make(chan struct{}),
+		doneCh: make(chan struct{}),
+	}
+}
+
+// Start は起動直後と一定間隔ごとの削除を開始する
+func (j *TusCleanupJob) Start() {
+	j.mu.Lock()
+	if j.started {
+		j.mu.Unlock()
+		return
+	}
+	j.started = true
+	j.mu.Unlock()
+
+	go func() {
+		defer close(j.doneCh)
+		j.Run()
+
+		ticker := time.NewTicker(tusCleanupInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				j.Run()
+			case <-j.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop は定期的な削除を止める
+func (j *TusCleanupJob) Stop() {
+	j.mu.Lock()
+	started := j.started
+	j.mu.Unlock()
+	if !started {
+		return
+	}
+	close(j.stopCh)
+	<-j.doneCh
+}
+
+// Run は有効期限を過ぎたアップロードをすべて削除する
+func (j *TusCleanupJob) Run() {
+	store, err := NewTusStore()
+	if err != nil {
+		common.LogVideoUploadError(err)
+		return
+	}
+
+	for {
+		uploads, err := models.GetExpiredTusUploads(time.Now(), tusCleanupBatchSize)
+		if err != nil {
+			common.LogVideoUploadError(fmt.Errorf("Failed to get expired tus uploads: %w", err))
+			return
+		}
+
+		skipped := false
+		for _, upload := range uploads {
+			// リクエストの処理中のアップロードは次回に削除する
+			unlock, ok := LockTusUpload(upload.ID)
+			if !ok {
+				skipped = true
+				continue
+			}
+			err := j.remove(store, upload.ID)
+			unlock()
+			if err != nil {
+				common.LogVideoUploadError(err)
+				return
+			}
+		}
+
+		if skipped || len(uploads) < tusCleanupBatchSize {
+			return
+		}
+	}
+}
+
+// アップロードのファイルと記録、ロックを削除する
+func (j *TusCleanupJob) remove(store *TusStore, id string) error {
+	if err := store.Remove(id); err != nil {
+		return fmt.Errorf("Failed to remove tus upload file %s: %w", id, err)
+	}
+	if err := models.DeleteTusUpload(id); err != nil {
+		return fmt.Errorf("Failed to delete tus upload %s: %w", id, err)
+	}
+	ForgetTusUploadLock(id)
+	return nil
+}
@@ -0,0 +1,123 @@
+// Package thumbnail はアップロードされた画像から表示サイズごとのサムネイルを作成する
+// 外部コマンドを使わず、縮小した JPEG と WebP を出力する
+package thumbnail
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+
+	// 読み込みに対応する画像の形式
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp"
+
+	"golang.org/x/image/draw"
+)
+
+var (
+	// 読み込めない画像の形式（SVG や ICO など）
+	ErrUnsupported = errors.New("thumbnail: unsupported image format")
+	// 画素数が多すぎる画像
+	ErrTooLarge = errors.New("thumbnail: image is too large")
+)
+
+// 読み込む画像の最大の画素数（展開後のメモリ使用量を抑える）
+const maxPixels = 40_000_000
+
+// JPEG の画質
+const jpegQuality = 85
+
+// サムネイルの種類
+const (
+	VariantSmall  = "small"
+	VariantMedium = "medium"
+	VariantLarge  = "large"
+	VariantWebP   = "webp"
+)
+
+// サムネイルの種類ごとの最大サイズ（縦横比を保って収まるように縮小し、拡大はしない）
+var variantSizes = []struct {
+	name          string
+	width, height int
+	webp          bool
+}{
+	{VariantSmall, 320, 180, false},
+	{VariantMedium, 640, 360, false},
+	{VariantLarge, 1280, 720, false},
+	{VariantWebP, 640, 360, true},
+}
+
+// 作成したサムネイル
+type Image struct {
+	Variant     string
+	Width       int
+	Height      int
+	ContentType string
+	Extension   string
+	Data        []byte
+}
+
+// Generate は画像を読み込み、すべての種類のサムネイルを作成する
+func Generate(data []byte) ([]Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if config.Width <= 0 || config.Height <= 0 || config.Width*config.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+
+	images := make([]Image, 0, len(variantSizes))
+	for _, size := range variantSizes {
+		resized := resize(src, size.width, size.height)
+		b := resized.Bounds()
+
+		var buf bytes.Buffer
+		thumb := Image{Variant: size.name, Width: b.Dx(), Height: b.Dy()}
+		if size.webp {
+			if err := encodeWebP(&buf, resized); err != nil {
+				return nil, err
+			}
+			thumb.ContentType, thumb.Extension = "image/webp", ".webp"
+		} else {
+			// JPEG は透過できないため白い背景に重ねる
+			flat := image.NewRGBA(b)
+			draw.Draw(flat, b, image.NewUniform(color.White), image.Point{}, draw.Src)
+			draw.Draw(flat, b, resized, b.Min, draw.Over)
+			if err := jpeg.Encode(&buf, flat, &jpeg.Options{Quality: jpegQuality}); err != nil {
+				return nil, err
+			}
+			thumb.ContentType, thumb.Extension = "image/jpeg", ".jpg"
+		}
+		thumb.Data = buf.Bytes()
+		images = append(images, thumb)
+	}
+	return images, nil
+}
+
+// 縦横比を保って maxWidth x maxHeight に収まるように縮小する
+func resize(src image.Image, maxWidth, maxHeight int) *image.NRGBA {
+	b := src.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width > maxWidth || height > maxHeight {
+		if width*maxHeight > height*maxWidth {
+			width, height = maxWidth, max(1, height*maxWidth/width)
+		} else {
+			width, height = max(1, width*maxHeight/height), maxHeight
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Src, nil)
+	return dst
+}
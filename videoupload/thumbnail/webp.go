@@ -0,0 +1,507 @@
+package thumbnail
+
+import (
+	"encoding/binary"
+	"image"
+	"io"
+	"math/bits"
+	"sort"
+)
+
+// WebP（VP8L 可逆圧縮）のエンコーダー
+// 標準ライブラリには WebP のエンコーダーがないため、減算グリーン変換と予測変換、
+// 左または上の画素と同じ画素の繰り返しの後方参照のみを使う簡易な実装を用意する
+
+const (
+	vp8lSignature = 0x2f
+
+	// 変換の種類
+	transformPredictor     = 0
+	transformSubtractGreen = 2
+
+	// 予測変換のブロックサイズ（2 の累乗の指数、最大 9）と予測モード（Average2(L, T)）
+	predictorBits = 9
+	predictorMode = 7
+
+	// 後方参照の長さの範囲
+	minCopyLength = 3
+	maxCopyLength = 4096
+
+	// 後方参照の距離の符号（1: 上の画素、2: 左の画素）
+	distanceCodeTop  = 1
+	distanceCodeLeft = 2
+
+	// プレフィックス符号の最大の長さ
+	maxCodeLength           = 15
+	maxCodeLengthCodeLength = 7
+)
+
+// 符号長の符号長を書き込む順序
+var codeLengthCodeOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// encodeWebP は画像を可逆圧縮の WebP として書き込む
+func encodeWebP(w io.Writer, img *image.NRGBA) error {
+	b := img.Bounds()
+	width, height := b.Dx(), b.Dy()
+
+	argb := make([]uint32, 0, width*height)
+	hasAlpha := false
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		row := img.Pix[img.PixOffset(b.Min.X, y):]
+		for x := 0; x < width; x++ {
+			p := row[x*4 : x*4+4]
+			if p[3] != 0xff {
+				hasAlpha = true
+			}
+			argb = append(argb, uint32(p[3])<<24|uint32(p[0])<<16|uint32(p[1])<<8|uint32(p[2]))
+		}
+	}
+
+	bw := &bitWriter{}
+	bw.write(vp8lSignature, 8)
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if hasAlpha {
+		bw.write(1, 1)
+	} else {
+		bw.write(0, 1)
+	}
+	bw.write(0, 3) // バージョン
+
+	// 減算グリーン変換（赤と青から緑を引く）
+	bw.write(1, 1)
+	bw.write(transformSubtractGreen, 2)
+	for i, p := range argb {
+		g := p >> 8 & 0xff
+		r := (p>>16 - g) & 0xff
+		bl := (p - g) & 0xff
+		argb[i] = p&0xff00ff00 | r<<16 | bl
+	}
+
+	// 予測変換（すべてのブロックで同じ予測モードを使う）
+	bw.write(1, 1)
+	bw.write(transformPredictor, 2)
+	bw.write(predictorBits-2, 3)
+	tiles := make([]uint32, subSampleSize(width, predictorBits)*subSampleSize(height, predictorBits))
+	for i := range tiles {
+		tiles[i] = predictorMode << 8
+	}
+	writeEntropyImage(bw, tiles, subSampleSize(width, predictorBits), false)
+	residuals := predictResiduals(argb, width, height)
+
+	bw.write(0, 1) // 変換の終わり
+	writeEntropyImage(bw, residuals, width, true)
+	data := bw.bytes()
+
+	// RIFF コンテナ
+	chunkSize := len(data)
+	padding := chunkSize & 1
+	header := make([]byte, 20)
+	copy(header[0:4], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:8], uint32(4+8+chunkSize+padding))
+	copy(header[8:12], "WEBP")
+	copy(header[12:16], "VP8L")
+	binary.LittleEndian.PutUint32(header[16:20], uint32(chunkSize))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if padding == 1 {
+		if _, err := w.Write([]byte{0}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func subSampleSize(size, bits int) int {
+	return (size + 1<<bits - 1) >> bits
+}
+
+// 左と上の画素の平均との差を求める（先頭の行は左、先頭の列は上の画素との差）
+func predictResiduals(argb []uint32, width, height int) []uint32 {
+	residuals := make([]uint32, len(argb))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+			var pred uint32
+			switch {
+			case x == 0 && y == 0:
+				pred = 0xff000000
+			case y == 0:
+				pred = argb[i-1]
+			case x == 0:
+				pred = argb[i-width]
+			default:
+				pred = average2(argb[i-1], argb[i-width])
+			}
+			residuals[i] = subPixels(argb[i], pred)
+		}
+	}
+	return residuals
+}
+
+func average2(a, b uint32) uint32 {
+	var v uint32
+	for shift := 0; shift < 32; shift += 8 {
+		v |= ((a>>shift&0xff + b>>shift&0xff) / 2) << shift
+	}
+	return v
+}
+
+func subPixels(a, b uint32) uint32 {
+	var v uint32
+	for shift := 0; shift < 32; shift += 8 {
+		v |= ((a>>shift - b>>shift) & 0xff) << shift
+	}
+	return v
+}
+
+// 画素または後方参照
+type pixelToken struct {
+	argb     uint32
+	length   int // 後方参照の長さ（0 の場合は画素）
+	distance int // 後方参照の距離の符号
+}
+
+// 左または上の画素と同じ画素が続く部分を後方参照にする
+func backwardReferences(argb []uint32, width int) []pixelToken {
+	tokens := make([]pixelToken, 0, len(argb))
+	for i := 0; i < len(argb); {
+		left, top := 0, 0
+		if i >= 1 {
+			for left < maxCopyLength && i+left < len(argb) && argb[i+left] == argb[i+left-1] {
+				left++
+			}
+		}
+		if i >= width {
+			for top < maxCopyLength && i+top < len(argb) && argb[i+top] == argb[i+top-width] {
+				top++
+			}
+		}
+		switch {
+		case left >= minCopyLength && left >= top:
+			tokens = append(tokens, pixelToken{length: left, distance: distanceCodeLeft})
+			i += left
+		case top >= minCopyLength:
+			tokens = append(tokens, pixelToken{length: top, distance: distanceCodeTop})
+			i += top
+		default:
+			tokens = append(tokens, pixelToken{argb: argb[i]})
+			i++
+		}
+	}
+	return tokens
+}
+
+// 後方参照の長さと距離をプレフィックスの記号と追加ビットに分ける
+func prefixEncode(v int) (int, uint32, uint) {
+	if v <= 4 {
+		return v - 1, 0, 0
+	}
+	d := v - 1
+	highest := bits.Len(uint(d)) - 1
+	second := d >> (highest - 1) & 1
+	extraBits := uint(highest - 1)
+	return 2*highest + second, uint32(d) & (1<<extraBits - 1), extraBits
+}
+
+// 画素をプレフィックス符号で書き込む（カラーキャッシュは使わない）
+func writeEntropyImage(bw *bitWriter, argb []uint32, width int, topLevel bool) {
+	bw.write(0, 1) // カラーキャッシュなし
+	if topLevel {
+		bw.write(0, 1) // メタプレフィックス符号なし
+	}
+
+	tokens := backwardReferences(argb, width)
+	green := make([]int, 256+24)
+	red := make([]int, 256)
+	blue := make([]int, 256)
+	alpha := make([]int, 256)
+	distance := make([]int, 40)
+	for _, t := range tokens {
+		if t.length > 0 {
+			lengthSymbol, _, _ := prefixEncode(t.length)
+			distanceSymbol, _, _ := prefixEncode(t.distance)
+			green[256+lengthSymbol]++
+			distance[distanceSymbol]++
+			continue
+		}
+		p := t.argb
+		green[p>>8&0xff]++
+		red[p>>16&0xff]++
+		blue[p&0xff]++
+		alpha[p>>24]++
+	}
+
+	greenCode := writePrefixCode(bw, green)
+	redCode := writePrefixCode(bw, red)
+	blueCode := writePrefixCode(bw, blue)
+	alphaCode := writePrefixCode(bw, alpha)
+	distanceCode := writePrefixCode(bw, distance)
+
+	for _, t := range tokens {
+		if t.length > 0 {
+			symbol, extra, extraBits := prefixEncode(t.length)
+			greenCode.write(bw, 256+symbol)
+			bw.write(extra, extraBits)
+			symbol, extra, extraBits = prefixEncode(t.distance)
+			distanceCode.write(bw, symbol)
+			bw.write(extra, extraBits)
+			continue
+		}
+		p := t.argb
+		greenCode.write(bw, int(p>>8&0xff))
+		redCode.write(bw, int(p>>16&0xff))
+		blueCode.write(bw, int(p&0xff))
+		alphaCode.write(bw, int(p>>24))
+	}
+}
+
+// プレフィックス符号（符号はビットの順序を反転して保持する）
+type prefixCode struct {
+	lengths []uint8
+	codes   []uint32
+}
+
+func (c *prefixCode) write(bw *bitWriter, symbol int) {
+	bw.write(c.codes[symbol], uint(c.lengths[symbol]))
+}
+
+// 出現回数からプレフィックス符号を作り、その符号長を書き込む
+func writePrefixCode(bw *bitWriter, counts []int) *prefixCode {
+	var symbols []int
+	for s, c := range counts {
+		if c > 0 {
+			symbols = append(symbols, s)
+		}
+	}
+	if len(symbols) == 0 {
+		symbols = []int{0}
+	}
+
+	// 記号が2つ以下の場合は簡易な形式で書き込む
+	if len(symbols) <= 2 && symbols[len(symbols)-1] < 256 {
+		bw.write(1, 1)
+		bw.write(uint32(len(symbols)-1), 1)
+		if symbols[0] < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(symbols[0]), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(symbols[0]), 8)
+		}
+		lengths := make([]uint8, len(counts))
+		if len(symbols) == 2 {
+			bw.write(uint32(symbols[1]), 8)
+			lengths[symbols[0]], lengths[symbols[1]] = 1, 1
+		}
+		return newPrefixCode(lengths)
+	}
+
+	lengths := huffmanLengths(counts, maxCodeLength)
+	tokens := tokenizeLengths(lengths)
+	tokenCounts := make([]int, len(codeLengthCodeOrder))
+	for _, t := range tokens {
+		tokenCounts[t.code]++
+	}
+	tokenLengths := huffmanLengths(tokenCounts, maxCodeLengthCodeLength)
+
+	n := len(codeLengthCodeOrder)
+	for n > 4 && tokenLengths[codeLengthCodeOrder[n-1]] == 0 {
+		n--
+	}
+	bw.write(0, 1)
+	bw.write(uint32(n-4), 4)
+	for _, s := range codeLengthCodeOrder[:n] {
+		bw.write(uint32(tokenLengths[s]), 3)
+	}
+	bw.write(0, 1) // すべての記号の符号長を書き込む
+
+	tokenCode := newPrefixCode(tokenLengths)
+	for _, t := range tokens {
+		tokenCode.write(bw, t.code)
+		if t.extraBits > 0 {
+			bw.write(t.extra, t.extraBits)
+		}
+	}
+	return newPrefixCode(lengths)
+}
+
+// 符号長から正準ハフマン符号を作る（記号が1つだけの場合は0ビットで表す）
+func newPrefixCode(lengths []uint8) *prefixCode {
+	c := &prefixCode{lengths: make([]uint8, len(lengths)), codes: make([]uint32, len(lengths))}
+	used := 0
+	for _, l := range lengths {
+		if l > 0 {
+			used++
+		}
+	}
+	if used <= 1 {
+		return c
+	}
+	copy(c.lengths, lengths)
+
+	var lengthCounts [maxCodeLength + 1]uint32
+	for _, l := range lengths {
+		if l > 0 {
+			lengthCounts[l]++
+		}
+	}
+	var next [maxCodeLength + 1]uint32
+	code := uint32(0)
+	for l := 1; l <= maxCodeLength; l++ {
+		code = (code + lengthCounts[l-1]) << 1
+		next[l] = code
+	}
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		c.codes[s] = reverseBits(next[l], uint(l))
+		next[l]++
+	}
+	return c
+}
+
+func reverseBits(v uint32, n uint) uint32 {
+	var r uint32
+	for i := uint(0); i < n; i++ {
+		r = r<<1 | v&1
+		v >>= 1
+	}
+	return r
+}
+
+// 符号長の列の符号（0〜15 は符号長、17・18 は 0 の繰り返し）
+type lengthToken struct {
+	code      int
+	extra     uint32
+	extraBits uint
+}
+
+func tokenizeLengths(lengths []uint8) []lengthToken {
+	var tokens []lengthToken
+	for i := 0; i < len(lengths); {
+		if lengths[i] != 0 {
+			tokens = append(tokens, lengthToken{code: int(lengths[i])})
+			i++
+			continue
+		}
+		run := 0
+		for i+run < len(lengths) && lengths[i+run] == 0 {
+			run++
+		}
+		i += run
+		for run >= 3 {
+			switch {
+			case run >= 11:
+				k := min(run, 138)
+				tokens = append(tokens, lengthToken{code: 18, extra: uint32(k - 11), extraBits: 7})
+				run -= k
+			default:
+				k := min(run, 10)
+				tokens = append(tokens, lengthToken{code: 17, extra: uint32(k - 3), extraBits: 3})
+				run -= k
+			}
+		}
+		for ; run > 0; run-- {
+			tokens = append(tokens, lengthToken{code: 0})
+		}
+	}
+	return tokens
+}
+
+// 出現回数からハフマン符号の符号長を求める
+// 最大の長さを超える場合は、少ない出現回数を底上げして作り直す
+func huffmanLengths(counts []int, limit int) []uint8 {
+	lengths := make([]uint8, len(counts))
+	minCount := 1
+	for {
+		type node struct {
+			weight int
+			parent int
+		}
+		var nodes []node
+		var leaves []int
+		for s, c := range counts {
+			if c > 0 {
+				nodes = append(nodes, node{weight: max(c, minCount), parent: -1})
+				leaves = append(leaves, s)
+			}
+		}
+		if len(leaves) == 0 {
+			return lengths
+		}
+		if len(leaves) == 1 {
+			lengths[leaves[0]] = 1
+			return lengths
+		}
+
+		// 重みの小さい順に2つずつ結合する
+		order := make([]int, len(nodes))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool { return nodes[order[a]].weight < nodes[order[b]].weight })
+		var merged []int
+		pop := func() int {
+			if len(merged) == 0 || (len(order) > 0 && nodes[order[0]].weight <= nodes[merged[0]].weight) {
+				i := order[0]
+				order = order[1:]
+				return i
+			}
+			i := merged[0]
+			merged = merged[1:]
+			return i
+		}
+		for len(order)+len(merged) > 1 {
+			a, b := pop(), pop()
+			nodes = append(nodes, node{weight: nodes[a].weight + nodes[b].weight, parent: -1})
+			nodes[a].parent = len(nodes) - 1
+			nodes[b].parent = len(nodes) - 1
+			merged = append(merged, len(nodes)-1)
+		}
+
+		maxLength := 0
+		for i, s := range leaves {
+			depth := 0
+			for n := i; nodes[n].parent >= 0; n = nodes[n].parent {
+				depth++
+			}
+			lengths[s] = uint8(min(depth, 255))
+			maxLength = max(maxLength, depth)
+		}
+		if maxLength <= limit {
+			return lengths
+		}
+		minCount *= 2
+	}
+}
+
+// 下位ビットから順に書き込むビットライター
+type bitWriter struct {
+	buf   []byte
+	acc   uint64
+	nBits uint
+}
+
+func (b *bitWriter) write(v uint32, n uint) {
+	b.acc |= uint64(v) << b.nBits
+	b.nBits += n
+	for b.nBits >= 8 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc >>= 8
+		b.nBits -= 8
+	}
+}
+
+func (b *bitWriter) bytes() []byte {
+	if b.nBits > 0 {
+		b.buf = append(b.buf, byte(b.acc))
+		b.acc, b.nBits = 0, 0
+	}
+	return b.buf
+}
@@ -0,0 +1,124 @@
+package thumbnail
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"golang.org/x/image/webp"
+)
+
+func TestEncodeWebPRoundTrip(t *testing.T) {
+	fill := func(width, height int, pixel func(x, y int) color.NRGBA) *image.NRGBA {
+		img := image.NewNRGBA(image.Rect(0, 0, width, height))
+		for y := 0; y < height; y++ {
+			for x := 0; x < width; x++ {
+				img.SetNRGBA(x, y, pixel(x, y))
+			}
+		}
+		return img
+	}
+	random := rand.New(rand.NewSource(1))
+
+	tests := []struct {
+		name string
+		img  *image.NRGBA
+	}{
+		{"1x1", fill(1, 1, func(x, y int) color.NRGBA { return color.NRGBA{10, 20, 30, 255} })},
+		{"solid", fill(64, 36, func(x, y int) color.NRGBA { return color.NRGBA{200, 30, 90, 255} })},
+		{"gradient", fill(320, 180, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x), uint8(y), uint8(x + y), 255}
+		})},
+		{"alpha", fill(33, 17, func(x, y int) color.NRGBA {
+			return color.NRGBA{uint8(x * 7), uint8(y * 13), 128, uint8(x * y)}
+		})},
+		// 左や上の画素の繰り返しが多く、後方参照が続く画像
+		{"stripes", fill(640, 360, func(x, y int) color.NRGBA {
+			if (x/40+y/30)%2 == 0 {
+				return color.NRGBA{255, 255, 255, 255}
+			}
+			return color.NRGBA{0, 0, 0, 255}
+		})},
+		// 記号の種類が多く、符号長の上限を超えやすい画像
+		{"noise", fill(257, 129, func(x, y int) color.NRGBA {
+			v := random.Uint32()
+			return color.NRGBA{uint8(v), uint8(v >> 8), uint8(v >> 16), uint8(v >> 24)}
+		})},
+		{"tall", fill(1, 300, func(x, y int) color.NRGBA { return color.NRGBA{uint8(y), 0, 0, 255} })},
+		{"wide", fill(300, 1, func(x, y int) color.NRGBA { return color.NRGBA{0, uint8(x), 0, 255} })},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := encodeWebP(&buf, tt.img); err != nil {
+				t.Fatalf("encodeWebP() error = %v", err)
+			}
+			decoded, err := webp.Decode(bytes.NewReader(buf.Bytes()))
+			if err != nil {
+				t.Fatalf("webp.Decode() error = %v", err)
+			}
+			if decoded.Bounds() != tt.img.Bounds() {
+				t.Fatalf("bounds = %v, want %v", decoded.Bounds(), tt.img.Bounds())
+			}
+			// 可逆圧縮のため、すべての画素が一致する
+			b := tt.img.Bounds()
+			for y := b.Min.Y; y < b.Max.Y; y++ {
+				for x := b.Min.X; x < b.Max.X; x++ {
+					got := color.NRGBAModel.Convert(decoded.At(x, y)).(color.NRGBA)
+					if want := tt.img.NRGBAAt(x, y); got != want {
+						t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	src := image.NewNRGBA(image.Rect(0, 0, 1920, 1080))
+	for y := 0; y < 1080; y++ {
+		for x := 0; x < 1920; x++ {
+			src.SetNRGBA(x, y, color.NRGBA{uint8(x), uint8(y), 100, 255})
+		}
+	}
+	var data bytes.Buffer
+	if err := png.Encode(&data, src); err != nil {
+		t.Fatal(err)
+	}
+
+	images, err := Generate(data.Bytes())
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	want := map[string][2]int{
+		VariantSmall:  {320, 180},
+		VariantMedium: {640, 360},
+		VariantLarge:  {1280, 720},
+		VariantWebP:   {640, 360},
+	}
+	if len(images) != len(want) {
+		t.Fatalf("Generate() returned %d images, want %d", len(images), len(want))
+	}
+	for _, img := range images {
+		size := want[img.Variant]
+		if img.Width != size[0] || img.Height != size[1] {
+			t.Errorf("%s size = %dx%d, want %dx%d", img.Variant, img.Width, img.Height, size[0], size[1])
+		}
+		config, format, err := image.DecodeConfig(bytes.NewReader(img.Data))
+		if err != nil {
+			t.Errorf("%s cannot be decoded: %v", img.Variant, err)
+			continue
+		}
+		if config.Width != img.Width || config.Height != img.Height || "image/"+format != img.ContentType {
+			t.Errorf("%s decoded as %s %dx%d, want %s %dx%d", img.Variant, format, config.Width, config.Height, img.ContentType, img.Width, img.Height)
+		}
+	}
+
+	if _, err := Generate([]byte("<svg></svg>")); err != ErrUnsupported {
+		t.Errorf("Generate(svg) error = %v, want %v", err, ErrUnsupported)
+	}
+}
@@ -0,0 +1,152 @@
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"live/videoupload/probe"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// Fake は外部コマンドを使わない Transcoder（動作確認用）
+// 解析は probe パッケージで行い、変換と書き出しは入力をそのままコピーし、フレームは単色の画像を書き出す
+type Fake struct {
+	mu sync.Mutex
+	// 操作ごとに返すエラー（キーは probe / transcode / thumbnail / package / hls:<レンディションの名前>）
+	Errors map[string]error
+	// 呼び出された操作の履歴
+	Calls []string
+}
+
+func NewFake() *Fake {
+	return &Fake{Errors: map[string]error{}}
+}
+
+// SetError は操作が返すエラーを設定する（nil の場合は成功に戻す）
+func (f *Fake) SetError(operation string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err == nil {
+		delete(f.Errors, operation)
+		return
+	}
+	f.Errors[operation] = err
+}
+
+// 呼び出しを記録し、設定されたエラーを返す
+func (f *Fake) call(operation string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.Calls = append(f.Calls, operation)
+	return f.Errors[operation]
+}
+
+func (f *Fake) Probe(ctx context.Context, input string) (*probe.Info, error) {
+	if err := f.call("probe"); err != nil {
+		return nil, err
+	}
+	return f.probeFile(input)
+}
+
+func (f *Fake) probeFile(input string) (*probe.Info, error) {
+	file, err := os.Open(input)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	stat, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return probe.Probe(file, stat.Size())
+}
+
+func (f *Fake) Transcode(ctx context.Context, input, output string, preset Preset) error {
+	if err := f.call("transcode"); err != nil {
+		return err
+	}
+	return copyFile(input, output)
+}
+
+func (f *Fake) Thumbnail(ctx context.Context, input, output string, at float64) error {
+	if err := f.call("thumbnail"); err != nil {
+		return err
+	}
+	img := image.NewGray(image.Rect(0, 0, 640, 360))
+	for i := range img.Pix {
+		img.Pix[i] = 0x80
+	}
+
+	file, err := os.Create(output)
+	if err != nil {
+		return err
+	}
+	if err := jpeg.Encode(file, img, nil); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (f *Fake) Package(ctx context.Context, input, output string) error {
+	if err := f.call("package"); err != nil {
+		return err
+	}
+	return copyFile(input, output)
+}
+
+// SegmentHLS は再生時間に応じた数のセグメントと、ビットレートに応じたサイズのダミーのファイルを書き出す
+func (f *Fake) SegmentHLS(ctx context.Context, input, outputDir string, rendition Rendition) error {
+	if err := f.call("hls:" + rendition.Name); err != nil {
+		return err
+	}
+	info, err := f.probeFile(input)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outputDir, HLSInitName), []byte("init"), 0o644); err != nil {
+		return err
+	}
+
+	var playlist strings.Builder
+	fmt.Fprintf(&playlist, "#EXTM3U\n#EXT-X-VERSION:7\n#EXT-X-TARGETDURATION:%d\n#EXT-X-MEDIA-SEQUENCE:0\n#EXT-X-PLAYLIST-TYPE:VOD\n", hlsSegmentDuration)
+	fmt.Fprintf(&playlist, "#EXT-X-MAP:URI=\"%s\"\n", HLSInitName)
+	remaining := math.Max(info.Duration, 1)
+	for i := 0; remaining > 0; i++ {
+		duration := math.Min(remaining, hlsSegmentDuration)
+		remaining -= duration
+
+		name := fmt.Sprintf(hlsSegmentName, i)
+		size := int(duration * float64(rendition.VideoBitrate+rendition.AudioBitrate) * 1000 / 8)
+		if err := os.WriteFile(filepath.Join(outputDir, name), make([]byte, size), 0o644); err != nil {
+			return err
+		}
+		fmt.Fprintf(&playlist, "#EXTINF:%.6f,\n%s\n", duration, name)
+	}
+	playlist.WriteString("#EXT-X-ENDLIST\n")
+	return os.WriteFile(filepath.Join(outputDir, HLSPlaylistName), []byte(playlist.String()), 0o644)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,165 @@
+package transcoder
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"live/videoupload/probe"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// エラーに含める ffmpeg の標準エラー出力の最大の長さ
+const maxStderrLength = 2000
+
+// FFmpeg は ffmpeg / ffprobe コマンドを使う Transcoder
+type FFmpeg struct {
+	FFmpegPath  string
+	FFprobePath string
+}
+
+// NewFFmpeg は FFMPEG_PATH / FFPROBE_PATH 環境変数（省略時は PATH から検索）のコマンドを使う Transcoder を作成する
+func NewFFmpeg() (*FFmpeg, error) {
+	ffmpegPath, err := lookPath("FFMPEG_PATH", "ffmpeg")
+	if err != nil {
+		return nil, err
+	}
+	ffprobePath, err := lookPath("FFPROBE_PATH", "ffprobe")
+	if err != nil {
+		return nil, err
+	}
+	return &FFmpeg{FFmpegPath: ffmpegPath, FFprobePath: ffprobePath}, nil
+}
+
+func lookPath(env, name string) (string, error) {
+	if path := os.Getenv(env); path != "" {
+		name = path
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s が見つかりません（%s 環境変数で指定できます）: %w", name, env, err)
+	}
+	return path, nil
+}
+
+// ffprobe の JSON 出力のうち使用する項目
+type ffprobeOutput struct {
+	Format struct {
+		Duration string `json:"duration"`
+	} `json:"format"`
+	Streams []struct {
+		CodecType    string `json:"codec_type"`
+		CodecName    string `json:"codec_name"`
+		Width        uint   `json:"width"`
+		Height       uint   `json:"height"`
+		AvgFrameRate string `json:"avg_frame_rate"`
+	} `json:"streams"`
+}
+
+func (f *FFmpeg) Probe(ctx context.Context, input string) (*probe.Info, error) {
+	out, err := f.run(ctx, f.FFprobePath, "-v", "error", "-print_format", "json", "-show_format", "-show_streams", input)
+	if err != nil {
+		return nil, err
+	}
+	var result ffprobeOutput
+	if err := json.Unmarshal(out, &result); err != nil {
+		return nil, fmt.Errorf("ffprobe の出力の解析に失敗しました: %w", err)
+	}
+
+	info := &probe.Info{}
+	info.Duration, _ = strconv.ParseFloat(result.Format.Duration, 64)
+	for _, s := range result.Streams {
+		switch {
+		case s.CodecType == "video" && info.VideoCodec == "":
+			info.VideoCodec = s.CodecName
+			info.Width, info.Height = s.Width, s.Height
+			info.FrameRate = parseFrameRate(s.AvgFrameRate)
+		case s.CodecType == "audio" && info.AudioCodec == "":
+			info.AudioCodec = s.CodecName
+		}
+	}
+	if info.VideoCodec == "" {
+		return nil, fmt.Errorf("映像のストリームがありません")
+	}
+	return info, nil
+}
+
+// 30000/1001 形式のフレームレートを小数点以下3桁に丸める
+func parseFrameRate(rate string) float64 {
+	num, den, ok := strings.Cut(rate, "/")
+	if !ok {
+		return 0
+	}
+	n, err1 := strconv.ParseFloat(num, 64)
+	d, err2 := strconv.ParseFloat(den, 64)
+	if err1 != nil || err2 != nil || d == 0 {
+		return 0
+	}
+	return math.Round(n/d*1000) / 1000
+}
+
+func (f *FFmpeg) Transcode(ctx context.Context, input, output string, preset Preset) error {
+	// 高さを上限以下に縮小し、幅は縦横比を保った偶数にする
+	scale := fmt.Sprintf("scale=-2:'min(%d,ih)'", preset.MaxHeight)
+	_, err := f.run(ctx, f.FFmpegPath, "-nostdin", "-y", "-i", input,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-vf", scale, "-c:v", "libx264", "-preset", "veryfast", "-crf", strconv.Itoa(preset.CRF), "-pix_fmt", "yuv420p",
+		"-c:a", "aac", "-b:a", preset.AudioBitrate,
+		"-movflags", "+faststart", output)
+	return err
+}
+
+func (f *FFmpeg) Thumbnail(ctx context.Context, input, output string, at float64) error {
+	_, err := f.run(ctx, f.FFmpegPath, "-nostdin", "-y", "-ss", strconv.FormatFloat(at, 'f', 3, 64), "-i", input,
+		"-frames:v", "1", "-q:v", "2", output)
+	return err
+}
+
+func (f *FFmpeg) Package(ctx context.Context, input, output string) error {
+	_, err := f.run(ctx, f.FFmpegPath, "-nostdin", "-y", "-i", input,
+		"-map", "0:v:0", "-map", "0:a:0?", "-c", "copy", "-movflags", "+faststart", output)
+	return err
+}
+
+func (f *FFmpeg) SegmentHLS(ctx context.Context, input, outputDir string, rendition Rendition) error {
+	// セグメントの区切りを揃えるため、シーンの切り替わりではなく一定の間隔でキーフレームを入れる
+	keyframes := fmt.Sprintf("expr:gte(t,n_forced*%d)", hlsKeyframeInterval)
+	_, err := f.run(ctx, f.FFmpegPath, "-nostdin", "-y", "-i", input,
+		"-map", "0:v:0", "-map", "0:a:0?",
+		"-vf", fmt.Sprintf("scale=-2:%d", rendition.Height),
+		"-c:v", "libx264", "-preset", "veryfast", "-profile:v", "high", "-level:v", "4.1", "-pix_fmt", "yuv420p",
+		"-b:v", fmt.Sprintf("%dk", rendition.VideoBitrate),
+		"-maxrate", fmt.Sprintf("%dk", rendition.VideoBitrate*107/100),
+		"-bufsize", fmt.Sprintf("%dk", rendition.VideoBitrate*3/2),
+		"-force_key_frames", keyframes, "-sc_threshold", "0",
+		"-c:a", "aac", "-b:a", fmt.Sprintf("%dk", rendition.AudioBitrate), "-ac", "2",
+		"-f", "hls", "-hls_time", strconv.Itoa(hlsSegmentDuration), "-hls_playlist_type", "vod",
+		"-hls_segment_type", "fmp4", "-hls_fmp4_init_filename", HLSInitName,
+		"-hls_segment_filename", filepath.Join(outputDir, hlsSegmentName),
+		filepath.Join(outputDir, HLSPlaylistName))
+	return err
+}
+
+// コマンドを実行して標準出力を返す（失敗した場合は標準エラー出力の末尾をエラーに含める）
+func (f *FFmpeg) run(ctx context.Context, name string, args ...string) ([]byte, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		message := strings.TrimSpace(stderr.String())
+		if len(message) > maxStderrLength {
+			message = "…" + message[len(message)-maxStderrLength:]
+		}
+		return nil, fmt.Errorf("%s の実行に失敗しました: %w: %s", name, err, message)
+	}
+	return stdout.Bytes(), nil
+}
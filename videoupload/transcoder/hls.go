@@ -0,0 +1,123 @@
+package transcoder
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HLS の出力ディレクトリに書き出すファイルの名前
+const (
+	HLSPlaylistName = "index.m3u8"
+	HLSInitName     = "init.mp4"
+	hlsSegmentName  = "segment_%05d.m4s"
+)
+
+// HLS のセグメントの長さ（秒）とキーフレームの間隔（秒）
+const (
+	hlsSegmentDuration  = 6
+	hlsKeyframeInterval = 2
+)
+
+// HLS / DASH で配信する画質ごとの設定
+type Rendition struct {
+	Name         string // 720p など
+	Height       uint   // 映像の高さ
+	VideoBitrate uint   // 映像の目標のビットレート（kbps）
+	AudioBitrate uint   // 音声のビットレート（kbps）
+}
+
+// 配信用に作成する既定のレンディション（高画質の順）
+var DefaultRenditions = []Rendition{
+	{Name: "1080p", Height: 1080, VideoBitrate: 5000, AudioBitrate: 128},
+	{Name: "720p", Height: 720, VideoBitrate: 2800, AudioBitrate: 128},
+	{Name: "480p", Height: 480, VideoBitrate: 1400, AudioBitrate: 96},
+	{Name: "360p", Height: 360, VideoBitrate: 800, AudioBitrate: 96},
+}
+
+// SelectRenditions は元の映像の高さ以下のレンディションを返す（拡大はしない）
+// 元の映像がすべてのレンディションより小さい場合は、最も低い画質の設定で元の高さのレンディションを1つ返す
+func SelectRenditions(renditions []Rendition, height uint) []Rendition {
+	selected := []Rendition{}
+	for _, r := range renditions {
+		if height == 0 || r.Height <= height {
+			selected = append(selected, r)
+		}
+	}
+	if len(selected) > 0 || len(renditions) == 0 {
+		return selected
+	}
+
+	r := renditions[len(renditions)-1]
+	r.Height = height &^ 1 // H.264 の映像の高さは偶数にする
+	r.Name = fmt.Sprintf("%dp", r.Height)
+	return []Rendition{r}
+}
+
+// HLS のメディアプレイリストのセグメント
+type HLSSegment struct {
+	URI      string
+	Duration float64
+}
+
+// HLS のメディアプレイリストから読み取った情報
+type HLSPlaylist struct {
+	Map      string // 初期化セグメント（EXT-X-MAP の URI）
+	Segments []HLSSegment
+}
+
+// ParseHLSPlaylist はメディアプレイリストから初期化セグメントとセグメントの一覧を読み取る
+func ParseHLSPlaylist(data []byte) (*HLSPlaylist, error) {
+	playlist := &HLSPlaylist{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	duration := -1.0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "#EXT-X-MAP:"):
+			playlist.Map = hlsAttribute(strings.TrimPrefix(line, "#EXT-X-MAP:"), "URI")
+		case strings.HasPrefix(line, "#EXTINF:"):
+			value, _, _ := strings.Cut(strings.TrimPrefix(line, "#EXTINF:"), ",")
+			d, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return nil, fmt.Errorf("セグメントの長さを読み取れません: %s", line)
+			}
+			duration = d
+		case strings.HasPrefix(line, "#"):
+		default:
+			if duration < 0 {
+				return nil, fmt.Errorf("セグメントの長さがありません: %s", line)
+			}
+			playlist.Segments = append(playlist.Segments, HLSSegment{URI: line, Duration: duration})
+			duration = -1
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(playlist.Segments) == 0 {
+		return nil, fmt.Errorf("プレイリストにセグメントがありません")
+	}
+	return playlist, nil
+}
+
+// 属性リスト（KEY=VALUE,KEY="VALUE"）から値を取得する
+func hlsAttribute(attributes, name string) string {
+	for len(attributes) > 0 {
+		var key, value string
+		key, attributes, _ = strings.Cut(attributes, "=")
+		if strings.HasPrefix(attributes, `"`) {
+			value, attributes, _ = strings.Cut(attributes[1:], `"`)
+			attributes = strings.TrimPrefix(attributes, ",")
+		} else {
+			value, attributes, _ = strings.Cut(attributes, ",")
+		}
+		if strings.TrimSpace(key) == name {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,64 @@
+// Package transcoder は動画ファイルの解析・変換・フレームの書き出しを行う
+// 実際の変換は ffmpeg を使い、動作確認用に外部コマンドを使わない実装も用意する
+package transcoder
+
+import (
+	"context"
+	"fmt"
+	"live/videoupload/probe"
+	"os"
+)
+
+// 変換の設定
+type Preset struct {
+	Name         string // 720p など
+	MaxHeight    uint   // 映像の高さの上限（元の映像が小さい場合は拡大しない）
+	CRF          int    // H.264 の画質（小さいほど高画質）
+	AudioBitrate string // AAC のビットレート（128k など）
+}
+
+// 配信用に変換する際の既定の設定
+var DefaultPreset = Preset{Name: "1080p", MaxHeight: 1080, CRF: 23, AudioBitrate: "128k"}
+
+// Transcoder は動画ファイルの処理に使う操作
+// 入力と出力はローカルのファイルのパスで指定する
+type Transcoder interface {
+	// Probe は動画ファイルの再生時間・解像度・コーデックを取得する
+	Probe(ctx context.Context, input string) (*probe.Info, error)
+	// Transcode は動画を H.264 / AAC の MP4 に変換する
+	Transcode(ctx context.Context, input, output string, preset Preset) error
+	// Thumbnail は at 秒の位置のフレームを JPEG として書き出す
+	Thumbnail(ctx context.Context, input, output string, at float64) error
+	// Package は動画を再エンコードせずに配信用の MP4（moov ボックスを先頭に置いたもの）に書き出す
+	Package(ctx context.Context, input, output string) error
+	// SegmentHLS は動画をレンディションの画質に変換し、fMP4 の HLS（プレイリスト、初期化セグメント、セグメント）として
+	// outputDir に書き出す（同じセグメントを DASH でも配信する）
+	SegmentHLS(ctx context.Context, input, outputDir string, rendition Rendition) error
+}
+
+// New は VIDEO_TRANSCODER 環境変数に応じて Transcoder を作成する
+// ffmpeg（既定）または fake を指定できる
+func New() (Transcoder, error) {
+	switch name := os.Getenv("VIDEO_TRANSCODER"); name {
+	case "", "ffmpeg":
+		return NewFFmpeg()
+	case "fake":
+		return NewFake(), nil
+	default:
+		return nil, fmt.Errorf("VIDEO_TRANSCODER に無効な値が設定されています: %s", name)
+	}
+}
+
+// NeedsTranscode は元の動画を変換せずに配信できない場合に true を返す
+// H.264 / AAC（または音声なし）の MP4 で、高さが上限以下の場合は変換しない
+func NeedsTranscode(info *probe.Info, format string, preset Preset) bool {
+	switch format {
+	case "video/mp4", "video/quicktime":
+	default:
+		return true
+	}
+	if info.VideoCodec != "h264" || (info.AudioCodec != "" && info.AudioCodec != "aac") {
+		return true
+	}
+	return info.Height == 0 || info.Height > preset.MaxHeight
+}
@@ -0,0 +1,21 @@
+package videoupload
+
+import (
+	"live/videoupload/services"
+)
+
+// StartWorkers はバックグラウンド処理を開始する
+func StartWorkers() {
+	services.TusCleanup.Start()
+	services.DirectUploadCleanup.Start()
+	services.VideoFileCleanup.Start()
+	services.Processor.Start()
+}
+
+// StopWorkers はバックグラウンド処理を止める
+func StopWorkers() {
+	services.Processor.Stop()
+	services.TusCleanup.Stop()
+	services.DirectUploadCleanup.Stop()
+	services.VideoFileCleanup.Stop()
+}